@@ -1,10 +1,12 @@
 module github.com/apparentlymart/terraform-provider
 
-go 1.12
+go 1.21
 
 require (
 	github.com/apparentlymart/terraform-schema-go v0.0.0-20190818171348-d92f0176cd4b
 	github.com/golang/protobuf v1.3.2
+	github.com/hashicorp/hcl2 v0.0.0-20190809210004-72d32879a5c5
+	github.com/vmihailenco/msgpack v3.3.3+incompatible
 	github.com/zclconf/go-cty v1.1.0
 	go.rpcplugin.org/rpcplugin v0.1.0
 	google.golang.org/grpc v1.23.0
@@ -0,0 +1,65 @@
+package tfprovider
+
+import (
+	"context"
+	"testing"
+
+	"google.golang.org/grpc"
+
+	"github.com/apparentlymart/terraform-provider/internal/tfplugin6"
+	"github.com/apparentlymart/terraform-provider/tfprovider/internal/common"
+)
+
+// countingClient6 wraps a real client, counting the schema requests made
+// through it.
+type countingClient6 struct {
+	tfplugin6.ProviderClient
+	schemaCalls int
+}
+
+func (c *countingClient6) GetProviderSchema(ctx context.Context, req *tfplugin6.GetProviderSchema_Request, opts ...grpc.CallOption) (*tfplugin6.GetProviderSchema_Response, error) {
+	c.schemaCalls++
+	return c.ProviderClient.GetProviderSchema(ctx, req, opts...)
+}
+
+func TestWithClientFactory(t *testing.T) {
+	conn := dialFakeServer6(t, &fakeServer6{})
+	var gotVersion int
+	var client *countingClient6
+	provider := newTestProvider6Conn(t, conn, WithClientFactory(func(protoVersion int, conn *grpc.ClientConn) interface{} {
+		gotVersion = protoVersion
+		client = &countingClient6{ProviderClient: tfplugin6.NewProviderClient(conn)}
+		return client
+	}))
+
+	if gotVersion != 6 {
+		t.Errorf("factory called with protocol version %d; want 6", gotVersion)
+	}
+	if client.schemaCalls != 1 {
+		t.Errorf("schema requested %d times through the factory's client; want 1", client.schemaCalls)
+	}
+	if got := provider.GRPCConn(); got != conn {
+		t.Errorf("provider has wrong connection %p; want %p", got, conn)
+	}
+	if got, err := provider.ManagedResourceSchemaVersion("fake_thing"); err != nil || got != 2 {
+		t.Errorf("wrong schema version %d (error %v); want 2", got, err)
+	}
+}
+
+func TestWithClientFactoryWrongType(t *testing.T) {
+	ctx := context.Background()
+	conn := dialFakeServer6(t, &fakeServer6{})
+
+	var o common.Options
+	WithClientFactory(func(protoVersion int, conn *grpc.ClientConn) interface{} {
+		return "not a client"
+	})(&o)
+
+	_, err := pluginClients(&o)[6].ClientProxy(ctx, conn)
+	if err == nil {
+		t.Fatal("ClientProxy succeeded; want an error")
+	}
+	if got, want := err.Error(), "client factory returned string, which is not a protocol version 6 provider client"; got != want {
+		t.Errorf("wrong error\ngot:  %s\nwant: %s", got, want)
+	}
+}
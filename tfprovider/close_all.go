@@ -0,0 +1,50 @@
+package tfprovider
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// CloseAll closes all of the given providers concurrently, allowing each of
+// them up to the given timeout to close cleanly. Any provider whose Close
+// call has not returned by the timeout has its child process killed, so
+// that a single unresponsive provider cannot prevent the others from
+// shutting down or leave an orphaned process behind.
+//
+// The result has an element for each of the given providers, in the same
+// order, which is nil if that provider closed successfully.
+func CloseAll(providers []Provider, timeout time.Duration) []error {
+	errs := make([]error, len(providers))
+	var wg sync.WaitGroup
+	for i, provider := range providers {
+		wg.Add(1)
+		go func(i int, provider Provider) {
+			defer wg.Done()
+			errs[i] = closeWithTimeout(provider, timeout)
+		}(i, provider)
+	}
+	wg.Wait()
+	return errs
+}
+
+func closeWithTimeout(provider Provider, timeout time.Duration) error {
+	// The channel is buffered so that the goroutine calling Close can
+	// still exit if we give up waiting for it.
+	done := make(chan error, 1)
+	go func() {
+		done <- provider.Close()
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case err := <-done:
+		return err
+	case <-timer.C:
+		if err := provider.Kill(); err != nil {
+			return fmt.Errorf("provider did not close within %s, and failed to kill its process: %s", timeout, err)
+		}
+		return fmt.Errorf("provider did not close within %s, so its process was killed", timeout)
+	}
+}
@@ -0,0 +1,110 @@
+package tfprovider
+
+import (
+	"errors"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/apparentlymart/terraform-provider/tfprovider/internal/common"
+)
+
+// closeTestProvider is a Provider whose Close blocks until either it is
+// allowed to return or the provider is killed.
+type closeTestProvider struct {
+	Provider
+
+	closeErr error
+	hang     bool
+	killed   chan struct{}
+}
+
+func newCloseTestProvider(hang bool, closeErr error) *closeTestProvider {
+	return &closeTestProvider{
+		closeErr: closeErr,
+		hang:     hang,
+		killed:   make(chan struct{}),
+	}
+}
+
+func (p *closeTestProvider) Close() error {
+	if p.hang {
+		<-p.killed
+	}
+	return p.closeErr
+}
+
+func (p *closeTestProvider) Kill() error {
+	close(p.killed)
+	return nil
+}
+
+func TestCloseAll(t *testing.T) {
+	closeErr := errors.New("failed to close")
+	providers := []*closeTestProvider{
+		newCloseTestProvider(false, nil),
+		newCloseTestProvider(true, nil),
+		newCloseTestProvider(false, closeErr),
+	}
+
+	start := time.Now()
+	errs := CloseAll([]Provider{providers[0], providers[1], providers[2]}, 50*time.Millisecond)
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("CloseAll took %s", elapsed)
+	}
+
+	if len(errs) != 3 {
+		t.Fatalf("got %d errors; want 3", len(errs))
+	}
+	if errs[0] != nil {
+		t.Errorf("unexpected error for first provider: %s", errs[0])
+	}
+	if errs[1] == nil || !strings.Contains(errs[1].Error(), "its process was killed") {
+		t.Errorf("wrong error for hanging provider: %v", errs[1])
+	}
+	if errs[2] != closeErr {
+		t.Errorf("wrong error for failing provider: %v", errs[2])
+	}
+
+	select {
+	case <-providers[1].killed:
+	default:
+		t.Errorf("hanging provider was not killed")
+	}
+	for _, i := range []int{0, 2} {
+		select {
+		case <-providers[i].killed:
+			t.Errorf("provider %d was killed", i)
+		default:
+		}
+	}
+}
+
+func TestProviderKill(t *testing.T) {
+	cmd := exec.Command("sleep", "60")
+	if err := cmd.Start(); err != nil {
+		t.Skipf("cannot start a child process: %s", err)
+	}
+	defer cmd.Process.Kill()
+
+	provider := newTestProvider6(t, &fakeServer6{}, func(o *common.Options) {
+		o.Cmd = cmd
+	})
+
+	if err := provider.Kill(); err != nil {
+		t.Fatalf("failed to kill: %s", err)
+	}
+	waited := make(chan error, 1)
+	go func() {
+		waited <- cmd.Wait()
+	}()
+	select {
+	case err := <-waited:
+		if err == nil {
+			t.Errorf("process exited successfully; want it killed")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("process still running after Kill")
+	}
+}
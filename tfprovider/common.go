@@ -1,6 +1,9 @@
 package tfprovider
 
 import (
+	"github.com/apparentlymart/terraform-schema-go/tfschema"
+	"github.com/zclconf/go-cty/cty"
+
 	"github.com/apparentlymart/terraform-provider/tfprovider/internal/common"
 )
 
@@ -11,6 +14,17 @@ import (
 
 type Schema = common.Schema
 
+// SchemaBuilder constructs a Schema by hand, for use in tests with Offline
+// and the encoding and decoding functions. Use NewSchemaBuilder to create
+// one.
+type SchemaBuilder = common.SchemaBuilder
+
+// NewSchemaBuilder returns a builder for a schema with an empty provider
+// configuration block and no resource types.
+func NewSchemaBuilder() *SchemaBuilder {
+	return common.NewSchemaBuilder()
+}
+
 type ManagedResourceTypeSchema = common.Schema
 
 type DataResourceTypeSchema = common.Schema
@@ -19,6 +33,10 @@ type Diagnostics = common.Diagnostics
 
 type Diagnostic = common.Diagnostic
 
+// DiagnosticResource identifies the resource instance that a diagnostic
+// pertains to, when the WithResourceDiagnosticContext option is enabled.
+type DiagnosticResource = common.DiagnosticResource
+
 type DiagnosticSeverity = common.DiagnosticSeverity
 
 const (
@@ -26,10 +44,84 @@ const (
 	Warning DiagnosticSeverity = common.Warning
 )
 
+// Format represents one of the serialization formats that the provider
+// plugin protocol allows for dynamic values.
+type Format = common.Format
+
+const (
+	FormatAny     Format = common.FormatAny
+	FormatMsgpack Format = common.FormatMsgpack
+	FormatJSON    Format = common.FormatJSON
+)
+
+// InternTable is a table of attribute types and description strings that
+// can be shared between the schemas of many providers. Use NewInternTable to
+// create one and WithSchemaInterning to use it.
+type InternTable = common.InternTable
+
+// NewInternTable creates a new, empty intern table holding at most the given
+// number of entries, or an unbounded table if the limit is zero or less.
+func NewInternTable(limit int) *InternTable {
+	return common.NewInternTable(limit)
+}
+
+// UnknownResourceTypeError is the error returned when a caller refers to a
+// resource type that the provider's schema doesn't include.
+type UnknownResourceTypeError = common.UnknownResourceTypeError
+
+// SchemaVersionUnavailableError is the error returned when a caller requests
+// a version of a resource type schema other than the current one, which
+// the provider plugin protocol cannot retrieve.
+type SchemaVersionUnavailableError = common.SchemaVersionUnavailableError
+
+// ResourceMode distinguishes managed resource types from data resource types.
+type ResourceMode = common.ResourceMode
+
+const (
+	ManagedResourceMode ResourceMode = common.ManagedResourceMode
+	DataResourceMode    ResourceMode = common.DataResourceMode
+)
+
+// ConnectionSecurity describes the transport security of the connection to
+// a provider plugin.
+type ConnectionSecurity = common.ConnectionSecurity
+
+// RetryPolicy describes how an idempotent request should be retried when it
+// fails with a transient error. Use it with WithSchemaLoadRetry.
+type RetryPolicy = common.RetryPolicy
+
+// ValidationTarget identifies one of the configurations validated by a call
+// to Provider.ValidateAll. Its String method returns "provider",
+// "resource[TYPE]", or "data[TYPE]".
+type ValidationTarget = common.ValidationTarget
+
+// ValidationTargetKind identifies the kind of configuration that a
+// ValidationTarget refers to.
+type ValidationTargetKind = common.ValidationTargetKind
+
+const (
+	ProviderConfigTarget        ValidationTargetKind = common.ProviderConfigTarget
+	ManagedResourceConfigTarget ValidationTargetKind = common.ManagedResourceConfigTarget
+	DataResourceConfigTarget    ValidationTargetKind = common.DataResourceConfigTarget
+)
+
+// Hooks is implemented by callers that wish to be notified before and after
+// each plan and apply operation on a managed resource type. Use it with
+// WithHooks.
+type Hooks = common.Hooks
+
+// NoopHooks is an implementation of Hooks whose methods do nothing, which
+// can be embedded in implementations that need only some of the methods.
+type NoopHooks = common.NoopHooks
+
 // Config represents a provider configuration that has already been prepared
 // using Provider.PrepareConfig, ready to be passed to Configure.
 type Config = common.Config
 
+// AttrPrompt describes a single attribute of the provider configuration
+// schema for the purpose of prompting a user for its value.
+type AttrPrompt = common.AttrPrompt
+
 type ManagedResourceType = common.ManagedResourceType
 
 type DataResourceType = common.DataResourceType
@@ -37,3 +129,235 @@ type DataResourceType = common.DataResourceType
 type ManagedResourceReadRequest = common.ManagedResourceReadRequest
 
 type ManagedResourceReadResponse = common.ManagedResourceReadResponse
+
+type ManagedResourcePlanRequest = common.ManagedResourcePlanRequest
+
+type ManagedResourcePlanResponse = common.ManagedResourcePlanResponse
+
+type ManagedResourceApplyRequest = common.ManagedResourceApplyRequest
+
+type ManagedResourceApplyResponse = common.ManagedResourceApplyResponse
+
+type ManagedResourceImportRequest = common.ManagedResourceImportRequest
+
+type ManagedResourceImportResponse = common.ManagedResourceImportResponse
+
+type ImportedResource = common.ImportedResource
+
+type DataResourceReadRequest = common.DataResourceReadRequest
+
+type DataResourceReadResponse = common.DataResourceReadResponse
+
+// TypeInventory lists the names of a provider's resource types, as returned
+// by Provider.AllTypeNames.
+type TypeInventory = common.TypeInventory
+
+// CloseError is the error returned from Provider.Close if the provider
+// plugin process exited abnormally, giving its exit code and final stderr
+// output.
+type CloseError = common.CloseError
+
+// CheckImportedState checks that a state returned by a provider's import
+// has values for all of the computed attributes in the given schema.
+func CheckImportedState(state cty.Value, schema *tfschema.Block) Diagnostics {
+	return common.CheckImportedState(state, schema)
+}
+
+// MetricsCollector receives measurements of the RPC calls made to a
+// provider plugin, as configured with WithMetrics.
+type MetricsCollector = common.MetricsCollector
+
+// Operation names for use as keys in the map given to
+// WithOperationTimeouts.
+const (
+	OperationSchema    = common.OperationSchema
+	OperationValidate  = common.OperationValidate
+	OperationConfigure = common.OperationConfigure
+	OperationUpgrade   = common.OperationUpgrade
+	OperationRead      = common.OperationRead
+	OperationPlan      = common.OperationPlan
+	OperationApply     = common.OperationApply
+	OperationImport    = common.OperationImport
+)
+
+// DataReadInput is a single request to read a data resource of a particular
+// type, as passed to Provider.ReadDataSources.
+type DataReadInput = common.DataReadInput
+
+// DataReadResult is the response and diagnostics from reading a single data
+// resource, as returned from Provider.ReadDataSources.
+type DataReadResult = common.DataReadResult
+
+// ConfigsEqual returns true if the two given provider configuration values
+// are equivalent, such that reconfiguring a provider from one to the other
+// would have no effect. Configurations containing unknown values are never
+// considered equal.
+func ConfigsEqual(a, b cty.Value) bool {
+	return common.ConfigsEqual(a, b)
+}
+
+// EncodedSize returns the size in bytes of the given value when serialized
+// for sending to a provider, which callers can compare against the gRPC
+// message size limit before making a request.
+func EncodedSize(val cty.Value, schema *tfschema.Block) (int, Diagnostics) {
+	return common.EncodedSize(val, schema)
+}
+
+// DynamicValueData is a value serialized for sending to a provider, as
+// returned by EncodePartial. Only one of its fields is populated, according
+// to the serialization format used.
+type DynamicValueData = common.DynamicValueData
+
+// EncodePartial builds an object conforming to the given schema from a map
+// giving only some of its attributes and nested blocks, as for
+// ConfigFromMap, and serializes it for sending to a provider.
+func EncodePartial(partial map[string]cty.Value, schema *tfschema.Block) (DynamicValueData, Diagnostics) {
+	return common.EncodePartial(partial, schema)
+}
+
+// ObjectFromGo converts the given Go map, such as one produced by decoding
+// JSON into an interface{}, into an object value conforming to the given
+// schema, including any nested blocks. Values that cannot be converted are
+// reported as error diagnostics with their paths.
+func ObjectFromGo(data map[string]interface{}, schema *tfschema.Block) (cty.Value, Diagnostics) {
+	return common.ObjectFromGo(data, schema)
+}
+
+// ConfigFromMap builds a value conforming to the given schema from a map
+// giving only some of its attributes and nested blocks, with the remaining
+// attributes null and the remaining nested blocks absent. This is useful for
+// building a provider configuration that sets only a few arguments.
+func ConfigFromMap(data map[string]cty.Value, schema *tfschema.Block) (cty.Value, Diagnostics) {
+	return common.ConfigFromMap(data, schema)
+}
+
+// CheckRequired returns an error diagnostic for each required attribute that
+// is null in the given object value, including those inside nested blocks
+// that are present in the value.
+func CheckRequired(val cty.Value, schema *tfschema.Block) Diagnostics {
+	return common.CheckRequired(val, schema)
+}
+
+// CheckProposed returns an error diagnostic for each computed attribute in
+// the given proposed new state that doesn't follow Terraform's rules for
+// building a proposed new state from the prior state and configuration,
+// such as a computed attribute whose prior value was wrongly discarded.
+func CheckProposed(prior, config, proposed cty.Value, schema *tfschema.Block) Diagnostics {
+	return common.CheckProposed(prior, config, proposed, schema)
+}
+
+// MarshalApplyRequestJSON serializes the given apply request as JSON, for
+// example for audit logging, encoding its values against the given resource
+// type schema. Private data is encoded in base64.
+func MarshalApplyRequestJSON(req ManagedResourceApplyRequest, schema *tfschema.Block) ([]byte, error) {
+	return common.MarshalApplyRequestJSON(req, schema)
+}
+
+// UnmarshalApplyRequestJSON decodes an apply request serialized by
+// MarshalApplyRequestJSON using the same schema.
+func UnmarshalApplyRequestJSON(src []byte, schema *tfschema.Block) (ManagedResourceApplyRequest, error) {
+	return common.UnmarshalApplyRequestJSON(src, schema)
+}
+
+// MarshalApplyResponseJSON serializes the given apply response as JSON,
+// encoding its new state against the given resource type schema.
+func MarshalApplyResponseJSON(resp ManagedResourceApplyResponse, schema *tfschema.Block) ([]byte, error) {
+	return common.MarshalApplyResponseJSON(resp, schema)
+}
+
+// UnmarshalApplyResponseJSON decodes an apply response serialized by
+// MarshalApplyResponseJSON using the same schema.
+func UnmarshalApplyResponseJSON(src []byte, schema *tfschema.Block) (ManagedResourceApplyResponse, error) {
+	return common.UnmarshalApplyResponseJSON(src, schema)
+}
+
+// ConfigRule is a constraint on the combination of attributes set in a
+// resource configuration, registered using WithConfigRules.
+type ConfigRule = common.ConfigRule
+
+// ExactlyOneOf returns a ConfigRule requiring that exactly one of the given
+// top-level attributes be set.
+func ExactlyOneOf(attrs ...string) ConfigRule {
+	return common.ExactlyOneOf(attrs...)
+}
+
+// ConflictingAttributes returns a ConfigRule requiring that at most one of
+// the given top-level attributes be set.
+func ConflictingAttributes(attrs ...string) ConfigRule {
+	return common.ConflictingAttributes(attrs...)
+}
+
+// RequiredTogether returns a ConfigRule requiring that either all or none of
+// the given top-level attributes be set.
+func RequiredTogether(attrs ...string) ConfigRule {
+	return common.RequiredTogether(attrs...)
+}
+
+// ValueVisitor is the signature of a function called for each leaf value of
+// a value being decoded incrementally, such as with the NewStateVisitor
+// field of ManagedResourceReadRequest.
+type ValueVisitor = common.ValueVisitor
+
+// FormatPath returns a string representation of the given path using
+// Terraform's traversal syntax, such as `tags["Name"]` or `rule[0].port`.
+func FormatPath(path cty.Path) string {
+	return common.FormatPath(path)
+}
+
+// FormatValue returns a human-readable, multi-line rendering of the given
+// object value conforming to the given schema, for logs and debugging, with
+// sensitive attributes hidden and unknown values shown as
+// "(known after apply)".
+func FormatValue(val cty.Value, schema *tfschema.Block) string {
+	return common.FormatValue(val, schema)
+}
+
+// UnknownPaths returns the paths of all of the unknown values within the
+// given value. A managed resource's new state returned from Apply should
+// have no unknown values at all.
+func UnknownPaths(val cty.Value) []cty.Path {
+	return common.UnknownPaths(val)
+}
+
+// UnknownsAsNull replaces each unknown value within the given value with a
+// null value of the same type, returning a warning that lists their paths.
+func UnknownsAsNull(val cty.Value) (cty.Value, Diagnostics) {
+	return common.UnknownsAsNull(val)
+}
+
+// SplitReplace interprets the result of planning a change to a managed
+// resource, reporting whether the change requires the remote object to be
+// replaced, whether the existing object must be destroyed before its
+// replacement is created, and which paths caused the replacement.
+func SplitReplace(req ManagedResourcePlanRequest, resp ManagedResourcePlanResponse) (needsReplace, destroyFirst bool, paths []cty.Path) {
+	return common.SplitReplace(req, resp)
+}
+
+// AttrChange describes a difference between two values at a particular
+// path, as returned by Diff and Provider.DetectDrift.
+type AttrChange = common.AttrChange
+
+// Diff compares two values of the same type and returns each difference
+// between them, reporting changes within objects, maps, and equal-length
+// lists at their own paths and any other change at the path of the whole
+// value.
+func Diff(before, after cty.Value) []AttrChange {
+	return common.Diff(before, after)
+}
+
+// PlanDiff returns the changes between the prior state in a plan request
+// and the planned state in its response, as described by Diff, marking
+// those that force replacement of the remote object.
+func PlanDiff(req ManagedResourcePlanRequest, resp ManagedResourcePlanResponse) []AttrChange {
+	return common.PlanDiff(req, resp)
+}
+
+// ParseConfigHCL parses the given HCL native syntax source as the body of a
+// block conforming to the given schema, such as a provider configuration
+// block, and returns the resulting value.
+//
+// Unexpected arguments and blocks and type conversion errors are returned
+// as error diagnostics.
+func ParseConfigHCL(src []byte, schema *tfschema.Block) (cty.Value, Diagnostics) {
+	return common.ParseConfigHCL(src, schema)
+}
@@ -0,0 +1,98 @@
+package tfprovider
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/zclconf/go-cty/cty"
+
+	"github.com/apparentlymart/terraform-provider/internal/tfplugin6"
+)
+
+// concurrencyServer6 is a fakeServer6 whose data source reads take a little
+// while, and which records the most reads it had in progress at once.
+type concurrencyServer6 struct {
+	fakeServer6
+
+	mu       sync.Mutex
+	inFlight int
+	max      int
+}
+
+func (s *concurrencyServer6) ReadDataSource(ctx context.Context, req *tfplugin6.ReadDataSource_Request) (*tfplugin6.ReadDataSource_Response, error) {
+	s.mu.Lock()
+	s.inFlight++
+	if s.inFlight > s.max {
+		s.max = s.inFlight
+	}
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		s.inFlight--
+		s.mu.Unlock()
+	}()
+
+	time.Sleep(20 * time.Millisecond)
+	return s.fakeServer6.ReadDataSource(ctx, req)
+}
+
+func TestWithTypeConcurrencyLimit(t *testing.T) {
+	tests := map[string]struct {
+		limits map[string]int
+		check  func(t *testing.T, max int)
+	}{
+		"limited": {
+			map[string]int{"fake_data": 2},
+			func(t *testing.T, max int) {
+				if max != 2 {
+					t.Errorf("%d reads in progress at once; want 2", max)
+				}
+			},
+		},
+		"other type limited": {
+			map[string]int{"fake_thing": 1},
+			func(t *testing.T, max int) {
+				if max <= 2 {
+					t.Errorf("%d reads in progress at once; want more than 2", max)
+				}
+			},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			srv := &concurrencyServer6{}
+			provider := newTestProvider6(t, srv, WithTypeConcurrencyLimit(test.limits))
+			if diags := provider.Configure(ctx, Config{Value: cty.EmptyObjectVal}); diags.HasErrors() {
+				t.Fatalf("unexpected errors: %s", diags.Err())
+			}
+			rt, err := provider.DataResourceType("fake_data")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var wg sync.WaitGroup
+			for i := 0; i < 8; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					_, diags := rt.Read(ctx, DataResourceReadRequest{
+						Config: cty.ObjectVal(map[string]cty.Value{
+							"name":     cty.StringVal("Ada"),
+							"greeting": cty.NullVal(cty.String),
+						}),
+					})
+					if diags.HasErrors() {
+						t.Errorf("unexpected errors: %s", diags.Err())
+					}
+				}()
+			}
+			wg.Wait()
+
+			test.check(t, srv.max)
+		})
+	}
+}
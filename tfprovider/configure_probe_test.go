@@ -0,0 +1,47 @@
+package tfprovider
+
+import (
+	"context"
+	"testing"
+
+	"github.com/zclconf/go-cty/cty"
+)
+
+func TestWithConfigureProbe(t *testing.T) {
+	ctx := context.Background()
+	fail := true
+	provider := newTestProvider6(t, &fakeServer6{},
+		WithConfigureProbe(func(ctx context.Context, p Provider) Diagnostics {
+			if fail {
+				return Diagnostics{{Severity: Error, Summary: "Probe failed"}}
+			}
+			rt, err := p.DataResourceType("fake_data")
+			if err != nil {
+				return Diagnostics{{Severity: Error, Summary: "Probe failed", Detail: err.Error()}}
+			}
+			_, diags := rt.Read(ctx, DataResourceReadRequest{
+				Config: cty.ObjectVal(map[string]cty.Value{
+					"name":     cty.StringVal("probe"),
+					"greeting": cty.NullVal(cty.String),
+				}),
+			})
+			return diags
+		}),
+	)
+
+	diags := provider.Configure(ctx, Config{Value: cty.EmptyObjectVal})
+	if len(diags) != 2 || diags[1].Summary != "Probe failed" {
+		t.Fatalf("wrong diagnostics %#v", diags)
+	}
+	if _, err := provider.DataResourceType("fake_data"); err == nil {
+		t.Error("DataResourceType succeeded after a failed probe; want an error")
+	}
+
+	fail = false
+	if diags := provider.Configure(ctx, Config{Value: cty.EmptyObjectVal}); diags.HasErrors() {
+		t.Fatalf("unexpected errors: %s", diags.Err())
+	}
+	if _, err := provider.DataResourceType("fake_data"); err != nil {
+		t.Errorf("unexpected error after a successful probe: %s", err)
+	}
+}
@@ -0,0 +1,45 @@
+package tfprovider
+
+import (
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/connectivity"
+)
+
+func TestConnectionStateCallback(t *testing.T) {
+	conn := dialFakeServer6(t, &fakeServer6{})
+	states := make(chan connectivity.State, 16)
+	provider := newTestProvider6Conn(t, conn, WithConnectionStateCallback(func(state connectivity.State) {
+		states <- state
+	}))
+
+	// Loading the schema has already established the connection.
+	if got, want := provider.ConnectionState(), connectivity.Ready; got != want {
+		t.Errorf("wrong initial state %s; want %s", got, want)
+	}
+
+	conn.Close()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case state := <-states:
+			if state != connectivity.Shutdown {
+				continue
+			}
+		case <-timeout:
+			t.Fatal("callback was not notified of the connection shutting down")
+		}
+		break
+	}
+	if got, want := provider.ConnectionState(), connectivity.Shutdown; got != want {
+		t.Errorf("wrong final state %s; want %s", got, want)
+	}
+}
+
+func TestConnectionStateOffline(t *testing.T) {
+	p := Offline(NewSchemaBuilder().Build())
+	if got, want := p.ConnectionState(), connectivity.Idle; got != want {
+		t.Errorf("wrong state %s; want %s", got, want)
+	}
+}
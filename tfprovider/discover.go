@@ -0,0 +1,182 @@
+package tfprovider
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// PluginMeta describes a provider plugin executable found by Discover.
+type PluginMeta struct {
+	// Name is the provider's type name, such as "aws" for an executable
+	// named terraform-provider-aws.
+	Name string
+
+	// Version is the provider's version, as given either in the executable
+	// name or in the directory layout, or empty if it could not be
+	// determined.
+	Version string
+
+	// Hostname and Namespace are the other parts of the provider's source
+	// address, such as "registry.terraform.io" and "hashicorp", for a plugin
+	// found in the unpacked registry directory layout. They are empty for
+	// plugins found in the legacy layout.
+	Hostname  string
+	Namespace string
+
+	// Path is the path to the plugin executable.
+	Path string
+}
+
+const pluginExePrefix = "terraform-provider-"
+
+// Discover searches the given directory for provider plugin executables
+// for the current platform, following Terraform's plugin directory
+// conventions, and returns metadata about each one that it finds.
+//
+// Two layouts are supported, and may be mixed in the same directory. In the
+// legacy layout, executables named terraform-provider-NAME or
+// terraform-provider-NAME_vVERSION are placed either directly in the
+// directory or in a subdirectory named for the current platform, such as
+// linux_amd64. In the unpacked registry layout, executables are placed at
+// HOSTNAME/NAMESPACE/NAME/VERSION/OS_ARCH/ beneath the directory.
+//
+// The result is sorted by name and then by version. A directory that
+// doesn't exist is not an error, and contains no plugins.
+func Discover(dir string) ([]PluginMeta, error) {
+	platform := runtime.GOOS + "_" + runtime.GOARCH
+
+	ret, err := discoverLegacy(dir)
+	if err != nil {
+		return nil, err
+	}
+	more, err := discoverLegacy(filepath.Join(dir, platform))
+	if err != nil {
+		return nil, err
+	}
+	ret = append(ret, more...)
+
+	// The unpacked registry layout has the platform as the last directory
+	// level, so we can find it with a glob.
+	platformDirs, err := filepath.Glob(filepath.Join(dir, "*", "*", "*", "*", platform))
+	if err != nil {
+		return nil, err
+	}
+	for _, platformDir := range platformDirs {
+		rel, err := filepath.Rel(dir, platformDir)
+		if err != nil {
+			continue
+		}
+		parts := strings.Split(filepath.ToSlash(rel), "/")
+		hostname, namespace, name, version := parts[0], parts[1], parts[2], parts[3]
+		found, err := discoverLegacy(platformDir)
+		if err != nil {
+			return nil, err
+		}
+		for _, meta := range found {
+			if meta.Name != name {
+				continue
+			}
+			meta.Hostname = hostname
+			meta.Namespace = namespace
+			meta.Version = version
+			ret = append(ret, meta)
+		}
+	}
+
+	sort.Slice(ret, func(i, j int) bool {
+		if ret[i].Name != ret[j].Name {
+			return ret[i].Name < ret[j].Name
+		}
+		if ret[i].Version != ret[j].Version {
+			return versionLess(ret[i].Version, ret[j].Version)
+		}
+		return ret[i].Path < ret[j].Path
+	})
+	return ret, nil
+}
+
+// OpenDiscovered starts the provider plugin described by the given
+// metadata, as returned from Discover.
+//
+// The provider's address, as used by Provider.ProvidersSchemaJSON, is set
+// from the metadata unless the given options set it. For plugins found in
+// the legacy layout the address is just the provider's name, as in the
+// output of older versions of Terraform.
+func OpenDiscovered(ctx context.Context, meta PluginMeta, opts ...Option) (Provider, error) {
+	opts = append([]Option{WithProviderAddress(meta.Address())}, opts...)
+	return StartWithOptions(ctx, meta.Path, nil, opts...)
+}
+
+// Address returns the provider's source address, such as
+// "registry.terraform.io/hashicorp/aws", or just its name for a plugin
+// found in the legacy layout.
+func (m PluginMeta) Address() string {
+	if m.Hostname == "" || m.Namespace == "" {
+		return m.Name
+	}
+	return m.Hostname + "/" + m.Namespace + "/" + m.Name
+}
+
+// discoverLegacy finds the plugin executables directly inside the given
+// directory, interpreting their names using the legacy naming scheme.
+func discoverLegacy(dir string) ([]PluginMeta, error) {
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var ret []PluginMeta
+	for _, info := range infos {
+		if info.IsDir() || !strings.HasPrefix(info.Name(), pluginExePrefix) {
+			continue
+		}
+		name := strings.TrimPrefix(info.Name(), pluginExePrefix)
+		if runtime.GOOS == "windows" {
+			if !strings.HasSuffix(name, ".exe") {
+				continue
+			}
+			name = strings.TrimSuffix(name, ".exe")
+		}
+		var version string
+		if i := strings.Index(name, "_v"); i >= 0 {
+			name, version = name[:i], name[i+2:]
+		}
+		if name == "" {
+			continue
+		}
+		ret = append(ret, PluginMeta{
+			Name:    name,
+			Version: version,
+			Path:    filepath.Join(dir, info.Name()),
+		})
+	}
+	return ret, nil
+}
+
+// versionLess compares two dot-separated version strings, comparing parts
+// that are both integers numerically and any others lexically.
+func versionLess(a, b string) bool {
+	aParts := strings.Split(a, ".")
+	bParts := strings.Split(b, ".")
+	for i := 0; i < len(aParts) && i < len(bParts); i++ {
+		if aParts[i] == bParts[i] {
+			continue
+		}
+		aNum, aErr := strconv.Atoi(aParts[i])
+		bNum, bErr := strconv.Atoi(bParts[i])
+		if aErr == nil && bErr == nil {
+			return aNum < bNum
+		}
+		return aParts[i] < bParts[i]
+	}
+	return len(aParts) < len(bParts)
+}
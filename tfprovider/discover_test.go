@@ -0,0 +1,107 @@
+package tfprovider
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"testing"
+)
+
+func TestDiscover(t *testing.T) {
+	dir := t.TempDir()
+	platform := runtime.GOOS + "_" + runtime.GOARCH
+	exe := func(name string) string {
+		if runtime.GOOS == "windows" {
+			return name + ".exe"
+		}
+		return name
+	}
+	files := []string{
+		// Legacy layout, directly in the directory.
+		exe("terraform-provider-null"),
+		exe("terraform-provider-aws_v2.10.0"),
+		exe("terraform-provider-aws_v2.9.0"),
+		// Legacy layout, in the platform subdirectory.
+		filepath.Join(platform, exe("terraform-provider-random_v3.0.0")),
+		// Unpacked registry layout.
+		filepath.Join("registry.terraform.io", "hashicorp", "aws", "3.0.0", platform, exe("terraform-provider-aws_v3.0.0_x5")),
+		filepath.Join("example.com", "acme", "widget", "0.1.0", platform, exe("terraform-provider-widget")),
+		// Not plugins, or not for this platform.
+		"README.md",
+		exe("terraform-provider-"),
+		filepath.Join("other_platform", exe("terraform-provider-random_v3.0.0")),
+		filepath.Join("registry.terraform.io", "hashicorp", "aws", "3.0.0", "other_platform", exe("terraform-provider-aws_v3.0.0_x5")),
+		// In the registry layout, the executable must match the directory.
+		filepath.Join("registry.terraform.io", "hashicorp", "google", "1.0.0", platform, exe("terraform-provider-aws")),
+	}
+	for _, name := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, nil, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got, err := Discover(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []PluginMeta{
+		{
+			Name:    "aws",
+			Version: "2.9.0",
+			Path:    filepath.Join(dir, exe("terraform-provider-aws_v2.9.0")),
+		},
+		{
+			Name:    "aws",
+			Version: "2.10.0",
+			Path:    filepath.Join(dir, exe("terraform-provider-aws_v2.10.0")),
+		},
+		{
+			Name:      "aws",
+			Version:   "3.0.0",
+			Hostname:  "registry.terraform.io",
+			Namespace: "hashicorp",
+			Path:      filepath.Join(dir, files[4]),
+		},
+		{
+			Name: "null",
+			Path: filepath.Join(dir, exe("terraform-provider-null")),
+		},
+		{
+			Name:    "random",
+			Version: "3.0.0",
+			Path:    filepath.Join(dir, files[3]),
+		},
+		{
+			Name:      "widget",
+			Version:   "0.1.0",
+			Hostname:  "example.com",
+			Namespace: "acme",
+			Path:      filepath.Join(dir, files[5]),
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("wrong result\ngot:  %#v\nwant: %#v", got, want)
+	}
+
+	if got, want := want[2].Address(), "registry.terraform.io/hashicorp/aws"; got != want {
+		t.Errorf("wrong address %q; want %q", got, want)
+	}
+	if got, want := want[3].Address(), "null"; got != want {
+		t.Errorf("wrong address %q; want %q", got, want)
+	}
+}
+
+func TestDiscoverNonexistent(t *testing.T) {
+	got, err := Discover(filepath.Join(t.TempDir(), "nonexistent"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 0 {
+		t.Errorf("found %d plugins; want none", len(got))
+	}
+}
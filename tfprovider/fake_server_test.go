@@ -0,0 +1,164 @@
+package tfprovider
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/zclconf/go-cty/cty"
+	ctymsgpack "github.com/zclconf/go-cty/cty/msgpack"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/apparentlymart/terraform-provider/internal/tfplugin6"
+	"github.com/apparentlymart/terraform-provider/tfprovider/internal/protocol6"
+)
+
+// fakeDataType is the type of objects of the "fake_data" data resource
+// type served by fakeServer6.
+var fakeDataType = cty.Object(map[string]cty.Type{
+	"name":     cty.String,
+	"greeting": cty.String,
+})
+
+// fakeServer6 is a protocol version 6 provider server that has no
+// configuration, a single managed resource type, and a single data resource
+// type whose greeting attribute is computed from its name.
+type fakeServer6 struct {
+	tfplugin6.UnimplementedProviderServer
+}
+
+func (s *fakeServer6) GetProviderSchema(ctx context.Context, req *tfplugin6.GetProviderSchema_Request) (*tfplugin6.GetProviderSchema_Response, error) {
+	return &tfplugin6.GetProviderSchema_Response{
+		Provider: &tfplugin6.Schema{Block: &tfplugin6.Schema_Block{}},
+		ResourceSchemas: map[string]*tfplugin6.Schema{
+			"fake_thing": {
+				Version: 2,
+				Block: &tfplugin6.Schema_Block{
+					Attributes: []*tfplugin6.Schema_Attribute{
+						{Name: "id", Type: []byte(`"string"`), Computed: true},
+					},
+				},
+			},
+		},
+		DataSourceSchemas: map[string]*tfplugin6.Schema{
+			"fake_data": {
+				Block: &tfplugin6.Schema_Block{
+					Attributes: []*tfplugin6.Schema_Attribute{
+						{Name: "name", Type: []byte(`"string"`), Required: true},
+						{Name: "greeting", Type: []byte(`"string"`), Computed: true},
+					},
+				},
+			},
+		},
+	}, nil
+}
+
+func (s *fakeServer6) ConfigureProvider(ctx context.Context, req *tfplugin6.ConfigureProvider_Request) (*tfplugin6.ConfigureProvider_Response, error) {
+	return &tfplugin6.ConfigureProvider_Response{}, nil
+}
+
+func (s *fakeServer6) ReadDataSource(ctx context.Context, req *tfplugin6.ReadDataSource_Request) (*tfplugin6.ReadDataSource_Response, error) {
+	config, err := ctymsgpack.Unmarshal(req.Config.Msgpack, fakeDataType)
+	if err != nil {
+		return nil, err
+	}
+	state := cty.ObjectVal(map[string]cty.Value{
+		"name":     config.GetAttr("name"),
+		"greeting": cty.StringVal("Hello, " + config.GetAttr("name").AsString() + "!"),
+	})
+	raw, err := ctymsgpack.Marshal(state, fakeDataType)
+	if err != nil {
+		return nil, err
+	}
+	return &tfplugin6.ReadDataSource_Response{
+		State: &tfplugin6.DynamicValue{Msgpack: raw},
+	}, nil
+}
+
+// dialFakeServer6 serves the given server over an in-memory connection,
+// returning a client connection to it.
+func dialFakeServer6(t *testing.T, srv tfplugin6.ProviderServer) *grpc.ClientConn {
+	t.Helper()
+	return dialFakeServer6Creds(t, srv, nil, grpc.WithInsecure())
+}
+
+// dialFakeServer6Creds is like dialFakeServer6 but secures the connection
+// using the given server credentials, if any, and client dial option.
+func dialFakeServer6Creds(t *testing.T, srv tfplugin6.ProviderServer, creds credentials.TransportCredentials, security grpc.DialOption) *grpc.ClientConn {
+	t.Helper()
+
+	lis := bufconn.Listen(1024 * 1024)
+	var serverOpts []grpc.ServerOption
+	if creds != nil {
+		serverOpts = append(serverOpts, grpc.Creds(creds))
+	}
+	server := grpc.NewServer(serverOpts...)
+	tfplugin6.RegisterProviderServer(server, srv)
+	go server.Serve(lis)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.DialContext(
+		context.Background(), "bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, addr string) (net.Conn, error) {
+			return lis.Dial()
+		}),
+		security,
+	)
+	if err != nil {
+		t.Fatalf("failed to dial fake server: %s", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// newTestProvider6 serves the given server over an in-memory connection and
+// returns a provider for it, created with the given options in the same way
+// as StartWithOptions would. The provider is closed when the test ends.
+func newTestProvider6(t *testing.T, srv tfplugin6.ProviderServer, opts ...Option) Provider {
+	t.Helper()
+	return newTestProvider6Conn(t, dialFakeServer6(t, srv), opts...)
+}
+
+// newTestProvider6Conn is like newTestProvider6 but uses the given
+// connection to a server.
+func newTestProvider6Conn(t *testing.T, conn *grpc.ClientConn, opts ...Option) Provider {
+	t.Helper()
+	ctx := context.Background()
+
+	o := newOptions(opts)
+	clientProxy, err := pluginClients(o)[6].ClientProxy(ctx, conn)
+	if err != nil {
+		t.Fatalf("failed to create client: %s", err)
+	}
+	provider, err := protocol6.NewProvider(ctx, nil, clientProxy, o)
+	if err != nil {
+		t.Fatalf("failed to create provider: %s", err)
+	}
+	t.Cleanup(func() { provider.Close() })
+	return provider
+}
+
+// readFakeData configures the given provider, which must be serving the
+// schema of fakeServer6, and then reads a fake_data object with the given
+// name.
+func readFakeData(t *testing.T, p Provider, name string) (cty.Value, Diagnostics) {
+	t.Helper()
+	ctx := context.Background()
+
+	if diags := p.Configure(ctx, Config{Value: cty.EmptyObjectVal}); diags.HasErrors() {
+		return cty.NilVal, diags
+	}
+	rt, err := p.DataResourceType("fake_data")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, diags := rt.Read(ctx, DataResourceReadRequest{
+		Config: cty.ObjectVal(map[string]cty.Value{
+			"name":     cty.StringVal(name),
+			"greeting": cty.NullVal(cty.String),
+		}),
+	})
+	return resp.State, diags
+}
@@ -0,0 +1,34 @@
+package tfprovider
+
+import (
+	"context"
+	"testing"
+
+	"github.com/apparentlymart/terraform-provider/internal/tfplugin6"
+)
+
+func TestProviderGRPCConn(t *testing.T) {
+	ctx := context.Background()
+	provider := newTestProvider6(t, &fakeServer6{})
+
+	raw := provider.GRPCConn()
+	if raw == nil {
+		t.Fatal("no connection")
+	}
+	// A raw call made directly through the connection bypasses the
+	// library, getting the protocol's own response type.
+	resp, err := tfplugin6.NewProviderClient(raw).GetProviderSchema(ctx, &tfplugin6.GetProviderSchema_Request{})
+	if err != nil {
+		t.Fatalf("raw GetProviderSchema call failed: %s", err)
+	}
+	if _, ok := resp.DataSourceSchemas["fake_data"]; !ok {
+		t.Errorf("raw schema response is missing fake_data")
+	}
+
+	if err := provider.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if got := provider.GRPCConn(); got != nil {
+		t.Errorf("connection %p returned after Close; want nil", got)
+	}
+}
@@ -0,0 +1,269 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/zclconf/go-cty/cty"
+	ctyjson "github.com/zclconf/go-cty/cty/json"
+)
+
+// applyRequestJSON is the JSON representation of a
+// ManagedResourceApplyRequest. Private data is represented in base64, as is
+// usual for byte slices in encoding/json.
+type applyRequestJSON struct {
+	PriorState           *valueJSON `json:"prior_state,omitempty"`
+	PlannedState         *valueJSON `json:"planned_state,omitempty"`
+	Config               *valueJSON `json:"config,omitempty"`
+	ProviderMeta         *valueJSON `json:"provider_meta,omitempty"`
+	Private              []byte     `json:"private,omitempty"`
+	PrivateSchemaVersion *int64     `json:"private_schema_version,omitempty"`
+	InstanceKey          string     `json:"instance_key,omitempty"`
+}
+
+// applyResponseJSON is the JSON representation of a
+// ManagedResourceApplyResponse.
+type applyResponseJSON struct {
+	NewState             *valueJSON `json:"new_state,omitempty"`
+	Private              []byte     `json:"private,omitempty"`
+	PrivateSchemaVersion int64      `json:"private_schema_version"`
+}
+
+// valueJSON is the JSON representation of a cty value. JSON has no way to
+// represent unknown values, so they are encoded as null and their paths and
+// types listed separately.
+type valueJSON struct {
+	Value   json.RawMessage `json:"value"`
+	Unknown []unknownJSON   `json:"unknown,omitempty"`
+}
+
+type unknownJSON struct {
+	Path []pathStepJSON  `json:"path"`
+	Type json.RawMessage `json:"type"`
+}
+
+// pathStepJSON is the JSON representation of a single cty.PathStep, with
+// exactly one of its fields set.
+type pathStepJSON struct {
+	Attr *string         `json:"attr,omitempty"`
+	Key  json.RawMessage `json:"key,omitempty"`
+}
+
+// MarshalApplyRequestJSON serializes the given apply request as JSON, for
+// example to keep an audit log of the changes applied to each resource. The
+// values in the request are encoded using the type implied by the given
+// resource type schema, except for the provider_meta value, which is encoded
+// along with its own type.
+//
+// Unknown values are preserved, except that values containing unknown
+// values within sets cannot be serialized.
+func MarshalApplyRequestJSON(req ManagedResourceApplyRequest, schema ImpliedTyper) ([]byte, error) {
+	ty := schema.ImpliedType()
+	var raw applyRequestJSON
+	var err error
+	if raw.PriorState, err = marshalValueJSON(req.PriorState, ty); err != nil {
+		return nil, fmt.Errorf("invalid prior state: %s", err)
+	}
+	if raw.PlannedState, err = marshalValueJSON(req.PlannedState, ty); err != nil {
+		return nil, fmt.Errorf("invalid planned state: %s", err)
+	}
+	if raw.Config, err = marshalValueJSON(req.Config, ty); err != nil {
+		return nil, fmt.Errorf("invalid config: %s", err)
+	}
+	if raw.ProviderMeta, err = marshalValueJSON(req.ProviderMeta, cty.DynamicPseudoType); err != nil {
+		return nil, fmt.Errorf("invalid provider_meta: %s", err)
+	}
+	raw.Private = req.OpaquePrivate
+	raw.PrivateSchemaVersion = req.PrivateSchemaVersion
+	raw.InstanceKey = req.InstanceKey
+	return json.Marshal(raw)
+}
+
+// UnmarshalApplyRequestJSON is the opposite of MarshalApplyRequestJSON,
+// decoding an apply request that was serialized using the same schema.
+func UnmarshalApplyRequestJSON(src []byte, schema ImpliedTyper) (ManagedResourceApplyRequest, error) {
+	ty := schema.ImpliedType()
+	var raw applyRequestJSON
+	var req ManagedResourceApplyRequest
+	if err := json.Unmarshal(src, &raw); err != nil {
+		return req, err
+	}
+	var err error
+	if req.PriorState, err = unmarshalValueJSON(raw.PriorState, ty); err != nil {
+		return req, fmt.Errorf("invalid prior state: %s", err)
+	}
+	if req.PlannedState, err = unmarshalValueJSON(raw.PlannedState, ty); err != nil {
+		return req, fmt.Errorf("invalid planned state: %s", err)
+	}
+	if req.Config, err = unmarshalValueJSON(raw.Config, ty); err != nil {
+		return req, fmt.Errorf("invalid config: %s", err)
+	}
+	if req.ProviderMeta, err = unmarshalValueJSON(raw.ProviderMeta, cty.DynamicPseudoType); err != nil {
+		return req, fmt.Errorf("invalid provider_meta: %s", err)
+	}
+	req.OpaquePrivate = raw.Private
+	req.PrivateSchemaVersion = raw.PrivateSchemaVersion
+	req.InstanceKey = raw.InstanceKey
+	return req, nil
+}
+
+// MarshalApplyResponseJSON serializes the given apply response as JSON,
+// encoding the new state using the type implied by the given resource type
+// schema.
+func MarshalApplyResponseJSON(resp ManagedResourceApplyResponse, schema ImpliedTyper) ([]byte, error) {
+	var raw applyResponseJSON
+	var err error
+	if raw.NewState, err = marshalValueJSON(resp.NewState, schema.ImpliedType()); err != nil {
+		return nil, fmt.Errorf("invalid new state: %s", err)
+	}
+	raw.Private = resp.OpaquePrivate
+	raw.PrivateSchemaVersion = resp.PrivateSchemaVersion
+	return json.Marshal(raw)
+}
+
+// UnmarshalApplyResponseJSON is the opposite of MarshalApplyResponseJSON,
+// decoding an apply response that was serialized using the same schema.
+func UnmarshalApplyResponseJSON(src []byte, schema ImpliedTyper) (ManagedResourceApplyResponse, error) {
+	var raw applyResponseJSON
+	var resp ManagedResourceApplyResponse
+	if err := json.Unmarshal(src, &raw); err != nil {
+		return resp, err
+	}
+	var err error
+	if resp.NewState, err = unmarshalValueJSON(raw.NewState, schema.ImpliedType()); err != nil {
+		return resp, fmt.Errorf("invalid new state: %s", err)
+	}
+	resp.OpaquePrivate = raw.Private
+	resp.PrivateSchemaVersion = raw.PrivateSchemaVersion
+	return resp, nil
+}
+
+// marshalValueJSON encodes the given value as JSON using the given type,
+// returning nil if the value is cty.NilVal.
+func marshalValueJSON(val cty.Value, ty cty.Type) (*valueJSON, error) {
+	if val == cty.NilVal {
+		return nil, nil
+	}
+
+	var ret valueJSON
+	err := cty.Walk(val, func(path cty.Path, v cty.Value) (bool, error) {
+		if !v.IsKnown() {
+			rawTy, err := ctyjson.MarshalType(v.Type())
+			if err != nil {
+				return false, path.NewError(err)
+			}
+			rawPath, err := marshalPathJSON(path)
+			if err != nil {
+				return false, path.NewError(err)
+			}
+			ret.Unknown = append(ret.Unknown, unknownJSON{
+				Path: rawPath,
+				Type: rawTy,
+			})
+			return false, nil
+		}
+		if v.Type().IsSetType() && !v.IsWhollyKnown() {
+			// Set elements are identified by their values, so there is
+			// no way to identify an unknown value within one.
+			return false, path.NewErrorf("unknown values within sets cannot be serialized")
+		}
+		return true, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(ret.Unknown) != 0 {
+		val, err = cty.Transform(val, func(path cty.Path, v cty.Value) (cty.Value, error) {
+			if !v.IsKnown() {
+				return cty.NullVal(v.Type()), nil
+			}
+			return v, nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	ret.Value, err = ctyjson.Marshal(val, ty)
+	if err != nil {
+		return nil, err
+	}
+	return &ret, nil
+}
+
+// unmarshalValueJSON is the opposite of marshalValueJSON, returning
+// cty.NilVal if the given raw value is nil.
+func unmarshalValueJSON(raw *valueJSON, ty cty.Type) (cty.Value, error) {
+	if raw == nil {
+		return cty.NilVal, nil
+	}
+	val, err := ctyjson.Unmarshal(raw.Value, ty)
+	if err != nil {
+		return cty.NilVal, err
+	}
+	for _, rawUnknown := range raw.Unknown {
+		path, err := unmarshalPathJSON(rawUnknown.Path)
+		if err != nil {
+			return cty.NilVal, err
+		}
+		uty, err := ctyjson.UnmarshalType(rawUnknown.Type)
+		if err != nil {
+			return cty.NilVal, fmt.Errorf("invalid type for unknown value at %s: %s", FormatPath(path), err)
+		}
+		val, err = cty.Transform(val, func(p cty.Path, v cty.Value) (cty.Value, error) {
+			if p.Equals(path) {
+				return cty.UnknownVal(uty), nil
+			}
+			return v, nil
+		})
+		if err != nil {
+			return cty.NilVal, err
+		}
+	}
+	return val, nil
+}
+
+func marshalPathJSON(path cty.Path) ([]pathStepJSON, error) {
+	ret := make([]pathStepJSON, len(path))
+	for i, step := range path {
+		switch s := step.(type) {
+		case cty.GetAttrStep:
+			name := s.Name
+			ret[i].Attr = &name
+		case cty.IndexStep:
+			raw, err := ctyjson.Marshal(s.Key, s.Key.Type())
+			if err != nil {
+				return nil, err
+			}
+			ret[i].Key = raw
+		default:
+			return nil, fmt.Errorf("unsupported path step %#v", step)
+		}
+	}
+	return ret, nil
+}
+
+func unmarshalPathJSON(raws []pathStepJSON) (cty.Path, error) {
+	ret := make(cty.Path, 0, len(raws))
+	for _, raw := range raws {
+		switch {
+		case raw.Attr != nil:
+			ret = ret.GetAttr(*raw.Attr)
+		case len(raw.Key) != 0:
+			// Only lists, tuples, and maps can contain unknown values that
+			// we serialize, so keys are always either numbers or strings.
+			ty := cty.Number
+			if raw.Key[0] == '"' {
+				ty = cty.String
+			}
+			key, err := ctyjson.Unmarshal(raw.Key, ty)
+			if err != nil {
+				return nil, fmt.Errorf("invalid path step: %s", err)
+			}
+			ret = ret.Index(key)
+		default:
+			return nil, fmt.Errorf("invalid path step: neither attr nor key is set")
+		}
+	}
+	return ret, nil
+}
@@ -0,0 +1,165 @@
+package common
+
+import (
+	"bytes"
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/apparentlymart/terraform-schema-go/tfschema"
+	"github.com/zclconf/go-cty/cty"
+)
+
+var applyJSONTestSchema = &tfschema.Block{
+	Attributes: map[string]*tfschema.Attribute{
+		"id":    {Type: cty.String, Computed: true},
+		"name":  {Type: cty.String, Required: true},
+		"ports": {Type: cty.List(cty.Number), Optional: true},
+		"tags":  {Type: cty.Map(cty.String), Optional: true},
+		"zones": {Type: cty.Set(cty.String), Optional: true},
+		"extra": {Type: cty.DynamicPseudoType, Optional: true},
+	},
+}
+
+func TestApplyRequestJSONRoundTrip(t *testing.T) {
+	version := int64(3)
+	private := []byte{0x00, 0xff, 'p', 'r', 'i', 'v'}
+	config := cty.ObjectVal(map[string]cty.Value{
+		"id":    cty.NullVal(cty.String),
+		"name":  cty.StringVal("example"),
+		"ports": cty.ListVal([]cty.Value{cty.NumberIntVal(80), cty.UnknownVal(cty.Number)}),
+		"tags": cty.MapVal(map[string]cty.Value{
+			"Name":  cty.StringVal("example"),
+			"Owner": cty.UnknownVal(cty.String),
+		}),
+		"zones": cty.SetVal([]cty.Value{cty.StringVal("a"), cty.StringVal("b")}),
+		"extra": cty.ObjectVal(map[string]cty.Value{
+			"nested": cty.TupleVal([]cty.Value{cty.True, cty.UnknownVal(cty.String)}),
+		}),
+	})
+	planned := cty.ObjectVal(map[string]cty.Value{
+		"id":    cty.UnknownVal(cty.String),
+		"name":  config.GetAttr("name"),
+		"ports": config.GetAttr("ports"),
+		"tags":  config.GetAttr("tags"),
+		"zones": cty.UnknownVal(cty.Set(cty.String)),
+		"extra": cty.DynamicVal,
+	})
+	req := ManagedResourceApplyRequest{
+		PriorState:   cty.NullVal(applyJSONTestSchema.ImpliedType()),
+		PlannedState: planned,
+		Config:       config,
+		ProviderMeta: cty.ObjectVal(map[string]cty.Value{
+			"module_name": cty.StringVal("network"),
+		}),
+		OpaquePrivate:        private,
+		PrivateSchemaVersion: &version,
+		InstanceKey:          "test_thing.example",
+	}
+
+	src, err := MarshalApplyRequestJSON(req, applyJSONTestSchema)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if want := base64.StdEncoding.EncodeToString(private); !strings.Contains(string(src), `"private":"`+want+`"`) {
+		t.Errorf("private data is not encoded as base64 in %s", src)
+	}
+
+	got, err := UnmarshalApplyRequestJSON(src, applyJSONTestSchema)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	for name, pair := range map[string][2]cty.Value{
+		"prior state":   {got.PriorState, req.PriorState},
+		"planned state": {got.PlannedState, req.PlannedState},
+		"config":        {got.Config, req.Config},
+		"provider meta": {got.ProviderMeta, req.ProviderMeta},
+	} {
+		if !pair[0].RawEquals(pair[1]) {
+			t.Errorf("wrong %s\ngot:  %#v\nwant: %#v", name, pair[0], pair[1])
+		}
+	}
+	if !bytes.Equal(got.OpaquePrivate, private) {
+		t.Errorf("wrong private data %q; want %q", got.OpaquePrivate, private)
+	}
+	if got.PrivateSchemaVersion == nil || *got.PrivateSchemaVersion != version {
+		t.Errorf("wrong private schema version %v; want %d", got.PrivateSchemaVersion, version)
+	}
+	if got.InstanceKey != req.InstanceKey {
+		t.Errorf("wrong instance key %q; want %q", got.InstanceKey, req.InstanceKey)
+	}
+}
+
+func TestApplyRequestJSONOmitted(t *testing.T) {
+	// A request with no values set survives the round trip without
+	// gaining any.
+	src, err := MarshalApplyRequestJSON(ManagedResourceApplyRequest{}, applyJSONTestSchema)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	got, err := UnmarshalApplyRequestJSON(src, applyJSONTestSchema)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if got.PriorState != cty.NilVal || got.ProviderMeta != cty.NilVal || got.OpaquePrivate != nil || got.PrivateSchemaVersion != nil {
+		t.Errorf("wrong result %#v; want an empty request", got)
+	}
+}
+
+func TestApplyRequestJSONUnknownInSet(t *testing.T) {
+	val := cty.ObjectVal(map[string]cty.Value{
+		"id":    cty.StringVal("a"),
+		"name":  cty.StringVal("example"),
+		"ports": cty.NullVal(cty.List(cty.Number)),
+		"tags":  cty.NullVal(cty.Map(cty.String)),
+		"zones": cty.SetVal([]cty.Value{cty.StringVal("a"), cty.UnknownVal(cty.String)}),
+		"extra": cty.NullVal(cty.DynamicPseudoType),
+	})
+	_, err := MarshalApplyRequestJSON(ManagedResourceApplyRequest{PlannedState: val}, applyJSONTestSchema)
+	if err == nil {
+		t.Fatal("serialization succeeded; want an error")
+	}
+	if !strings.Contains(err.Error(), "planned state") {
+		t.Errorf("error does not say which value is invalid: %s", err)
+	}
+}
+
+func TestApplyResponseJSONRoundTrip(t *testing.T) {
+	resp := ManagedResourceApplyResponse{
+		NewState: cty.ObjectVal(map[string]cty.Value{
+			"id":    cty.StringVal("thing-1"),
+			"name":  cty.StringVal("example"),
+			"ports": cty.ListValEmpty(cty.Number),
+			"tags":  cty.NullVal(cty.Map(cty.String)),
+			"zones": cty.SetVal([]cty.Value{cty.StringVal("a")}),
+			"extra": cty.StringVal("dynamic"),
+		}),
+		OpaquePrivate:        []byte(`{"schema_version":"1"}`),
+		PrivateSchemaVersion: 1,
+	}
+
+	src, err := MarshalApplyResponseJSON(resp, applyJSONTestSchema)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	got, err := UnmarshalApplyResponseJSON(src, applyJSONTestSchema)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !got.NewState.RawEquals(resp.NewState) {
+		t.Errorf("wrong new state\ngot:  %#v\nwant: %#v", got.NewState, resp.NewState)
+	}
+	if !bytes.Equal(got.OpaquePrivate, resp.OpaquePrivate) || got.PrivateSchemaVersion != resp.PrivateSchemaVersion {
+		t.Errorf("wrong private data %q version %d; want %q version %d", got.OpaquePrivate, got.PrivateSchemaVersion, resp.OpaquePrivate, resp.PrivateSchemaVersion)
+	}
+
+	// The new state must match the schema it is decoded with.
+	other := &tfschema.Block{
+		Attributes: map[string]*tfschema.Attribute{
+			"id": {Type: cty.Number, Computed: true},
+		},
+	}
+	if _, err := UnmarshalApplyResponseJSON(src, other); err == nil {
+		t.Error("decoding with a different schema succeeded; want an error")
+	}
+}
@@ -0,0 +1,118 @@
+package common
+
+import (
+	"fmt"
+
+	"github.com/apparentlymart/terraform-schema-go/tfschema"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// CheckProposed checks that the computed attributes of the given proposed
+// new state follow the rules Terraform uses to build a proposed new state
+// from the prior state and the configuration, returning an error diagnostic
+// for each attribute that doesn't. This catches mistakes in callers that
+// build their own proposed states.
+//
+// A computed attribute that the configuration doesn't set, which includes
+// every attribute that is computed but not optional, must keep its value
+// from the prior state, which is null if there is no prior object. An
+// optional computed attribute that the configuration does set must have
+// the configured value.
+//
+// Nested blocks are checked recursively, matching elements of list and map
+// blocks between the three values by index or key. Elements of set blocks
+// cannot be matched with their prior elements, and so are not checked.
+// Attributes whose expected or proposed values are not wholly known are not
+// checked either.
+func CheckProposed(prior, config, proposed cty.Value, schema *tfschema.Block) Diagnostics {
+	return checkProposedBlock(nil, prior, config, proposed, schema)
+}
+
+func checkProposedBlock(path cty.Path, prior, config, proposed cty.Value, schema *tfschema.Block) Diagnostics {
+	if config.IsNull() || !config.IsKnown() || proposed.IsNull() || !proposed.IsKnown() || !prior.IsKnown() {
+		return nil
+	}
+
+	var diags Diagnostics
+	for _, name := range sortedAttributeNames(schema) {
+		attrS := schema.Attributes[name]
+		if !attrS.Computed {
+			continue
+		}
+		configVal := config.GetAttr(name)
+		proposedVal := proposed.GetAttr(name)
+		want, from := getAttrOrNull(prior, name), "its prior value"
+		if attrS.Optional && !configVal.IsNull() {
+			want, from = configVal, "the configured value"
+		}
+		if !want.IsWhollyKnown() || !proposedVal.IsWhollyKnown() || want.RawEquals(proposedVal) {
+			continue
+		}
+		attrPath := append(path.Copy(), cty.GetAttrStep{Name: name})
+		detail := fmt.Sprintf("The proposed new state for the computed attribute %s does not match %s.", FormatPath(attrPath), from)
+		if proposedVal.IsNull() {
+			detail = fmt.Sprintf("The proposed new state for the computed attribute %s is null, but it should have %s.", FormatPath(attrPath), from)
+		}
+		diags = append(diags, Diagnostic{
+			Severity:  Error,
+			Summary:   "Invalid proposed new state",
+			Detail:    detail,
+			Attribute: attrPath,
+		})
+	}
+
+	for _, name := range sortedBlockTypeNames(schema) {
+		blockS := schema.BlockTypes[name]
+		blockPath := append(path.Copy(), cty.GetAttrStep{Name: name})
+		priorVal := getAttrOrNull(prior, name)
+		configVal := config.GetAttr(name)
+		proposedVal := proposed.GetAttr(name)
+
+		switch blockS.Nesting {
+		case tfschema.NestingSingle, tfschema.NestingGroup:
+			diags = append(diags, checkProposedBlock(blockPath, priorVal, configVal, proposedVal, &blockS.Block)...)
+		case tfschema.NestingList, tfschema.NestingMap:
+			// Blocks containing dynamically-typed attributes are tuples or
+			// objects rather than collections, so we don't check those.
+			if !isCollection(configVal) || configVal.IsNull() || !configVal.IsKnown() {
+				continue
+			}
+			for it := configVal.ElementIterator(); it.Next(); {
+				key, configElem := it.Element()
+				if !collectionHasIndex(proposedVal, key) {
+					continue
+				}
+				priorElem := cty.NullVal(configElem.Type())
+				if collectionHasIndex(priorVal, key) {
+					priorElem = priorVal.Index(key)
+				}
+				elemPath := append(blockPath.Copy(), cty.IndexStep{Key: key})
+				diags = append(diags, checkProposedBlock(elemPath, priorElem, configElem, proposedVal.Index(key), &blockS.Block)...)
+			}
+		}
+	}
+	return diags
+}
+
+// getAttrOrNull returns the named attribute of the given object, or a null
+// value of the attribute's type if the object itself is null.
+func getAttrOrNull(obj cty.Value, name string) cty.Value {
+	if obj.IsNull() {
+		return cty.NullVal(obj.Type().AttributeType(name))
+	}
+	return obj.GetAttr(name)
+}
+
+func isCollection(val cty.Value) bool {
+	ty := val.Type()
+	return ty.IsListType() || ty.IsMapType()
+}
+
+// collectionHasIndex returns true if the given value is a known, non-null
+// list or map that has an element with the given key.
+func collectionHasIndex(coll, key cty.Value) bool {
+	if !isCollection(coll) || coll.IsNull() || !coll.IsKnown() {
+		return false
+	}
+	return coll.HasIndex(key).True()
+}
@@ -0,0 +1,138 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/apparentlymart/terraform-schema-go/tfschema"
+	"github.com/zclconf/go-cty/cty"
+)
+
+func TestCheckProposed(t *testing.T) {
+	schema := &tfschema.Block{
+		Attributes: map[string]*tfschema.Attribute{
+			"id":   {Type: cty.String, Computed: true},
+			"name": {Type: cty.String, Optional: true},
+			"size": {Type: cty.Number, Optional: true, Computed: true},
+		},
+		BlockTypes: map[string]*tfschema.NestedBlock{
+			"rule": {
+				Nesting: tfschema.NestingList,
+				Block: tfschema.Block{
+					Attributes: map[string]*tfschema.Attribute{
+						"port":    {Type: cty.Number, Required: true},
+						"rule_id": {Type: cty.String, Computed: true},
+					},
+				},
+			},
+		},
+	}
+	ruleTy := schema.BlockTypes["rule"].Block.ImpliedType()
+	rule := func(port int64, ruleID cty.Value) cty.Value {
+		return cty.ObjectVal(map[string]cty.Value{
+			"port":    cty.NumberIntVal(port),
+			"rule_id": ruleID,
+		})
+	}
+	obj := func(id, size cty.Value, rules ...cty.Value) cty.Value {
+		rulesVal := cty.ListValEmpty(ruleTy)
+		if len(rules) != 0 {
+			rulesVal = cty.ListVal(rules)
+		}
+		return cty.ObjectVal(map[string]cty.Value{
+			"id":   id,
+			"name": cty.StringVal("example"),
+			"size": size,
+			"rule": rulesVal,
+		})
+	}
+	null := cty.NullVal(cty.String)
+	nullNum := cty.NullVal(cty.Number)
+	id := cty.StringVal("i-123")
+	ruleID := cty.StringVal("r-1")
+
+	prior := obj(id, cty.NumberIntVal(2), rule(80, ruleID))
+	config := obj(null, nullNum, rule(80, null))
+
+	tests := map[string]struct {
+		prior, config, proposed cty.Value
+		wantPaths               []string
+	}{
+		"prior values kept": {
+			prior,
+			config,
+			obj(id, cty.NumberIntVal(2), rule(80, ruleID)),
+			nil,
+		},
+		"computed attribute wrongly cleared": {
+			prior,
+			config,
+			obj(null, cty.NumberIntVal(2), rule(80, ruleID)),
+			[]string{"id"},
+		},
+		"optional computed attribute wrongly cleared": {
+			prior,
+			config,
+			obj(id, nullNum, rule(80, ruleID)),
+			[]string{"size"},
+		},
+		"computed attribute changed": {
+			prior,
+			config,
+			obj(cty.StringVal("i-456"), cty.NumberIntVal(2), rule(80, ruleID)),
+			[]string{"id"},
+		},
+		"nested computed attribute wrongly cleared": {
+			prior,
+			config,
+			obj(id, cty.NumberIntVal(2), rule(80, null)),
+			[]string{"rule[0].rule_id"},
+		},
+		"configured value overrides prior": {
+			prior,
+			obj(null, cty.NumberIntVal(3), rule(80, null)),
+			obj(id, cty.NumberIntVal(3), rule(80, ruleID)),
+			nil,
+		},
+		"configured value not used": {
+			prior,
+			obj(null, cty.NumberIntVal(3), rule(80, null)),
+			obj(id, cty.NumberIntVal(2), rule(80, ruleID)),
+			[]string{"size"},
+		},
+		"create": {
+			cty.NullVal(schema.ImpliedType()),
+			config,
+			obj(cty.UnknownVal(cty.String), cty.UnknownVal(cty.Number), rule(80, cty.UnknownVal(cty.String))),
+			nil,
+		},
+		"new nested block": {
+			prior,
+			obj(null, nullNum, rule(80, null), rule(443, null)),
+			obj(id, cty.NumberIntVal(2), rule(80, ruleID), rule(443, null)),
+			nil,
+		},
+		"destroy": {
+			prior,
+			cty.NullVal(schema.ImpliedType()),
+			cty.NullVal(schema.ImpliedType()),
+			nil,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			diags := CheckProposed(test.prior, test.config, test.proposed, schema)
+			if len(diags) != len(test.wantPaths) {
+				t.Fatalf("got %d diagnostics; want %d: %#v", len(diags), len(test.wantPaths), diags)
+			}
+			for i, diag := range diags {
+				if diag.Severity != Error || diag.Summary != "Invalid proposed new state" {
+					t.Errorf("wrong diagnostic %#v", diag)
+				}
+				if got, want := FormatPath(diag.Attribute), test.wantPaths[i]; got != want {
+					t.Errorf("wrong path %s; want %s", got, want)
+				}
+			}
+		})
+	}
+}
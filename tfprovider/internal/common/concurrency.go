@@ -0,0 +1,37 @@
+package common
+
+import (
+	"context"
+
+	"github.com/golang/protobuf/proto"
+)
+
+// TypeConcurrencyInterceptor returns an RPCInterceptor that limits the
+// number of RPC calls in progress at once for each resource type named in
+// the given map to the corresponding number. Calls for other resource types,
+// and calls that don't relate to a particular resource type, are not
+// limited, and nor are types whose limit is zero or less.
+//
+// A call that must wait for others to complete fails with the context's
+// error if the context is cancelled while it waits.
+func TypeConcurrencyInterceptor(limits map[string]int) RPCInterceptor {
+	sems := make(map[string]chan struct{}, len(limits))
+	for typeName, limit := range limits {
+		if limit > 0 {
+			sems[typeName] = make(chan struct{}, limit)
+		}
+	}
+	return func(ctx context.Context, method string, req, resp proto.Message, invoke RPCInvoker) error {
+		sem, ok := sems[RequestTypeName(req)]
+		if !ok {
+			return invoke(ctx, req, resp)
+		}
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		defer func() { <-sem }()
+		return invoke(ctx, req, resp)
+	}
+}
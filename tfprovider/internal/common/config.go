@@ -2,10 +2,91 @@ package common
 
 import (
 	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/convert"
 )
 
 // Config represents a provider configuration that has already been prepared
 // using Provider.PrepareConfig, ready to be passed to Configure.
 type Config struct {
+	// Value is the prepared configuration value, which is the value given
+	// to PrepareConfig unless the provider modified it.
 	Value cty.Value
+
+	// Modified is true if the provider altered the configuration while
+	// preparing it, such as by inserting default values, in which case
+	// Value differs from the value that was given to PrepareConfig.
+	//
+	// Providers using protocol version 6 have no separate preparation step,
+	// so their configurations are never modified.
+	Modified bool
+}
+
+// ConfigsEqual returns true if the two given provider configuration values
+// are equivalent, and so configuring a provider with one after having
+// configured it with the other would have no effect.
+//
+// Nulls are equal only to other nulls of the same type, and sets are
+// compared by their members regardless of the order in which those members
+// were originally given. If b's type differs from a's then it is first
+// converted to a's type, so that a tuple can equal a list with the same
+// elements, for example.
+//
+// A configuration containing unknown values is never equal to any other
+// configuration, because its final value cannot be determined.
+func ConfigsEqual(a, b cty.Value) bool {
+	if a == cty.NilVal || b == cty.NilVal {
+		return a == b
+	}
+	if !a.IsWhollyKnown() || !b.IsWhollyKnown() {
+		return false
+	}
+	if !a.Type().Equals(b.Type()) {
+		var err error
+		b, err = convert.Convert(b, a.Type())
+		if err != nil {
+			return false
+		}
+	}
+	return a.RawEquals(b)
+}
+
+// ConfigModified returns true if the prepared configuration returned by a
+// provider differs from the configuration it was given.
+//
+// Unlike ConfigsEqual, which must treat any unknown value as possibly
+// differing, this compares the values exactly, so that a provider that
+// returns a partially-unknown configuration with its unknown values in the
+// same places is not considered to have modified it. The prepared value is
+// first converted to the type of the given one, in case the provider
+// returned an equivalent value of a different type.
+func ConfigModified(given, prepared cty.Value) bool {
+	if given == cty.NilVal || prepared == cty.NilVal {
+		return given != prepared
+	}
+	if !prepared.Type().Equals(given.Type()) {
+		var err error
+		prepared, err = convert.Convert(prepared, given.Type())
+		if err != nil {
+			return true
+		}
+	}
+	return !given.RawEquals(prepared)
+}
+
+// ConfigureProbeDiagnostics returns the diagnostics to report when the
+// readiness probe from ConfigureProbe fails after the provider reported that
+// it was configured successfully, given the diagnostics from the probe. It
+// returns the probe's diagnostics unchanged if the probe succeeded.
+func ConfigureProbeDiagnostics(probeDiags Diagnostics) Diagnostics {
+	if !probeDiags.HasErrors() {
+		return probeDiags
+	}
+	diags := Diagnostics{
+		{
+			Severity: Error,
+			Summary:  "Provider not ready after configuration",
+			Detail:   "The provider reported that it was configured successfully, but then failed the readiness check made after configuration, so it has not been treated as configured.",
+		},
+	}
+	return append(diags, probeDiags...)
 }
@@ -0,0 +1,162 @@
+package common
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/zclconf/go-cty/cty"
+)
+
+// ConfigRule is implemented by constraints on the combination of attributes
+// set in a resource configuration, which the provider's schema has no way
+// to express.
+//
+// Rules are registered for a particular resource type using the
+// WithConfigRules option, and then checked by ValidateConfig before the
+// configuration is sent to the provider.
+type ConfigRule interface {
+	// CheckConfig returns error diagnostics if the given configuration,
+	// which is known and not null, violates the rule.
+	CheckConfig(config cty.Value) Diagnostics
+}
+
+// ExactlyOneOf returns a ConfigRule requiring that exactly one of the given
+// top-level attributes be set.
+func ExactlyOneOf(attrs ...string) ConfigRule {
+	return attrCountRule{attrs: attrs, min: 1, max: 1}
+}
+
+// ConflictingAttributes returns a ConfigRule requiring that at most one of
+// the given top-level attributes be set.
+func ConflictingAttributes(attrs ...string) ConfigRule {
+	return attrCountRule{attrs: attrs, min: 0, max: 1}
+}
+
+// RequiredTogether returns a ConfigRule requiring that either all or none of
+// the given top-level attributes be set.
+func RequiredTogether(attrs ...string) ConfigRule {
+	return requiredTogetherRule{attrs: attrs}
+}
+
+// attrCountRule requires that the number of the given attributes that are
+// set be between min and max inclusive.
+type attrCountRule struct {
+	attrs    []string
+	min, max int
+}
+
+func (r attrCountRule) CheckConfig(config cty.Value) Diagnostics {
+	set, maybe := countSetAttrs(config, r.attrs)
+	switch {
+	case len(set) > r.max:
+		var diags Diagnostics
+		for _, name := range set {
+			diags = append(diags, Diagnostic{
+				Severity:  Error,
+				Summary:   "Conflicting configuration arguments",
+				Detail:    fmt.Sprintf("Only one of %s may be set, but %s are all set.", formatAttrNames(r.attrs), formatAttrNames(set)),
+				Attribute: cty.GetAttrPath(name),
+			})
+		}
+		return diags
+	case len(set)+len(maybe) < r.min:
+		return Diagnostics{
+			{
+				Severity: Error,
+				Summary:  "Missing required argument",
+				Detail:   fmt.Sprintf("Exactly one of %s must be set.", formatAttrNames(r.attrs)),
+			},
+		}
+	default:
+		return nil
+	}
+}
+
+type requiredTogetherRule struct {
+	attrs []string
+}
+
+func (r requiredTogetherRule) CheckConfig(config cty.Value) Diagnostics {
+	set, _ := countSetAttrs(config, r.attrs)
+	if len(set) == 0 {
+		return nil
+	}
+	var diags Diagnostics
+	for _, name := range r.attrs {
+		if !config.Type().HasAttribute(name) || !config.GetAttr(name).IsNull() {
+			continue
+		}
+		diags = append(diags, Diagnostic{
+			Severity:  Error,
+			Summary:   "Missing required argument",
+			Detail:    fmt.Sprintf("The argument %q is required when %s is set, because %s must be set together.", name, formatAttrNames(set), formatAttrNames(r.attrs)),
+			Attribute: cty.GetAttrPath(name),
+		})
+	}
+	return diags
+}
+
+// countSetAttrs returns the names of those of the given attributes that are
+// set to known values, and the names of those that are unknown and so may
+// or may not turn out to be set. Names of attributes that the configuration
+// doesn't have are ignored.
+func countSetAttrs(config cty.Value, attrs []string) (set, maybe []string) {
+	ty := config.Type()
+	if !ty.IsObjectType() {
+		return nil, nil
+	}
+	for _, name := range attrs {
+		if !ty.HasAttribute(name) {
+			continue
+		}
+		v := config.GetAttr(name)
+		switch {
+		case !v.IsKnown():
+			maybe = append(maybe, name)
+		case !v.IsNull():
+			set = append(set, name)
+		}
+	}
+	return set, maybe
+}
+
+func formatAttrNames(names []string) string {
+	quoted := make([]string, len(names))
+	for i, name := range names {
+		quoted[i] = fmt.Sprintf("%q", name)
+	}
+	return strings.Join(quoted, ", ")
+}
+
+// configRuleTarget identifies the resource type that rules registered with
+// Options.AddConfigRules apply to.
+type configRuleTarget struct {
+	mode     ResourceMode
+	typeName string
+}
+
+// AddConfigRules registers the given rules to be checked by CheckConfigRules
+// for the resource type of the given mode and name.
+func (o *Options) AddConfigRules(mode ResourceMode, typeName string, rules ...ConfigRule) {
+	if o.configRules == nil {
+		o.configRules = make(map[configRuleTarget][]ConfigRule)
+	}
+	target := configRuleTarget{mode, typeName}
+	o.configRules[target] = append(o.configRules[target], rules...)
+}
+
+// CheckConfigRules checks the given configuration against each of the rules
+// registered for the resource type of the given mode and name, returning
+// the diagnostics from all of them. A configuration that is null or unknown
+// is not checked.
+func (o *Options) CheckConfigRules(mode ResourceMode, typeName string, config cty.Value) Diagnostics {
+	rules := o.configRules[configRuleTarget{mode, typeName}]
+	if len(rules) == 0 || config.IsNull() || !config.IsKnown() {
+		return nil
+	}
+	var diags Diagnostics
+	for _, rule := range rules {
+		diags = append(diags, rule.CheckConfig(config)...)
+	}
+	return diags
+}
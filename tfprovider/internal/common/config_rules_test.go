@@ -0,0 +1,133 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/zclconf/go-cty/cty"
+)
+
+func TestConfigRules(t *testing.T) {
+	config := func(a, b, c cty.Value) cty.Value {
+		return cty.ObjectVal(map[string]cty.Value{
+			"a": a,
+			"b": b,
+			"c": c,
+		})
+	}
+	set := cty.StringVal("set")
+	null := cty.NullVal(cty.String)
+	unknown := cty.UnknownVal(cty.String)
+
+	tests := map[string]struct {
+		rule   ConfigRule
+		config cty.Value
+		// wantPaths are the paths of the expected diagnostics, with the
+		// empty string for a diagnostic with no path.
+		wantPaths []string
+	}{
+		"exactly one, one set": {
+			ExactlyOneOf("a", "b"),
+			config(set, null, set),
+			nil,
+		},
+		"exactly one, both set": {
+			ExactlyOneOf("a", "b"),
+			config(set, set, null),
+			[]string{"a", "b"},
+		},
+		"exactly one, none set": {
+			ExactlyOneOf("a", "b"),
+			config(null, null, set),
+			[]string{""},
+		},
+		"exactly one, one unknown": {
+			ExactlyOneOf("a", "b"),
+			config(null, unknown, null),
+			nil,
+		},
+		"conflicting, none set": {
+			ConflictingAttributes("a", "b", "c"),
+			config(null, null, null),
+			nil,
+		},
+		"conflicting, two set": {
+			ConflictingAttributes("a", "b", "c"),
+			config(null, set, set),
+			[]string{"b", "c"},
+		},
+		"conflicting, one set and one unknown": {
+			ConflictingAttributes("a", "b"),
+			config(set, unknown, null),
+			nil,
+		},
+		"together, all set": {
+			RequiredTogether("a", "b"),
+			config(set, set, null),
+			nil,
+		},
+		"together, none set": {
+			RequiredTogether("a", "b"),
+			config(null, null, set),
+			nil,
+		},
+		"together, some set": {
+			RequiredTogether("a", "b", "c"),
+			config(set, null, null),
+			[]string{"b", "c"},
+		},
+		"together, some unknown": {
+			RequiredTogether("a", "b"),
+			config(set, unknown, null),
+			nil,
+		},
+		"undeclared attribute": {
+			ExactlyOneOf("a", "nonexistent"),
+			config(set, null, null),
+			nil,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			diags := test.rule.CheckConfig(test.config)
+			if len(diags) != len(test.wantPaths) {
+				t.Fatalf("got %d diagnostics; want %d: %#v", len(diags), len(test.wantPaths), diags)
+			}
+			for i, diag := range diags {
+				if diag.Severity != Error {
+					t.Errorf("diagnostic %d is not an error: %#v", i, diag)
+				}
+				if got, want := FormatPath(diag.Attribute), test.wantPaths[i]; got != want {
+					t.Errorf("wrong path for diagnostic %d %q; want %q", i, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestOptionsCheckConfigRules(t *testing.T) {
+	var o Options
+	o.AddConfigRules(ManagedResourceMode, "test_thing", ExactlyOneOf("a", "b"))
+	o.AddConfigRules(ManagedResourceMode, "test_thing", RequiredTogether("a", "c"))
+	config := cty.ObjectVal(map[string]cty.Value{
+		"a": cty.StringVal("set"),
+		"b": cty.StringVal("set"),
+		"c": cty.NullVal(cty.String),
+	})
+
+	// Both rules registered for the type are checked.
+	if diags := o.CheckConfigRules(ManagedResourceMode, "test_thing", config); len(diags) != 3 {
+		t.Errorf("got %d diagnostics; want 3: %#v", len(diags), diags)
+	}
+	// Rules apply only to the resource type they were registered for.
+	if diags := o.CheckConfigRules(DataResourceMode, "test_thing", config); len(diags) != 0 {
+		t.Errorf("unexpected diagnostics for data resource type: %#v", diags)
+	}
+	if diags := o.CheckConfigRules(ManagedResourceMode, "test_other", config); len(diags) != 0 {
+		t.Errorf("unexpected diagnostics for other resource type: %#v", diags)
+	}
+	// Configurations that are not yet known are not checked.
+	if diags := o.CheckConfigRules(ManagedResourceMode, "test_thing", cty.UnknownVal(config.Type())); len(diags) != 0 {
+		t.Errorf("unexpected diagnostics for unknown config: %#v", diags)
+	}
+}
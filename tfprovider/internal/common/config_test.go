@@ -0,0 +1,185 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/zclconf/go-cty/cty"
+)
+
+func TestConfigsEqual(t *testing.T) {
+	ty := cty.Object(map[string]cty.Type{
+		"region": cty.String,
+		"zones":  cty.Set(cty.String),
+	})
+	config := func(region string, zones ...string) cty.Value {
+		zoneVals := make([]cty.Value, len(zones))
+		for i, zone := range zones {
+			zoneVals[i] = cty.StringVal(zone)
+		}
+		return cty.ObjectVal(map[string]cty.Value{
+			"region": cty.StringVal(region),
+			"zones":  cty.SetVal(zoneVals),
+		})
+	}
+
+	tests := map[string]struct {
+		a, b cty.Value
+		want bool
+	}{
+		"identical": {
+			config("us-west-2", "a", "b"),
+			config("us-west-2", "a", "b"),
+			true,
+		},
+		"set in a different order": {
+			config("us-west-2", "a", "b", "c"),
+			config("us-west-2", "c", "a", "b"),
+			true,
+		},
+		"different set members": {
+			config("us-west-2", "a", "b"),
+			config("us-west-2", "a", "c"),
+			false,
+		},
+		"different attribute": {
+			config("us-west-2", "a"),
+			config("us-east-1", "a"),
+			false,
+		},
+		"both null": {
+			cty.NullVal(ty),
+			cty.NullVal(ty),
+			true,
+		},
+		"one null": {
+			cty.NullVal(ty),
+			config("us-west-2", "a"),
+			false,
+		},
+		"null attribute": {
+			cty.ObjectVal(map[string]cty.Value{
+				"region": cty.NullVal(cty.String),
+				"zones":  cty.NullVal(cty.Set(cty.String)),
+			}),
+			cty.ObjectVal(map[string]cty.Value{
+				"region": cty.NullVal(cty.String),
+				"zones":  cty.NullVal(cty.Set(cty.String)),
+			}),
+			true,
+		},
+		"same unknown": {
+			cty.UnknownVal(ty),
+			cty.UnknownVal(ty),
+			false,
+		},
+		"nested unknown": {
+			cty.ObjectVal(map[string]cty.Value{
+				"region": cty.UnknownVal(cty.String),
+				"zones":  cty.SetValEmpty(cty.String),
+			}),
+			cty.ObjectVal(map[string]cty.Value{
+				"region": cty.UnknownVal(cty.String),
+				"zones":  cty.SetValEmpty(cty.String),
+			}),
+			false,
+		},
+		"convertible type": {
+			cty.ListVal([]cty.Value{cty.StringVal("a"), cty.StringVal("b")}),
+			cty.TupleVal([]cty.Value{cty.StringVal("a"), cty.StringVal("b")}),
+			true,
+		},
+		"inconvertible type": {
+			cty.ListVal([]cty.Value{cty.StringVal("a")}),
+			cty.StringVal("a"),
+			false,
+		},
+		"both nil": {
+			cty.NilVal,
+			cty.NilVal,
+			true,
+		},
+		"one nil": {
+			cty.NilVal,
+			cty.NullVal(ty),
+			false,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := ConfigsEqual(test.a, test.b); got != test.want {
+				t.Errorf("wrong result %t; want %t", got, test.want)
+			}
+		})
+	}
+}
+
+func TestConfigModified(t *testing.T) {
+	ty := cty.Object(map[string]cty.Type{
+		"region": cty.String,
+		"count":  cty.Number,
+	})
+	config := func(region, count cty.Value) cty.Value {
+		return cty.ObjectVal(map[string]cty.Value{
+			"region": region,
+			"count":  count,
+		})
+	}
+
+	tests := map[string]struct {
+		given, prepared cty.Value
+		want            bool
+	}{
+		"identical": {
+			config(cty.StringVal("us-west-2"), cty.NumberIntVal(1)),
+			config(cty.StringVal("us-west-2"), cty.NumberIntVal(1)),
+			false,
+		},
+		"unknown in the same place": {
+			config(cty.UnknownVal(cty.String), cty.NumberIntVal(1)),
+			config(cty.UnknownVal(cty.String), cty.NumberIntVal(1)),
+			false,
+		},
+		"unknown made known": {
+			config(cty.UnknownVal(cty.String), cty.NumberIntVal(1)),
+			config(cty.StringVal("us-west-2"), cty.NumberIntVal(1)),
+			true,
+		},
+		"default inserted": {
+			config(cty.StringVal("us-west-2"), cty.NullVal(cty.Number)),
+			config(cty.StringVal("us-west-2"), cty.NumberIntVal(1)),
+			true,
+		},
+		"equivalent type": {
+			config(cty.StringVal("us-west-2"), cty.NumberIntVal(1)),
+			cty.ObjectVal(map[string]cty.Value{
+				"region": cty.StringVal("us-west-2"),
+				"count":  cty.StringVal("1"),
+			}),
+			false,
+		},
+		"inconvertible": {
+			config(cty.StringVal("us-west-2"), cty.NumberIntVal(1)),
+			cty.StringVal("us-west-2"),
+			true,
+		},
+		"null": {
+			cty.NullVal(ty),
+			cty.NullVal(ty),
+			false,
+		},
+		"nil": {
+			cty.NilVal,
+			cty.NullVal(ty),
+			true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := ConfigModified(test.given, test.prepared); got != test.want {
+				t.Errorf("wrong result %t; want %t", got, test.want)
+			}
+		})
+	}
+}
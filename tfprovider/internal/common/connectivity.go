@@ -0,0 +1,62 @@
+package common
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
+)
+
+// ClientConnProxy is implemented by client proxies that can provide the gRPC
+// connection they use, so that providers can report its state.
+type ClientConnProxy interface {
+	ClientConn() *grpc.ClientConn
+}
+
+// ConnectionState returns the current state of the given connection, or
+// connectivity.Idle if it is nil because there is no real connection.
+func ConnectionState(conn *grpc.ClientConn) connectivity.State {
+	if conn == nil {
+		return connectivity.Idle
+	}
+	return conn.GetState()
+}
+
+// WatchConnectionState calls the given function each time the state of the
+// given connection changes, until the connection shuts down or the returned
+// stop function is called. It does nothing if the connection is nil.
+func WatchConnectionState(conn *grpc.ClientConn, cb func(connectivity.State)) (stop func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	if conn == nil {
+		return cancel
+	}
+	// We read the initial state before returning so that a change made
+	// immediately afterwards is still reported.
+	state := conn.GetState()
+	go func() {
+		for state != connectivity.Shutdown {
+			if !conn.WaitForStateChange(ctx, state) {
+				return // stop was called
+			}
+			state = conn.GetState()
+			cb(state)
+		}
+	}()
+	return cancel
+}
+
+// closerFunc adapts a function to io.Closer, for use with Options.OnClose.
+type closerFunc func() error
+
+func (f closerFunc) Close() error {
+	return f()
+}
+
+// OnCloseFunc registers a function to be called when the provider that these
+// options belong to is closed.
+func (o *Options) OnCloseFunc(f func()) {
+	o.OnClose(closerFunc(func() error {
+		f()
+		return nil
+	}))
+}
@@ -0,0 +1,87 @@
+package common
+
+import (
+	"bytes"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// crashCaptureLimit is the maximum number of bytes of a plugin's most
+// recent stderr output that a CrashCapture retains.
+const crashCaptureLimit = 64 * 1024
+
+// crashOutputWait is how long CrashCapture.Intercept waits for a panic
+// message to arrive on stderr after a call fails because the connection
+// to the plugin was lost, since the two are reported asynchronously.
+const crashOutputWait = 250 * time.Millisecond
+
+// CrashCapture is an io.Writer that retains the most recent output written
+// to it, intended to receive a provider plugin's stderr so that the message
+// and stack trace from a panic in the plugin can be included in errors.
+type CrashCapture struct {
+	mu  sync.Mutex
+	buf []byte
+}
+
+// NewCrashCapture creates a new, empty crash capture.
+func NewCrashCapture() *CrashCapture {
+	return &CrashCapture{}
+}
+
+func (c *CrashCapture) Write(p []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.buf = append(c.buf, p...)
+	if excess := len(c.buf) - crashCaptureLimit; excess > 0 {
+		c.buf = append(c.buf[:0], c.buf[excess:]...)
+	}
+	return len(p), nil
+}
+
+// Output returns all of the output retained, with leading and trailing
+// whitespace removed.
+func (c *CrashCapture) Output() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return string(bytes.TrimSpace(c.buf))
+}
+
+// PanicOutput returns the output written since the most recent Go panic
+// message, or an empty string if no panic message has been written.
+func (c *CrashCapture) PanicOutput() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	i := bytes.LastIndex(c.buf, []byte("panic: "))
+	if i < 0 {
+		return ""
+	}
+	// Only a panic at the start of a line is a real panic message.
+	if i > 0 && c.buf[i-1] != '\n' {
+		return ""
+	}
+	return string(bytes.TrimSpace(c.buf[i:]))
+}
+
+// Intercept is an RPCInterceptor that, if a call fails because the
+// connection to the plugin was lost and the plugin's stderr shows that it
+// panicked, adds the panic message and stack trace to the error.
+func (c *CrashCapture) Intercept(ctx context.Context, method string, req, resp proto.Message, invoke RPCInvoker) error {
+	err := invoke(ctx, req, resp)
+	if status.Code(err) != codes.Unavailable {
+		return err
+	}
+	output := c.PanicOutput()
+	if output == "" {
+		time.Sleep(crashOutputWait)
+		output = c.PanicOutput()
+	}
+	if output == "" {
+		return err
+	}
+	return status.Errorf(codes.Unavailable, "%s\n\nThe provider plugin crashed with the following output:\n\n%s", status.Convert(err).Message(), output)
+}
@@ -0,0 +1,109 @@
+package common
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const crashTestPanic = `panic: runtime error: invalid memory address or nil pointer dereference
+[signal SIGSEGV: segmentation violation code=0x1 addr=0x0 pc=0x1234]
+
+goroutine 42 [running]:
+example.com/terraform-provider-test/internal.resourceThingCreate(...)
+	/src/internal/thing.go:17 +0x2a`
+
+func TestCrashCaptureIntercept(t *testing.T) {
+	lost := status.Error(codes.Unavailable, "transport is closing")
+
+	tests := map[string]struct {
+		// before is written to stderr before the call fails, and after is
+		// written shortly after it fails, as a crashing plugin might.
+		before, after string
+		err           error
+		wantPanic     bool
+	}{
+		"panic before connection lost": {
+			before:    "starting up\n" + crashTestPanic + "\n",
+			err:       lost,
+			wantPanic: true,
+		},
+		"panic after connection lost": {
+			before:    "starting up\n",
+			after:     crashTestPanic + "\n",
+			err:       lost,
+			wantPanic: true,
+		},
+		"connection lost without panic": {
+			before: "starting up\nshutting down\n",
+			err:    lost,
+		},
+		"panic mentioned mid-line": {
+			before: "log: recovered from panic: oops\n",
+			err:    lost,
+		},
+		"other error": {
+			before: crashTestPanic + "\n",
+			err:    status.Error(codes.Internal, "something else"),
+		},
+		"success": {
+			before: crashTestPanic + "\n",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			c := NewCrashCapture()
+			c.Write([]byte(test.before))
+			invoke := func(ctx context.Context, req, resp proto.Message) error {
+				if test.after != "" {
+					go func() {
+						time.Sleep(crashOutputWait / 10)
+						c.Write([]byte(test.after))
+					}()
+				}
+				return test.err
+			}
+
+			err := c.Intercept(context.Background(), "/tfplugin6.Provider/ApplyResourceChange", nil, nil, invoke)
+			if test.err == nil {
+				if err != nil {
+					t.Fatalf("unexpected error: %s", err)
+				}
+				return
+			}
+			if got, want := status.Code(err), status.Code(test.err); got != want {
+				t.Errorf("wrong status code %s; want %s", got, want)
+			}
+			msg := status.Convert(err).Message()
+			if !strings.HasPrefix(msg, status.Convert(test.err).Message()) {
+				t.Errorf("message does not start with the original error: %s", msg)
+			}
+			if got := strings.Contains(msg, "goroutine 42 [running]"); got != test.wantPanic {
+				t.Errorf("message includes stack trace = %t; want %t\n%s", got, test.wantPanic, msg)
+			}
+		})
+	}
+}
+
+func TestCrashCaptureLimit(t *testing.T) {
+	c := NewCrashCapture()
+	c.Write([]byte(crashTestPanic + "\n"))
+	c.Write([]byte(strings.Repeat("x", crashCaptureLimit)))
+	if got := c.PanicOutput(); got != "" {
+		t.Errorf("panic output retained beyond the limit: %.40q", got)
+	}
+	if got := len(c.Output()); got != crashCaptureLimit {
+		t.Errorf("retained %d bytes; want %d", got, crashCaptureLimit)
+	}
+
+	c.Write([]byte("\n" + crashTestPanic))
+	if got := c.PanicOutput(); got != crashTestPanic {
+		t.Errorf("wrong panic output %q; want %q", got, crashTestPanic)
+	}
+}
@@ -0,0 +1,65 @@
+package common
+
+import (
+	"context"
+	"sync"
+)
+
+// DataReadInput is a single request to read a data resource, as passed to a
+// provider's ReadDataSources method.
+type DataReadInput struct {
+	TypeName string
+	Request  DataResourceReadRequest
+}
+
+// DataReadResult is the outcome of reading a single data resource, as
+// returned from a provider's ReadDataSources method.
+type DataReadResult struct {
+	Response    DataResourceReadResponse
+	Diagnostics Diagnostics
+}
+
+// readDataSourcesParallelism is the maximum number of data resource read
+// requests that RunDataReads will have in progress at once.
+const readDataSourcesParallelism = 4
+
+// RunDataReads calls the given function for each index up to n, with a
+// bounded number of calls running concurrently, and returns the results in
+// index order.
+//
+// If the context is cancelled then no further calls are started, and the
+// results for the calls not started have error diagnostics reporting the
+// cancellation.
+func RunDataReads(ctx context.Context, n int, read func(ctx context.Context, i int) (DataResourceReadResponse, Diagnostics)) []DataReadResult {
+	ret := make([]DataReadResult, n)
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, readDataSourcesParallelism)
+	for i := 0; i < n; i++ {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if err := ctx.Err(); err != nil {
+			for j := i; j < n; j++ {
+				ret[j].Diagnostics = ErrorDiagnostics(
+					"Data resource read cancelled",
+					"The read was not started because the operation was cancelled",
+					err,
+				)
+			}
+			break
+		}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			resp, diags := read(ctx, i)
+			ret[i] = DataReadResult{
+				Response:    resp,
+				Diagnostics: diags,
+			}
+		}(i)
+	}
+	wg.Wait()
+	return ret
+}
@@ -0,0 +1,73 @@
+package common
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRunDataReads(t *testing.T) {
+	var mu sync.Mutex
+	running, maxRunning := 0, 0
+	got := RunDataReads(context.Background(), 20, func(ctx context.Context, i int) (DataResourceReadResponse, Diagnostics) {
+		mu.Lock()
+		running++
+		if running > maxRunning {
+			maxRunning = running
+		}
+		mu.Unlock()
+		time.Sleep(time.Millisecond)
+		mu.Lock()
+		running--
+		mu.Unlock()
+		return DataResourceReadResponse{}, Diagnostics{
+			{Severity: Warning, Summary: "Read", Detail: string(rune('a' + i))},
+		}
+	})
+
+	if len(got) != 20 {
+		t.Fatalf("got %d results; want 20", len(got))
+	}
+	for i, result := range got {
+		if want := string(rune('a' + i)); len(result.Diagnostics) != 1 || result.Diagnostics[0].Detail != want {
+			t.Errorf("wrong diagnostics for read %d: %#v", i, result.Diagnostics)
+		}
+	}
+	if maxRunning > readDataSourcesParallelism {
+		t.Errorf("%d reads ran at once; want at most %d", maxRunning, readDataSourcesParallelism)
+	}
+}
+
+func TestRunDataReadsCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	var mu sync.Mutex
+	started := 0
+	got := RunDataReads(ctx, 10, func(ctx context.Context, i int) (DataResourceReadResponse, Diagnostics) {
+		mu.Lock()
+		started++
+		mu.Unlock()
+		// The first read cancels the operation, and all of the reads
+		// already in progress wait for it.
+		if i == 0 {
+			cancel()
+		}
+		<-ctx.Done()
+		return DataResourceReadResponse{}, nil
+	})
+
+	if started != readDataSourcesParallelism {
+		t.Errorf("started %d reads; want %d", started, readDataSourcesParallelism)
+	}
+	for i, result := range got {
+		if i < started {
+			if len(result.Diagnostics) != 0 {
+				t.Errorf("unexpected diagnostics for started read %d: %s", i, result.Diagnostics.Err())
+			}
+			continue
+		}
+		if !result.Diagnostics.HasErrors() {
+			t.Errorf("no error for read %d, which was not started", i)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package common
+
+import (
+	"sync"
+)
+
+// DiagnosticLog accumulates the diagnostics returned by a provider plugin
+// over its lifetime, so that they can be reviewed after the fact even if
+// the callers of the individual operations discarded them.
+//
+// The methods of a nil *DiagnosticLog do nothing, so that callers need not
+// check whether accumulation is enabled.
+type DiagnosticLog struct {
+	mu    sync.Mutex
+	diags Diagnostics
+}
+
+// NewDiagnosticLog creates a new, empty diagnostic log.
+func NewDiagnosticLog() *DiagnosticLog {
+	return &DiagnosticLog{}
+}
+
+// Append adds the given diagnostics to the log.
+func (l *DiagnosticLog) Append(diags Diagnostics) {
+	if l == nil || len(diags) == 0 {
+		return
+	}
+	l.mu.Lock()
+	l.diags = append(l.diags, diags...)
+	l.mu.Unlock()
+}
+
+// All returns a copy of all of the diagnostics in the log, in the order
+// they were added.
+func (l *DiagnosticLog) All() Diagnostics {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append(Diagnostics(nil), l.diags...)
+}
@@ -1,6 +1,10 @@
 package common
 
 import (
+	"encoding/json"
+	"errors"
+	"strings"
+
 	"github.com/zclconf/go-cty/cty"
 )
 
@@ -18,6 +22,46 @@ type Diagnostic struct {
 	Summary   string
 	Detail    string
 	Attribute cty.Path
+
+	// Source identifies the provider that emitted the diagnostic, if the
+	// provider was started with a source tag. It is empty otherwise.
+	Source string
+
+	// Resource identifies the resource instance that the diagnostic
+	// pertains to, if the provider was started with resource diagnostic
+	// context enabled and the diagnostic was returned by an operation on a
+	// managed resource type. It is nil otherwise.
+	Resource *DiagnosticResource
+}
+
+// DiagnosticResource identifies the resource instance that a diagnostic
+// pertains to.
+type DiagnosticResource struct {
+	TypeName string
+
+	// InstanceKey is the instance key given in the request that produced
+	// the diagnostic, which is empty if the request didn't specify one.
+	InstanceKey string
+}
+
+// WithResource returns a copy of the receiver with the Resource field of
+// each diagnostic set to the given resource type and instance key, if the
+// given options enable resource diagnostic context. Otherwise the receiver
+// is returned unchanged.
+func (diags Diagnostics) WithResource(opts *Options, typeName, instanceKey string) Diagnostics {
+	if !opts.ResourceDiagnosticContext || len(diags) == 0 {
+		return diags
+	}
+	res := &DiagnosticResource{
+		TypeName:    typeName,
+		InstanceKey: instanceKey,
+	}
+	ret := make(Diagnostics, len(diags))
+	for i, diag := range diags {
+		diag.Resource = res
+		ret[i] = diag
+	}
+	return ret
 }
 
 // Diagnostics represents a collection of diagnostic messages
@@ -33,6 +77,88 @@ func (diags Diagnostics) HasErrors() bool {
 	return false
 }
 
+// Err returns an error summarizing the error diagnostics in the receiver,
+// or nil if there are no errors.
+func (diags Diagnostics) Err() error {
+	var msgs []string
+	for _, diag := range diags {
+		if diag.Severity != Error {
+			continue
+		}
+		if diag.Detail != "" {
+			msgs = append(msgs, diag.Summary+": "+diag.Detail)
+		} else {
+			msgs = append(msgs, diag.Summary)
+		}
+	}
+	if len(msgs) == 0 {
+		return nil
+	}
+	return errors.New(strings.Join(msgs, "; "))
+}
+
+// Dedup returns a copy of the receiver with any diagnostic that exactly
+// duplicates an earlier one removed, preserving the order of the rest.
+// Diagnostics are duplicates if they have the same severity, summary,
+// detail, and attribute path.
+func (diags Diagnostics) Dedup() Diagnostics {
+	if len(diags) < 2 {
+		return diags
+	}
+	type dedupKey struct {
+		severity        DiagnosticSeverity
+		summary, detail string
+	}
+	seen := make(map[dedupKey][]cty.Path, len(diags))
+	ret := make(Diagnostics, 0, len(diags))
+Diags:
+	for _, diag := range diags {
+		key := dedupKey{diag.Severity, diag.Summary, diag.Detail}
+		for _, path := range seen[key] {
+			if path.Equals(diag.Attribute) {
+				continue Diags
+			}
+		}
+		seen[key] = append(seen[key], diag.Attribute)
+		ret = append(ret, diag)
+	}
+	return ret
+}
+
+// tfjsonDiagnostic is the JSON representation of a single diagnostic, using
+// the same property names as Terraform's own machine-readable output.
+type tfjsonDiagnostic struct {
+	Severity string `json:"severity"`
+	Summary  string `json:"summary"`
+	Detail   string `json:"detail"`
+	Address  string `json:"address,omitempty"`
+}
+
+// ToTFJSON returns a JSON array describing the diagnostics in the same shape
+// that Terraform uses for diagnostics in its machine-readable output, with
+// the attribute path (if any) serialized as a traversal string in the
+// "address" property.
+func (diags Diagnostics) ToTFJSON() []byte {
+	raws := make([]tfjsonDiagnostic, 0, len(diags))
+	for _, diag := range diags {
+		raw := tfjsonDiagnostic{
+			Summary: diag.Summary,
+			Detail:  diag.Detail,
+			Address: FormatPath(diag.Attribute),
+		}
+		switch diag.Severity {
+		case Error:
+			raw.Severity = "error"
+		case Warning:
+			raw.Severity = "warning"
+		}
+		raws = append(raws, raw)
+	}
+	// Marshal can't fail here, because we're encoding only strings.
+	ret, _ := json.Marshal(raws)
+	return ret
+}
+
 // ErrorDiagnostics creates a diagnostic with Error severity from an error
 func ErrorDiagnostics(summary, detail string, err error) Diagnostics {
 	return Diagnostics{
@@ -56,4 +182,4 @@ func RPCErrorDiagnostics(err error) Diagnostics {
 			Detail:   "Error while calling provider: " + err.Error(),
 		},
 	}
-}
\ No newline at end of file
+}
@@ -0,0 +1,95 @@
+package common
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/zclconf/go-cty/cty"
+)
+
+func TestDiagnosticsToTFJSON(t *testing.T) {
+	diags := Diagnostics{
+		{
+			Severity:  Error,
+			Summary:   "Invalid port",
+			Detail:    "Port must be between 1 and 65535.",
+			Attribute: cty.GetAttrPath("rule").Index(cty.NumberIntVal(0)).GetAttr("port"),
+		},
+		{
+			Severity: Warning,
+			Summary:  "Deprecated provider",
+			Detail:   "Use the other one.",
+		},
+	}
+
+	var got []map[string]interface{}
+	if err := json.Unmarshal(diags.ToTFJSON(), &got); err != nil {
+		t.Fatalf("result is not valid JSON: %s", err)
+	}
+	want := []map[string]interface{}{
+		{
+			"severity": "error",
+			"summary":  "Invalid port",
+			"detail":   "Port must be between 1 and 65535.",
+			"address":  "rule[0].port",
+		},
+		{
+			// The address is omitted for diagnostics without an attribute.
+			"severity": "warning",
+			"summary":  "Deprecated provider",
+			"detail":   "Use the other one.",
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("wrong result\ngot:  %#v\nwant: %#v", got, want)
+	}
+}
+
+func TestDiagnosticsToTFJSONEmpty(t *testing.T) {
+	if got, want := string(Diagnostics(nil).ToTFJSON()), "[]"; got != want {
+		t.Errorf("wrong result %s; want %s", got, want)
+	}
+}
+
+func TestDiagnosticsDedup(t *testing.T) {
+	portPath := cty.GetAttrPath("rule").Index(cty.NumberIntVal(0)).GetAttr("port")
+	diag := func(severity DiagnosticSeverity, summary, detail string, path cty.Path) Diagnostic {
+		return Diagnostic{
+			Severity:  severity,
+			Summary:   summary,
+			Detail:    detail,
+			Attribute: path,
+		}
+	}
+	diags := Diagnostics{
+		diag(Error, "Invalid port", "Out of range.", portPath),
+		diag(Warning, "Deprecated", "Use the other one.", nil),
+		// An exact duplicate of the first, with an equal but separately
+		// built path.
+		diag(Error, "Invalid port", "Out of range.", cty.GetAttrPath("rule").Index(cty.NumberIntVal(0)).GetAttr("port")),
+		// Near-duplicates of the first, each differing in one respect.
+		diag(Warning, "Invalid port", "Out of range.", portPath),
+		diag(Error, "Invalid port number", "Out of range.", portPath),
+		diag(Error, "Invalid port", "Too large.", portPath),
+		diag(Error, "Invalid port", "Out of range.", cty.GetAttrPath("rule").Index(cty.NumberIntVal(1)).GetAttr("port")),
+		diag(Error, "Invalid port", "Out of range.", nil),
+		// An exact duplicate of the second.
+		diag(Warning, "Deprecated", "Use the other one.", nil),
+	}
+
+	got := diags.Dedup()
+	want := append(Diagnostics{diags[0], diags[1]}, diags[3:8]...)
+	if len(got) != len(want) {
+		t.Fatalf("got %d diagnostics; want %d: %#v", len(got), len(want), got)
+	}
+	for i := range got {
+		if !reflect.DeepEqual(got[i], want[i]) {
+			t.Errorf("wrong diagnostic %d\ngot:  %#v\nwant: %#v", i, got[i], want[i])
+		}
+	}
+
+	if got := (Diagnostics{diags[0]}).Dedup(); len(got) != 1 {
+		t.Errorf("single diagnostic deduplicated to %d", len(got))
+	}
+}
@@ -0,0 +1,145 @@
+package common
+
+import (
+	"sort"
+
+	"github.com/zclconf/go-cty/cty"
+)
+
+// AttrChange describes a difference between two values at a particular
+// path, as returned by Diff.
+type AttrChange struct {
+	Path   cty.Path
+	Before cty.Value
+	After  cty.Value
+
+	// RequiresReplace is set by PlanDiff for changes that force the
+	// remote object to be replaced.
+	RequiresReplace bool
+}
+
+// KnownAfterApply returns true if the new value is not yet wholly known,
+// as is the case for computed attributes in a planned state, and so will be
+// decided only when the change is applied.
+func (c AttrChange) KnownAfterApply() bool {
+	return !c.After.IsWhollyKnown()
+}
+
+// Diff compares the two given values, which should be of the same type, and
+// returns a description of each difference between them.
+//
+// Objects, maps, and lists or tuples of the same length are compared element
+// by element, so that a change deep within a value is reported at its own
+// path. Any other differences, including differences in the number of
+// elements in a sequence, any difference in the contents of a set, and a
+// change between null and non-null, are reported at the path of the whole
+// value. In particular, if exactly one of the two values is null then there
+// is a single change with an empty path.
+//
+// An unknown value is always reported as a change unless the other value is
+// also unknown and of the same type. The changes are returned in a
+// consistent order, with the attributes of each object and the keys of each
+// map visited in lexical order.
+func Diff(before, after cty.Value) []AttrChange {
+	return appendDiff(nil, nil, before, after)
+}
+
+func appendDiff(changes []AttrChange, path cty.Path, a, b cty.Value) []AttrChange {
+	changed := func() []AttrChange {
+		return append(changes, AttrChange{
+			Path:   path.Copy(),
+			Before: a,
+			After:  b,
+		})
+	}
+
+	switch {
+	case !a.IsKnown() || !b.IsKnown():
+		if a.RawEquals(b) {
+			return changes
+		}
+		return changed()
+	case a.IsNull() || b.IsNull():
+		if a.IsNull() && b.IsNull() {
+			return changes
+		}
+		return changed()
+	}
+
+	aty, bty := a.Type(), b.Type()
+	switch {
+	case aty.IsObjectType() && bty.IsObjectType():
+		names := make(map[string]struct{})
+		for name := range aty.AttributeTypes() {
+			names[name] = struct{}{}
+		}
+		for name := range bty.AttributeTypes() {
+			names[name] = struct{}{}
+		}
+		for _, name := range sortedKeys(names) {
+			changes = appendDiff(changes, path.GetAttr(name), attrOrNull(a, name), attrOrNull(b, name))
+		}
+		return changes
+
+	case aty.IsMapType() && bty.IsMapType():
+		keys := make(map[string]struct{})
+		for k := range a.AsValueMap() {
+			keys[k] = struct{}{}
+		}
+		for k := range b.AsValueMap() {
+			keys[k] = struct{}{}
+		}
+		for _, k := range sortedKeys(keys) {
+			key := cty.StringVal(k)
+			changes = appendDiff(changes, path.Index(key), indexOrNull(a, key), indexOrNull(b, key))
+		}
+		return changes
+
+	case (aty.IsListType() && bty.IsListType()) || (aty.IsTupleType() && bty.IsTupleType()):
+		if a.LengthInt() != b.LengthInt() {
+			return changed()
+		}
+		for i := 0; i < a.LengthInt(); i++ {
+			key := cty.NumberIntVal(int64(i))
+			changes = appendDiff(changes, path.Index(key), a.Index(key), b.Index(key))
+		}
+		return changes
+
+	default:
+		if !aty.Equals(bty) {
+			return changed()
+		}
+		eq := a.Equals(b)
+		if !eq.IsKnown() || eq.False() {
+			return changed()
+		}
+		return changes
+	}
+}
+
+// attrOrNull returns the named attribute of the given object, or a null
+// value if the object's type has no such attribute.
+func attrOrNull(obj cty.Value, name string) cty.Value {
+	if !obj.Type().HasAttribute(name) {
+		return cty.NullVal(cty.DynamicPseudoType)
+	}
+	return obj.GetAttr(name)
+}
+
+// indexOrNull returns the element of the given map with the given key, or
+// a null value of the map's element type if there is no such element.
+func indexOrNull(m cty.Value, key cty.Value) cty.Value {
+	if m.HasIndex(key).False() {
+		return cty.NullVal(m.Type().ElementType())
+	}
+	return m.Index(key)
+}
+
+func sortedKeys(m map[string]struct{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,155 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/zclconf/go-cty/cty"
+)
+
+func TestDiff(t *testing.T) {
+	obj := func(name cty.Value, tags cty.Value, zones cty.Value) cty.Value {
+		return cty.ObjectVal(map[string]cty.Value{
+			"name":  name,
+			"tags":  tags,
+			"zones": zones,
+		})
+	}
+	tags := cty.MapVal(map[string]cty.Value{
+		"Name": cty.StringVal("example"),
+		"Team": cty.StringVal("platform"),
+	})
+	zones := cty.ListVal([]cty.Value{cty.StringVal("a"), cty.StringVal("b")})
+	base := obj(cty.StringVal("example"), tags, zones)
+
+	tests := map[string]struct {
+		before, after cty.Value
+		wantPaths     []string
+	}{
+		"equal": {
+			base,
+			base,
+			nil,
+		},
+		"attribute changed": {
+			base,
+			obj(cty.StringVal("renamed"), tags, zones),
+			[]string{"name"},
+		},
+		"map element changed, added, and removed": {
+			base,
+			obj(cty.StringVal("example"), cty.MapVal(map[string]cty.Value{
+				"Name":  cty.StringVal("renamed"),
+				"Owner": cty.StringVal("ada"),
+			}), zones),
+			[]string{`tags["Name"]`, `tags["Owner"]`, `tags["Team"]`},
+		},
+		"list element changed": {
+			base,
+			obj(cty.StringVal("example"), tags, cty.ListVal([]cty.Value{cty.StringVal("a"), cty.StringVal("c")})),
+			[]string{"zones[1]"},
+		},
+		"list length changed": {
+			base,
+			obj(cty.StringVal("example"), tags, cty.ListVal([]cty.Value{cty.StringVal("a")})),
+			[]string{"zones"},
+		},
+		"attribute unknown": {
+			base,
+			obj(cty.UnknownVal(cty.String), tags, zones),
+			[]string{"name"},
+		},
+		"both unknown": {
+			obj(cty.UnknownVal(cty.String), tags, zones),
+			obj(cty.UnknownVal(cty.String), tags, zones),
+			nil,
+		},
+		"became null": {
+			base,
+			cty.NullVal(base.Type()),
+			[]string{""},
+		},
+		"set changed": {
+			cty.SetVal([]cty.Value{cty.StringVal("a")}),
+			cty.SetVal([]cty.Value{cty.StringVal("b")}),
+			[]string{""},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			changes := Diff(test.before, test.after)
+			if len(changes) != len(test.wantPaths) {
+				t.Fatalf("got %d changes; want %d: %#v", len(changes), len(test.wantPaths), changes)
+			}
+			for i, change := range changes {
+				if got, want := FormatPath(change.Path), test.wantPaths[i]; got != want {
+					t.Errorf("wrong path %s; want %s", got, want)
+				}
+				if change.Before.RawEquals(change.After) {
+					t.Errorf("change at %s has equal values %#v", test.wantPaths[i], change.Before)
+				}
+			}
+		})
+	}
+}
+
+func TestPlanDiff(t *testing.T) {
+	obj := func(name string, zones ...string) cty.Value {
+		vals := make([]cty.Value, len(zones))
+		for i, zone := range zones {
+			vals[i] = cty.StringVal(zone)
+		}
+		return cty.ObjectVal(map[string]cty.Value{
+			"name":  cty.StringVal(name),
+			"zones": cty.ListVal(vals),
+		})
+	}
+	zonePath := cty.GetAttrPath("zones").Index(cty.NumberIntVal(0))
+
+	tests := map[string]struct {
+		prior, planned cty.Value
+		wantReplace    map[string]bool
+	}{
+		"replacing element changed": {
+			obj("a", "z1", "z2"),
+			obj("b", "z3", "z2"),
+			map[string]bool{"name": false, "zones[0]": true},
+		},
+		"collection containing replacing element changed": {
+			obj("a", "z1"),
+			obj("a", "z2", "z3"),
+			map[string]bool{"zones": true},
+		},
+		"other element changed": {
+			obj("a", "z1", "z2"),
+			obj("a", "z1", "z3"),
+			map[string]bool{"zones[1]": false},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			changes := PlanDiff(
+				ManagedResourcePlanRequest{PriorState: test.prior},
+				ManagedResourcePlanResponse{
+					PlannedState:    test.planned,
+					RequiresReplace: []cty.Path{zonePath},
+				},
+			)
+			if len(changes) != len(test.wantReplace) {
+				t.Fatalf("got %d changes; want %d: %#v", len(changes), len(test.wantReplace), changes)
+			}
+			for _, change := range changes {
+				path := FormatPath(change.Path)
+				want, ok := test.wantReplace[path]
+				if !ok {
+					t.Errorf("unexpected change at %s", path)
+					continue
+				}
+				if change.RequiresReplace != want {
+					t.Errorf("wrong RequiresReplace %t for %s; want %t", change.RequiresReplace, path, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,48 @@
+package common
+
+import (
+	"sync"
+
+	"github.com/zclconf/go-cty/cty"
+)
+
+// EncodeCache remembers the encoding of the most recent value passed to
+// its Encode method, so that encoding the same value repeatedly, as happens
+// with the provider_meta value sent with every managed resource operation,
+// is done only once.
+//
+// A nil *EncodeCache is valid and caches nothing.
+type EncodeCache struct {
+	mu    sync.Mutex
+	val   cty.Value
+	data  DynamicValueData
+	diags Diagnostics
+}
+
+// NewEncodeCache returns a new, empty cache.
+func NewEncodeCache() *EncodeCache {
+	return &EncodeCache{}
+}
+
+// Encode returns the cached result of encoding the given value if it is
+// identical to the value most recently encoded through the cache, or calls
+// encode to encode it otherwise. Only successful results are cached.
+//
+// The returned data may be shared with other callers, and so must not be
+// modified.
+func (c *EncodeCache) Encode(val cty.Value, encode func() (DynamicValueData, Diagnostics)) (DynamicValueData, Diagnostics) {
+	if c == nil {
+		return encode()
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.val != cty.NilVal && c.val.RawEquals(val) {
+		return c.data, c.diags
+	}
+	data, diags := encode()
+	if diags.HasErrors() {
+		return data, diags
+	}
+	c.val, c.data, c.diags = val, data, diags
+	return data, diags
+}
@@ -0,0 +1,74 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/apparentlymart/terraform-schema-go/tfschema"
+	"github.com/zclconf/go-cty/cty"
+)
+
+func TestEncodeCache(t *testing.T) {
+	schema := &tfschema.Block{
+		Attributes: map[string]*tfschema.Attribute{
+			"name": {Type: cty.String, Optional: true},
+		},
+	}
+	c := NewEncodeCache()
+	obj := func(name string) cty.Value {
+		return cty.ObjectVal(map[string]cty.Value{
+			"name": cty.StringVal(name),
+		})
+	}
+	encodes := 0
+	encode := func(val cty.Value, fail bool) DynamicValueData {
+		t.Helper()
+		data, diags := c.Encode(val, func() (DynamicValueData, Diagnostics) {
+			encodes++
+			if fail {
+				return DynamicValueData{}, Diagnostics{{Severity: Error, Summary: "Encoding failed"}}
+			}
+			return EncodeDynamicValue(val, schema)
+		})
+		if got := diags.HasErrors(); got != fail {
+			t.Fatalf("wrong errors %s", diags.Err())
+		}
+		return data
+	}
+
+	a := encode(obj("a"), false)
+	if got := encode(obj("a"), false); &got.Msgpack[0] != &a.Msgpack[0] {
+		t.Errorf("identical value was not given the cached encoding")
+	}
+	if encodes != 1 {
+		t.Errorf("encoded %d times for identical values; want 1", encodes)
+	}
+
+	// A different value replaces the cached one.
+	b := encode(obj("b"), false)
+	encode(obj("a"), false)
+	if encodes != 3 {
+		t.Errorf("encoded %d times after the value changed; want 3", encodes)
+	}
+	if string(b.Msgpack) == string(a.Msgpack) {
+		t.Errorf("different values have the same encoding")
+	}
+
+	// Failures are not cached.
+	encode(obj("c"), true)
+	encode(obj("c"), true)
+	if encodes != 5 {
+		t.Errorf("encoded %d times after failures; want 5", encodes)
+	}
+
+	var nilCache *EncodeCache
+	calls := 0
+	for i := 0; i < 2; i++ {
+		nilCache.Encode(obj("a"), func() (DynamicValueData, Diagnostics) {
+			calls++
+			return DynamicValueData{}, nil
+		})
+	}
+	if calls != 2 {
+		t.Errorf("nil cache encoded %d times; want 2", calls)
+	}
+}
@@ -1,7 +1,8 @@
 package common
 
 import (
-	"github.com/apparentlymart/terraform-schema-go/tfschema"
+	"fmt"
+
 	"github.com/zclconf/go-cty/cty"
 	"github.com/zclconf/go-cty/cty/json"
 	"github.com/zclconf/go-cty/cty/msgpack"
@@ -14,23 +15,109 @@ type DynamicValueData struct {
 }
 
 // EncodeDynamicValue encodes a cty.Value into msgpack format
-func EncodeDynamicValue(val cty.Value, schema *tfschema.Block) (DynamicValueData, Diagnostics) {
+//
+// Values in positions where the schema calls for cty.DynamicPseudoType are
+// encoded along with their concrete types, as the protocol requires, so
+// that DecodeDynamicValue recovers the same types.
+func EncodeDynamicValue(val cty.Value, schema ImpliedTyper) (DynamicValueData, Diagnostics) {
+	return encodeDynamicValue(val, schema, FormatMsgpack)
+}
+
+// encodeDynamicValue is like EncodeDynamicValue but encodes into the given
+// format, which is msgpack unless it is FormatJSON. JSON cannot represent
+// unknown values, so values containing them can only be encoded as msgpack.
+func encodeDynamicValue(val cty.Value, schema ImpliedTyper, format Format) (DynamicValueData, Diagnostics) {
+	diags := checkEncodable(val)
+	if diags.HasErrors() {
+		return DynamicValueData{}, diags
+	}
 	ty := schema.ImpliedType()
+	if format == FormatJSON {
+		raw, err := json.Marshal(val, ty)
+		if err != nil {
+			return DynamicValueData{}, append(diags, ErrorDiagnostics(
+				"Invalid object",
+				"Value cannot be serialized as JSON",
+				err,
+			)...)
+		}
+		return DynamicValueData{
+			JSON: raw,
+		}, diags
+	}
 	raw, err := msgpack.Marshal(val, ty)
 	if err != nil {
-		return DynamicValueData{}, ErrorDiagnostics(
+		return DynamicValueData{}, append(diags, ErrorDiagnostics(
 			"Invalid object",
 			"Value does not have the required type",
 			err,
-		)
+		)...)
 	}
 	return DynamicValueData{
 		Msgpack: raw,
-	}, nil
+	}, diags
+}
+
+// numberPrecision is the precision in bits that cty uses for numbers that
+// it decodes from their string representations, as the msgpack encoding
+// does for numbers that don't fit in an int64 or float64.
+const numberPrecision = 512
+
+// checkEncodable returns an error diagnostic for each value of a capsule
+// type within the given value, since capsule types cannot be serialized,
+// and a warning diagnostic for each number that cannot be decoded again
+// without losing precision.
+func checkEncodable(val cty.Value) Diagnostics {
+	var diags Diagnostics
+	cty.Walk(val, func(path cty.Path, v cty.Value) (bool, error) {
+		where := "The value"
+		if len(path) != 0 {
+			where = "The value at " + FormatPath(path)
+		}
+		switch {
+		case v.Type().IsCapsuleType():
+			diags = append(diags, Diagnostic{
+				Severity:  Error,
+				Summary:   "Invalid object",
+				Detail:    fmt.Sprintf("%s is of capsule type %s, which cannot be serialized for sending to a provider.", where, v.Type().FriendlyName()),
+				Attribute: path.Copy(),
+			})
+			return false, nil
+		case v.Type() == cty.Number && v.IsKnown() && !v.IsNull():
+			if bf := v.AsBigFloat(); !bf.IsInf() && bf.MinPrec() > numberPrecision {
+				diags = append(diags, Diagnostic{
+					Severity:  Warning,
+					Summary:   "Number will lose precision",
+					Detail:    fmt.Sprintf("%s is a number requiring %d bits of precision, but it will be decoded with only %d bits and so will be rounded.", where, bf.MinPrec(), numberPrecision),
+					Attribute: path.Copy(),
+				})
+			}
+			return false, nil
+		}
+		return true, nil
+	})
+	return diags
+}
+
+// EncodedSize returns the number of bytes that EncodeDynamicValue would
+// produce for the given value, for comparison against message size limits
+// before sending the value to a provider, along with the same diagnostics
+// that EncodeDynamicValue would return.
+//
+// The value is fully encoded in order to measure it, because cty's msgpack
+// encoder can only produce a complete buffer, so this needs as much memory
+// as encoding the value would. The buffer is discarded before returning,
+// so only the size is retained.
+func EncodedSize(val cty.Value, schema ImpliedTyper) (int, Diagnostics) {
+	data, diags := EncodeDynamicValue(val, schema)
+	if diags.HasErrors() {
+		return 0, diags
+	}
+	return len(data.Msgpack), diags
 }
 
 // DecodeDynamicValue decodes raw dynamic value data back into a cty.Value
-func DecodeDynamicValue(data DynamicValueData, schema *tfschema.Block) (cty.Value, Diagnostics) {
+func DecodeDynamicValue(data DynamicValueData, schema ImpliedTyper) (cty.Value, Diagnostics) {
 	ty := schema.ImpliedType()
 	switch {
 	case len(data.JSON) > 0:
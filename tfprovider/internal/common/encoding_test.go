@@ -0,0 +1,247 @@
+package common
+
+import (
+	"fmt"
+	"math/big"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/apparentlymart/terraform-schema-go/tfschema"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/msgpack"
+)
+
+func TestEncodedSize(t *testing.T) {
+	schema := &tfschema.Block{
+		Attributes: map[string]*tfschema.Attribute{
+			"name":  {Type: cty.String, Optional: true},
+			"items": {Type: cty.List(cty.String), Optional: true},
+			"extra": {Type: cty.DynamicPseudoType, Optional: true},
+		},
+	}
+	items := make([]cty.Value, 1000)
+	for i := range items {
+		items[i] = cty.StringVal(strings.Repeat("x", i%50))
+	}
+
+	tests := map[string]cty.Value{
+		"null": cty.NullVal(schema.ImpliedType()),
+		"small": cty.ObjectVal(map[string]cty.Value{
+			"name":  cty.StringVal("a"),
+			"items": cty.NullVal(cty.List(cty.String)),
+			"extra": cty.NullVal(cty.DynamicPseudoType),
+		}),
+		"large": cty.ObjectVal(map[string]cty.Value{
+			"name":  cty.StringVal(strings.Repeat("long name ", 100)),
+			"items": cty.ListVal(items),
+			"extra": cty.NullVal(cty.DynamicPseudoType),
+		}),
+		"unknown": cty.ObjectVal(map[string]cty.Value{
+			"name":  cty.UnknownVal(cty.String),
+			"items": cty.UnknownVal(cty.List(cty.String)),
+			"extra": cty.DynamicVal,
+		}),
+		"dynamic": cty.ObjectVal(map[string]cty.Value{
+			"name":  cty.StringVal("a"),
+			"items": cty.ListValEmpty(cty.String),
+			"extra": cty.MapVal(map[string]cty.Value{
+				"a": cty.NumberIntVal(1),
+			}),
+		}),
+	}
+
+	for name, val := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, diags := EncodedSize(val, schema)
+			if diags.HasErrors() {
+				t.Fatalf("unexpected errors: %s", diags.Err())
+			}
+			raw, err := msgpack.Marshal(val, schema.ImpliedType())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := len(raw); got != want {
+				t.Errorf("wrong size %d; want %d", got, want)
+			}
+		})
+	}
+}
+
+func TestEncodedSizeInvalid(t *testing.T) {
+	got, diags := EncodedSize(cty.NumberIntVal(1), testBlock)
+	if !diags.HasErrors() {
+		t.Fatalf("EncodedSize succeeded; want an error")
+	}
+	if got != 0 {
+		t.Errorf("wrong size %d; want 0", got)
+	}
+}
+
+func TestEncodeDynamicValueCapsule(t *testing.T) {
+	capsuleType := cty.Capsule("widget", reflect.TypeOf(0))
+	schema := &tfschema.Block{
+		Attributes: map[string]*tfschema.Attribute{
+			"name":  {Type: cty.String, Optional: true},
+			"extra": {Type: cty.DynamicPseudoType, Optional: true},
+		},
+	}
+	n := 1
+	val := cty.ObjectVal(map[string]cty.Value{
+		"name": cty.StringVal("a"),
+		"extra": cty.TupleVal([]cty.Value{
+			cty.StringVal("fine"),
+			cty.CapsuleVal(capsuleType, &n),
+		}),
+	})
+
+	_, diags := EncodeDynamicValue(val, schema)
+	if len(diags) != 1 {
+		t.Fatalf("got %d diagnostics; want 1", len(diags))
+	}
+	diag := diags[0]
+	if diag.Severity != Error || diag.Summary != "Invalid object" {
+		t.Errorf("wrong diagnostic %#v", diag)
+	}
+	wantPath := cty.GetAttrPath("extra").Index(cty.NumberIntVal(1))
+	if !diag.Attribute.Equals(wantPath) {
+		t.Errorf("wrong path %s; want %s", FormatPath(diag.Attribute), FormatPath(wantPath))
+	}
+	if !strings.Contains(diag.Detail, "capsule type widget") {
+		t.Errorf("detail %q does not name the capsule type", diag.Detail)
+	}
+}
+
+func TestEncodeDynamicValueNumbers(t *testing.T) {
+	schema := &tfschema.Block{
+		Attributes: map[string]*tfschema.Attribute{
+			"number": {Type: cty.Number, Optional: true},
+			"extra":  {Type: cty.DynamicPseudoType, Optional: true},
+		},
+	}
+	tests := []string{
+		"0",
+		"-1",
+		"9223372036854775807",  // largest int64
+		"-9223372036854775808", // smallest int64
+		"9223372036854775808",
+		"18446744073709551615", // largest uint64
+		"18446744073709551616",
+		"123456789012345678901234567890",
+		"0.1",
+		"1.5",
+		"3.14159265358979323846264338327950288419716939937510",
+		"-0.000000000000000000000000000001",
+		"1e300",
+	}
+
+	for _, format := range []Format{FormatMsgpack, FormatJSON} {
+		for _, src := range tests {
+			t.Run(fmt.Sprintf("%s %s", format, src), func(t *testing.T) {
+				num := cty.MustParseNumberVal(src)
+				val := cty.ObjectVal(map[string]cty.Value{
+					"number": num,
+					"extra":  num,
+				})
+				data, diags := encodeDynamicValue(val, schema, format)
+				if len(diags) != 0 {
+					t.Fatalf("unexpected diagnostics: %#v", diags)
+				}
+				got, diags := DecodeDynamicValue(data, schema)
+				if diags.HasErrors() {
+					t.Fatalf("unexpected errors: %s", diags.Err())
+				}
+				for _, name := range []string{"number", "extra"} {
+					v := got.GetAttr(name)
+					if !v.Type().Equals(cty.Number) || v.Equals(num).False() {
+						t.Errorf("wrong %s %#v; want %#v", name, v, num)
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestEncodeDynamicValuePrecisionLoss(t *testing.T) {
+	schema := &tfschema.Block{
+		Attributes: map[string]*tfschema.Attribute{
+			"number": {Type: cty.Number, Optional: true},
+		},
+	}
+	// One plus a fraction too small to represent with the precision that
+	// numbers are decoded with.
+	bf := new(big.Float).SetPrec(1024).SetInt64(1)
+	bf.Add(bf, new(big.Float).SetMantExp(big.NewFloat(1), -600))
+	val := cty.ObjectVal(map[string]cty.Value{
+		"number": cty.NumberVal(bf),
+	})
+
+	data, diags := EncodeDynamicValue(val, schema)
+	if len(diags) != 1 {
+		t.Fatalf("got %d diagnostics; want 1", len(diags))
+	}
+	diag := diags[0]
+	if diag.Severity != Warning || diag.Summary != "Number will lose precision" {
+		t.Errorf("wrong diagnostic %#v", diag)
+	}
+	if got, want := FormatPath(diag.Attribute), "number"; got != want {
+		t.Errorf("wrong path %s; want %s", got, want)
+	}
+	// The value is still encoded, rounded as the warning describes.
+	got, diags := DecodeDynamicValue(data, schema)
+	if diags.HasErrors() {
+		t.Fatalf("unexpected errors: %s", diags.Err())
+	}
+	if want := cty.NumberIntVal(1); got.GetAttr("number").Equals(want).False() {
+		t.Errorf("wrong decoded number %#v; want %#v", got.GetAttr("number"), want)
+	}
+}
+
+func TestEncodeDynamicValueDynamicAttribute(t *testing.T) {
+	schema := &tfschema.Block{
+		Attributes: map[string]*tfschema.Attribute{
+			"value": {Type: cty.DynamicPseudoType, Optional: true},
+		},
+	}
+	tests := map[string]cty.Value{
+		"string": cty.StringVal("hello"),
+		"number": cty.NumberIntVal(12),
+		"list":   cty.ListVal([]cty.Value{cty.StringVal("a")}),
+		"object": cty.ObjectVal(map[string]cty.Value{
+			"name": cty.StringVal("a"),
+			"tags": cty.MapVal(map[string]cty.Value{"env": cty.StringVal("prod")}),
+		}),
+		"null string":    cty.NullVal(cty.String),
+		"unknown number": cty.UnknownVal(cty.Number),
+		"null":           cty.NullVal(cty.DynamicPseudoType),
+		"unknown":        cty.DynamicVal,
+	}
+
+	for name, inner := range tests {
+		t.Run(name, func(t *testing.T) {
+			val := cty.ObjectVal(map[string]cty.Value{"value": inner})
+			data, diags := EncodeDynamicValue(val, schema)
+			if diags.HasErrors() {
+				t.Fatalf("unexpected errors: %s", diags.Err())
+			}
+
+			// The concrete type is recorded in the encoding itself, as
+			// a provider decoding it independently would need.
+			raw, err := msgpack.Unmarshal(data.Msgpack, schema.ImpliedType())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := raw.GetAttr("value").Type(); !got.Equals(inner.Type()) {
+				t.Errorf("wrong encoded type %#v; want %#v", got, inner.Type())
+			}
+
+			got, diags := DecodeDynamicValue(data, schema)
+			if diags.HasErrors() {
+				t.Fatalf("unexpected errors: %s", diags.Err())
+			}
+			if !got.RawEquals(val) {
+				t.Errorf("wrong result\ngot:  %#v\nwant: %#v", got, val)
+			}
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package common
+
+import (
+	"fmt"
+)
+
+// ResourceMode distinguishes managed resource types from data resource
+// types in errors that could apply to either.
+type ResourceMode int
+
+const (
+	ManagedResourceMode ResourceMode = iota
+	DataResourceMode
+)
+
+func (m ResourceMode) String() string {
+	switch m {
+	case ManagedResourceMode:
+		return "managed"
+	case DataResourceMode:
+		return "data"
+	default:
+		return fmt.Sprintf("ResourceMode(%d)", int(m))
+	}
+}
+
+// UnknownResourceTypeError is the error returned when a caller refers to a
+// resource type that the provider's schema doesn't include.
+type UnknownResourceTypeError struct {
+	Mode     ResourceMode
+	TypeName string
+}
+
+func (e UnknownResourceTypeError) Error() string {
+	return fmt.Sprintf("%s resource type %q not found", e.Mode, e.TypeName)
+}
+
+// SchemaVersionUnavailableError is the error returned when a caller requests
+// a version of a resource type schema other than the current one.
+//
+// The provider plugin protocol only allows retrieving the current version
+// of each schema. Older state must instead be upgraded by the provider,
+// which understands its own earlier schema versions.
+type SchemaVersionUnavailableError struct {
+	TypeName       string
+	Version        int64
+	CurrentVersion int64
+}
+
+func (e SchemaVersionUnavailableError) Error() string {
+	return fmt.Sprintf("schema version %d of managed resource type %q is not available: the provider plugin protocol can only retrieve the current version, %d", e.Version, e.TypeName, e.CurrentVersion)
+}
@@ -0,0 +1,45 @@
+package common
+
+import (
+	"github.com/apparentlymart/terraform-schema-go/tfschema"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// ConfigFromMap builds a value conforming to the given schema, such as a
+// provider configuration schema, from a map giving only some of its
+// attributes and nested blocks.
+//
+// Unspecified attributes are null, so that the provider can apply its own
+// defaults, and unspecified nested blocks take the values they would have
+// if absent from the configuration, as for tfschema's EmptyValue methods.
+// Nested blocks that are given as objects, or as collections of objects,
+// are completed in the same way, so they need specify only some of their
+// own attributes.
+//
+// Given values are converted to the types the schema requires. Each value
+// that cannot be converted, and each key that doesn't match an attribute or
+// nested block, is reported as an error diagnostic with its path.
+func ConfigFromMap(data map[string]cty.Value, schema *tfschema.Block) (cty.Value, Diagnostics) {
+	// ObjectFromGo accepts cty.Value anywhere it accepts a Go value, so
+	// this is just a more specific signature for the same traversal.
+	raw := make(map[string]interface{}, len(data))
+	for k, v := range data {
+		raw[k] = v
+	}
+	return ObjectFromGo(raw, schema)
+}
+
+// EncodePartial builds a value conforming to the given schema from a map
+// giving only some of its attributes and nested blocks, as for
+// ConfigFromMap, and then encodes it as for EncodeDynamicValue.
+func EncodePartial(partial map[string]cty.Value, schema *tfschema.Block) (DynamicValueData, Diagnostics) {
+	val, diags := ConfigFromMap(partial, schema)
+	if diags.HasErrors() {
+		return DynamicValueData{}, diags
+	}
+	if schema == nil {
+		schema = &tfschema.Block{}
+	}
+	data, moreDiags := EncodeDynamicValue(val, schema)
+	return data, append(diags, moreDiags...)
+}
@@ -0,0 +1,206 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/apparentlymart/terraform-schema-go/tfschema"
+	"github.com/zclconf/go-cty/cty"
+)
+
+func TestConfigFromMap(t *testing.T) {
+	schema := &tfschema.Block{
+		Attributes: map[string]*tfschema.Attribute{
+			"region":   {Type: cty.String, Required: true},
+			"endpoint": {Type: cty.String, Optional: true, Computed: true},
+			"retries":  {Type: cty.Number, Optional: true},
+		},
+		BlockTypes: map[string]*tfschema.NestedBlock{
+			"assume_role": {
+				Nesting: tfschema.NestingSingle,
+				Block: tfschema.Block{
+					Attributes: map[string]*tfschema.Attribute{
+						"role_arn":     {Type: cty.String, Required: true},
+						"session_name": {Type: cty.String, Optional: true, Computed: true},
+					},
+				},
+			},
+			"ignore_tags": {
+				Nesting: tfschema.NestingList,
+				Block: tfschema.Block{
+					Attributes: map[string]*tfschema.Attribute{
+						"keys": {Type: cty.Set(cty.String), Optional: true},
+					},
+				},
+			},
+		},
+	}
+
+	got, diags := ConfigFromMap(map[string]cty.Value{
+		"region":  cty.StringVal("us-west-2"),
+		"retries": cty.StringVal("3"),
+		"assume_role": cty.ObjectVal(map[string]cty.Value{
+			"role_arn": cty.StringVal("arn:example"),
+		}),
+	}, schema)
+	if diags.HasErrors() {
+		t.Fatalf("unexpected errors: %s", diags.Err())
+	}
+	want := cty.ObjectVal(map[string]cty.Value{
+		"region":   cty.StringVal("us-west-2"),
+		"endpoint": cty.NullVal(cty.String),
+		"retries":  cty.NumberIntVal(3),
+		"assume_role": cty.ObjectVal(map[string]cty.Value{
+			"role_arn":     cty.StringVal("arn:example"),
+			"session_name": cty.NullVal(cty.String),
+		}),
+		"ignore_tags": cty.ListValEmpty(schema.BlockTypes["ignore_tags"].Block.ImpliedType()),
+	})
+	if !got.RawEquals(want) {
+		t.Errorf("wrong result\ngot:  %#v\nwant: %#v", got, want)
+	}
+
+	// The result is suitable for encoding against the schema.
+	if _, diags := EncodeDynamicValue(got, schema); diags.HasErrors() {
+		t.Errorf("result cannot be encoded: %s", diags.Err())
+	}
+}
+
+func TestConfigFromMapEmpty(t *testing.T) {
+	got, diags := ConfigFromMap(nil, requiredTestSchema)
+	if diags.HasErrors() {
+		t.Fatalf("unexpected errors: %s", diags.Err())
+	}
+	if want := requiredTestSchema.EmptyValue(); !got.RawEquals(want) {
+		t.Errorf("wrong result\ngot:  %#v\nwant: %#v", got, want)
+	}
+}
+
+func TestConfigFromMapErrors(t *testing.T) {
+	_, diags := ConfigFromMap(map[string]cty.Value{
+		"region": cty.ListValEmpty(cty.String),
+		"assume_role": cty.ObjectVal(map[string]cty.Value{
+			"role": cty.StringVal("arn:example"),
+		}),
+		"profile": cty.StringVal("default"),
+	}, requiredTestSchema)
+
+	wantPaths := map[string]bool{
+		"region":           false,
+		"assume_role.role": false,
+		"profile":          false,
+	}
+	for _, diag := range diags {
+		path := FormatPath(diag.Attribute)
+		if _, ok := wantPaths[path]; !ok || diag.Severity != Error {
+			t.Errorf("unexpected diagnostic at %s: %s", path, diag.Detail)
+			continue
+		}
+		wantPaths[path] = true
+	}
+	for path, found := range wantPaths {
+		if !found {
+			t.Errorf("no diagnostic at %s", path)
+		}
+	}
+}
+
+func TestEncodePartial(t *testing.T) {
+	data, diags := EncodePartial(map[string]cty.Value{
+		"region": cty.StringVal("us-west-2"),
+		"endpoint": cty.ListVal([]cty.Value{
+			cty.ObjectVal(map[string]cty.Value{
+				"service": cty.StringVal("s3"),
+				"url":     cty.StringVal("https://s3.example.com/"),
+			}),
+		}),
+	}, requiredTestSchema)
+	if diags.HasErrors() {
+		t.Fatalf("unexpected errors: %s", diags.Err())
+	}
+
+	got, diags := DecodeDynamicValue(data, requiredTestSchema)
+	if diags.HasErrors() {
+		t.Fatalf("result cannot be decoded: %s", diags.Err())
+	}
+	ty := requiredTestSchema.ImpliedType()
+	want := cty.ObjectVal(map[string]cty.Value{
+		"region":      cty.StringVal("us-west-2"),
+		"assume_role": cty.NullVal(ty.AttributeType("assume_role")),
+		"endpoint": cty.ListVal([]cty.Value{
+			cty.ObjectVal(map[string]cty.Value{
+				"service": cty.StringVal("s3"),
+				"url":     cty.StringVal("https://s3.example.com/"),
+			}),
+		}),
+		"header": cty.SetValEmpty(ty.AttributeType("header").ElementType()),
+	})
+	if !got.RawEquals(want) {
+		t.Errorf("wrong result\ngot:  %#v\nwant: %#v", got, want)
+	}
+}
+
+func TestEncodePartialUnknownName(t *testing.T) {
+	data, diags := EncodePartial(map[string]cty.Value{
+		"region":  cty.StringVal("us-west-2"),
+		"profile": cty.StringVal("default"),
+	}, requiredTestSchema)
+	if len(diags) != 1 || diags[0].Severity != Error {
+		t.Fatalf("wrong diagnostics %#v", diags)
+	}
+	if got, want := FormatPath(diags[0].Attribute), "profile"; got != want {
+		t.Errorf("wrong path %s; want %s", got, want)
+	}
+	if len(data.Msgpack) != 0 || len(data.JSON) != 0 {
+		t.Errorf("value encoded despite errors")
+	}
+}
+
+func TestConfigFromMapNestingModes(t *testing.T) {
+	inner := tfschema.Block{
+		Attributes: map[string]*tfschema.Attribute{
+			"name": {Type: cty.String, Optional: true},
+		},
+	}
+	innerTy := inner.ImpliedType()
+	schema := &tfschema.Block{
+		BlockTypes: map[string]*tfschema.NestedBlock{
+			"single": {Nesting: tfschema.NestingSingle, Block: inner},
+			"list":   {Nesting: tfschema.NestingList, Block: inner},
+			"set":    {Nesting: tfschema.NestingSet, Block: inner},
+			"map":    {Nesting: tfschema.NestingMap, Block: inner},
+			"group": {
+				Nesting: tfschema.NestingGroup,
+				Block: tfschema.Block{
+					Attributes: inner.Attributes,
+					BlockTypes: map[string]*tfschema.NestedBlock{
+						"list": {Nesting: tfschema.NestingList, Block: inner},
+					},
+				},
+			},
+		},
+	}
+
+	got, diags := ConfigFromMap(nil, schema)
+	if diags.HasErrors() {
+		t.Fatalf("unexpected errors: %s", diags.Err())
+	}
+	want := cty.ObjectVal(map[string]cty.Value{
+		"single": cty.NullVal(innerTy),
+		"list":   cty.ListValEmpty(innerTy),
+		"set":    cty.SetValEmpty(innerTy),
+		"map":    cty.MapValEmpty(innerTy),
+		"group": cty.ObjectVal(map[string]cty.Value{
+			"name": cty.NullVal(cty.String),
+			"list": cty.ListValEmpty(innerTy),
+		}),
+	})
+	if !got.RawEquals(want) {
+		t.Errorf("wrong result\ngot:  %#v\nwant: %#v", got, want)
+	}
+	if empty := schema.EmptyValue(); !empty.RawEquals(want) {
+		t.Errorf("wrong empty value\ngot:  %#v\nwant: %#v", empty, want)
+	}
+	if _, diags := EncodeDynamicValue(got, schema); diags.HasErrors() {
+		t.Errorf("result cannot be encoded: %s", diags.Err())
+	}
+}
@@ -0,0 +1,77 @@
+package common
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"sort"
+
+	"github.com/apparentlymart/terraform-schema-go/tfschema"
+	ctyjson "github.com/zclconf/go-cty/cty/json"
+)
+
+// Fingerprint returns a hash of the entire schema as a hexadecimal string,
+// which is the same for identical schemas even across different runs of the
+// program, and differs if anything in the schema changes, including
+// attribute types, flags, and descriptions, block nesting modes, and
+// resource type schema versions. Callers can compare fingerprints to cheaply
+// detect that a provider's schema has changed, such as after an upgrade.
+func (s *Schema) Fingerprint() string {
+	h := sha256.New()
+	fingerprintBlock(h, "provider", s.ProviderConfig)
+	if s.ProviderMeta != nil {
+		fingerprintBlock(h, "provider_meta", s.ProviderMeta)
+	}
+
+	names := make([]string, 0, len(s.ManagedResourceTypes))
+	for name := range s.ManagedResourceTypes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		rs := s.ManagedResourceTypes[name]
+		fmt.Fprintf(h, "managed %q %d\n", name, rs.Version)
+		fingerprintBlock(h, "", rs.Content)
+	}
+
+	names = names[:0]
+	for name := range s.DataResourceTypes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(h, "data %q\n", name)
+		fingerprintBlock(h, "", s.DataResourceTypes[name].Content)
+	}
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// fingerprintBlock writes a canonical representation of the given block to
+// the given hash, with the given label written first. Strings are quoted so
+// that no two different blocks can have the same representation.
+func fingerprintBlock(h hash.Hash, label string, block *tfschema.Block) {
+	fmt.Fprintf(h, "block %q {\n", label)
+	if block != nil {
+		for _, name := range sortedAttributeNames(block) {
+			attr := block.Attributes[name]
+			// The JSON serialization of a type is canonical, because
+			// encoding/json sorts the keys of object types' attributes.
+			ty, err := ctyjson.MarshalType(attr.Type)
+			if err != nil {
+				ty = []byte(fmt.Sprintf("%q", attr.Type.GoString()))
+			}
+			fmt.Fprintf(h, "attr %q %s %q %t %t %t %t\n",
+				name, ty, attr.Description,
+				attr.Required, attr.Optional, attr.Computed, attr.Sensitive,
+			)
+		}
+		for _, name := range sortedBlockTypeNames(block) {
+			nested := block.BlockTypes[name]
+			fmt.Fprintf(h, "nesting %d\n", nested.Nesting)
+			fingerprintBlock(h, name, &nested.Block)
+		}
+	}
+	fmt.Fprintf(h, "}\n")
+}
@@ -0,0 +1,111 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/apparentlymart/terraform-schema-go/tfschema"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// fingerprintTestSchema returns a new copy of a schema exercising each of
+// the parts of a schema that contribute to its fingerprint.
+func fingerprintTestSchema() *Schema {
+	return &Schema{
+		ProviderConfig: &tfschema.Block{
+			Attributes: map[string]*tfschema.Attribute{
+				"region": {Type: cty.String, Optional: true, Description: "The region."},
+				"token":  {Type: cty.String, Optional: true, Sensitive: true},
+			},
+		},
+		ManagedResourceTypes: map[string]*ManagedResourceTypeSchema{
+			"test_thing": NewManagedResourceTypeSchema(1, &tfschema.Block{
+				Attributes: map[string]*tfschema.Attribute{
+					"id":   {Type: cty.String, Computed: true},
+					"tags": {Type: cty.Map(cty.String), Optional: true},
+					"spec": {Type: cty.Object(map[string]cty.Type{"a": cty.Number, "b": cty.Bool}), Optional: true},
+				},
+				BlockTypes: map[string]*tfschema.NestedBlock{
+					"rule": {
+						Nesting: tfschema.NestingList,
+						Block: tfschema.Block{
+							Attributes: map[string]*tfschema.Attribute{
+								"port": {Type: cty.Number, Required: true},
+							},
+						},
+					},
+				},
+			}),
+			"test_other": NewManagedResourceTypeSchema(0, &tfschema.Block{}),
+		},
+		DataResourceTypes: map[string]*DataResourceTypeSchema{
+			"test_data": NewDataResourceTypeSchema(&tfschema.Block{
+				Attributes: map[string]*tfschema.Attribute{
+					"name": {Type: cty.String, Required: true},
+				},
+			}),
+		},
+	}
+}
+
+func TestSchemaFingerprint(t *testing.T) {
+	base := fingerprintTestSchema().Fingerprint()
+
+	// The fingerprint depends only on the schema, so it is the same for
+	// separately-built copies and across runs of the program.
+	for i := 0; i < 10; i++ {
+		if got := fingerprintTestSchema().Fingerprint(); got != base {
+			t.Fatalf("fingerprint changed between identical schemas: %s, then %s", base, got)
+		}
+	}
+	const want = "d6a4771172b3a5e849615f97c7e83a4e76ab32e3f6a2c115c11a647b91442a9c"
+	if base != want {
+		t.Errorf("wrong fingerprint %s; want %s", base, want)
+	}
+
+	changes := map[string]func(s *Schema){
+		"provider attribute added": func(s *Schema) {
+			s.ProviderConfig.Attributes["profile"] = &tfschema.Attribute{Type: cty.String, Optional: true}
+		},
+		"attribute type": func(s *Schema) {
+			s.ManagedResourceTypes["test_thing"].Content.Attributes["tags"].Type = cty.Map(cty.Number)
+		},
+		"object attribute type": func(s *Schema) {
+			s.ManagedResourceTypes["test_thing"].Content.Attributes["spec"].Type = cty.Object(map[string]cty.Type{"a": cty.Number, "c": cty.Bool})
+		},
+		"attribute flag": func(s *Schema) {
+			s.ProviderConfig.Attributes["token"].Sensitive = false
+		},
+		"attribute description": func(s *Schema) {
+			s.ProviderConfig.Attributes["region"].Description = "The AWS region."
+		},
+		"block nesting": func(s *Schema) {
+			s.ManagedResourceTypes["test_thing"].Content.BlockTypes["rule"].Nesting = tfschema.NestingSet
+		},
+		"nested attribute": func(s *Schema) {
+			s.ManagedResourceTypes["test_thing"].Content.BlockTypes["rule"].Block.Attributes["port"].Optional = true
+		},
+		"schema version": func(s *Schema) {
+			s.ManagedResourceTypes["test_thing"].Version = 2
+		},
+		"managed resource type removed": func(s *Schema) {
+			delete(s.ManagedResourceTypes, "test_other")
+		},
+		"managed resource type became data": func(s *Schema) {
+			delete(s.ManagedResourceTypes, "test_other")
+			s.DataResourceTypes["test_other"] = NewDataResourceTypeSchema(&tfschema.Block{})
+		},
+		"provider_meta added": func(s *Schema) {
+			s.ProviderMeta = &tfschema.Block{}
+		},
+	}
+	seen := map[string]string{base: "unchanged"}
+	for name, change := range changes {
+		s := fingerprintTestSchema()
+		change(s)
+		got := s.Fingerprint()
+		if other, ok := seen[got]; ok {
+			t.Errorf("%s has the same fingerprint as %s", name, other)
+		}
+		seen[got] = name
+	}
+}
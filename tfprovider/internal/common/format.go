@@ -0,0 +1,136 @@
+package common
+
+import (
+	"fmt"
+
+	"github.com/apparentlymart/terraform-schema-go/tfschema"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// Format represents one of the serialization formats that the provider
+// plugin protocol allows for dynamic values.
+type Format int
+
+const (
+	// FormatAny is the default, which accepts either serialization format.
+	FormatAny Format = iota
+
+	// FormatMsgpack represents the msgpack serialization format.
+	FormatMsgpack
+
+	// FormatJSON represents the JSON serialization format.
+	FormatJSON
+)
+
+func (f Format) String() string {
+	switch f {
+	case FormatAny:
+		return "any"
+	case FormatMsgpack:
+		return "msgpack"
+	case FormatJSON:
+		return "JSON"
+	default:
+		return fmt.Sprintf("Format(%d)", int(f))
+	}
+}
+
+// Format returns the format of the data, or FormatAny if the data is empty.
+//
+// If both formats are present then the result is FormatJSON, because that
+// is the format that DecodeDynamicValue prefers.
+func (data DynamicValueData) Format() Format {
+	switch {
+	case len(data.JSON) > 0:
+		return FormatJSON
+	case len(data.Msgpack) > 0:
+		return FormatMsgpack
+	default:
+		return FormatAny
+	}
+}
+
+// checkDecodeFormat returns error diagnostics if the given data isn't in
+// the format the options require.
+func (o *Options) checkDecodeFormat(data DynamicValueData) Diagnostics {
+	want := o.DecodeFormat
+	got := data.Format()
+	if want == FormatAny || got == FormatAny || got == want {
+		return nil
+	}
+	return Diagnostics{
+		{
+			Severity: Error,
+			Summary:  "Provider using unexpected response format",
+			Detail:   fmt.Sprintf("Provider's response is in %s format, but only %s is allowed.", got, want),
+		},
+	}
+}
+
+// EncodeDynamicValue is like the package-level EncodeDynamicValue but first
+// passes the value through the ValueSanitizer from the options, if any, and
+// uses the format from TypeEncodingOverrides for the given resource type
+// name, if any. The type name is empty for values that don't belong to a
+// resource type, such as the provider configuration.
+func (o *Options) EncodeDynamicValue(typeName string, val cty.Value, schema ImpliedTyper) (DynamicValueData, Diagnostics) {
+	if o.ValueSanitizer != nil {
+		val = o.ValueSanitizer(val, schemaBlock(schema))
+	}
+	format := FormatMsgpack
+	if override, ok := o.TypeEncodingOverrides[typeName]; ok && typeName != "" {
+		format = override
+	}
+	return encodeDynamicValue(val, schema, format)
+}
+
+// schemaBlock returns the block schema underlying the given schema, or nil
+// if it isn't one of the schema types that has a block.
+func schemaBlock(schema ImpliedTyper) *tfschema.Block {
+	switch schema := schema.(type) {
+	case *tfschema.Block:
+		return schema
+	case *ManagedResourceTypeSchema:
+		return schema.Content
+	case *DataResourceTypeSchema:
+		return schema.Content
+	default:
+		return nil
+	}
+}
+
+// DecodeDynamicValue is like the package-level DecodeDynamicValue but
+// also enforces the decoding rules from the options.
+func (o *Options) DecodeDynamicValue(data DynamicValueData, schema ImpliedTyper) (cty.Value, Diagnostics) {
+	if diags := o.checkDecodeFormat(data); diags.HasErrors() {
+		return cty.DynamicVal, diags
+	}
+	return o.unknownsAsNull(DecodeDynamicValue(data, schema))
+}
+
+// DecodeDynamicValueLegacy is like the package-level DecodeDynamicValueLegacy
+// but also enforces the decoding rules from the options.
+func (o *Options) DecodeDynamicValueLegacy(data DynamicValueData, schema ImpliedTyper) (cty.Value, Diagnostics) {
+	if diags := o.checkDecodeFormat(data); diags.HasErrors() {
+		return cty.DynamicVal, diags
+	}
+	return o.unknownsAsNull(DecodeDynamicValueLegacy(data, schema))
+}
+
+// unknownsAsNull applies UnknownsAsNull to the given decoded value if the
+// options enable UnknownAsNull and decoding succeeded.
+func (o *Options) unknownsAsNull(val cty.Value, diags Diagnostics) (cty.Value, Diagnostics) {
+	if !o.UnknownAsNull || diags.HasErrors() {
+		return val, diags
+	}
+	val, moreDiags := UnknownsAsNull(val)
+	return val, append(diags, moreDiags...)
+}
+
+// VisitDynamicValue is like the package-level VisitDynamicValue but first
+// checks that the data uses a format the options allow.
+func (o *Options) VisitDynamicValue(data DynamicValueData, schema ImpliedTyper, visit ValueVisitor) Diagnostics {
+	if diags := o.checkDecodeFormat(data); diags.HasErrors() {
+		return diags
+	}
+	return VisitDynamicValue(data, schema, visit)
+}
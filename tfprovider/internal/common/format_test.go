@@ -0,0 +1,64 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/apparentlymart/terraform-schema-go/tfschema"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// testBlock is a block schema with a single optional string attribute
+// "name", for tests that need a schema but don't care about its details.
+var testBlock = &tfschema.Block{
+	Attributes: map[string]*tfschema.Attribute{
+		"name": {Type: cty.String, Optional: true},
+	},
+}
+
+func TestOptionsDecodeDynamicValueFormat(t *testing.T) {
+	val := cty.ObjectVal(map[string]cty.Value{
+		"name": cty.StringVal("a"),
+	})
+	msgpackData, diags := encodeDynamicValue(val, testBlock, FormatMsgpack)
+	if diags.HasErrors() {
+		t.Fatal(diags.Err())
+	}
+	jsonData, diags := encodeDynamicValue(val, testBlock, FormatJSON)
+	if diags.HasErrors() {
+		t.Fatal(diags.Err())
+	}
+
+	tests := []struct {
+		allow   Format
+		data    DynamicValueData
+		wantErr bool
+	}{
+		{FormatAny, msgpackData, false},
+		{FormatAny, jsonData, false},
+		{FormatMsgpack, msgpackData, false},
+		{FormatMsgpack, jsonData, true},
+		{FormatJSON, jsonData, false},
+		{FormatJSON, msgpackData, true},
+	}
+	for _, test := range tests {
+		t.Run(test.allow.String()+" allows "+test.data.Format().String(), func(t *testing.T) {
+			o := &Options{DecodeFormat: test.allow}
+			got, diags := o.DecodeDynamicValue(test.data, testBlock)
+			if test.wantErr {
+				if !diags.HasErrors() {
+					t.Fatalf("decoding succeeded; want an error")
+				}
+				if got, want := diags[0].Summary, "Provider using unexpected response format"; got != want {
+					t.Errorf("wrong error summary %q; want %q", got, want)
+				}
+				return
+			}
+			if diags.HasErrors() {
+				t.Fatalf("unexpected errors: %s", diags.Err())
+			}
+			if !got.RawEquals(val) {
+				t.Errorf("wrong result %#v; want %#v", got, val)
+			}
+		})
+	}
+}
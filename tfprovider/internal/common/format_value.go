@@ -0,0 +1,136 @@
+package common
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/apparentlymart/terraform-schema-go/tfschema"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// FormatValue returns a human-readable, multi-line rendering of the given
+// object value, which must conform to the given schema, for use in logs and
+// debugging output.
+//
+// The value is rendered in a syntax resembling Terraform's own plan output,
+// with each attribute on its own line and nested blocks written as blocks.
+// The values of attributes the schema marks as sensitive are replaced by
+// "(sensitive)", and unknown values are shown as "(known after apply)".
+func FormatValue(val cty.Value, schema *tfschema.Block) string {
+	switch {
+	case !val.IsKnown():
+		return "(known after apply)"
+	case val.IsNull():
+		return "null"
+	}
+	var buf strings.Builder
+	formatBlock(&buf, val, schema, 0)
+	return buf.String()
+}
+
+func formatBlock(buf *strings.Builder, val cty.Value, schema *tfschema.Block, indent int) {
+	pad := strings.Repeat("  ", indent)
+	for _, name := range sortedAttributeNames(schema) {
+		attrS := schema.Attributes[name]
+		v := val.GetAttr(name)
+		fmt.Fprintf(buf, "%s%s = ", pad, name)
+		if attrS.Sensitive && !v.IsNull() {
+			buf.WriteString("(sensitive)")
+		} else {
+			formatAttrValue(buf, v, indent)
+		}
+		buf.WriteByte('\n')
+	}
+
+	for _, name := range sortedBlockTypeNames(schema) {
+		blockS := schema.BlockTypes[name]
+		v := val.GetAttr(name)
+		switch {
+		case !v.IsKnown():
+			fmt.Fprintf(buf, "%s%s (known after apply)\n", pad, name)
+			continue
+		case v.IsNull():
+			continue
+		}
+
+		switch blockS.Nesting {
+		case tfschema.NestingSingle, tfschema.NestingGroup:
+			formatNestedBlock(buf, name, v, &blockS.Block, indent)
+		case tfschema.NestingMap:
+			for it := v.ElementIterator(); it.Next(); {
+				key, ev := it.Element()
+				formatNestedBlock(buf, fmt.Sprintf("%s %q", name, key.AsString()), ev, &blockS.Block, indent)
+			}
+		default:
+			for it := v.ElementIterator(); it.Next(); {
+				_, ev := it.Element()
+				formatNestedBlock(buf, name, ev, &blockS.Block, indent)
+			}
+		}
+	}
+}
+
+func formatNestedBlock(buf *strings.Builder, header string, val cty.Value, schema *tfschema.Block, indent int) {
+	pad := strings.Repeat("  ", indent)
+	if !val.IsKnown() {
+		fmt.Fprintf(buf, "%s%s (known after apply)\n", pad, header)
+		return
+	}
+	fmt.Fprintf(buf, "%s%s {\n", pad, header)
+	formatBlock(buf, val, schema, indent+1)
+	fmt.Fprintf(buf, "%s}\n", pad)
+}
+
+func formatAttrValue(buf *strings.Builder, val cty.Value, indent int) {
+	switch {
+	case !val.IsKnown():
+		buf.WriteString("(known after apply)")
+		return
+	case val.IsNull():
+		buf.WriteString("null")
+		return
+	}
+
+	ty := val.Type()
+	pad := strings.Repeat("  ", indent)
+	switch {
+	case ty == cty.String:
+		fmt.Fprintf(buf, "%q", val.AsString())
+	case ty == cty.Number:
+		buf.WriteString(val.AsBigFloat().Text('f', -1))
+	case ty == cty.Bool:
+		fmt.Fprintf(buf, "%t", val.True())
+	case ty.IsListType() || ty.IsSetType() || ty.IsTupleType():
+		if val.LengthInt() == 0 {
+			buf.WriteString("[]")
+			return
+		}
+		buf.WriteString("[\n")
+		for it := val.ElementIterator(); it.Next(); {
+			_, ev := it.Element()
+			fmt.Fprintf(buf, "%s  ", pad)
+			formatAttrValue(buf, ev, indent+1)
+			buf.WriteString(",\n")
+		}
+		fmt.Fprintf(buf, "%s]", pad)
+	case ty.IsMapType() || ty.IsObjectType():
+		if val.LengthInt() == 0 {
+			buf.WriteString("{}")
+			return
+		}
+		buf.WriteString("{\n")
+		for it := val.ElementIterator(); it.Next(); {
+			key, ev := it.Element()
+			if ty.IsMapType() {
+				fmt.Fprintf(buf, "%s  %q = ", pad, key.AsString())
+			} else {
+				fmt.Fprintf(buf, "%s  %s = ", pad, key.AsString())
+			}
+			formatAttrValue(buf, ev, indent+1)
+			buf.WriteByte('\n')
+		}
+		fmt.Fprintf(buf, "%s}", pad)
+	default:
+		buf.WriteString(val.GoString())
+	}
+}
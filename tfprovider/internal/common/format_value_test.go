@@ -0,0 +1,111 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/apparentlymart/terraform-schema-go/tfschema"
+	"github.com/zclconf/go-cty/cty"
+)
+
+func TestFormatValue(t *testing.T) {
+	schema := &tfschema.Block{
+		Attributes: map[string]*tfschema.Attribute{
+			"id":       {Type: cty.String, Computed: true},
+			"name":     {Type: cty.String, Required: true},
+			"password": {Type: cty.String, Optional: true, Sensitive: true},
+			"token":    {Type: cty.String, Optional: true, Sensitive: true},
+			"size":     {Type: cty.Number, Optional: true},
+			"tags":     {Type: cty.Map(cty.String), Optional: true},
+			"zones":    {Type: cty.List(cty.String), Optional: true},
+		},
+		BlockTypes: map[string]*tfschema.NestedBlock{
+			"rule": {
+				Nesting: tfschema.NestingList,
+				Block: tfschema.Block{
+					Attributes: map[string]*tfschema.Attribute{
+						"port":    {Type: cty.Number, Required: true},
+						"enabled": {Type: cty.Bool, Optional: true},
+					},
+				},
+			},
+			"settings": {
+				Nesting: tfschema.NestingSingle,
+				Block: tfschema.Block{
+					Attributes: map[string]*tfschema.Attribute{
+						"mode": {Type: cty.String, Optional: true},
+					},
+				},
+			},
+			"endpoint": {
+				Nesting: tfschema.NestingMap,
+				Block: tfschema.Block{
+					Attributes: map[string]*tfschema.Attribute{
+						"url": {Type: cty.String, Required: true},
+					},
+				},
+			},
+		},
+	}
+	ty := schema.ImpliedType()
+	ruleTy := schema.BlockTypes["rule"].Block.ImpliedType()
+	val := cty.ObjectVal(map[string]cty.Value{
+		"id":       cty.UnknownVal(cty.String),
+		"name":     cty.StringVal("example"),
+		"password": cty.StringVal("hunter2"),
+		"token":    cty.NullVal(cty.String),
+		"size":     cty.NumberFloatVal(1.5),
+		"tags": cty.MapVal(map[string]cty.Value{
+			"Name": cty.StringVal("example"),
+		}),
+		"zones": cty.ListVal([]cty.Value{
+			cty.StringVal("a"),
+			cty.UnknownVal(cty.String),
+		}),
+		"rule": cty.ListVal([]cty.Value{
+			cty.ObjectVal(map[string]cty.Value{
+				"port":    cty.NumberIntVal(80),
+				"enabled": cty.True,
+			}),
+			cty.UnknownVal(ruleTy),
+		}),
+		"settings": cty.NullVal(ty.AttributeType("settings")),
+		"endpoint": cty.MapVal(map[string]cty.Value{
+			"api": cty.ObjectVal(map[string]cty.Value{
+				"url": cty.StringVal("https://example.com/"),
+			}),
+		}),
+	})
+
+	got := FormatValue(val, schema)
+	want := `id = (known after apply)
+name = "example"
+password = (sensitive)
+size = 1.5
+tags = {
+  "Name" = "example"
+}
+token = null
+zones = [
+  "a",
+  (known after apply),
+]
+endpoint "api" {
+  url = "https://example.com/"
+}
+rule {
+  enabled = true
+  port = 80
+}
+rule (known after apply)
+`
+	if got != want {
+		t.Errorf("wrong result\ngot:\n%s\nwant:\n%s", got, want)
+	}
+
+	if got, want := FormatValue(cty.NullVal(ty), schema), "null"; got != want {
+		t.Errorf("wrong result for null %q; want %q", got, want)
+	}
+	if got, want := FormatValue(cty.UnknownVal(ty), schema), "(known after apply)"; got != want {
+		t.Errorf("wrong result for unknown %q; want %q", got, want)
+	}
+}
@@ -0,0 +1,415 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/apparentlymart/terraform-schema-go/tfschema"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/convert"
+	"github.com/zclconf/go-cty/cty/gocty"
+)
+
+// ObjectFromGo converts the given Go map, such as one produced by decoding
+// JSON into an interface{}, into an object value conforming to the given
+// schema, descending into nested blocks and collections as needed.
+//
+// Nested maps must be map[string]interface{} and nested sequences must be
+// []interface{}. Leaf values may be strings, bools, any of Go's numeric
+// types, json.Number, nil for a null value, or cty.Value. A nested block
+// may also be given as a cty.Value: an object or map for a single block or
+// for a map of blocks, or a list, set, or tuple for a list or set of blocks,
+// whose elements are then completed in the same way. A null value means
+// that the block is absent, and an unknown value produces an unknown
+// result. Absent attributes are null and absent nested blocks are empty, as
+// for tfschema's EmptyValue methods.
+//
+// Each value that cannot be converted, and each map key that doesn't match
+// an attribute or nested block, is reported as an error diagnostic with the
+// path of the problematic value.
+func ObjectFromGo(data map[string]interface{}, schema *tfschema.Block) (cty.Value, Diagnostics) {
+	if schema == nil {
+		schema = &tfschema.Block{}
+	}
+	val, diags := blockFromGo(data, schema, nil)
+	if diags.HasErrors() {
+		return cty.UnknownVal(schema.ImpliedType()), diags
+	}
+	return val, diags
+}
+
+func blockFromGo(data map[string]interface{}, schema *tfschema.Block, path cty.Path) (cty.Value, Diagnostics) {
+	var diags Diagnostics
+	vals := make(map[string]cty.Value, len(schema.Attributes)+len(schema.BlockTypes))
+
+	for name, attrS := range schema.Attributes {
+		raw, ok := data[name]
+		if !ok {
+			vals[name] = cty.NullVal(attrS.Type)
+			continue
+		}
+		v, moreDiags := valueFromGo(raw, attrS.Type, path.GetAttr(name))
+		diags = append(diags, moreDiags...)
+		vals[name] = v
+	}
+
+	for name, blockS := range schema.BlockTypes {
+		raw, ok := data[name]
+		if !ok || raw == nil {
+			vals[name] = blockS.EmptyValue()
+			continue
+		}
+		v, moreDiags := nestedBlockFromGo(raw, blockS, path.GetAttr(name))
+		diags = append(diags, moreDiags...)
+		vals[name] = v
+	}
+
+	// We sort the unexpected keys only so that the diagnostics are
+	// returned in a consistent order.
+	var unexpected []string
+	for name := range data {
+		if _, ok := vals[name]; !ok {
+			unexpected = append(unexpected, name)
+		}
+	}
+	sort.Strings(unexpected)
+	for _, name := range unexpected {
+		diags = append(diags, fromGoDiagnostic(path.GetAttr(name), "Unsupported argument", fmt.Sprintf("The schema has no attribute or block type named %q.", name)))
+	}
+
+	if diags.HasErrors() {
+		return cty.UnknownVal(schema.ImpliedType()), diags
+	}
+	return cty.ObjectVal(vals), diags
+}
+
+func nestedBlockFromGo(raw interface{}, blockS *tfschema.NestedBlock, path cty.Path) (cty.Value, Diagnostics) {
+	ty := blockS.Block.ImpliedType()
+	if v, ok := raw.(cty.Value); ok {
+		switch {
+		case !v.IsKnown():
+			return cty.UnknownVal(blockS.EmptyValue().Type()), nil
+		case v.IsNull():
+			return blockS.EmptyValue(), nil
+		}
+	}
+
+	switch blockS.Nesting {
+	case tfschema.NestingSingle, tfschema.NestingGroup:
+		data, ok := mapFromGo(raw)
+		if !ok {
+			return cty.UnknownVal(ty), Diagnostics{fromGoTypeDiagnostic(path, "a map", raw)}
+		}
+		return blockFromGo(data, &blockS.Block, path)
+
+	case tfschema.NestingList, tfschema.NestingSet:
+		raws, ok := sliceFromGo(raw)
+		if !ok {
+			return cty.UnknownVal(cty.DynamicPseudoType), Diagnostics{fromGoTypeDiagnostic(path, "a slice", raw)}
+		}
+		if len(raws) == 0 {
+			return blockS.EmptyValue(), nil
+		}
+		var diags Diagnostics
+		elems := make([]cty.Value, 0, len(raws))
+		for i, raw := range raws {
+			ePath := path.Index(cty.NumberIntVal(int64(i)))
+			data, ok := mapFromGo(raw)
+			if !ok {
+				diags = append(diags, fromGoTypeDiagnostic(ePath, "a map", raw))
+				continue
+			}
+			v, moreDiags := blockFromGo(data, &blockS.Block, ePath)
+			diags = append(diags, moreDiags...)
+			elems = append(elems, v)
+		}
+		if diags.HasErrors() {
+			return cty.UnknownVal(cty.DynamicPseudoType), diags
+		}
+		switch {
+		case blockS.Nesting == tfschema.NestingSet:
+			return cty.SetVal(elems), diags
+		case ty.HasDynamicTypes():
+			return cty.TupleVal(elems), diags
+		default:
+			return cty.ListVal(elems), diags
+		}
+
+	case tfschema.NestingMap:
+		raws, ok := mapFromGo(raw)
+		if !ok {
+			return cty.UnknownVal(cty.DynamicPseudoType), Diagnostics{fromGoTypeDiagnostic(path, "a map", raw)}
+		}
+		if len(raws) == 0 {
+			return blockS.EmptyValue(), nil
+		}
+		var diags Diagnostics
+		elems := make(map[string]cty.Value, len(raws))
+		for k, raw := range raws {
+			ePath := path.Index(cty.StringVal(k))
+			data, ok := mapFromGo(raw)
+			if !ok {
+				diags = append(diags, fromGoTypeDiagnostic(ePath, "a map", raw))
+				continue
+			}
+			v, moreDiags := blockFromGo(data, &blockS.Block, ePath)
+			diags = append(diags, moreDiags...)
+			elems[k] = v
+		}
+		if diags.HasErrors() {
+			return cty.UnknownVal(cty.DynamicPseudoType), diags
+		}
+		if ty.HasDynamicTypes() {
+			return cty.ObjectVal(elems), diags
+		}
+		return cty.MapVal(elems), diags
+
+	default:
+		return cty.UnknownVal(cty.DynamicPseudoType), Diagnostics{fromGoDiagnostic(path, "Unsupported nesting mode", "The schema uses a nesting mode that this function doesn't support.")}
+	}
+}
+
+// mapFromGo returns the elements of the given value if it is either a
+// map[string]interface{} or a known, non-null cty object or map, whose
+// attributes or elements are then returned as cty.Value.
+func mapFromGo(raw interface{}) (map[string]interface{}, bool) {
+	switch raw := raw.(type) {
+	case map[string]interface{}:
+		return raw, true
+	case cty.Value:
+		ty := raw.Type()
+		if !(ty.IsObjectType() || ty.IsMapType()) || raw.IsNull() || !raw.IsKnown() {
+			return nil, false
+		}
+		ret := make(map[string]interface{}, raw.LengthInt())
+		for it := raw.ElementIterator(); it.Next(); {
+			k, v := it.Element()
+			ret[k.AsString()] = v
+		}
+		return ret, true
+	default:
+		return nil, false
+	}
+}
+
+// sliceFromGo is like mapFromGo but for []interface{} and for cty lists,
+// sets, and tuples.
+func sliceFromGo(raw interface{}) ([]interface{}, bool) {
+	switch raw := raw.(type) {
+	case []interface{}:
+		return raw, true
+	case cty.Value:
+		ty := raw.Type()
+		if !(ty.IsListType() || ty.IsSetType() || ty.IsTupleType()) || raw.IsNull() || !raw.IsKnown() {
+			return nil, false
+		}
+		ret := make([]interface{}, 0, raw.LengthInt())
+		for it := raw.ElementIterator(); it.Next(); {
+			_, v := it.Element()
+			ret = append(ret, v)
+		}
+		return ret, true
+	default:
+		return nil, false
+	}
+}
+
+// valueFromGo converts a Go value into a value of the given type, which may
+// be cty.DynamicPseudoType to infer a type from the Go value.
+func valueFromGo(raw interface{}, ty cty.Type, path cty.Path) (cty.Value, Diagnostics) {
+	if raw == nil {
+		return cty.NullVal(ty), nil
+	}
+	if v, ok := raw.(cty.Value); ok {
+		return convertFromGo(v, ty, path)
+	}
+
+	switch {
+	case ty == cty.DynamicPseudoType:
+		return inferFromGo(raw, path)
+
+	case ty.IsPrimitiveType():
+		v, ok := primitiveFromGo(raw)
+		if !ok {
+			return cty.UnknownVal(ty), Diagnostics{fromGoTypeDiagnostic(path, ty.FriendlyName(), raw)}
+		}
+		return convertFromGo(v, ty, path)
+
+	case ty.IsListType() || ty.IsSetType():
+		raws, ok := raw.([]interface{})
+		if !ok {
+			return cty.UnknownVal(ty), Diagnostics{fromGoTypeDiagnostic(path, "a slice", raw)}
+		}
+		ety := ty.ElementType()
+		if len(raws) == 0 {
+			if ty.IsSetType() {
+				return cty.SetValEmpty(ety), nil
+			}
+			return cty.ListValEmpty(ety), nil
+		}
+		var diags Diagnostics
+		elems := make([]cty.Value, len(raws))
+		for i, raw := range raws {
+			v, moreDiags := valueFromGo(raw, ety, path.Index(cty.NumberIntVal(int64(i))))
+			diags = append(diags, moreDiags...)
+			elems[i] = v
+		}
+		if diags.HasErrors() {
+			return cty.UnknownVal(ty), diags
+		}
+		// We build a tuple and convert it so that, if the element type is
+		// dynamic, elements of differing types can be unified.
+		return convertFromGo(cty.TupleVal(elems), ty, path)
+
+	case ty.IsMapType():
+		raws, ok := raw.(map[string]interface{})
+		if !ok {
+			return cty.UnknownVal(ty), Diagnostics{fromGoTypeDiagnostic(path, "a map", raw)}
+		}
+		if len(raws) == 0 {
+			return cty.MapValEmpty(ty.ElementType()), nil
+		}
+		var diags Diagnostics
+		elems := make(map[string]cty.Value, len(raws))
+		for k, raw := range raws {
+			v, moreDiags := valueFromGo(raw, ty.ElementType(), path.Index(cty.StringVal(k)))
+			diags = append(diags, moreDiags...)
+			elems[k] = v
+		}
+		if diags.HasErrors() {
+			return cty.UnknownVal(ty), diags
+		}
+		return convertFromGo(cty.ObjectVal(elems), ty, path)
+
+	case ty.IsObjectType():
+		raws, ok := raw.(map[string]interface{})
+		if !ok {
+			return cty.UnknownVal(ty), Diagnostics{fromGoTypeDiagnostic(path, "a map", raw)}
+		}
+		var diags Diagnostics
+		atys := ty.AttributeTypes()
+		vals := make(map[string]cty.Value, len(atys))
+		for name, aty := range atys {
+			v, moreDiags := valueFromGo(raws[name], aty, path.GetAttr(name))
+			diags = append(diags, moreDiags...)
+			vals[name] = v
+		}
+		var unexpected []string
+		for name := range raws {
+			if _, ok := atys[name]; !ok {
+				unexpected = append(unexpected, name)
+			}
+		}
+		sort.Strings(unexpected)
+		for _, name := range unexpected {
+			diags = append(diags, fromGoDiagnostic(path.GetAttr(name), "Unsupported attribute", fmt.Sprintf("The object type has no attribute named %q.", name)))
+		}
+		if diags.HasErrors() {
+			return cty.UnknownVal(ty), diags
+		}
+		return cty.ObjectVal(vals), diags
+
+	case ty.IsTupleType():
+		raws, ok := raw.([]interface{})
+		if !ok {
+			return cty.UnknownVal(ty), Diagnostics{fromGoTypeDiagnostic(path, "a slice", raw)}
+		}
+		etys := ty.TupleElementTypes()
+		if len(raws) != len(etys) {
+			return cty.UnknownVal(ty), Diagnostics{fromGoDiagnostic(path, "Incorrect tuple length", fmt.Sprintf("Expected %d elements, but got %d.", len(etys), len(raws)))}
+		}
+		var diags Diagnostics
+		elems := make([]cty.Value, len(raws))
+		for i, raw := range raws {
+			v, moreDiags := valueFromGo(raw, etys[i], path.Index(cty.NumberIntVal(int64(i))))
+			diags = append(diags, moreDiags...)
+			elems[i] = v
+		}
+		if diags.HasErrors() {
+			return cty.UnknownVal(ty), diags
+		}
+		return cty.TupleVal(elems), diags
+
+	default:
+		return cty.UnknownVal(ty), Diagnostics{fromGoDiagnostic(path, "Unsupported type", fmt.Sprintf("Cannot convert a Go value to %s.", ty.FriendlyName()))}
+	}
+}
+
+// inferFromGo converts a Go value into a value of a type inferred from the
+// Go value, with maps becoming objects and slices becoming tuples.
+func inferFromGo(raw interface{}, path cty.Path) (cty.Value, Diagnostics) {
+	switch raw := raw.(type) {
+	case nil:
+		return cty.NullVal(cty.DynamicPseudoType), nil
+	case cty.Value:
+		return raw, nil
+	case map[string]interface{}:
+		var diags Diagnostics
+		vals := make(map[string]cty.Value, len(raw))
+		for k, raw := range raw {
+			v, moreDiags := inferFromGo(raw, path.GetAttr(k))
+			diags = append(diags, moreDiags...)
+			vals[k] = v
+		}
+		return cty.ObjectVal(vals), diags
+	case []interface{}:
+		var diags Diagnostics
+		elems := make([]cty.Value, len(raw))
+		for i, raw := range raw {
+			v, moreDiags := inferFromGo(raw, path.Index(cty.NumberIntVal(int64(i))))
+			diags = append(diags, moreDiags...)
+			elems[i] = v
+		}
+		return cty.TupleVal(elems), diags
+	default:
+		v, ok := primitiveFromGo(raw)
+		if !ok {
+			return cty.DynamicVal, Diagnostics{fromGoTypeDiagnostic(path, "a string, number, bool, map, or slice", raw)}
+		}
+		return v, nil
+	}
+}
+
+// primitiveFromGo returns the primitive value corresponding to the given Go
+// value, or false if it isn't of a supported primitive Go type.
+func primitiveFromGo(raw interface{}) (cty.Value, bool) {
+	switch raw := raw.(type) {
+	case string:
+		return cty.StringVal(raw), true
+	case bool:
+		return cty.BoolVal(raw), true
+	case json.Number:
+		v, err := cty.ParseNumberVal(string(raw))
+		return v, err == nil
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+		v, err := gocty.ToCtyValue(raw, cty.Number)
+		return v, err == nil
+	default:
+		return cty.NilVal, false
+	}
+}
+
+func convertFromGo(v cty.Value, ty cty.Type, path cty.Path) (cty.Value, Diagnostics) {
+	ret, err := convert.Convert(v, ty)
+	if err != nil {
+		return cty.UnknownVal(ty), Diagnostics{fromGoDiagnostic(path, "Incorrect value type", fmt.Sprintf("Invalid value: %s.", err))}
+	}
+	return ret, nil
+}
+
+func fromGoTypeDiagnostic(path cty.Path, want string, got interface{}) Diagnostic {
+	if v, ok := got.(cty.Value); ok {
+		return fromGoDiagnostic(path, "Incorrect value type", fmt.Sprintf("Expected %s, but got a value of type %s.", want, v.Type().FriendlyName()))
+	}
+	return fromGoDiagnostic(path, "Incorrect value type", fmt.Sprintf("Expected %s, but got a value of Go type %T.", want, got))
+}
+
+func fromGoDiagnostic(path cty.Path, summary, detail string) Diagnostic {
+	return Diagnostic{
+		Severity:  Error,
+		Summary:   summary,
+		Detail:    detail,
+		Attribute: path.Copy(),
+	}
+}
@@ -0,0 +1,196 @@
+package common
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/apparentlymart/terraform-schema-go/tfschema"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// fromGoTestSchema is a block schema with attributes of several types and
+// a nested block type for each nesting mode.
+var fromGoTestSchema = &tfschema.Block{
+	Attributes: map[string]*tfschema.Attribute{
+		"name":  {Type: cty.String, Required: true},
+		"count": {Type: cty.Number, Optional: true},
+		"zones": {Type: cty.List(cty.String), Optional: true},
+		"tags":  {Type: cty.Map(cty.String), Optional: true},
+		"extra": {Type: cty.DynamicPseudoType, Optional: true},
+	},
+	BlockTypes: map[string]*tfschema.NestedBlock{
+		"network": {
+			Nesting: tfschema.NestingSingle,
+			Block: tfschema.Block{
+				Attributes: map[string]*tfschema.Attribute{
+					"cidr": {Type: cty.String, Required: true},
+				},
+			},
+		},
+		"rule": {
+			Nesting: tfschema.NestingList,
+			Block: tfschema.Block{
+				Attributes: map[string]*tfschema.Attribute{
+					"port": {Type: cty.Number, Required: true},
+				},
+			},
+		},
+		"ingress": {
+			Nesting: tfschema.NestingSet,
+			Block: tfschema.Block{
+				Attributes: map[string]*tfschema.Attribute{
+					"from": {Type: cty.String, Required: true},
+				},
+			},
+		},
+		"disk": {
+			Nesting: tfschema.NestingMap,
+			Block: tfschema.Block{
+				Attributes: map[string]*tfschema.Attribute{
+					"size": {Type: cty.Number, Required: true},
+				},
+			},
+		},
+	},
+}
+
+func TestObjectFromGo(t *testing.T) {
+	src := `{
+		"name": "example",
+		"count": 2,
+		"zones": ["a", "b"],
+		"tags": {"Owner": "me"},
+		"extra": {"nested": [1, "two"]},
+		"network": {"cidr": "10.0.0.0/16"},
+		"rule": [{"port": 80}, {"port": 443}],
+		"ingress": [{"from": "a"}, {"from": "b"}],
+		"disk": {"root": {"size": 10}}
+	}`
+	dec := json.NewDecoder(strings.NewReader(src))
+	dec.UseNumber()
+	var data map[string]interface{}
+	if err := dec.Decode(&data); err != nil {
+		t.Fatal(err)
+	}
+
+	got, diags := ObjectFromGo(data, fromGoTestSchema)
+	if diags.HasErrors() {
+		t.Fatalf("unexpected errors: %s", diags.Err())
+	}
+	want := cty.ObjectVal(map[string]cty.Value{
+		"name":  cty.StringVal("example"),
+		"count": cty.NumberIntVal(2),
+		"zones": cty.ListVal([]cty.Value{cty.StringVal("a"), cty.StringVal("b")}),
+		"tags":  cty.MapVal(map[string]cty.Value{"Owner": cty.StringVal("me")}),
+		"extra": cty.ObjectVal(map[string]cty.Value{
+			"nested": cty.TupleVal([]cty.Value{cty.NumberIntVal(1), cty.StringVal("two")}),
+		}),
+		"network": cty.ObjectVal(map[string]cty.Value{
+			"cidr": cty.StringVal("10.0.0.0/16"),
+		}),
+		"rule": cty.ListVal([]cty.Value{
+			cty.ObjectVal(map[string]cty.Value{"port": cty.NumberIntVal(80)}),
+			cty.ObjectVal(map[string]cty.Value{"port": cty.NumberIntVal(443)}),
+		}),
+		"ingress": cty.SetVal([]cty.Value{
+			cty.ObjectVal(map[string]cty.Value{"from": cty.StringVal("a")}),
+			cty.ObjectVal(map[string]cty.Value{"from": cty.StringVal("b")}),
+		}),
+		"disk": cty.MapVal(map[string]cty.Value{
+			"root": cty.ObjectVal(map[string]cty.Value{"size": cty.NumberIntVal(10)}),
+		}),
+	})
+	if !got.RawEquals(want) {
+		t.Errorf("wrong result\ngot:  %#v\nwant: %#v", got, want)
+	}
+	// The result's type differs from the implied type only where the
+	// schema calls for cty.DynamicPseudoType.
+	if errs := got.Type().TestConformance(fromGoTestSchema.ImpliedType()); len(errs) != 0 {
+		t.Errorf("result does not conform to the schema: %s", errs[0])
+	}
+}
+
+func TestObjectFromGoDefaults(t *testing.T) {
+	got, diags := ObjectFromGo(map[string]interface{}{
+		"name": "example",
+	}, fromGoTestSchema)
+	if diags.HasErrors() {
+		t.Fatalf("unexpected errors: %s", diags.Err())
+	}
+	for name, attrS := range fromGoTestSchema.Attributes {
+		if name == "name" {
+			continue
+		}
+		if v := got.GetAttr(name); !v.RawEquals(cty.NullVal(attrS.Type)) {
+			t.Errorf("wrong value for absent attribute %q: %#v", name, v)
+		}
+	}
+	for name, blockS := range fromGoTestSchema.BlockTypes {
+		if v := got.GetAttr(name); !v.RawEquals(blockS.EmptyValue()) {
+			t.Errorf("wrong value for absent block %q: %#v", name, v)
+		}
+	}
+}
+
+func TestObjectFromGoCtyValues(t *testing.T) {
+	rules := cty.ListVal([]cty.Value{
+		cty.ObjectVal(map[string]cty.Value{"port": cty.NumberIntVal(22)}),
+	})
+	got, diags := ObjectFromGo(map[string]interface{}{
+		"name": cty.StringVal("example"),
+		"rule": rules,
+		"disk": cty.UnknownVal(cty.Map(cty.EmptyObject)),
+	}, fromGoTestSchema)
+	if diags.HasErrors() {
+		t.Fatalf("unexpected errors: %s", diags.Err())
+	}
+	if v := got.GetAttr("rule"); !v.RawEquals(rules) {
+		t.Errorf("wrong rule %#v; want %#v", v, rules)
+	}
+	if v := got.GetAttr("disk"); v.IsKnown() {
+		t.Errorf("disk is %#v; want unknown", v)
+	}
+}
+
+func TestObjectFromGoErrors(t *testing.T) {
+	got, diags := ObjectFromGo(map[string]interface{}{
+		"name":    "example",
+		"count":   "many",
+		"zones":   []interface{}{"a", []interface{}{}},
+		"network": []interface{}{},
+		"rule":    []interface{}{map[string]interface{}{"port": 80}, "443"},
+		"disk": map[string]interface{}{
+			"root": map[string]interface{}{"size": 10, "type": "ssd"},
+		},
+		"unknown": true,
+	}, fromGoTestSchema)
+	if !diags.HasErrors() {
+		t.Fatal("conversion succeeded; want errors")
+	}
+	if got.IsKnown() {
+		t.Errorf("result is %#v; want unknown", got)
+	}
+
+	wantPaths := map[string]bool{
+		"count":             false,
+		"zones[1]":          false,
+		"network":           false,
+		"rule[1]":           false,
+		`disk["root"].type`: false,
+		"unknown":           false,
+	}
+	for _, diag := range diags {
+		path := FormatPath(diag.Attribute)
+		if _, ok := wantPaths[path]; !ok {
+			t.Errorf("unexpected diagnostic at %s: %s", path, diag.Detail)
+			continue
+		}
+		wantPaths[path] = true
+	}
+	for path, found := range wantPaths {
+		if !found {
+			t.Errorf("no diagnostic at %s", path)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package common
+
+import (
+	"fmt"
+
+	"github.com/apparentlymart/terraform-schema-go/tfschema"
+	"github.com/hashicorp/hcl2/hcl"
+	"github.com/hashicorp/hcl2/hcl/hclsyntax"
+	"github.com/hashicorp/hcl2/hcldec"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// ParseConfigHCL parses the given source as the body of a block in HCL
+// native syntax and decodes it using the given schema, producing a value
+// conforming to the schema's implied type.
+//
+// Arguments or nested blocks not declared in the schema, missing required
+// arguments, and values that cannot be converted to the declared attribute
+// types are all reported as error diagnostics. Expressions in the source
+// are evaluated without any variables or functions available.
+func ParseConfigHCL(src []byte, schema *tfschema.Block) (cty.Value, Diagnostics) {
+	ty := schema.ImpliedType()
+
+	f, hclDiags := hclsyntax.ParseConfig(src, "config.hcl", hcl.Pos{Line: 1, Column: 1, Byte: 0})
+	diags := hclDiagnostics(hclDiags)
+	if hclDiags.HasErrors() {
+		return cty.UnknownVal(ty), diags
+	}
+
+	val, hclDiags := hcldec.Decode(f.Body, schema.DecoderSpec(), nil)
+	diags = append(diags, hclDiagnostics(hclDiags)...)
+	if hclDiags.HasErrors() {
+		return cty.UnknownVal(ty), diags
+	}
+	return val, diags
+}
+
+// hclDiagnostics converts HCL diagnostics into our own diagnostics type,
+// appending the source location of each to its detail message.
+func hclDiagnostics(hclDiags hcl.Diagnostics) Diagnostics {
+	if len(hclDiags) == 0 {
+		return nil
+	}
+	diags := make(Diagnostics, 0, len(hclDiags))
+	for _, hclDiag := range hclDiags {
+		diag := Diagnostic{
+			Severity: Error,
+			Summary:  hclDiag.Summary,
+			Detail:   hclDiag.Detail,
+		}
+		if hclDiag.Severity == hcl.DiagWarning {
+			diag.Severity = Warning
+		}
+		if rng := hclDiag.Subject; rng != nil {
+			diag.Detail = fmt.Sprintf("%s (on %s line %d)", diag.Detail, rng.Filename, rng.Start.Line)
+		}
+		diags = append(diags, diag)
+	}
+	return diags
+}
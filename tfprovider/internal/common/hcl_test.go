@@ -0,0 +1,92 @@
+package common
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/apparentlymart/terraform-schema-go/tfschema"
+	"github.com/zclconf/go-cty/cty"
+)
+
+func TestParseConfigHCL(t *testing.T) {
+	schema := &tfschema.Block{
+		Attributes: map[string]*tfschema.Attribute{
+			"region":  {Type: cty.String, Required: true},
+			"retries": {Type: cty.Number, Optional: true},
+		},
+		BlockTypes: map[string]*tfschema.NestedBlock{
+			"assume_role": {
+				Nesting: tfschema.NestingList,
+				Block: tfschema.Block{
+					Attributes: map[string]*tfschema.Attribute{
+						"role_arn": {Type: cty.String, Required: true},
+					},
+				},
+			},
+		},
+	}
+
+	t.Run("valid", func(t *testing.T) {
+		src := `
+region  = "us-west-2"
+retries = "3"
+
+assume_role {
+  role_arn = "arn:aws:iam::123456789012:role/a"
+}
+`
+		got, diags := ParseConfigHCL([]byte(src), schema)
+		if diags.HasErrors() {
+			t.Fatalf("unexpected errors: %s", diags.Err())
+		}
+		want := cty.ObjectVal(map[string]cty.Value{
+			"region": cty.StringVal("us-west-2"),
+			// The string is converted to the attribute's declared type.
+			"retries": cty.NumberIntVal(3),
+			"assume_role": cty.ListVal([]cty.Value{
+				cty.ObjectVal(map[string]cty.Value{
+					"role_arn": cty.StringVal("arn:aws:iam::123456789012:role/a"),
+				}),
+			}),
+		})
+		if !got.RawEquals(want) {
+			t.Errorf("wrong result\ngot:  %#v\nwant: %#v", got, want)
+		}
+	})
+
+	errTests := map[string]struct {
+		src     string
+		wantErr string
+	}{
+		"syntax error": {
+			src:     `region = `,
+			wantErr: "line 1",
+		},
+		"missing required argument": {
+			src:     `retries = 1`,
+			wantErr: `The argument "region" is required`,
+		},
+		"unsupported argument": {
+			src:     "region = \"a\"\nprofile = \"b\"",
+			wantErr: `An argument named "profile" is not expected here`,
+		},
+		"wrong type": {
+			src:     "region = \"a\"\nretries = \"many\"",
+			wantErr: "a number is required",
+		},
+	}
+	for name, test := range errTests {
+		t.Run(name, func(t *testing.T) {
+			got, diags := ParseConfigHCL([]byte(test.src), schema)
+			if !diags.HasErrors() {
+				t.Fatalf("parsing succeeded; want an error")
+			}
+			if msg := diags.Err().Error(); !strings.Contains(msg, test.wantErr) {
+				t.Errorf("wrong error %q; want it to contain %q", msg, test.wantErr)
+			}
+			if got.IsKnown() || !got.Type().Equals(schema.ImpliedType()) {
+				t.Errorf("wrong result %#v; want an unknown value of the schema's type", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,34 @@
+package common
+
+import (
+	"context"
+)
+
+// Hooks is implemented by callers that wish to be notified before and after
+// each plan and apply operation on a managed resource type, such as to
+// report progress in a user interface.
+//
+// The hook methods are called synchronously on the goroutine making the
+// request, so implementations should return promptly. Embed NoopHooks in an
+// implementation to implement only some of the methods.
+type Hooks interface {
+	PrePlan(ctx context.Context, typeName string, req ManagedResourcePlanRequest)
+	PostPlan(ctx context.Context, typeName string, req ManagedResourcePlanRequest, resp ManagedResourcePlanResponse, diags Diagnostics)
+	PreApply(ctx context.Context, typeName string, req ManagedResourceApplyRequest)
+	PostApply(ctx context.Context, typeName string, req ManagedResourceApplyRequest, resp ManagedResourceApplyResponse, diags Diagnostics)
+}
+
+// NoopHooks is an implementation of Hooks whose methods do nothing.
+type NoopHooks struct{}
+
+var _ Hooks = NoopHooks{}
+
+func (NoopHooks) PrePlan(context.Context, string, ManagedResourcePlanRequest) {}
+
+func (NoopHooks) PostPlan(context.Context, string, ManagedResourcePlanRequest, ManagedResourcePlanResponse, Diagnostics) {
+}
+
+func (NoopHooks) PreApply(context.Context, string, ManagedResourceApplyRequest) {}
+
+func (NoopHooks) PostApply(context.Context, string, ManagedResourceApplyRequest, ManagedResourceApplyResponse, Diagnostics) {
+}
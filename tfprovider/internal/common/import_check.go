@@ -0,0 +1,66 @@
+package common
+
+import (
+	"fmt"
+
+	"github.com/apparentlymart/terraform-schema-go/tfschema"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// CheckImportedState checks that the given state, returned by a provider
+// when importing an object of a managed resource type with the given
+// schema, has a value for each of its computed attributes, returning an
+// error diagnostic naming each one that is null. Unknown values are
+// accepted.
+//
+// Nested blocks are checked recursively. Problems within elements of set
+// blocks are reported with paths that skip over the element, because set
+// elements have no path of their own.
+func CheckImportedState(state cty.Value, schema *tfschema.Block) Diagnostics {
+	return checkImportedBlock(nil, state, schema)
+}
+
+func checkImportedBlock(path cty.Path, val cty.Value, schema *tfschema.Block) Diagnostics {
+	if val.IsNull() || !val.IsKnown() {
+		return nil
+	}
+
+	var diags Diagnostics
+	for _, name := range sortedAttributeNames(schema) {
+		if !schema.Attributes[name].Computed || !val.GetAttr(name).IsNull() {
+			continue
+		}
+		attrPath := append(path.Copy(), cty.GetAttrStep{Name: name})
+		diags = append(diags, Diagnostic{
+			Severity:  Error,
+			Summary:   "Incomplete imported state",
+			Detail:    fmt.Sprintf("The provider returned an imported state with no value for the computed attribute %s. This is a bug in the provider, which should be reported in the provider's own issue tracker.", FormatPath(attrPath)),
+			Attribute: attrPath,
+		})
+	}
+
+	for _, name := range sortedBlockTypeNames(schema) {
+		blockS := schema.BlockTypes[name]
+		blockPath := append(path.Copy(), cty.GetAttrStep{Name: name})
+		blockVal := val.GetAttr(name)
+		if blockVal.IsNull() || !blockVal.IsKnown() {
+			continue
+		}
+
+		switch blockS.Nesting {
+		case tfschema.NestingSingle, tfschema.NestingGroup:
+			diags = append(diags, checkImportedBlock(blockPath, blockVal, &blockS.Block)...)
+		case tfschema.NestingList, tfschema.NestingMap:
+			for it := blockVal.ElementIterator(); it.Next(); {
+				key, elem := it.Element()
+				diags = append(diags, checkImportedBlock(append(blockPath.Copy(), cty.IndexStep{Key: key}), elem, &blockS.Block)...)
+			}
+		case tfschema.NestingSet:
+			for it := blockVal.ElementIterator(); it.Next(); {
+				_, elem := it.Element()
+				diags = append(diags, checkImportedBlock(blockPath, elem, &blockS.Block)...)
+			}
+		}
+	}
+	return diags
+}
@@ -0,0 +1,54 @@
+package common
+
+import (
+	"context"
+
+	"github.com/golang/protobuf/proto"
+)
+
+// RPCInvoker is the signature of the function an RPCInterceptor calls to
+// continue an RPC call, either to the next interceptor or to the plugin
+// itself. The invoker populates resp from the provider's response.
+type RPCInvoker func(ctx context.Context, req, resp proto.Message) error
+
+// RPCInterceptor is a function that wraps a single RPC call to a provider
+// plugin, in a similar manner to a gRPC unary client interceptor.
+//
+// The method argument is the name of the RPC method as declared in the
+// protocol's service definition, such as "ReadResource". An interceptor
+// may answer the call itself by populating resp and returning without
+// calling invoke.
+type RPCInterceptor func(ctx context.Context, method string, req, resp proto.Message, invoke RPCInvoker) error
+
+// ChainRPCInterceptors combines the given interceptors into a single
+// interceptor, with the first element being the outermost.
+func ChainRPCInterceptors(interceptors ...RPCInterceptor) RPCInterceptor {
+	return func(ctx context.Context, method string, req, resp proto.Message, invoke RPCInvoker) error {
+		return chainRPCInterceptors(interceptors, method, invoke)(ctx, req, resp)
+	}
+}
+
+func chainRPCInterceptors(interceptors []RPCInterceptor, method string, invoke RPCInvoker) RPCInvoker {
+	if len(interceptors) == 0 {
+		return invoke
+	}
+	next := chainRPCInterceptors(interceptors[1:], method, invoke)
+	return func(ctx context.Context, req, resp proto.Message) error {
+		return interceptors[0](ctx, method, req, resp, next)
+	}
+}
+
+// typeNamer is implemented by all of the protocol request messages that
+// relate to a specific resource type.
+type typeNamer interface {
+	GetTypeName() string
+}
+
+// RequestTypeName returns the resource type name that the given RPC request
+// relates to, or an empty string if the request is not type-specific.
+func RequestTypeName(req proto.Message) string {
+	if tn, ok := req.(typeNamer); ok {
+		return tn.GetTypeName()
+	}
+	return ""
+}
@@ -0,0 +1,84 @@
+package common
+
+import (
+	"sync"
+
+	"github.com/zclconf/go-cty/cty"
+)
+
+// InternTable is a table of previously-seen attribute types and description
+// strings that can be shared between the schemas of many providers, so that
+// identical values are retained in memory only once.
+//
+// An InternTable is safe for concurrent use. A nil *InternTable is valid and
+// interns nothing, returning its arguments unchanged.
+type InternTable struct {
+	mu      sync.Mutex
+	limit   int
+	types   map[string]cty.Type
+	strings map[string]string
+}
+
+// NewInternTable creates a new, empty intern table that will hold at most
+// the given number of entries. Once the table is full, values not already
+// in it are returned unchanged rather than being added. A limit of zero or
+// less means that the table is unbounded.
+func NewInternTable(limit int) *InternTable {
+	return &InternTable{
+		limit:   limit,
+		types:   make(map[string]cty.Type),
+		strings: make(map[string]string),
+	}
+}
+
+// Type returns a previously-interned type for the given key, which should be
+// the type's JSON serialization, or interns and returns the given type if
+// there is no such entry yet.
+func (t *InternTable) Type(key string, ty cty.Type) cty.Type {
+	if t == nil {
+		return ty
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if existing, ok := t.types[key]; ok {
+		return existing
+	}
+	if t.full() {
+		return ty
+	}
+	t.types[key] = ty
+	return ty
+}
+
+// String returns a previously-interned string equal to the given string, or
+// interns and returns the given string if there is no such entry yet.
+func (t *InternTable) String(s string) string {
+	if t == nil || s == "" {
+		return s
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if existing, ok := t.strings[s]; ok {
+		return existing
+	}
+	if t.full() {
+		return s
+	}
+	t.strings[s] = s
+	return s
+}
+
+// Len returns the total number of types and strings currently interned.
+func (t *InternTable) Len() int {
+	if t == nil {
+		return 0
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.types) + len(t.strings)
+}
+
+// full must be called only while holding t.mu.
+func (t *InternTable) full() bool {
+	return t.limit > 0 && len(t.types)+len(t.strings) >= t.limit
+}
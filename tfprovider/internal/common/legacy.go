@@ -0,0 +1,167 @@
+package common
+
+import (
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/convert"
+	"github.com/zclconf/go-cty/cty/json"
+	"github.com/zclconf/go-cty/cty/msgpack"
+)
+
+// DecodeDynamicValueLegacy is like DecodeDynamicValue but, if strict decoding
+// fails, falls back on a lenient decoding that tolerates the kinds of
+// inconsistencies produced by providers using the legacy SDK type system,
+// such as missing optional attributes. A successful lenient decoding
+// produces a warning rather than an error.
+//
+// Use this only when the provider has explicitly indicated that it uses the
+// legacy type system, because the lenient decoding can silently discard
+// information that doesn't fit the schema.
+func DecodeDynamicValueLegacy(data DynamicValueData, schema ImpliedTyper) (cty.Value, Diagnostics) {
+	val, diags := DecodeDynamicValue(data, schema)
+	if !diags.HasErrors() {
+		return val, diags
+	}
+
+	ty := schema.ImpliedType()
+	var raw cty.Value
+	var err error
+	switch {
+	case len(data.JSON) > 0:
+		var impliedTy cty.Type
+		impliedTy, err = json.ImpliedType(data.JSON)
+		if err == nil {
+			raw, err = json.Unmarshal(data.JSON, impliedTy)
+		}
+	case len(data.Msgpack) > 0:
+		var impliedTy cty.Type
+		impliedTy, err = msgpack.ImpliedType(data.Msgpack)
+		if err == nil {
+			raw, err = msgpack.Unmarshal(data.Msgpack, impliedTy)
+		}
+	default:
+		// The strict decoder already reported that there's no value at all.
+		return val, diags
+	}
+	if err == nil {
+		val, err = conformLeniently(raw, ty, nil)
+	}
+	if err != nil {
+		// If lenient decoding fails too then the original diagnostics
+		// from the strict decoder are the most helpful thing to return.
+		return cty.DynamicVal, diags
+	}
+
+	return val, Diagnostics{
+		{
+			Severity: Warning,
+			Summary:  "Provider returned inconsistent object",
+			Detail:   "The provider uses the legacy SDK type system and returned an object that does not conform to its schema, so it was decoded leniently: " + diags[0].Detail,
+		},
+	}
+}
+
+// conformLeniently converts a value decoded using its implied type into a
+// value of the given type, filling in nulls for missing object attributes
+// and ignoring any unexpected ones.
+func conformLeniently(val cty.Value, ty cty.Type, path cty.Path) (cty.Value, error) {
+	switch {
+	case !val.IsKnown():
+		return cty.UnknownVal(ty), nil
+	case val.IsNull():
+		return cty.NullVal(ty), nil
+	case ty == cty.DynamicPseudoType:
+		return val, nil
+	}
+
+	vty := val.Type()
+	switch {
+	case ty.IsObjectType():
+		if !vty.IsObjectType() && !vty.IsMapType() {
+			return cty.NilVal, path.NewErrorf("object is required")
+		}
+		atys := ty.AttributeTypes()
+		if len(atys) == 0 {
+			return cty.EmptyObjectVal, nil
+		}
+		vals := make(map[string]cty.Value, len(atys))
+		for name, aty := range atys {
+			var av cty.Value
+			switch {
+			case vty.IsObjectType() && vty.HasAttribute(name):
+				av = val.GetAttr(name)
+			case vty.IsMapType() && val.HasIndex(cty.StringVal(name)).True():
+				av = val.Index(cty.StringVal(name))
+			default:
+				vals[name] = cty.NullVal(aty)
+				continue
+			}
+			av, err := conformLeniently(av, aty, path.GetAttr(name))
+			if err != nil {
+				return cty.NilVal, err
+			}
+			vals[name] = av
+		}
+		return cty.ObjectVal(vals), nil
+
+	case ty.IsTupleType():
+		etys := ty.TupleElementTypes()
+		if !vty.IsTupleType() && !vty.IsListType() || val.LengthInt() != len(etys) {
+			return cty.NilVal, path.NewErrorf("tuple with %d elements is required", len(etys))
+		}
+		if len(etys) == 0 {
+			return cty.EmptyTupleVal, nil
+		}
+		vals := make([]cty.Value, 0, len(etys))
+		for it := val.ElementIterator(); it.Next(); {
+			k, ev := it.Element()
+			i := len(vals)
+			ev, err := conformLeniently(ev, etys[i], path.Index(k))
+			if err != nil {
+				return cty.NilVal, err
+			}
+			vals = append(vals, ev)
+		}
+		return cty.TupleVal(vals), nil
+
+	case ty.IsListType() || ty.IsSetType() || ty.IsMapType():
+		ety := ty.ElementType()
+		var vals []cty.Value
+		var mapVals map[string]cty.Value
+		if ty.IsMapType() {
+			mapVals = make(map[string]cty.Value)
+		}
+		for it := val.ElementIterator(); it.Next(); {
+			k, ev := it.Element()
+			ev, err := conformLeniently(ev, ety, path.Index(k))
+			if err != nil {
+				return cty.NilVal, err
+			}
+			if mapVals != nil {
+				mapVals[k.AsString()] = ev
+			} else {
+				vals = append(vals, ev)
+			}
+		}
+		switch {
+		case ty.IsMapType() && len(mapVals) == 0:
+			return cty.MapValEmpty(ety), nil
+		case ty.IsMapType():
+			return cty.MapVal(mapVals), nil
+		case len(vals) == 0 && ty.IsListType():
+			return cty.ListValEmpty(ety), nil
+		case len(vals) == 0:
+			return cty.SetValEmpty(ety), nil
+		case ty.IsListType():
+			return cty.ListVal(vals), nil
+		default:
+			return cty.SetVal(vals), nil
+		}
+
+	default:
+		ret, err := convert.Convert(val, ty)
+		if err != nil {
+			return cty.NilVal, path.NewErrorf("%s", err)
+		}
+		return ret, nil
+	}
+}
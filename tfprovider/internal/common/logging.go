@@ -0,0 +1,42 @@
+package common
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+)
+
+// LogDebug emits a debug-level record with the given message and attributes
+// to the logger from the options, or does nothing if there is no logger.
+func (o *Options) LogDebug(ctx context.Context, msg string, args ...interface{}) {
+	if o.Logger == nil {
+		return
+	}
+	o.Logger.DebugContext(ctx, msg, args...)
+}
+
+// LoggingInterceptor returns an RPCInterceptor that emits a debug-level
+// record to the given logger for each RPC call, recording the method, the
+// resource type name if the request has one, the time the call took, and
+// the error the call failed with, if any.
+func LoggingInterceptor(logger *slog.Logger) RPCInterceptor {
+	return func(ctx context.Context, method string, req, resp proto.Message, invoke RPCInvoker) error {
+		start := time.Now()
+		err := invoke(ctx, req, resp)
+
+		attrs := []slog.Attr{
+			slog.String("method", method),
+			slog.Duration("duration", time.Since(start)),
+		}
+		if typeName := RequestTypeName(req); typeName != "" {
+			attrs = append(attrs, slog.String("type_name", typeName))
+		}
+		if err != nil {
+			attrs = append(attrs, slog.String("error", err.Error()))
+		}
+		logger.LogAttrs(ctx, slog.LevelDebug, "provider RPC call", attrs...)
+		return err
+	}
+}
@@ -0,0 +1,53 @@
+package common
+
+import (
+	"context"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+)
+
+// MetricsCollector receives measurements of the RPC calls made to a provider
+// plugin, so that they can be exported to a monitoring system. The method
+// argument is the RPC method name and typeName is the resource type name
+// from the request, or an empty string if the request has none.
+//
+// A collector may be called concurrently from many goroutines.
+type MetricsCollector interface {
+	// IncRPC is called once for each RPC call, before it is made.
+	IncRPC(method, typeName string)
+
+	// ObserveDuration is called once for each RPC call, after it completes,
+	// with the time the call took.
+	ObserveDuration(method, typeName string, d time.Duration)
+
+	// IncError is called for each RPC call that fails with an error. It is
+	// not called for calls that succeed but return error diagnostics.
+	IncError(method, typeName string)
+}
+
+// NoopMetricsCollector is a MetricsCollector that discards all measurements.
+type NoopMetricsCollector struct{}
+
+func (NoopMetricsCollector) IncRPC(method, typeName string)                           {}
+func (NoopMetricsCollector) ObserveDuration(method, typeName string, d time.Duration) {}
+func (NoopMetricsCollector) IncError(method, typeName string)                         {}
+
+// MetricsInterceptor returns an RPCInterceptor that reports each RPC call
+// to the given collector.
+func MetricsInterceptor(collector MetricsCollector) RPCInterceptor {
+	if collector == nil {
+		collector = NoopMetricsCollector{}
+	}
+	return func(ctx context.Context, method string, req, resp proto.Message, invoke RPCInvoker) error {
+		typeName := RequestTypeName(req)
+		collector.IncRPC(method, typeName)
+		start := time.Now()
+		err := invoke(ctx, req, resp)
+		collector.ObserveDuration(method, typeName, time.Since(start))
+		if err != nil {
+			collector.IncError(method, typeName)
+		}
+		return err
+	}
+}
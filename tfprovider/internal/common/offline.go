@@ -0,0 +1,15 @@
+package common
+
+import (
+	"context"
+
+	"github.com/golang/protobuf/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// OfflineInterceptor is an RPCInterceptor that fails every call, for use
+// with a provider that has a schema but no plugin behind it.
+func OfflineInterceptor(ctx context.Context, method string, req, resp proto.Message, invoke RPCInvoker) error {
+	return status.Errorf(codes.FailedPrecondition, "this is an offline provider with only a schema, so it cannot handle %s, which requires a running provider plugin", method)
+}
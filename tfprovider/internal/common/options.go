@@ -0,0 +1,193 @@
+package common
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os/exec"
+	"time"
+
+	"github.com/apparentlymart/terraform-schema-go/tfschema"
+	"github.com/zclconf/go-cty/cty"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
+)
+
+// Options represents the caller-customizable settings for a provider
+// instance. The tfprovider package populates this from its functional
+// options before passing it to the protocol-specific implementations.
+//
+// The zero value of Options represents the default behavior.
+type Options struct {
+	// Stderr, if set, receives everything the provider plugin writes to its
+	// stderr stream.
+	Stderr io.Writer
+
+	// RecordPath, if set, is a file that every RPC request and response
+	// will be recorded into, for later use with a replaying provider.
+	RecordPath string
+
+	// TypeEncodingOverrides, if set, gives the serialization format to use
+	// for values sent to the provider for particular resource types, by
+	// type name. Values are otherwise sent in msgpack format.
+	TypeEncodingOverrides map[string]Format
+
+	// DecodeFormat is the only serialization format that will be accepted
+	// in responses from the provider, unless it is FormatAny.
+	DecodeFormat Format
+
+	// CheckPrivateSchemaVersion enables warnings when private data is
+	// passed to an operation along with a schema version that doesn't
+	// match the resource type's current schema version.
+	CheckPrivateSchemaVersion bool
+
+	// Interceptors are wrapped around every RPC to the provider plugin,
+	// with the first element being the outermost.
+	Interceptors []RPCInterceptor
+
+	// RPCTimeout, if greater than zero, limits the time each RPC call to
+	// the provider plugin may take, except for operations that have their
+	// own timeout in OperationTimeouts.
+	RPCTimeout time.Duration
+
+	// OperationTimeouts gives the time limits for RPC calls belonging to
+	// particular operations, keyed by the Operation constants.
+	OperationTimeouts map[string]time.Duration
+
+	// TypeConcurrencyLimits gives the maximum number of RPC calls that may
+	// be in progress at once for each of the resource types it includes.
+	TypeConcurrencyLimits map[string]int
+
+	// Metrics, if set, receives measurements of each RPC call made to the
+	// provider plugin.
+	Metrics MetricsCollector
+
+	// SchemaIntern, if set, is used to deduplicate attribute types and
+	// description strings in the provider's schema, and may be shared
+	// between many providers.
+	SchemaIntern *InternTable
+
+	// SchemaRetry, if set, is the policy for retrying a failed request for
+	// the provider's schema while starting up. Other operations, and in
+	// particular those that change remote objects, are never retried.
+	SchemaRetry *RetryPolicy
+
+	// ImportRetry and ImportRetryable, if both set, cause an import to be
+	// retried according to the policy when it fails with an error
+	// diagnostic for which ImportRetryable returns true.
+	ImportRetry     *RetryPolicy
+	ImportRetryable func(Diagnostic) bool
+
+	// ConfigureProbe, if set, is called by Configure with the provider
+	// after it reports that it was configured successfully, and the
+	// provider is treated as unconfigured if it returns errors. The
+	// provider's type is interface{} because the interface it implements
+	// belongs to the tfprovider package.
+	ConfigureProbe func(ctx context.Context, provider interface{}) Diagnostics
+
+	// ProviderAddress, if set, is the provider's source address, used to
+	// identify it in the output of ProvidersSchemaJSON.
+	ProviderAddress string
+
+	// SourceTag, if set, is recorded as the Source of every diagnostic
+	// returned by the provider plugin.
+	SourceTag string
+
+	// Hooks, if set, is notified before and after each plan and apply
+	// operation on a managed resource type.
+	Hooks Hooks
+
+	// ConnectionStateCallback, if set, is called each time the state of
+	// the gRPC connection to the provider plugin changes.
+	ConnectionStateCallback func(connectivity.State)
+
+	// ValidatePlanRequests enables local validation of plan requests using
+	// ValidatePlanRequest before they are sent to the provider.
+	ValidatePlanRequests bool
+
+	// StrictCreatePlans makes it an error, rather than a warning, for a
+	// provider to plan a null state when asked to plan a create.
+	StrictCreatePlans bool
+
+	// UnknownAsNull makes decoding of values returned by the provider
+	// replace any unknown values with nulls, with a warning.
+	UnknownAsNull bool
+
+	// DiagnosticLog, if set, accumulates every diagnostic returned by the
+	// provider plugin.
+	DiagnosticLog *DiagnosticLog
+
+	// StrictImports makes Import check that each imported state has values
+	// for all of its computed attributes, using CheckImportedState.
+	StrictImports bool
+
+	// ResourceDiagnosticContext enables recording the resource type and
+	// instance key in diagnostics returned by managed resource operations.
+	ResourceDiagnosticContext bool
+
+	// PlannedPrivate, if set, records the private data returned by each
+	// plan and checks that apply is given the same private data.
+	PlannedPrivate *PlannedPrivateTracker
+
+	// Logger, if set, receives structured debug logs for RPC calls, schema
+	// loading, and retries.
+	Logger *slog.Logger
+
+	// ProviderMetaCache, if set, is used to avoid re-encoding the same
+	// provider_meta value for each managed resource operation.
+	ProviderMetaCache *EncodeCache
+
+	// DedupDiagnostics enables removing exact duplicates from the
+	// diagnostics returned by each call to the provider plugin.
+	DedupDiagnostics bool
+
+	// DisablePanicRecovery disables the conversion of panics during
+	// resource operations into error diagnostics, so that they crash the
+	// program as usual.
+	DisablePanicRecovery bool
+
+	// SecretResolvers are used to resolve references to secrets in the
+	// provider configuration when the provider is configured.
+	SecretResolvers []SecretResolver
+
+	// ValueSanitizer, if set, is called with each value and the schema it
+	// conforms to before the value is encoded for sending to the provider
+	// plugin, and returns the value to send instead.
+	ValueSanitizer func(cty.Value, *tfschema.Block) cty.Value
+
+	// ClientFactory, if set, creates the client for the connection to the
+	// provider plugin for the negotiated protocol version, instead of the
+	// client generated from the protocol definitions.
+	ClientFactory func(protoVersion int, conn *grpc.ClientConn) interface{}
+
+	// Cmd, if set, is the already-started command running the provider
+	// plugin's child process, which KillProcess terminates and whose exit
+	// status is reported by ExitError.
+	Cmd *exec.Cmd
+
+	// StderrCapture, if set, retains the most recent output the provider
+	// plugin wrote to its stderr stream, for inclusion in errors.
+	StderrCapture *CrashCapture
+
+	closers     []io.Closer
+	configRules map[configRuleTarget][]ConfigRule
+}
+
+// OnClose registers an object to be closed when the provider that these
+// options belong to is closed.
+func (o *Options) OnClose(c io.Closer) {
+	o.closers = append(o.closers, c)
+}
+
+// Close closes all of the objects previously registered with OnClose,
+// returning the first error encountered, if any.
+func (o *Options) Close() error {
+	var firstErr error
+	for _, c := range o.closers {
+		if err := c.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	o.closers = nil
+	return firstErr
+}
@@ -0,0 +1,39 @@
+package common
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/zclconf/go-cty/cty"
+)
+
+// FormatPath returns a string representation of the given path using
+// Terraform's traversal syntax, such as `tags["Name"]` or `rule[0].port`.
+//
+// Element keys that are neither strings nor numbers, which can arise when
+// addressing set elements, are rendered as "[...]".
+func FormatPath(path cty.Path) string {
+	var buf strings.Builder
+	for _, step := range path {
+		switch step := step.(type) {
+		case cty.GetAttrStep:
+			if buf.Len() != 0 {
+				buf.WriteByte('.')
+			}
+			buf.WriteString(step.Name)
+		case cty.IndexStep:
+			key := step.Key
+			switch {
+			case !key.IsKnown() || key.IsNull():
+				buf.WriteString("[...]")
+			case key.Type() == cty.String:
+				fmt.Fprintf(&buf, "[%q]", key.AsString())
+			case key.Type() == cty.Number:
+				fmt.Fprintf(&buf, "[%s]", key.AsBigFloat().Text('f', -1))
+			default:
+				buf.WriteString("[...]")
+			}
+		}
+	}
+	return buf.String()
+}
@@ -0,0 +1,120 @@
+package common
+
+import (
+	"fmt"
+
+	"github.com/apparentlymart/terraform-schema-go/tfschema"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// ValidatePlanRequest checks that the values in the given plan request
+// conform to the given resource type schema and are consistent with one
+// another, returning an error diagnostic for each problem found.
+//
+// This catches mistakes such as passing a value belonging to a different
+// resource type, which would otherwise produce a confusing error from the
+// provider. Each top-level attribute that is not computed must have the
+// same value in the proposed new state as in the configuration, the
+// configuration must be null if and only if the proposed new state is null,
+// and the computed attributes of the proposed new state are checked against
+// the prior state and configuration using CheckProposed.
+func ValidatePlanRequest(req ManagedResourcePlanRequest, schema *tfschema.Block) Diagnostics {
+	var diags Diagnostics
+	ty := schema.ImpliedType()
+
+	fields := []struct {
+		name string
+		val  cty.Value
+	}{
+		{"PriorState", req.PriorState},
+		{"ProposedNewState", req.ProposedNewState},
+		{"Config", req.Config},
+	}
+	for _, field := range fields {
+		if field.val == cty.NilVal {
+			diags = append(diags, Diagnostic{
+				Severity: Error,
+				Summary:  "Invalid plan request",
+				Detail:   fmt.Sprintf("The %s field is not set. Use a null value of the resource type's object type to represent an absent object.", field.name),
+			})
+			continue
+		}
+		for _, err := range field.val.Type().TestConformance(ty) {
+			diag := Diagnostic{
+				Severity: Error,
+				Summary:  "Invalid plan request",
+				Detail:   fmt.Sprintf("The %s value does not conform to the resource type schema: %s.", field.name, err),
+			}
+			if pathErr, ok := err.(cty.PathError); ok {
+				diag.Attribute = pathErr.Path
+			}
+			diags = append(diags, diag)
+		}
+	}
+	if diags.HasErrors() {
+		return diags
+	}
+
+	proposed, config := req.ProposedNewState, req.Config
+	switch {
+	case proposed.IsNull() && !config.IsNull():
+		diags = append(diags, Diagnostic{
+			Severity: Error,
+			Summary:  "Invalid plan request",
+			Detail:   "The ProposedNewState value is null, representing a destroy, but the Config value is not null.",
+		})
+	case config.IsNull() && !proposed.IsNull():
+		diags = append(diags, Diagnostic{
+			Severity: Error,
+			Summary:  "Invalid plan request",
+			Detail:   "The Config value is null, but the ProposedNewState value is not null. A plan to destroy must have a null ProposedNewState.",
+		})
+	case config.IsNull() || !config.IsKnown() || !proposed.IsKnown():
+		// Nothing more to check
+	default:
+		for name, attrS := range schema.Attributes {
+			if attrS.Computed {
+				continue
+			}
+			configVal := config.GetAttr(name)
+			proposedVal := proposed.GetAttr(name)
+			if !configVal.IsWhollyKnown() || !proposedVal.IsWhollyKnown() {
+				continue
+			}
+			if !configVal.RawEquals(proposedVal) {
+				diags = append(diags, Diagnostic{
+					Severity:  Error,
+					Summary:   "Invalid plan request",
+					Detail:    fmt.Sprintf("The ProposedNewState value for the non-computed attribute %q does not match the Config value.", name),
+					Attribute: cty.GetAttrPath(name),
+				})
+			}
+		}
+		diags = append(diags, CheckProposed(req.PriorState, config, proposed, schema)...)
+	}
+	return diags
+}
+
+// CheckCreatePlan returns a diagnostic if the given plan request is for
+// creating a new object but the provider's response plans a null state, so
+// that applying the plan would create nothing. This is a warning unless the
+// options enable StrictCreatePlans, in which case it is an error.
+func (o *Options) CheckCreatePlan(req ManagedResourcePlanRequest, resp ManagedResourcePlanResponse) Diagnostics {
+	isNull := func(v cty.Value) bool {
+		return v == cty.NilVal || v.IsNull()
+	}
+	if !isNull(req.PriorState) || isNull(req.ProposedNewState) || !isNull(resp.PlannedState) {
+		return nil
+	}
+	severity := Warning
+	if o.StrictCreatePlans {
+		severity = Error
+	}
+	return Diagnostics{
+		{
+			Severity: severity,
+			Summary:  "Provider planned no object for a create",
+			Detail:   "The provider was asked to plan the creation of a new object, but returned a null planned state. Applying this plan would not create anything.",
+		},
+	}
+}
@@ -0,0 +1,133 @@
+package common
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/apparentlymart/terraform-schema-go/tfschema"
+	"github.com/zclconf/go-cty/cty"
+)
+
+func TestValidatePlanRequest(t *testing.T) {
+	schema := &tfschema.Block{
+		Attributes: map[string]*tfschema.Attribute{
+			"id":   {Type: cty.String, Computed: true},
+			"name": {Type: cty.String, Optional: true},
+		},
+	}
+	ty := schema.ImpliedType()
+	obj := func(id cty.Value, name string) cty.Value {
+		return cty.ObjectVal(map[string]cty.Value{
+			"id":   id,
+			"name": cty.StringVal(name),
+		})
+	}
+	config := obj(cty.NullVal(cty.String), "a")
+	proposed := obj(cty.UnknownVal(cty.String), "a")
+	// dataVal is a value of a data resource type with different attributes,
+	// as a caller might mistakenly pass.
+	dataVal := cty.ObjectVal(map[string]cty.Value{
+		"name":  cty.StringVal("a"),
+		"value": cty.StringVal("b"),
+	})
+
+	tests := map[string]struct {
+		req     ManagedResourcePlanRequest
+		wantErr string
+	}{
+		"create": {
+			req: ManagedResourcePlanRequest{
+				PriorState:       cty.NullVal(ty),
+				ProposedNewState: proposed,
+				Config:           config,
+			},
+		},
+		"update": {
+			req: ManagedResourcePlanRequest{
+				PriorState:       obj(cty.StringVal("x"), "b"),
+				ProposedNewState: obj(cty.StringVal("x"), "a"),
+				Config:           config,
+			},
+		},
+		"destroy": {
+			req: ManagedResourcePlanRequest{
+				PriorState:       obj(cty.StringVal("x"), "a"),
+				ProposedNewState: cty.NullVal(ty),
+				Config:           cty.NullVal(ty),
+			},
+		},
+		"unset field": {
+			req: ManagedResourcePlanRequest{
+				ProposedNewState: proposed,
+				Config:           config,
+			},
+			wantErr: "The PriorState field is not set",
+		},
+		"wrong prior state type": {
+			req: ManagedResourcePlanRequest{
+				PriorState:       dataVal,
+				ProposedNewState: proposed,
+				Config:           config,
+			},
+			wantErr: "The PriorState value does not conform to the resource type schema",
+		},
+		"wrong config type": {
+			req: ManagedResourcePlanRequest{
+				PriorState:       cty.NullVal(ty),
+				ProposedNewState: proposed,
+				Config:           cty.StringVal("a"),
+			},
+			wantErr: "The Config value does not conform to the resource type schema",
+		},
+		"null config": {
+			req: ManagedResourcePlanRequest{
+				PriorState:       cty.NullVal(ty),
+				ProposedNewState: proposed,
+				Config:           cty.NullVal(ty),
+			},
+			wantErr: "The Config value is null",
+		},
+		"null proposed": {
+			req: ManagedResourcePlanRequest{
+				PriorState:       cty.NullVal(ty),
+				ProposedNewState: cty.NullVal(ty),
+				Config:           config,
+			},
+			wantErr: "The ProposedNewState value is null",
+		},
+		"config not in proposed": {
+			req: ManagedResourcePlanRequest{
+				PriorState:       cty.NullVal(ty),
+				ProposedNewState: obj(cty.UnknownVal(cty.String), "b"),
+				Config:           config,
+			},
+			wantErr: `non-computed attribute "name" does not match`,
+		},
+		"computed attribute cleared": {
+			req: ManagedResourcePlanRequest{
+				PriorState:       obj(cty.StringVal("x"), "b"),
+				ProposedNewState: obj(cty.NullVal(cty.String), "a"),
+				Config:           config,
+			},
+			wantErr: "computed attribute id is null, but it should have its prior value",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			diags := ValidatePlanRequest(test.req, schema)
+			if test.wantErr == "" {
+				if diags.HasErrors() {
+					t.Fatalf("unexpected errors: %s", diags.Err())
+				}
+				return
+			}
+			if !diags.HasErrors() {
+				t.Fatalf("validation succeeded; want an error")
+			}
+			if msg := diags.Err().Error(); !strings.Contains(msg, test.wantErr) {
+				t.Errorf("wrong error %q; want it to contain %q", msg, test.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,78 @@
+package common
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+)
+
+// PlannedPrivateTracker remembers the private data returned by each plan
+// operation so that it can be compared with the private data later given to
+// the corresponding apply operation, which the protocol requires to be
+// identical.
+//
+// Plans are identified by resource type name and instance key. A nil
+// PlannedPrivateTracker records nothing and checks nothing.
+type PlannedPrivateTracker struct {
+	mu      sync.Mutex
+	planned map[plannedPrivateKey][]byte
+}
+
+type plannedPrivateKey struct {
+	typeName    string
+	instanceKey string
+}
+
+// NewPlannedPrivateTracker returns a new, empty PlannedPrivateTracker.
+func NewPlannedPrivateTracker() *PlannedPrivateTracker {
+	return &PlannedPrivateTracker{
+		planned: make(map[plannedPrivateKey][]byte),
+	}
+}
+
+// RecordPlan remembers the private data returned by a successful plan for
+// the given resource instance, replacing any previously recorded.
+func (t *PlannedPrivateTracker) RecordPlan(typeName, instanceKey string, private []byte) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.planned[plannedPrivateKey{typeName, instanceKey}] = private
+}
+
+// CheckApply returns an error diagnostic if private data was recorded for
+// the given resource instance and it differs from the given private data
+// from an apply request. The recorded data is forgotten either way, since
+// each plan may be applied only once.
+//
+// If nothing was recorded for the resource instance, such as because it was
+// planned by another process, CheckApply returns no diagnostics.
+func (t *PlannedPrivateTracker) CheckApply(typeName, instanceKey string, private []byte) Diagnostics {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	key := plannedPrivateKey{typeName, instanceKey}
+	planned, ok := t.planned[key]
+	if !ok {
+		return nil
+	}
+	delete(t.planned, key)
+	if bytes.Equal(planned, private) {
+		return nil
+	}
+
+	what := typeName
+	if instanceKey != "" {
+		what = fmt.Sprintf("%s instance %q", typeName, instanceKey)
+	}
+	return Diagnostics{
+		{
+			Severity: Error,
+			Summary:  "Planned private data changed",
+			Detail:   fmt.Sprintf("The private data given when applying %s differs from the private data returned by its plan. The private data from the plan must be passed to apply unchanged.", what),
+		},
+	}
+}
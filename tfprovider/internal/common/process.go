@@ -0,0 +1,73 @@
+package common
+
+import (
+	"fmt"
+)
+
+// CloseError is the error returned when closing a provider reveals that
+// its plugin process exited abnormally, such as by crashing.
+type CloseError struct {
+	// ExitCode is the exit code of the plugin process.
+	ExitCode int
+
+	// Stderr is the most recent output the plugin wrote to its stderr
+	// stream, if the provider was started with a stderr writer. It is
+	// empty otherwise.
+	Stderr string
+
+	// Err is the error returned when closing the connection to the plugin,
+	// if any.
+	Err error
+}
+
+func (e *CloseError) Error() string {
+	msg := fmt.Sprintf("provider plugin exited with code %d", e.ExitCode)
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	if e.Stderr != "" {
+		msg += "\n\nThe provider plugin's final output was:\n\n" + e.Stderr
+	}
+	return msg
+}
+
+func (e *CloseError) Unwrap() error {
+	return e.Err
+}
+
+// KillProcess forcefully terminates the provider plugin's child process,
+// if there is one.
+func (o *Options) KillProcess() error {
+	if o.Cmd == nil || o.Cmd.Process == nil {
+		return nil
+	}
+	return o.Cmd.Process.Kill()
+}
+
+// ExitError interprets the given error from closing the plugin in light of
+// how the plugin process exited, for use as the result of closing a
+// provider.
+//
+// The result is nil if the process exited with code zero or was terminated
+// by a signal, as happens when it is killed during a normal shutdown, even
+// if closing the plugin returned an error. It is a *CloseError if the
+// process exited with any other code. If the process's exit status is not
+// available, such as when there is no child process at all, the given error
+// is returned unchanged.
+func (o *Options) ExitError(closeErr error) error {
+	if o.Cmd == nil || o.Cmd.ProcessState == nil {
+		return closeErr
+	}
+	state := o.Cmd.ProcessState
+	if !state.Exited() || state.Success() {
+		return nil
+	}
+	ret := &CloseError{
+		ExitCode: state.ExitCode(),
+		Err:      closeErr,
+	}
+	if o.StderrCapture != nil {
+		ret.Stderr = o.StderrCapture.Output()
+	}
+	return ret
+}
@@ -0,0 +1,73 @@
+package common
+
+import (
+	"errors"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+// exitedCmd runs the given shell script to completion, with its stderr
+// written to the given capture, and returns the finished command.
+func exitedCmd(t *testing.T, script string, stderr *CrashCapture) *exec.Cmd {
+	t.Helper()
+	cmd := exec.Command("sh", "-c", script)
+	if stderr != nil {
+		cmd.Stderr = stderr
+	}
+	if err := cmd.Start(); err != nil {
+		t.Skipf("cannot start a child process: %s", err)
+	}
+	cmd.Wait()
+	return cmd
+}
+
+func TestOptionsExitError(t *testing.T) {
+	closeErr := errors.New("connection reset")
+
+	t.Run("crash", func(t *testing.T) {
+		stderr := NewCrashCapture()
+		o := &Options{
+			Cmd:           exitedCmd(t, "echo 'panic: oops' >&2; exit 2", stderr),
+			StderrCapture: stderr,
+		}
+		err := o.ExitError(closeErr)
+		var got *CloseError
+		if !errors.As(err, &got) {
+			t.Fatalf("wrong error %#v; want a *CloseError", err)
+		}
+		if got.ExitCode != 2 {
+			t.Errorf("wrong exit code %d; want 2", got.ExitCode)
+		}
+		if !strings.Contains(got.Stderr, "panic: oops") {
+			t.Errorf("wrong stderr %q", got.Stderr)
+		}
+		if !errors.Is(err, closeErr) {
+			t.Errorf("error does not wrap the close error: %s", err)
+		}
+		if msg := err.Error(); !strings.Contains(msg, "exited with code 2") || !strings.Contains(msg, "panic: oops") {
+			t.Errorf("wrong message %q", msg)
+		}
+	})
+
+	t.Run("clean exit", func(t *testing.T) {
+		o := &Options{Cmd: exitedCmd(t, "exit 0", nil)}
+		if err := o.ExitError(closeErr); err != nil {
+			t.Errorf("unexpected error %s", err)
+		}
+	})
+
+	t.Run("killed", func(t *testing.T) {
+		o := &Options{Cmd: exitedCmd(t, "kill -KILL $$", nil)}
+		if err := o.ExitError(closeErr); err != nil {
+			t.Errorf("unexpected error %s", err)
+		}
+	})
+
+	t.Run("no process", func(t *testing.T) {
+		o := &Options{}
+		if err := o.ExitError(closeErr); err != closeErr {
+			t.Errorf("wrong error %v; want the close error unchanged", err)
+		}
+	})
+}
@@ -0,0 +1,59 @@
+package common
+
+import (
+	"github.com/apparentlymart/terraform-schema-go/tfschema"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// AttrPrompt describes a single attribute of a configuration schema in the
+// terms needed to prompt a user for its value interactively.
+type AttrPrompt struct {
+	// Name is the attribute name, prefixed with the names of any nested
+	// block types containing it, separated by dots.
+	Name string
+
+	Type        cty.Type
+	Description string
+	Required    bool
+	Optional    bool
+
+	// Sensitive is true if the attribute's value should be masked as the
+	// user enters it.
+	Sensitive bool
+}
+
+// ConfigPrompts flattens the given schema into a list of prompts, one for
+// each attribute that the user may set, including the attributes of nested
+// blocks.
+//
+// The attributes of each block are listed in lexical order, followed by the
+// attributes of each of its nested block types in lexical order. Computed
+// attributes that are not also optional are omitted, since they cannot be
+// set.
+func ConfigPrompts(schema *tfschema.Block) []AttrPrompt {
+	if schema == nil {
+		return nil
+	}
+	return appendConfigPrompts(nil, schema, "")
+}
+
+func appendConfigPrompts(prompts []AttrPrompt, schema *tfschema.Block, prefix string) []AttrPrompt {
+	for _, name := range sortedAttributeNames(schema) {
+		attrS := schema.Attributes[name]
+		if !attrS.Required && !attrS.Optional {
+			continue
+		}
+		prompts = append(prompts, AttrPrompt{
+			Name:        prefix + name,
+			Type:        attrS.Type,
+			Description: attrS.Description,
+			Required:    attrS.Required,
+			Optional:    attrS.Optional,
+			Sensitive:   attrS.Sensitive,
+		})
+	}
+	for _, name := range sortedBlockTypeNames(schema) {
+		prompts = appendConfigPrompts(prompts, &schema.BlockTypes[name].Block, prefix+name+".")
+	}
+	return prompts
+}
@@ -0,0 +1,67 @@
+package common
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/apparentlymart/terraform-schema-go/tfschema"
+	"github.com/zclconf/go-cty/cty"
+)
+
+func TestConfigPrompts(t *testing.T) {
+	schema := &tfschema.Block{
+		Attributes: map[string]*tfschema.Attribute{
+			"region":     {Type: cty.String, Required: true, Description: "The region to use."},
+			"token":      {Type: cty.String, Optional: true, Sensitive: true},
+			"account_id": {Type: cty.String, Computed: true},
+			"max_tries":  {Type: cty.Number, Optional: true, Computed: true},
+		},
+		BlockTypes: map[string]*tfschema.NestedBlock{
+			"assume_role": {
+				Nesting: tfschema.NestingSingle,
+				Block: tfschema.Block{
+					Attributes: map[string]*tfschema.Attribute{
+						"role_arn":    {Type: cty.String, Required: true},
+						"external_id": {Type: cty.String, Optional: true, Sensitive: true},
+					},
+					BlockTypes: map[string]*tfschema.NestedBlock{
+						"tags": {
+							Nesting: tfschema.NestingList,
+							Block: tfschema.Block{
+								Attributes: map[string]*tfschema.Attribute{
+									"key": {Type: cty.String, Required: true},
+								},
+							},
+						},
+					},
+				},
+			},
+			"endpoints": {
+				Nesting: tfschema.NestingSet,
+				Block: tfschema.Block{
+					Attributes: map[string]*tfschema.Attribute{
+						"s3": {Type: cty.String, Optional: true},
+					},
+				},
+			},
+		},
+	}
+
+	got := ConfigPrompts(schema)
+	want := []AttrPrompt{
+		{Name: "max_tries", Type: cty.Number, Optional: true},
+		{Name: "region", Type: cty.String, Required: true, Description: "The region to use."},
+		{Name: "token", Type: cty.String, Optional: true, Sensitive: true},
+		{Name: "assume_role.external_id", Type: cty.String, Optional: true, Sensitive: true},
+		{Name: "assume_role.role_arn", Type: cty.String, Required: true},
+		{Name: "assume_role.tags.key", Type: cty.String, Required: true},
+		{Name: "endpoints.s3", Type: cty.String, Optional: true},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("wrong result\ngot:  %#v\nwant: %#v", got, want)
+	}
+
+	if got := ConfigPrompts(nil); len(got) != 0 {
+		t.Errorf("wrong result for nil schema %#v; want none", got)
+	}
+}
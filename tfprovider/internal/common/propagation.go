@@ -0,0 +1,23 @@
+package common
+
+import (
+	"context"
+
+	"github.com/golang/protobuf/proto"
+	"google.golang.org/grpc/metadata"
+)
+
+// ContextPropagationInterceptor returns an RPCInterceptor that calls the
+// given function with the context of each RPC call and sends the key/value
+// pairs it returns to the provider plugin as gRPC request metadata, such as
+// for propagating distributed tracing headers into the provider.
+//
+// gRPC metadata keys are case-insensitive, and are sent in lowercase.
+func ContextPropagationInterceptor(propagate func(context.Context) map[string]string) RPCInterceptor {
+	return func(ctx context.Context, method string, req, resp proto.Message, invoke RPCInvoker) error {
+		for k, v := range propagate(ctx) {
+			ctx = metadata.AppendToOutgoingContext(ctx, k, v)
+		}
+		return invoke(ctx, req, resp)
+	}
+}
@@ -0,0 +1,187 @@
+package common
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/golang/protobuf/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// recordingHeader is the first line of a recording file, describing which
+// protocol the remaining lines belong to.
+type recordingHeader struct {
+	ProtocolVersion int `json:"protocol_version"`
+}
+
+// recordedCall is a single RPC request/response pair in a recording file.
+// The request itself is not retained, only a hash of it for matching
+// purposes during replay.
+type recordedCall struct {
+	Method    string `json:"method"`
+	InputHash string `json:"input_hash"`
+	Response  []byte `json:"response,omitempty"`
+	ErrorCode uint32 `json:"error_code,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// Recorder is an RPC interceptor that writes each request/response pair to
+// a file, producing a recording that a Replayer can later serve.
+type Recorder struct {
+	mu  sync.Mutex
+	f   *os.File
+	enc *json.Encoder
+}
+
+// NewRecorder creates a new recording file at the given path, overwriting
+// any existing file, for RPCs using the given protocol major version.
+func NewRecorder(path string, protoVersion int) (*Recorder, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	enc := json.NewEncoder(f)
+	if err := enc.Encode(recordingHeader{ProtocolVersion: protoVersion}); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &Recorder{f: f, enc: enc}, nil
+}
+
+// Intercept is an RPCInterceptor that passes the call through to the
+// plugin and then records the result.
+func (r *Recorder) Intercept(ctx context.Context, method string, req, resp proto.Message, invoke RPCInvoker) error {
+	hash, err := hashRPCInput(method, req)
+	if err != nil {
+		return err
+	}
+
+	callErr := invoke(ctx, req, resp)
+
+	call := recordedCall{
+		Method:    method,
+		InputHash: hash,
+	}
+	if callErr != nil {
+		s, _ := status.FromError(callErr)
+		call.ErrorCode = uint32(s.Code())
+		call.Error = s.Message()
+	} else {
+		raw, err := proto.Marshal(resp)
+		if err != nil {
+			return err
+		}
+		call.Response = raw
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.enc.Encode(call); err != nil {
+		return fmt.Errorf("failed to record %s call: %s", method, err)
+	}
+	return callErr
+}
+
+// Close closes the underlying recording file.
+func (r *Recorder) Close() error {
+	return r.f.Close()
+}
+
+// Replayer is an RPC interceptor that answers calls using responses from
+// a recording previously created by a Recorder, without calling the plugin.
+//
+// Calls are matched by method name and a hash of the request. If the same
+// request was recorded more than once then the responses are returned in
+// the order they were recorded, with the final response repeated once the
+// others have been used.
+type Replayer struct {
+	ProtocolVersion int
+
+	mu    sync.Mutex
+	calls map[string][]recordedCall
+}
+
+// LoadReplayer reads the recording file at the given path.
+func LoadReplayer(path string) (*Replayer, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	sc := bufio.NewScanner(f)
+	sc.Buffer(nil, 256*1024*1024)
+	if !sc.Scan() {
+		if err := sc.Err(); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%s is not a provider recording: file is empty", path)
+	}
+	var header recordingHeader
+	if err := json.Unmarshal(sc.Bytes(), &header); err != nil || header.ProtocolVersion == 0 {
+		return nil, fmt.Errorf("%s is not a provider recording: invalid header", path)
+	}
+
+	ret := &Replayer{
+		ProtocolVersion: header.ProtocolVersion,
+		calls:           make(map[string][]recordedCall),
+	}
+	line := 1
+	for sc.Scan() {
+		line++
+		var call recordedCall
+		if err := json.Unmarshal(sc.Bytes(), &call); err != nil {
+			return nil, fmt.Errorf("%s:%d: invalid recorded call: %s", path, line, err)
+		}
+		key := call.Method + ":" + call.InputHash
+		ret.calls[key] = append(ret.calls[key], call)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// Intercept is an RPCInterceptor that answers the call from the recording.
+// It never calls invoke.
+func (r *Replayer) Intercept(ctx context.Context, method string, req, resp proto.Message, invoke RPCInvoker) error {
+	hash, err := hashRPCInput(method, req)
+	if err != nil {
+		return err
+	}
+	key := method + ":" + hash
+
+	r.mu.Lock()
+	calls := r.calls[key]
+	if len(calls) == 0 {
+		r.mu.Unlock()
+		return status.Errorf(codes.NotFound, "no recorded response for %s with the given request", method)
+	}
+	call := calls[0]
+	if len(calls) > 1 {
+		r.calls[key] = calls[1:]
+	}
+	r.mu.Unlock()
+
+	if call.ErrorCode != 0 {
+		return status.Error(codes.Code(call.ErrorCode), call.Error)
+	}
+	return proto.Unmarshal(call.Response, resp)
+}
+
+func hashRPCInput(method string, req proto.Message) (string, error) {
+	var buf proto.Buffer
+	buf.SetDeterministic(true)
+	if err := buf.Marshal(req); err != nil {
+		return "", fmt.Errorf("failed to encode %s request: %s", method, err)
+	}
+	sum := sha256.Sum256(buf.Bytes())
+	return hex.EncodeToString(sum[:]), nil
+}
@@ -0,0 +1,27 @@
+package common
+
+import (
+	"fmt"
+)
+
+// RecoverPanic recovers from a panic in the operation it is deferred from,
+// unless the options disable panic recovery, and appends an error diagnostic
+// describing the panic to the diagnostics that diags points to. It must be
+// called directly using defer, because otherwise it cannot recover.
+//
+// This prevents a bug in the handling of a malformed value, such as one
+// returned by a misbehaving provider, from crashing the calling program.
+func (o *Options) RecoverPanic(operation string, diags *Diagnostics) {
+	if o.DisablePanicRecovery {
+		return
+	}
+	r := recover()
+	if r == nil {
+		return
+	}
+	*diags = append(*diags, Diagnostic{
+		Severity: Error,
+		Summary:  "Internal error",
+		Detail:   fmt.Sprintf("A panic occurred during the %s operation, which indicates a bug in the provider or in the library that called it: %v.", operation, r),
+	})
+}
@@ -0,0 +1,74 @@
+package common
+
+import (
+	"github.com/zclconf/go-cty/cty"
+)
+
+// SplitReplace interprets the result of planning a change to a managed
+// resource, reporting whether the change requires the remote object to be
+// replaced and, if so, which of the provider's RequiresReplace paths caused
+// it.
+//
+// As in Terraform itself, a path the provider marks as requiring
+// replacement causes replacement only if its value actually differs between
+// the prior state and the planned state. Creating or destroying an object
+// is never a replacement.
+//
+// The provider has no say in the order of the two halves of a replacement:
+// that is decided by the caller through req.CreateBeforeDestroy. The
+// destroyFirst result is true if the replacement must destroy the existing
+// object before creating its successor, which is the default.
+func SplitReplace(req ManagedResourcePlanRequest, resp ManagedResourcePlanResponse) (needsReplace, destroyFirst bool, paths []cty.Path) {
+	prior := req.PriorState
+	planned := resp.PlannedState
+	if prior == cty.NilVal || prior.IsNull() || planned == cty.NilVal || planned.IsNull() {
+		return false, false, nil
+	}
+
+	for _, path := range resp.RequiresReplace {
+		if pathValueChanged(path, prior, planned) {
+			paths = append(paths, path)
+		}
+	}
+	if len(paths) == 0 {
+		return false, false, nil
+	}
+	return true, !req.CreateBeforeDestroy, paths
+}
+
+// pathValueChanged returns true if the value at the given path differs
+// between the two given values, treating an unknown value as a change.
+func pathValueChanged(path cty.Path, a, b cty.Value) bool {
+	av, aErr := path.Apply(a)
+	bv, bErr := path.Apply(b)
+	switch {
+	case aErr != nil && bErr != nil:
+		// Neither value has anything at this path, such as when an
+		// enclosing block is absent from both.
+		return false
+	case aErr != nil || bErr != nil:
+		return true
+	}
+	eq := av.Equals(bv)
+	return !eq.IsKnown() || eq.False()
+}
+
+// PlanDiff returns the changes between the prior state of the given plan
+// request and the planned state of the given response, as described by Diff,
+// with the RequiresReplace field set on each change that overlaps one of
+// the paths that SplitReplace reports as causing replacement.
+func PlanDiff(req ManagedResourcePlanRequest, resp ManagedResourcePlanResponse) []AttrChange {
+	changes := Diff(req.PriorState, resp.PlannedState)
+	_, _, replacePaths := SplitReplace(req, resp)
+	for i, change := range changes {
+		for _, path := range replacePaths {
+			// A change may be either within a value that requires
+			// replacement or to a whole collection containing one.
+			if change.Path.HasPrefix(path) || path.HasPrefix(change.Path) {
+				changes[i].RequiresReplace = true
+				break
+			}
+		}
+	}
+	return changes
+}
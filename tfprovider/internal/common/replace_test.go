@@ -0,0 +1,103 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/zclconf/go-cty/cty"
+)
+
+func TestSplitReplace(t *testing.T) {
+	ty := cty.Object(map[string]cty.Type{
+		"name": cty.String,
+		"zone": cty.String,
+	})
+	obj := func(name, zone string) cty.Value {
+		return cty.ObjectVal(map[string]cty.Value{
+			"name": cty.StringVal(name),
+			"zone": cty.StringVal(zone),
+		})
+	}
+	zonePath := cty.GetAttrPath("zone")
+	namePath := cty.GetAttrPath("name")
+
+	tests := map[string]struct {
+		prior, planned      cty.Value
+		requiresReplace     []cty.Path
+		createBeforeDestroy bool
+
+		wantReplace      bool
+		wantDestroyFirst bool
+		wantPaths        []cty.Path
+	}{
+		"update": {
+			prior:           obj("a", "z1"),
+			planned:         obj("b", "z1"),
+			requiresReplace: []cty.Path{zonePath},
+		},
+		"replace": {
+			prior:            obj("a", "z1"),
+			planned:          obj("a", "z2"),
+			requiresReplace:  []cty.Path{zonePath, namePath},
+			wantReplace:      true,
+			wantDestroyFirst: true,
+			wantPaths:        []cty.Path{zonePath},
+		},
+		"replace create before destroy": {
+			prior:               obj("a", "z1"),
+			planned:             obj("a", "z2"),
+			requiresReplace:     []cty.Path{zonePath},
+			createBeforeDestroy: true,
+			wantReplace:         true,
+			wantPaths:           []cty.Path{zonePath},
+		},
+		"replace with unknown": {
+			prior: obj("a", "z1"),
+			planned: cty.ObjectVal(map[string]cty.Value{
+				"name": cty.StringVal("a"),
+				"zone": cty.UnknownVal(cty.String),
+			}),
+			requiresReplace:  []cty.Path{zonePath},
+			wantReplace:      true,
+			wantDestroyFirst: true,
+			wantPaths:        []cty.Path{zonePath},
+		},
+		"create": {
+			prior:           cty.NullVal(ty),
+			planned:         obj("a", "z1"),
+			requiresReplace: []cty.Path{zonePath},
+		},
+		"destroy": {
+			prior:           obj("a", "z1"),
+			planned:         cty.NullVal(ty),
+			requiresReplace: []cty.Path{zonePath},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			req := ManagedResourcePlanRequest{
+				PriorState:          test.prior,
+				CreateBeforeDestroy: test.createBeforeDestroy,
+			}
+			resp := ManagedResourcePlanResponse{
+				PlannedState:    test.planned,
+				RequiresReplace: test.requiresReplace,
+			}
+			gotReplace, gotDestroyFirst, gotPaths := SplitReplace(req, resp)
+			if gotReplace != test.wantReplace {
+				t.Errorf("wrong needsReplace %t; want %t", gotReplace, test.wantReplace)
+			}
+			if gotDestroyFirst != test.wantDestroyFirst {
+				t.Errorf("wrong destroyFirst %t; want %t", gotDestroyFirst, test.wantDestroyFirst)
+			}
+			if len(gotPaths) != len(test.wantPaths) {
+				t.Fatalf("wrong paths %#v; want %#v", gotPaths, test.wantPaths)
+			}
+			for i := range gotPaths {
+				if !gotPaths[i].Equals(test.wantPaths[i]) {
+					t.Errorf("wrong path %d: %s; want %s", i, FormatPath(gotPaths[i]), FormatPath(test.wantPaths[i]))
+				}
+			}
+		})
+	}
+}
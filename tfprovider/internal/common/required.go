@@ -0,0 +1,89 @@
+package common
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/apparentlymart/terraform-schema-go/tfschema"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// CheckRequired returns an error diagnostic for each required attribute that
+// is null in the given object value, which must conform to the given schema.
+//
+// The check descends into nested blocks, such as the credentials blocks that
+// many providers have in their configuration schemas, so a required
+// attribute inside a nested block is reported if that block is present but
+// the attribute is not. Absent nested blocks are not checked, and unknown
+// values are assumed to be set.
+func CheckRequired(val cty.Value, schema *tfschema.Block) Diagnostics {
+	if schema == nil {
+		return nil
+	}
+	return checkRequiredBlock(val, schema, nil)
+}
+
+func checkRequiredBlock(val cty.Value, schema *tfschema.Block, path cty.Path) Diagnostics {
+	if val.IsNull() || !val.IsKnown() {
+		return nil
+	}
+
+	var diags Diagnostics
+	for _, name := range sortedAttributeNames(schema) {
+		if !schema.Attributes[name].Required {
+			continue
+		}
+		if val.GetAttr(name).IsNull() {
+			diags = append(diags, Diagnostic{
+				Severity:  Error,
+				Summary:   "Missing required argument",
+				Detail:    fmt.Sprintf("The argument %q is required, but no definition was found.", name),
+				Attribute: path.GetAttr(name),
+			})
+		}
+	}
+
+	for _, name := range sortedBlockTypeNames(schema) {
+		blockS := schema.BlockTypes[name]
+		bv := val.GetAttr(name)
+		bPath := path.GetAttr(name)
+		switch blockS.Nesting {
+		case tfschema.NestingSingle, tfschema.NestingGroup:
+			diags = append(diags, checkRequiredBlock(bv, &blockS.Block, bPath)...)
+		default:
+			if bv.IsNull() || !bv.IsKnown() {
+				continue
+			}
+			for it := bv.ElementIterator(); it.Next(); {
+				k, ev := it.Element()
+				if blockS.Nesting == tfschema.NestingSet {
+					// Set elements have no index of their own.
+					k = cty.UnknownVal(ev.Type())
+				}
+				diags = append(diags, checkRequiredBlock(ev, &blockS.Block, bPath.Index(k))...)
+			}
+		}
+	}
+	return diags
+}
+
+// sortedAttributeNames and sortedBlockTypeNames return the names from the
+// given schema in lexical order, so that diagnostics about them are returned
+// in a consistent order.
+func sortedAttributeNames(schema *tfschema.Block) []string {
+	names := make([]string, 0, len(schema.Attributes))
+	for name := range schema.Attributes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func sortedBlockTypeNames(schema *tfschema.Block) []string {
+	names := make([]string, 0, len(schema.BlockTypes))
+	for name := range schema.BlockTypes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
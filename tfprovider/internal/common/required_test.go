@@ -0,0 +1,168 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/apparentlymart/terraform-schema-go/tfschema"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// requiredTestSchema is a provider configuration schema with credentials in
+// nested blocks, as many cloud providers have.
+var requiredTestSchema = &tfschema.Block{
+	Attributes: map[string]*tfschema.Attribute{
+		"region": {Type: cty.String, Optional: true},
+	},
+	BlockTypes: map[string]*tfschema.NestedBlock{
+		"assume_role": {
+			Nesting: tfschema.NestingSingle,
+			Block: tfschema.Block{
+				Attributes: map[string]*tfschema.Attribute{
+					"role_arn":     {Type: cty.String, Required: true},
+					"session_name": {Type: cty.String, Optional: true},
+				},
+			},
+		},
+		"endpoint": {
+			Nesting: tfschema.NestingList,
+			Block: tfschema.Block{
+				Attributes: map[string]*tfschema.Attribute{
+					"service": {Type: cty.String, Required: true},
+					"url":     {Type: cty.String, Required: true},
+				},
+			},
+		},
+		"header": {
+			Nesting: tfschema.NestingSet,
+			Block: tfschema.Block{
+				Attributes: map[string]*tfschema.Attribute{
+					"name": {Type: cty.String, Required: true},
+				},
+			},
+		},
+	},
+}
+
+func TestCheckRequired(t *testing.T) {
+	ty := requiredTestSchema.ImpliedType()
+	assumeRoleTy := ty.AttributeType("assume_role")
+	endpointTy := ty.AttributeType("endpoint").ElementType()
+	headerTy := ty.AttributeType("header").ElementType()
+	empty := requiredTestSchema.EmptyValue()
+	with := func(attrs map[string]cty.Value) cty.Value {
+		ret := empty.AsValueMap()
+		for k, v := range attrs {
+			ret[k] = v
+		}
+		return cty.ObjectVal(ret)
+	}
+
+	tests := map[string]struct {
+		val       cty.Value
+		wantPaths []string
+	}{
+		"empty": {
+			empty,
+			nil,
+		},
+		"unknown": {
+			cty.UnknownVal(ty),
+			nil,
+		},
+		"complete": {
+			with(map[string]cty.Value{
+				"assume_role": cty.ObjectVal(map[string]cty.Value{
+					"role_arn":     cty.StringVal("arn:example"),
+					"session_name": cty.NullVal(cty.String),
+				}),
+				"endpoint": cty.ListVal([]cty.Value{
+					cty.ObjectVal(map[string]cty.Value{
+						"service": cty.StringVal("s3"),
+						"url":     cty.UnknownVal(cty.String),
+					}),
+				}),
+			}),
+			nil,
+		},
+		"nested missing": {
+			with(map[string]cty.Value{
+				"assume_role": cty.ObjectVal(map[string]cty.Value{
+					"role_arn":     cty.NullVal(cty.String),
+					"session_name": cty.StringVal("example"),
+				}),
+				"endpoint": cty.ListVal([]cty.Value{
+					cty.ObjectVal(map[string]cty.Value{
+						"service": cty.StringVal("s3"),
+						"url":     cty.StringVal("https://s3.example.com/"),
+					}),
+					cty.ObjectVal(map[string]cty.Value{
+						"service": cty.NullVal(cty.String),
+						"url":     cty.NullVal(cty.String),
+					}),
+				}),
+				"header": cty.SetVal([]cty.Value{
+					cty.ObjectVal(map[string]cty.Value{
+						"name": cty.NullVal(cty.String),
+					}),
+				}),
+			}),
+			[]string{
+				"assume_role.role_arn",
+				"endpoint[1].service",
+				"endpoint[1].url",
+				"header[...].name",
+			},
+		},
+		"unknown blocks": {
+			with(map[string]cty.Value{
+				"assume_role": cty.UnknownVal(assumeRoleTy),
+				"endpoint":    cty.UnknownVal(cty.List(endpointTy)),
+				"header":      cty.UnknownVal(cty.Set(headerTy)),
+			}),
+			nil,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			diags := CheckRequired(test.val, requiredTestSchema)
+			if len(diags) != len(test.wantPaths) {
+				t.Fatalf("got %d diagnostics; want %d: %#v", len(diags), len(test.wantPaths), diags)
+			}
+			for i, diag := range diags {
+				if diag.Severity != Error || diag.Summary != "Missing required argument" {
+					t.Errorf("wrong diagnostic %#v", diag)
+				}
+				if got, want := FormatPath(diag.Attribute), test.wantPaths[i]; got != want {
+					t.Errorf("wrong path %s; want %s", got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestCheckRequiredEmptyValue(t *testing.T) {
+	// The empty value of a schema leaves out all of the nested blocks, so
+	// only the top-level required attributes are reported.
+	schema := &tfschema.Block{
+		Attributes: map[string]*tfschema.Attribute{
+			"token": {Type: cty.String, Required: true},
+		},
+		BlockTypes: requiredTestSchema.BlockTypes,
+	}
+	val := schema.EmptyValue()
+	if got := val.GetAttr("assume_role"); !got.IsNull() {
+		t.Errorf("wrong empty assume_role %#v; want null", got)
+	}
+	if got := val.GetAttr("endpoint"); got.IsNull() || got.LengthInt() != 0 {
+		t.Errorf("wrong empty endpoint %#v; want an empty list", got)
+	}
+
+	diags := CheckRequired(val, schema)
+	if len(diags) != 1 {
+		t.Fatalf("got %d diagnostics; want 1: %#v", len(diags), diags)
+	}
+	if got, want := FormatPath(diags[0].Attribute), "token"; got != want {
+		t.Errorf("wrong path %s; want %s", got, want)
+	}
+}
@@ -16,7 +16,16 @@ type ManagedResourceType interface {
 	// ValidateConfig validates a configuration for this managed resource type.
 	ValidateConfig(context.Context, cty.Value) Diagnostics
 
+	// SchemaVersion returns the version of the schema that the provider
+	// currently uses for this resource type. Stored state produced under
+	// an older version must be upgraded before it can be used with Read.
+	SchemaVersion() int64
+
 	// Import imports an existing resource into Terraform state.
+	//
+	// If the context is cancelled while the import is in progress, the
+	// provider is asked to stop its in-progress operations and is given
+	// StopGracePeriod to return before the request is abandoned.
 	Import(context.Context, ManagedResourceImportRequest) (ManagedResourceImportResponse, Diagnostics)
 
 	// Read asks the provider to update a value for this resource that was
@@ -63,11 +72,46 @@ type DataResourceType interface {
 type ManagedResourceReadRequest struct {
 	PreviousValue cty.Value
 	OpaquePrivate []byte
+
+	// ProviderMeta is the value of the provider_meta block from the module
+	// containing the resource, or null if there is none. It is an error to
+	// set it to a non-null value if the provider has no provider_meta schema.
+	ProviderMeta cty.Value
+
+	// NewStateVisitor, if set, is called for each leaf value of the
+	// refreshed state as it is decoded, instead of decoding the state into
+	// the RefreshedValue field of the response, which is then left as
+	// cty.NilVal. This reduces the memory needed to examine a very large
+	// state. See VisitDynamicValue for details on which values are visited.
+	NewStateVisitor ValueVisitor
+
+	// PreviousSchemaVersion is the schema version that PreviousValue was
+	// produced under, if known. If set and it doesn't match the resource
+	// type's current schema version then Read returns an error without
+	// calling the provider.
+	PreviousSchemaVersion *int64
+
+	// InstanceKey optionally identifies the resource instance that the
+	// request relates to. It is not sent to the provider, but is recorded
+	// in diagnostics when the WithResourceDiagnosticContext option is
+	// enabled.
+	InstanceKey string
+
+	// PrivateSchemaVersion is the schema version that OpaquePrivate was
+	// produced under, as returned alongside it by an earlier operation, if
+	// known. When the WithPrivateSchemaVersion option is enabled, a
+	// mismatch with the current schema version produces a warning.
+	PrivateSchemaVersion *int64
 }
 
 type ManagedResourceReadResponse struct {
 	RefreshedValue cty.Value
 	OpaquePrivate  []byte
+
+	// PrivateSchemaVersion is the schema version that OpaquePrivate was
+	// produced under, which callers can store alongside it and return in
+	// the PrivateSchemaVersion field of later requests.
+	PrivateSchemaVersion int64
 }
 
 // ManagedResourcePlanRequest represents a request to plan a resource change.
@@ -77,6 +121,24 @@ type ManagedResourcePlanRequest struct {
 	Config           cty.Value
 	ProviderMeta     cty.Value
 	OpaquePrivate    []byte
+
+	// CreateBeforeDestroy is a hint that the caller intends to create any
+	// replacement object before destroying the existing one. It is not
+	// sent to the provider, which has no say in the matter, but it is
+	// taken into account by SplitReplace.
+	CreateBeforeDestroy bool
+
+	// InstanceKey optionally identifies the resource instance that the
+	// request relates to. It is not sent to the provider, but is recorded
+	// in diagnostics when the WithResourceDiagnosticContext option is
+	// enabled.
+	InstanceKey string
+
+	// PrivateSchemaVersion is the schema version that OpaquePrivate was
+	// produced under, as returned alongside it by an earlier operation, if
+	// known. When the WithPrivateSchemaVersion option is enabled, a
+	// mismatch with the current schema version produces a warning.
+	PrivateSchemaVersion *int64
 }
 
 // ManagedResourcePlanResponse represents the response from planning a resource change.
@@ -84,6 +146,16 @@ type ManagedResourcePlanResponse struct {
 	PlannedState    cty.Value
 	RequiresReplace []cty.Path
 	OpaquePrivate   []byte
+
+	// LegacyTypeSystem is true if the provider indicated that it uses the
+	// legacy SDK type system, in which case PlannedState may have been
+	// decoded leniently and may not be entirely consistent with the schema.
+	LegacyTypeSystem bool
+
+	// PrivateSchemaVersion is the schema version that OpaquePrivate was
+	// produced under, which callers can store alongside it and return in
+	// the PrivateSchemaVersion field of later requests.
+	PrivateSchemaVersion int64
 }
 
 // ManagedResourceApplyRequest represents a request to apply a resource change.
@@ -93,17 +165,43 @@ type ManagedResourceApplyRequest struct {
 	Config        cty.Value
 	ProviderMeta  cty.Value
 	OpaquePrivate []byte
+
+	// PrivateSchemaVersion is the schema version that OpaquePrivate was
+	// produced under, as returned alongside it by an earlier operation, if
+	// known. When the WithPrivateSchemaVersion option is enabled, a
+	// mismatch with the current schema version produces a warning.
+	PrivateSchemaVersion *int64
+
+	// InstanceKey optionally identifies the resource instance that the
+	// request relates to. It is not sent to the provider, but is recorded
+	// in diagnostics when the WithResourceDiagnosticContext option is
+	// enabled.
+	InstanceKey string
 }
 
 // ManagedResourceApplyResponse represents the response from applying a resource change.
 type ManagedResourceApplyResponse struct {
-	NewState      cty.Value
+	NewState cty.Value
+
+	// OpaquePrivate is the private data returned by the provider, or the
+	// private data from the request if the provider returned none.
 	OpaquePrivate []byte
+
+	// PrivateSchemaVersion is the schema version that OpaquePrivate was
+	// produced under, which callers can store alongside it and return in
+	// the PrivateSchemaVersion field of later requests.
+	PrivateSchemaVersion int64
 }
 
 // ManagedResourceImportRequest represents a request to import a resource.
 type ManagedResourceImportRequest struct {
 	ID string
+
+	// InstanceKey optionally identifies the resource instance that the
+	// request relates to. It is not sent to the provider, but is recorded
+	// in diagnostics when the WithResourceDiagnosticContext option is
+	// enabled.
+	InstanceKey string
 }
 
 // ImportedResource represents a single imported resource in the response.
@@ -111,6 +209,11 @@ type ImportedResource struct {
 	TypeName      string
 	State         cty.Value
 	OpaquePrivate []byte
+
+	// PrivateSchemaVersion is the schema version that OpaquePrivate was
+	// produced under, which callers can store alongside it and return in
+	// the PrivateSchemaVersion field of later requests.
+	PrivateSchemaVersion int64
 }
 
 // ManagedResourceImportResponse represents the response from importing a resource.
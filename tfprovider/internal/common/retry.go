@@ -0,0 +1,114 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// RetryPolicy describes how an idempotent RPC call should be retried when
+// it fails with a transient error, such as the plugin not yet being ready
+// to accept requests.
+//
+// Only errors with the gRPC status codes Unavailable, ResourceExhausted, and
+// Aborted are retried. Errors reported by the provider as diagnostics are
+// never retried.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts to make, including the
+	// first. Values less than one are treated as one.
+	MaxAttempts int
+
+	// Delay is the time to wait before the second attempt, which is then
+	// doubled before each subsequent attempt, up to MaxDelay if that is
+	// greater than zero.
+	Delay    time.Duration
+	MaxDelay time.Duration
+}
+
+// Retry calls the given function until it succeeds, fails with an error that
+// isn't retryable, or the policy's attempts are exhausted, returning the
+// error from the final attempt. A nil policy makes only one attempt.
+//
+// Retry stops early with the context's error if the context is cancelled
+// while waiting between attempts.
+func (p *RetryPolicy) Retry(ctx context.Context, fn func() error) error {
+	return p.RetryIf(ctx, fn, retryableRPCError)
+}
+
+// RetryIf is like Retry but retries errors for which the given function
+// returns true, rather than only transient RPC errors.
+func (p *RetryPolicy) RetryIf(ctx context.Context, fn func() error, retryable func(error) bool) error {
+	attempts := 1
+	var delay time.Duration
+	if p != nil {
+		if p.MaxAttempts > 1 {
+			attempts = p.MaxAttempts
+		}
+		delay = p.Delay
+	}
+
+	var err error
+	for i := 0; i < attempts; i++ {
+		if i > 0 {
+			timer := time.NewTimer(delay)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return ctx.Err()
+			case <-timer.C:
+			}
+			delay *= 2
+			if p.MaxDelay > 0 && delay > p.MaxDelay {
+				delay = p.MaxDelay
+			}
+		}
+		err = fn()
+		if err == nil || !retryable(err) {
+			return err
+		}
+	}
+	return err
+}
+
+func retryableRPCError(err error) bool {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.ResourceExhausted, codes.Aborted:
+		return true
+	default:
+		return false
+	}
+}
+
+// errRetryableImport is the error used internally by RetryImport to signal
+// that an import attempt returned a retryable diagnostic.
+var errRetryableImport = errors.New("import returned a retryable diagnostic")
+
+// RetryImport calls the given import function, retrying it according to
+// the ImportRetry policy from the options for as long as the returned
+// diagnostics include an error that ImportRetryable reports as retryable.
+// The result of the final attempt is returned. If either of the options is
+// unset, the function is called only once.
+func (o *Options) RetryImport(ctx context.Context, importFn func() (ManagedResourceImportResponse, Diagnostics)) (ManagedResourceImportResponse, Diagnostics) {
+	if o.ImportRetry == nil || o.ImportRetryable == nil {
+		return importFn()
+	}
+	var resp ManagedResourceImportResponse
+	var diags Diagnostics
+	o.ImportRetry.RetryIf(ctx, func() error {
+		resp, diags = importFn()
+		for _, diag := range diags {
+			if diag.Severity == Error && o.ImportRetryable(diag) {
+				return errRetryableImport
+			}
+		}
+		return nil
+	}, func(err error) bool {
+		return err == errRetryableImport
+	})
+	// If the context was cancelled while waiting to retry then the
+	// diagnostics from the last attempt still describe the failure.
+	return resp, diags
+}
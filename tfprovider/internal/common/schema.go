@@ -1,23 +1,155 @@
 package common
 
 import (
+	"fmt"
+	"sort"
+
 	"github.com/apparentlymart/terraform-schema-go/tfschema"
+	"github.com/zclconf/go-cty/cty"
 )
 
 type Schema struct {
-	ProviderConfig       *tfschema.Block
+	ProviderConfig *tfschema.Block
+
+	// ProviderMeta is the schema for the provider_meta block in modules
+	// that use this provider, or nil if the provider doesn't declare one.
 	ProviderMeta         *tfschema.Block
 	ManagedResourceTypes map[string]*ManagedResourceTypeSchema
 	DataResourceTypes    map[string]*DataResourceTypeSchema
 }
 
+// TypeInventory lists the names of the resource types in a provider's
+// schema, each in lexical order.
+//
+// There are no fields for ephemeral resource types or provider functions
+// because the versions of the plugin protocol this package implements
+// predate them: GetProviderSchema has no way to return them, so every
+// provider would report none.
+type TypeInventory struct {
+	ManagedResourceTypes []string
+	DataResourceTypes    []string
+}
+
+// TypeInventory returns the names of all of the resource types in the
+// schema.
+func (s *Schema) TypeInventory() TypeInventory {
+	ret := TypeInventory{
+		ManagedResourceTypes: make([]string, 0, len(s.ManagedResourceTypes)),
+		DataResourceTypes:    make([]string, 0, len(s.DataResourceTypes)),
+	}
+	for name := range s.ManagedResourceTypes {
+		ret.ManagedResourceTypes = append(ret.ManagedResourceTypes, name)
+	}
+	for name := range s.DataResourceTypes {
+		ret.DataResourceTypes = append(ret.DataResourceTypes, name)
+	}
+	sort.Strings(ret.ManagedResourceTypes)
+	sort.Strings(ret.DataResourceTypes)
+	return ret
+}
+
+// ImpliedTyper is implemented by schema types that imply an object type for
+// values conforming to them, which is the type used to encode and decode
+// those values for the provider. *tfschema.Block implements it directly, but
+// recomputes the type on each call.
+type ImpliedTyper interface {
+	ImpliedType() cty.Type
+}
+
 type ManagedResourceTypeSchema struct {
 	Version int64
 	Content *tfschema.Block
+
+	impliedType cty.Type
+}
+
+// NewManagedResourceTypeSchema returns a managed resource type schema with
+// the given version and content, with the implied type of the content
+// computed once up front so that encoding and decoding values doesn't need
+// to recompute it each time.
+func NewManagedResourceTypeSchema(version int64, content *tfschema.Block) *ManagedResourceTypeSchema {
+	return &ManagedResourceTypeSchema{
+		Version:     version,
+		Content:     content,
+		impliedType: content.ImpliedType(),
+	}
+}
+
+// ImpliedType returns the object type implied by the schema content. The
+// type is cached if the schema was created with NewManagedResourceTypeSchema,
+// and computed from the content otherwise.
+func (s *ManagedResourceTypeSchema) ImpliedType() cty.Type {
+	if s.impliedType != cty.NilType {
+		return s.impliedType
+	}
+	return s.Content.ImpliedType()
+}
+
+// CheckStateVersion returns error diagnostics if a state value produced
+// under the given schema version cannot be used directly with the current
+// version of the schema for the given resource type.
+func (s *ManagedResourceTypeSchema) CheckStateVersion(typeName string, version int64) Diagnostics {
+	switch {
+	case version == s.Version:
+		return nil
+	case version < s.Version:
+		return Diagnostics{
+			{
+				Severity: Error,
+				Summary:  "Resource state requires upgrade",
+				Detail:   fmt.Sprintf("The given state for %s was produced under schema version %d, but the provider now uses version %d. Upgrade the state using UpgradeResourceState before reading it.", typeName, version, s.Version),
+			},
+		}
+	default:
+		return Diagnostics{
+			{
+				Severity: Error,
+				Summary:  "Resource state from newer provider",
+				Detail:   fmt.Sprintf("The given state for %s was produced under schema version %d, which is newer than the version %d used by this provider. A newer version of the provider is required.", typeName, version, s.Version),
+			},
+		}
+	}
+}
+
+// CheckPrivateVersion returns warning diagnostics if the given options
+// request checking of private data versions and the given version, if any,
+// doesn't match the current version of the schema for the given resource
+// type.
+func (s *ManagedResourceTypeSchema) CheckPrivateVersion(opts *Options, typeName string, private []byte, version *int64) Diagnostics {
+	if !opts.CheckPrivateSchemaVersion || len(private) == 0 || version == nil || *version == s.Version {
+		return nil
+	}
+	return Diagnostics{
+		{
+			Severity: Warning,
+			Summary:  "Private data from different schema version",
+			Detail:   fmt.Sprintf("The given private data for %s was produced under schema version %d, but the provider now uses version %d. The provider may not be able to interpret it correctly.", typeName, *version, s.Version),
+		},
+	}
 }
 
 type DataResourceTypeSchema struct {
 	Content *tfschema.Block
+
+	impliedType cty.Type
+}
+
+// NewDataResourceTypeSchema is like NewManagedResourceTypeSchema but for
+// data resource types.
+func NewDataResourceTypeSchema(content *tfschema.Block) *DataResourceTypeSchema {
+	return &DataResourceTypeSchema{
+		Content:     content,
+		impliedType: content.ImpliedType(),
+	}
+}
+
+// ImpliedType returns the object type implied by the schema content, using
+// the cached type if the schema was created with NewDataResourceTypeSchema.
+func (s *DataResourceTypeSchema) ImpliedType() cty.Type {
+	if s.impliedType != cty.NilType {
+		return s.impliedType
+	}
+	return s.Content.ImpliedType()
 }
 
 func (s *Schema) HasManagedResourceType(name string) bool {
@@ -29,3 +161,62 @@ func (s *Schema) HasDataResourceType(name string) bool {
 	_, ok := s.DataResourceTypes[name]
 	return ok
 }
+
+// CheckProviderMetaConformance returns an error diagnostic for each way in
+// which the given provider_meta value fails to conform to the given
+// provider_meta schema, with the path of the offending attribute where
+// possible.
+func CheckProviderMetaConformance(val cty.Value, schema *tfschema.Block) Diagnostics {
+	var diags Diagnostics
+	for _, err := range val.Type().TestConformance(schema.ImpliedType()) {
+		diag := Diagnostic{
+			Severity: Error,
+			Summary:  "Invalid provider_meta value",
+			Detail:   fmt.Sprintf("The provider_meta value does not conform to the provider's provider_meta schema: %s.", err),
+		}
+		if pathErr, ok := err.(cty.PathError); ok {
+			diag.Attribute = pathErr.Path
+			if len(pathErr.Path) > 0 {
+				diag.Detail = fmt.Sprintf("The provider_meta value does not conform to the provider's provider_meta schema at %s: %s.", FormatPath(pathErr.Path), err)
+			}
+		}
+		diags = append(diags, diag)
+	}
+	return diags
+}
+
+// DeprecationWarnings returns the warning diagnostics describing the parts
+// of a provider's schema that the provider has marked as deprecated: the
+// provider as a whole if providerDeprecated is set, and then each of the
+// given managed and data resource types in lexical order by name.
+//
+// The protocol can only mark schemas as deprecated, without any message
+// explaining why or what to use instead.
+func DeprecationWarnings(providerDeprecated bool, managed, data []string) Diagnostics {
+	var diags Diagnostics
+	if providerDeprecated {
+		diags = append(diags, Diagnostic{
+			Severity: Warning,
+			Summary:  "Deprecated provider",
+			Detail:   "The provider has marked its configuration as deprecated, so it may be removed or replaced in a future release. Refer to the provider's documentation for details.",
+		})
+	}
+	for _, types := range []struct {
+		mode  ResourceMode
+		names []string
+	}{
+		{ManagedResourceMode, managed},
+		{DataResourceMode, data},
+	} {
+		names := append([]string(nil), types.names...)
+		sort.Strings(names)
+		for _, name := range names {
+			diags = append(diags, Diagnostic{
+				Severity: Warning,
+				Summary:  "Deprecated resource type",
+				Detail:   fmt.Sprintf("The provider has marked the %s resource type %q as deprecated, so it may be removed in a future release. Refer to the provider's documentation for details.", types.mode, name),
+			})
+		}
+	}
+	return diags
+}
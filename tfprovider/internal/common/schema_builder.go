@@ -0,0 +1,69 @@
+package common
+
+import (
+	"github.com/apparentlymart/terraform-schema-go/tfschema"
+)
+
+// SchemaBuilder constructs a Schema by hand, for testing code that works
+// with provider schemas without needing a real provider plugin. The result
+// can be used with an offline provider and with the encoding and decoding
+// functions.
+//
+// Each method returns the receiver so that calls can be chained.
+type SchemaBuilder struct {
+	schema Schema
+}
+
+// NewSchemaBuilder returns a builder for a schema that initially has an
+// empty provider configuration block and no resource types.
+func NewSchemaBuilder() *SchemaBuilder {
+	return &SchemaBuilder{
+		schema: Schema{
+			ProviderConfig:       &tfschema.Block{},
+			ManagedResourceTypes: make(map[string]*ManagedResourceTypeSchema),
+			DataResourceTypes:    make(map[string]*DataResourceTypeSchema),
+		},
+	}
+}
+
+// ProviderConfig sets the schema for the provider's configuration block.
+func (b *SchemaBuilder) ProviderConfig(block *tfschema.Block) *SchemaBuilder {
+	b.schema.ProviderConfig = block
+	return b
+}
+
+// ProviderMeta sets the schema for the provider_meta block.
+func (b *SchemaBuilder) ProviderMeta(block *tfschema.Block) *SchemaBuilder {
+	b.schema.ProviderMeta = block
+	return b
+}
+
+// AddManagedResource adds a managed resource type with the given name,
+// schema version, and content, replacing any existing one with that name.
+func (b *SchemaBuilder) AddManagedResource(name string, version int64, block *tfschema.Block) *SchemaBuilder {
+	b.schema.ManagedResourceTypes[name] = NewManagedResourceTypeSchema(version, block)
+	return b
+}
+
+// AddDataResource adds a data resource type with the given name and
+// content, replacing any existing one with that name.
+func (b *SchemaBuilder) AddDataResource(name string, block *tfschema.Block) *SchemaBuilder {
+	b.schema.DataResourceTypes[name] = NewDataResourceTypeSchema(block)
+	return b
+}
+
+// Build returns the schema built so far. Later changes made through the
+// builder do not affect schemas it has already returned, but the blocks
+// given to the builder are shared and so must not be modified.
+func (b *SchemaBuilder) Build() *Schema {
+	ret := b.schema
+	ret.ManagedResourceTypes = make(map[string]*ManagedResourceTypeSchema, len(b.schema.ManagedResourceTypes))
+	for name, s := range b.schema.ManagedResourceTypes {
+		ret.ManagedResourceTypes[name] = s
+	}
+	ret.DataResourceTypes = make(map[string]*DataResourceTypeSchema, len(b.schema.DataResourceTypes))
+	for name, s := range b.schema.DataResourceTypes {
+		ret.DataResourceTypes[name] = s
+	}
+	return &ret
+}
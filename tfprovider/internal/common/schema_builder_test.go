@@ -0,0 +1,82 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/apparentlymart/terraform-schema-go/tfschema"
+	"github.com/zclconf/go-cty/cty"
+)
+
+func TestSchemaBuilder(t *testing.T) {
+	thing := &tfschema.Block{
+		Attributes: map[string]*tfschema.Attribute{
+			"id":   {Type: cty.String, Computed: true},
+			"tags": {Type: cty.Map(cty.String), Optional: true},
+		},
+		BlockTypes: map[string]*tfschema.NestedBlock{
+			"rule": {
+				Nesting: tfschema.NestingList,
+				Block: tfschema.Block{
+					Attributes: map[string]*tfschema.Attribute{
+						"port": {Type: cty.Number, Required: true},
+					},
+				},
+			},
+		},
+	}
+	data := &tfschema.Block{
+		Attributes: map[string]*tfschema.Attribute{
+			"name": {Type: cty.String, Required: true},
+		},
+	}
+	b := NewSchemaBuilder().
+		AddManagedResource("test_thing", 2, thing).
+		AddDataResource("test_data", data)
+	schema := b.Build()
+
+	if got := schema.ProviderConfig; got == nil || len(got.Attributes) != 0 {
+		t.Errorf("wrong provider config schema %#v; want an empty block", got)
+	}
+	rs, ok := schema.ManagedResourceTypes["test_thing"]
+	if !ok {
+		t.Fatal("no schema for test_thing")
+	}
+	if rs.Version != 2 || rs.Content != thing {
+		t.Errorf("wrong schema for test_thing %#v", rs)
+	}
+	if _, ok := schema.DataResourceTypes["test_data"]; !ok {
+		t.Error("no schema for test_data")
+	}
+
+	// Schemas already built are not affected by later changes.
+	b.AddManagedResource("test_other", 0, thing)
+	if _, ok := schema.ManagedResourceTypes["test_other"]; ok {
+		t.Error("earlier schema changed by later AddManagedResource")
+	}
+	if _, ok := b.Build().ManagedResourceTypes["test_other"]; !ok {
+		t.Error("no schema for test_other in later schema")
+	}
+
+	val := cty.ObjectVal(map[string]cty.Value{
+		"id": cty.StringVal("a"),
+		"tags": cty.MapVal(map[string]cty.Value{
+			"Name": cty.StringVal("example"),
+		}),
+		"rule": cty.ListVal([]cty.Value{
+			cty.ObjectVal(map[string]cty.Value{
+				"port": cty.NumberIntVal(80),
+			}),
+		}),
+	})
+	encoded, diags := EncodeDynamicValue(val, rs)
+	if diags.HasErrors() {
+		t.Fatalf("unexpected errors encoding: %s", diags.Err())
+	}
+	got, diags := DecodeDynamicValue(encoded, rs)
+	if diags.HasErrors() {
+		t.Fatalf("unexpected errors decoding: %s", diags.Err())
+	}
+	if !got.RawEquals(val) {
+		t.Errorf("wrong round-tripped value\ngot:  %#v\nwant: %#v", got, val)
+	}
+}
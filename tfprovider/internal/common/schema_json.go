@@ -0,0 +1,156 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/apparentlymart/terraform-schema-go/tfschema"
+	ctyjson "github.com/zclconf/go-cty/cty/json"
+)
+
+// providersSchemaFormatVersion is the version of the JSON format produced
+// by ProvidersSchemaJSON, matching the version Terraform reports for the
+// same format.
+const providersSchemaFormatVersion = "1.0"
+
+// The following types mirror the JSON structure of the output of
+// "terraform providers schema -json".
+
+type providersSchemaJSON struct {
+	FormatVersion   string                         `json:"format_version"`
+	ProviderSchemas map[string]*providerSchemaJSON `json:"provider_schemas"`
+}
+
+type providerSchemaJSON struct {
+	Provider          *schemaJSON           `json:"provider,omitempty"`
+	ResourceSchemas   map[string]schemaJSON `json:"resource_schemas,omitempty"`
+	DataSourceSchemas map[string]schemaJSON `json:"data_source_schemas,omitempty"`
+}
+
+type schemaJSON struct {
+	Version int64      `json:"version"`
+	Block   *blockJSON `json:"block,omitempty"`
+}
+
+type blockJSON struct {
+	Attributes map[string]*attributeJSON   `json:"attributes,omitempty"`
+	BlockTypes map[string]*nestedBlockJSON `json:"block_types,omitempty"`
+}
+
+type attributeJSON struct {
+	Type            json.RawMessage `json:"type"`
+	Description     string          `json:"description,omitempty"`
+	DescriptionKind string          `json:"description_kind,omitempty"`
+	Required        bool            `json:"required,omitempty"`
+	Optional        bool            `json:"optional,omitempty"`
+	Computed        bool            `json:"computed,omitempty"`
+	Sensitive       bool            `json:"sensitive,omitempty"`
+}
+
+type nestedBlockJSON struct {
+	NestingMode string     `json:"nesting_mode"`
+	Block       *blockJSON `json:"block"`
+}
+
+// ProvidersSchemaJSON returns the schema in the JSON format produced by
+// "terraform providers schema -json", describing a single provider with
+// the given source address, such as "registry.terraform.io/hashicorp/aws",
+// so that tools written to consume Terraform's output can also consume
+// schemas obtained using this module.
+//
+// Descriptions are always reported as plain text, because the schema
+// doesn't record whether they use Markdown.
+func (s *Schema) ProvidersSchemaJSON(address string) ([]byte, error) {
+	provider := &providerSchemaJSON{
+		ResourceSchemas:   make(map[string]schemaJSON, len(s.ManagedResourceTypes)),
+		DataSourceSchemas: make(map[string]schemaJSON, len(s.DataResourceTypes)),
+	}
+
+	var err error
+	if s.ProviderConfig != nil {
+		provider.Provider = &schemaJSON{}
+		if provider.Provider.Block, err = marshalBlockJSON(s.ProviderConfig); err != nil {
+			return nil, fmt.Errorf("provider configuration: %s", err)
+		}
+	}
+	for name, rs := range s.ManagedResourceTypes {
+		block, err := marshalBlockJSON(rs.Content)
+		if err != nil {
+			return nil, fmt.Errorf("managed resource type %q: %s", name, err)
+		}
+		provider.ResourceSchemas[name] = schemaJSON{Version: rs.Version, Block: block}
+	}
+	for name, rs := range s.DataResourceTypes {
+		block, err := marshalBlockJSON(rs.Content)
+		if err != nil {
+			return nil, fmt.Errorf("data resource type %q: %s", name, err)
+		}
+		provider.DataSourceSchemas[name] = schemaJSON{Block: block}
+	}
+
+	return json.Marshal(providersSchemaJSON{
+		FormatVersion: providersSchemaFormatVersion,
+		ProviderSchemas: map[string]*providerSchemaJSON{
+			address: provider,
+		},
+	})
+}
+
+func marshalBlockJSON(block *tfschema.Block) (*blockJSON, error) {
+	ret := &blockJSON{}
+	if len(block.Attributes) != 0 {
+		ret.Attributes = make(map[string]*attributeJSON, len(block.Attributes))
+	}
+	for name, attr := range block.Attributes {
+		ty, err := ctyjson.MarshalType(attr.Type)
+		if err != nil {
+			return nil, fmt.Errorf("attribute %q: %s", name, err)
+		}
+		attrJSON := &attributeJSON{
+			Type:        ty,
+			Description: attr.Description,
+			Required:    attr.Required,
+			Optional:    attr.Optional,
+			Computed:    attr.Computed,
+			Sensitive:   attr.Sensitive,
+		}
+		if attr.Description != "" {
+			attrJSON.DescriptionKind = "plain"
+		}
+		ret.Attributes[name] = attrJSON
+	}
+
+	if len(block.BlockTypes) != 0 {
+		ret.BlockTypes = make(map[string]*nestedBlockJSON, len(block.BlockTypes))
+	}
+	for name, blockS := range block.BlockTypes {
+		content, err := marshalBlockJSON(&blockS.Block)
+		if err != nil {
+			return nil, fmt.Errorf("block type %q: %s", name, err)
+		}
+		ret.BlockTypes[name] = &nestedBlockJSON{
+			NestingMode: nestingModeJSON(blockS.Nesting),
+			Block:       content,
+		}
+	}
+	return ret, nil
+}
+
+// nestingModeJSON returns the name Terraform uses for the given nesting
+// mode in its JSON output.
+func nestingModeJSON(mode tfschema.NestingMode) string {
+	switch mode {
+	case tfschema.NestingSingle:
+		return "single"
+	case tfschema.NestingGroup:
+		return "group"
+	case tfschema.NestingList:
+		return "list"
+	case tfschema.NestingSet:
+		return "set"
+	case tfschema.NestingMap:
+		return "map"
+	default:
+		return "invalid"
+	}
+}
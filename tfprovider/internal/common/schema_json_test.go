@@ -0,0 +1,184 @@
+package common
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/apparentlymart/terraform-schema-go/tfschema"
+	"github.com/zclconf/go-cty/cty"
+)
+
+func TestSchemaProvidersSchemaJSON(t *testing.T) {
+	nested := func(mode tfschema.NestingMode, name string, attr *tfschema.Attribute) *tfschema.NestedBlock {
+		return &tfschema.NestedBlock{
+			Nesting: mode,
+			Block: tfschema.Block{
+				Attributes: map[string]*tfschema.Attribute{name: attr},
+			},
+		}
+	}
+	schema := NewSchemaBuilder().
+		ProviderConfig(&tfschema.Block{
+			Attributes: map[string]*tfschema.Attribute{
+				"region": {Type: cty.String, Optional: true, Description: "The region to use."},
+			},
+		}).
+		AddManagedResource("test_thing", 2, &tfschema.Block{
+			Attributes: map[string]*tfschema.Attribute{
+				"id":       {Type: cty.String, Computed: true},
+				"password": {Type: cty.String, Optional: true, Sensitive: true},
+				"tags":     {Type: cty.Map(cty.String), Optional: true, Computed: true},
+			},
+			BlockTypes: map[string]*tfschema.NestedBlock{
+				"config":  nested(tfschema.NestingSingle, "enabled", &tfschema.Attribute{Type: cty.Bool, Required: true}),
+				"group":   nested(tfschema.NestingGroup, "size", &tfschema.Attribute{Type: cty.Number, Optional: true}),
+				"rule":    nested(tfschema.NestingList, "port", &tfschema.Attribute{Type: cty.Number, Required: true}),
+				"ingress": nested(tfschema.NestingSet, "cidrs", &tfschema.Attribute{Type: cty.Set(cty.String), Required: true}),
+				"labels":  nested(tfschema.NestingMap, "value", &tfschema.Attribute{Type: cty.String, Optional: true}),
+			},
+		}).
+		AddDataResource("test_data", &tfschema.Block{
+			Attributes: map[string]*tfschema.Attribute{
+				"name":  {Type: cty.String, Required: true},
+				"value": {Type: cty.String, Computed: true},
+			},
+		}).
+		Build()
+
+	src, err := schema.ProvidersSchemaJSON("registry.terraform.io/example/test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, src, "", "  "); err != nil {
+		t.Fatalf("result is not valid JSON: %s", err)
+	}
+	if got := buf.String(); got != providersSchemaJSONGolden {
+		t.Errorf("wrong result\ngot:\n%s\nwant:\n%s", got, providersSchemaJSONGolden)
+	}
+}
+
+const providersSchemaJSONGolden = `{
+  "format_version": "1.0",
+  "provider_schemas": {
+    "registry.terraform.io/example/test": {
+      "provider": {
+        "version": 0,
+        "block": {
+          "attributes": {
+            "region": {
+              "type": "string",
+              "description": "The region to use.",
+              "description_kind": "plain",
+              "optional": true
+            }
+          }
+        }
+      },
+      "resource_schemas": {
+        "test_thing": {
+          "version": 2,
+          "block": {
+            "attributes": {
+              "id": {
+                "type": "string",
+                "computed": true
+              },
+              "password": {
+                "type": "string",
+                "optional": true,
+                "sensitive": true
+              },
+              "tags": {
+                "type": [
+                  "map",
+                  "string"
+                ],
+                "optional": true,
+                "computed": true
+              }
+            },
+            "block_types": {
+              "config": {
+                "nesting_mode": "single",
+                "block": {
+                  "attributes": {
+                    "enabled": {
+                      "type": "bool",
+                      "required": true
+                    }
+                  }
+                }
+              },
+              "group": {
+                "nesting_mode": "group",
+                "block": {
+                  "attributes": {
+                    "size": {
+                      "type": "number",
+                      "optional": true
+                    }
+                  }
+                }
+              },
+              "ingress": {
+                "nesting_mode": "set",
+                "block": {
+                  "attributes": {
+                    "cidrs": {
+                      "type": [
+                        "set",
+                        "string"
+                      ],
+                      "required": true
+                    }
+                  }
+                }
+              },
+              "labels": {
+                "nesting_mode": "map",
+                "block": {
+                  "attributes": {
+                    "value": {
+                      "type": "string",
+                      "optional": true
+                    }
+                  }
+                }
+              },
+              "rule": {
+                "nesting_mode": "list",
+                "block": {
+                  "attributes": {
+                    "port": {
+                      "type": "number",
+                      "required": true
+                    }
+                  }
+                }
+              }
+            }
+          }
+        }
+      },
+      "data_source_schemas": {
+        "test_data": {
+          "version": 0,
+          "block": {
+            "attributes": {
+              "name": {
+                "type": "string",
+                "required": true
+              },
+              "value": {
+                "type": "string",
+                "computed": true
+              }
+            }
+          }
+        }
+      }
+    }
+  }
+}`
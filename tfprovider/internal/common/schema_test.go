@@ -0,0 +1,71 @@
+package common
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/zclconf/go-cty/cty"
+)
+
+func TestSchemaTypeInventory(t *testing.T) {
+	schema := &Schema{
+		ManagedResourceTypes: map[string]*ManagedResourceTypeSchema{
+			"test_zebra":  {},
+			"test_apple":  {},
+			"test_shared": {},
+			"test_mango":  {},
+		},
+		DataResourceTypes: map[string]*DataResourceTypeSchema{
+			"test_shared": {},
+			"test_banana": {},
+		},
+	}
+
+	got := schema.TypeInventory()
+	want := TypeInventory{
+		ManagedResourceTypes: []string{"test_apple", "test_mango", "test_shared", "test_zebra"},
+		DataResourceTypes:    []string{"test_banana", "test_shared"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("wrong result\ngot:  %#v\nwant: %#v", got, want)
+	}
+}
+
+func TestSchemaTypeInventoryEmpty(t *testing.T) {
+	got := (&Schema{}).TypeInventory()
+
+	// Callers can range over or marshal the result without nil checks.
+	if got.ManagedResourceTypes == nil || len(got.ManagedResourceTypes) != 0 {
+		t.Errorf("wrong managed resource types %#v; want empty non-nil slice", got.ManagedResourceTypes)
+	}
+	if got.DataResourceTypes == nil || len(got.DataResourceTypes) != 0 {
+		t.Errorf("wrong data resource types %#v; want empty non-nil slice", got.DataResourceTypes)
+	}
+}
+
+func TestResourceTypeSchemaImpliedType(t *testing.T) {
+	want := testBlock.ImpliedType()
+
+	managed := NewManagedResourceTypeSchema(2, testBlock)
+	if managed.impliedType == cty.NilType {
+		t.Error("managed resource type schema did not cache its implied type")
+	}
+	if got := managed.ImpliedType(); !got.Equals(want) {
+		t.Errorf("wrong managed resource type %#v; want %#v", got, want)
+	}
+	data := NewDataResourceTypeSchema(testBlock)
+	if data.impliedType == cty.NilType {
+		t.Error("data resource type schema did not cache its implied type")
+	}
+	if got := data.ImpliedType(); !got.Equals(want) {
+		t.Errorf("wrong data resource type %#v; want %#v", got, want)
+	}
+
+	// Schemas constructed directly compute the type on each call instead.
+	if got := (&ManagedResourceTypeSchema{Content: testBlock}).ImpliedType(); !got.Equals(want) {
+		t.Errorf("wrong uncached managed resource type %#v; want %#v", got, want)
+	}
+	if got := (&DataResourceTypeSchema{Content: testBlock}).ImpliedType(); !got.Equals(want) {
+		t.Errorf("wrong uncached data resource type %#v; want %#v", got, want)
+	}
+}
@@ -0,0 +1,57 @@
+package common
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/zclconf/go-cty/cty"
+)
+
+// SecretResolver resolves references to secrets held in an external secret
+// store, such as "vault:secret/db#password", into the secret values.
+type SecretResolver struct {
+	// Scheme is the prefix, not including the colon that follows it, that
+	// identifies string values that are references for this resolver.
+	Scheme string
+
+	// Resolve returns the secret value for the given reference, which is
+	// the string value with the scheme and colon removed.
+	Resolve func(ref string) (string, error)
+}
+
+// ResolveSecrets returns a copy of the given value in which every known
+// string starting with the scheme of one of the secret resolvers from the
+// options, followed by a colon, is replaced by the secret value it refers
+// to. An error diagnostic is returned for each reference that cannot be
+// resolved.
+func (o *Options) ResolveSecrets(val cty.Value) (cty.Value, Diagnostics) {
+	if len(o.SecretResolvers) == 0 {
+		return val, nil
+	}
+	var diags Diagnostics
+	ret, _ := cty.Transform(val, func(path cty.Path, v cty.Value) (cty.Value, error) {
+		if v.Type() != cty.String || v.IsNull() || !v.IsKnown() {
+			return v, nil
+		}
+		s := v.AsString()
+		for _, resolver := range o.SecretResolvers {
+			ref := strings.TrimPrefix(s, resolver.Scheme+":")
+			if ref == s {
+				continue
+			}
+			secret, err := resolver.Resolve(ref)
+			if err != nil {
+				diags = append(diags, Diagnostic{
+					Severity:  Error,
+					Summary:   "Failed to resolve secret",
+					Detail:    fmt.Sprintf("The %s secret reference %q could not be resolved: %s.", resolver.Scheme, ref, err),
+					Attribute: path.Copy(),
+				})
+				return v, nil
+			}
+			return cty.StringVal(secret), nil
+		}
+		return v, nil
+	})
+	return ret, diags
+}
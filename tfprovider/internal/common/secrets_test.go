@@ -0,0 +1,76 @@
+package common
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/zclconf/go-cty/cty"
+)
+
+func TestOptionsResolveSecrets(t *testing.T) {
+	o := &Options{
+		SecretResolvers: []SecretResolver{
+			{
+				Scheme: "vault",
+				Resolve: func(ref string) (string, error) {
+					if ref == "broken" {
+						return "", errors.New("permission denied")
+					}
+					return "vault secret " + ref, nil
+				},
+			},
+			{
+				Scheme: "asm",
+				Resolve: func(ref string) (string, error) {
+					return "asm secret " + ref, nil
+				},
+			},
+		},
+	}
+
+	got, diags := o.ResolveSecrets(cty.ObjectVal(map[string]cty.Value{
+		"password": cty.StringVal("vault:db#password"),
+		"headers": cty.MapVal(map[string]cty.Value{
+			"Authorization": cty.StringVal("asm:api-token"),
+		}),
+		"plain":   cty.StringVal("example"),
+		"other":   cty.StringVal("ssm:parameter"),
+		"unknown": cty.UnknownVal(cty.String),
+		"null":    cty.NullVal(cty.String),
+	}))
+	if diags.HasErrors() {
+		t.Fatalf("unexpected errors: %s", diags.Err())
+	}
+	want := cty.ObjectVal(map[string]cty.Value{
+		"password": cty.StringVal("vault secret db#password"),
+		"headers": cty.MapVal(map[string]cty.Value{
+			"Authorization": cty.StringVal("asm secret api-token"),
+		}),
+		"plain":   cty.StringVal("example"),
+		"other":   cty.StringVal("ssm:parameter"),
+		"unknown": cty.UnknownVal(cty.String),
+		"null":    cty.NullVal(cty.String),
+	})
+	if !got.RawEquals(want) {
+		t.Errorf("wrong result\ngot:  %#v\nwant: %#v", got, want)
+	}
+
+	_, diags = o.ResolveSecrets(cty.ObjectVal(map[string]cty.Value{
+		"tokens": cty.ListVal([]cty.Value{
+			cty.StringVal("vault:fine"),
+			cty.StringVal("vault:broken"),
+		}),
+	}))
+	if len(diags) != 1 || diags[0].Summary != "Failed to resolve secret" {
+		t.Fatalf("wrong diagnostics %#v", diags)
+	}
+	if got, want := FormatPath(diags[0].Attribute), "tokens[1]"; got != want {
+		t.Errorf("wrong path %s; want %s", got, want)
+	}
+
+	// Without any resolvers, values are returned unchanged.
+	val := cty.StringVal("vault:db#password")
+	if got, _ := (&Options{}).ResolveSecrets(val); !got.RawEquals(val) {
+		t.Errorf("value changed without resolvers: %#v", got)
+	}
+}
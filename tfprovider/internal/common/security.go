@@ -0,0 +1,37 @@
+package common
+
+import (
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+)
+
+// ConnectionSecurity describes the transport security of the connection to
+// a provider plugin.
+type ConnectionSecurity struct {
+	// TLS is true if the connection is secured using TLS.
+	TLS bool
+
+	// Version and CipherSuite are the negotiated TLS version and cipher
+	// suite, as the corresponding constants from the crypto/tls package.
+	// Both are zero if TLS is false.
+	Version     uint16
+	CipherSuite uint16
+}
+
+// ConnectionSecurityFromPeer interprets the peer information that gRPC
+// captured for an RPC call, returning false if there is none, such as when
+// the call didn't reach a real plugin.
+func ConnectionSecurityFromPeer(p *peer.Peer) (ConnectionSecurity, bool) {
+	if p == nil || p.Addr == nil {
+		return ConnectionSecurity{}, false
+	}
+	info, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok {
+		return ConnectionSecurity{}, true
+	}
+	return ConnectionSecurity{
+		TLS:         true,
+		Version:     info.State.Version,
+		CipherSuite: info.State.CipherSuite,
+	}, true
+}
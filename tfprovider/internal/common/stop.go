@@ -0,0 +1,50 @@
+package common
+
+import (
+	"context"
+	"time"
+)
+
+// StopGracePeriod is how long CallWithStop allows for the provider to
+// respond to a request to stop, and then for the interrupted call to return,
+// before abandoning the call.
+const StopGracePeriod = 5 * time.Second
+
+// CallWithStop runs the given call, which is expected to make a single
+// long-running RPC to a provider plugin, in a way that gives the provider a
+// chance to wind down gracefully if the given context is cancelled.
+//
+// The call receives a context that is not cancelled along with ctx. If ctx
+// is cancelled before the call returns then stop is called, which should
+// ask the provider to halt its in-progress operations, and the call is then
+// given up to the given grace period to return before its context is
+// cancelled too. CallWithStop doesn't return until the call has returned.
+func CallWithStop(ctx context.Context, grace time.Duration, stop func(context.Context), call func(context.Context)) {
+	callCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	defer cancel()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		call(callCtx)
+	}()
+
+	select {
+	case <-done:
+		return
+	case <-ctx.Done():
+	}
+
+	stopCtx, stopCancel := context.WithTimeout(callCtx, grace)
+	stop(stopCtx)
+	stopCancel()
+
+	timer := time.NewTimer(grace)
+	defer timer.Stop()
+	select {
+	case <-done:
+	case <-timer.C:
+		cancel()
+		<-done
+	}
+}
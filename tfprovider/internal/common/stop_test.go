@@ -0,0 +1,68 @@
+package common
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestCallWithStop(t *testing.T) {
+	t.Run("completes", func(t *testing.T) {
+		stops := 0
+		CallWithStop(context.Background(), time.Second, func(context.Context) {
+			stops++
+		}, func(ctx context.Context) {})
+		if stops != 0 {
+			t.Errorf("stop called %d times for a call that completed", stops)
+		}
+	})
+
+	t.Run("stops", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		stopped := make(chan struct{})
+		var callErr error
+		CallWithStop(ctx, time.Minute, func(context.Context) {
+			close(stopped)
+		}, func(ctx context.Context) {
+			cancel()
+			select {
+			case <-stopped:
+			case <-time.After(10 * time.Second):
+			}
+			callErr = ctx.Err()
+		})
+		select {
+		case <-stopped:
+		default:
+			t.Fatal("stop was not called")
+		}
+		if callErr != nil {
+			t.Errorf("call's context was cancelled before the grace period: %s", callErr)
+		}
+	})
+
+	t.Run("grace period expires", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		stops := 0
+		var callErr error
+		start := time.Now()
+		CallWithStop(ctx, 20*time.Millisecond, func(context.Context) {
+			stops++
+		}, func(ctx context.Context) {
+			// The call ignores the request to stop, and returns only once
+			// its own context is cancelled.
+			cancel()
+			<-ctx.Done()
+			callErr = ctx.Err()
+		})
+		if stops != 1 {
+			t.Errorf("stop called %d times; want 1", stops)
+		}
+		if callErr != context.Canceled {
+			t.Errorf("wrong error from call's context %v", callErr)
+		}
+		if elapsed := time.Since(start); elapsed > 5*time.Second {
+			t.Errorf("call was abandoned only after %s", elapsed)
+		}
+	})
+}
@@ -0,0 +1,67 @@
+package common
+
+import (
+	"context"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+)
+
+// Operation names accepted as keys of the timeouts given to
+// TimeoutInterceptor, each covering the RPC methods of both protocol
+// versions that implement that operation.
+const (
+	OperationSchema    = "Schema"
+	OperationValidate  = "Validate"
+	OperationConfigure = "Configure"
+	OperationUpgrade   = "Upgrade"
+	OperationRead      = "Read"
+	OperationPlan      = "Plan"
+	OperationApply     = "Apply"
+	OperationImport    = "Import"
+)
+
+// methodOperations maps RPC method names to the operations they belong to.
+// Methods not listed, such as those asking the provider to stop, are never
+// subject to a timeout.
+var methodOperations = map[string]string{
+	"GetSchema":                  OperationSchema,
+	"GetProviderSchema":          OperationSchema,
+	"PrepareProviderConfig":      OperationValidate,
+	"ValidateProviderConfig":     OperationValidate,
+	"ValidateResourceTypeConfig": OperationValidate,
+	"ValidateResourceConfig":     OperationValidate,
+	"ValidateDataSourceConfig":   OperationValidate,
+	"ValidateDataResourceConfig": OperationValidate,
+	"Configure":                  OperationConfigure,
+	"ConfigureProvider":          OperationConfigure,
+	"UpgradeResourceState":       OperationUpgrade,
+	"ReadResource":               OperationRead,
+	"ReadDataSource":             OperationRead,
+	"PlanResourceChange":         OperationPlan,
+	"ApplyResourceChange":        OperationApply,
+	"ImportResourceState":        OperationImport,
+}
+
+// TimeoutInterceptor returns an RPCInterceptor that limits the time each
+// RPC call may take, using the timeout given in the operations map for the
+// operation the call belongs to, or the given default timeout otherwise. A
+// timeout of zero means no limit.
+func TimeoutInterceptor(def time.Duration, operations map[string]time.Duration) RPCInterceptor {
+	return func(ctx context.Context, method string, req, resp proto.Message, invoke RPCInvoker) error {
+		op, ok := methodOperations[method]
+		if !ok {
+			return invoke(ctx, req, resp)
+		}
+		timeout, ok := operations[op]
+		if !ok {
+			timeout = def
+		}
+		if timeout <= 0 {
+			return invoke(ctx, req, resp)
+		}
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+		return invoke(ctx, req, resp)
+	}
+}
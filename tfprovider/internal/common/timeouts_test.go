@@ -0,0 +1,73 @@
+package common
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+)
+
+func TestTimeoutInterceptor(t *testing.T) {
+	intercept := TimeoutInterceptor(time.Minute, map[string]time.Duration{
+		OperationApply:  time.Hour,
+		OperationImport: 0,
+	})
+
+	tests := map[string]struct {
+		method string
+		want   time.Duration // zero means no deadline
+	}{
+		"specific timeout":    {"ApplyResourceChange", time.Hour},
+		"global timeout":      {"ReadResource", time.Minute},
+		"global timeout v5":   {"GetSchema", time.Minute},
+		"specific zero":       {"ImportResourceState", 0},
+		"no operation":        {"StopProvider", 0},
+		"unknown method":      {"ExampleMethod", 0},
+		"data read is a read": {"ReadDataSource", time.Minute},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			start := time.Now()
+			var deadline time.Time
+			var hasDeadline bool
+			invoke := func(ctx context.Context, req, resp proto.Message) error {
+				deadline, hasDeadline = ctx.Deadline()
+				return nil
+			}
+			if err := intercept(context.Background(), test.method, nil, nil, invoke); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			if test.want == 0 {
+				if hasDeadline {
+					t.Errorf("call has deadline %s; want none", deadline)
+				}
+				return
+			}
+			if !hasDeadline {
+				t.Fatalf("call has no deadline; want %s", test.want)
+			}
+			// The deadline is measured from when the interceptor was called,
+			// which was shortly after start.
+			if got := deadline.Sub(start); got < test.want || got > test.want+time.Second {
+				t.Errorf("wrong timeout %s; want %s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestTimeoutInterceptorExpires(t *testing.T) {
+	intercept := TimeoutInterceptor(time.Hour, map[string]time.Duration{
+		OperationApply: 10 * time.Millisecond,
+	})
+	invoke := func(ctx context.Context, req, resp proto.Message) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	err := intercept(context.Background(), "ApplyResourceChange", nil, nil, invoke)
+	if err != context.DeadlineExceeded {
+		t.Errorf("wrong error %v; want %s", err, context.DeadlineExceeded)
+	}
+}
@@ -0,0 +1,66 @@
+package common
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/zclconf/go-cty/cty"
+)
+
+// UnknownPaths returns the paths of all of the unknown values within the
+// given value, in the order they are encountered when walking it.
+//
+// Once a managed resource has been applied its new state should be wholly
+// known, so a non-empty result for a value returned from Apply indicates a
+// bug in the provider.
+//
+// Unknown values are not descended into, so a wholly-unknown collection is
+// reported only by its own path. If the given value is itself unknown then
+// the result is a single empty path.
+func UnknownPaths(val cty.Value) []cty.Path {
+	var ret []cty.Path
+	cty.Walk(val, func(path cty.Path, v cty.Value) (bool, error) {
+		if !v.IsKnown() {
+			// The path's backing array is reused by Walk, so we must copy it.
+			ret = append(ret, append(make(cty.Path, 0, len(path)), path...))
+			return false, nil
+		}
+		return true, nil
+	})
+	return ret
+}
+
+// UnknownsAsNull returns a copy of the given value with each unknown value
+// within it replaced by a null value of the same type, along with a warning
+// listing the paths of the values replaced, if any.
+func UnknownsAsNull(val cty.Value) (cty.Value, Diagnostics) {
+	paths := UnknownPaths(val)
+	if len(paths) == 0 {
+		return val, nil
+	}
+	val, err := cty.Transform(val, func(path cty.Path, v cty.Value) (cty.Value, error) {
+		if !v.IsKnown() {
+			return cty.NullVal(v.Type()), nil
+		}
+		return v, nil
+	})
+	if err != nil {
+		// Our transform function never fails, so this should not happen.
+		panic(err)
+	}
+
+	formatted := make([]string, len(paths))
+	for i, path := range paths {
+		formatted[i] = FormatPath(path)
+		if formatted[i] == "" {
+			formatted[i] = "(the whole value)"
+		}
+	}
+	return val, Diagnostics{
+		{
+			Severity: Warning,
+			Summary:  "Unknown values replaced with null",
+			Detail:   fmt.Sprintf("The provider returned unknown values, which have been replaced with null: %s.", strings.Join(formatted, ", ")),
+		},
+	}
+}
@@ -0,0 +1,158 @@
+package common
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/apparentlymart/terraform-schema-go/tfschema"
+	"github.com/zclconf/go-cty/cty"
+)
+
+func TestUnknownPaths(t *testing.T) {
+	tests := map[string]struct {
+		val  cty.Value
+		want []string
+	}{
+		"wholly known": {
+			cty.ObjectVal(map[string]cty.Value{
+				"name": cty.StringVal("a"),
+			}),
+			nil,
+		},
+		"wholly unknown": {
+			cty.UnknownVal(cty.String),
+			[]string{""},
+		},
+		"attributes": {
+			cty.ObjectVal(map[string]cty.Value{
+				"id":   cty.UnknownVal(cty.String),
+				"name": cty.StringVal("a"),
+				"arn":  cty.UnknownVal(cty.String),
+			}),
+			[]string{"arn", "id"},
+		},
+		"nested in a list": {
+			cty.ObjectVal(map[string]cty.Value{
+				"rule": cty.ListVal([]cty.Value{
+					cty.ObjectVal(map[string]cty.Value{
+						"port": cty.NumberIntVal(80),
+					}),
+					cty.ObjectVal(map[string]cty.Value{
+						"port": cty.UnknownVal(cty.Number),
+					}),
+				}),
+			}),
+			[]string{"rule[1].port"},
+		},
+		"nested in a map": {
+			cty.ObjectVal(map[string]cty.Value{
+				"tags": cty.MapVal(map[string]cty.Value{
+					"Name":  cty.StringVal("a"),
+					"Owner": cty.UnknownVal(cty.String),
+				}),
+			}),
+			[]string{`tags["Owner"]`},
+		},
+		"nested in a set": {
+			cty.ObjectVal(map[string]cty.Value{
+				"ingress": cty.SetVal([]cty.Value{
+					cty.ObjectVal(map[string]cty.Value{
+						"cidr": cty.UnknownVal(cty.String),
+					}),
+				}),
+			}),
+			// Set elements are keyed by their own values, which can't be
+			// shown when they contain unknowns.
+			[]string{"ingress[...].cidr"},
+		},
+		"unknown collection": {
+			cty.ObjectVal(map[string]cty.Value{
+				"rule": cty.UnknownVal(cty.List(cty.String)),
+			}),
+			[]string{"rule"},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			var got []string
+			for _, path := range UnknownPaths(test.val) {
+				got = append(got, FormatPath(path))
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("wrong result\ngot:  %#v\nwant: %#v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestUnknownPathsCopies(t *testing.T) {
+	// Walk reuses the backing array of its paths, so each result must be
+	// an independent copy.
+	val := cty.ObjectVal(map[string]cty.Value{
+		"a": cty.ObjectVal(map[string]cty.Value{
+			"x": cty.UnknownVal(cty.String),
+		}),
+		"b": cty.ObjectVal(map[string]cty.Value{
+			"y": cty.UnknownVal(cty.String),
+		}),
+	})
+	got := UnknownPaths(val)
+	want := []cty.Path{
+		cty.GetAttrPath("a").GetAttr("x"),
+		cty.GetAttrPath("b").GetAttr("y"),
+	}
+	if len(got) != len(want) {
+		t.Fatalf("wrong number of paths %d; want %d", len(got), len(want))
+	}
+	for i := range want {
+		if !got[i].Equals(want[i]) {
+			t.Errorf("wrong path %d: %s; want %s", i, FormatPath(got[i]), FormatPath(want[i]))
+		}
+	}
+}
+
+func TestOptionsDecodeUnknownAsNull(t *testing.T) {
+	schema := &tfschema.Block{
+		Attributes: map[string]*tfschema.Attribute{
+			"id":   {Type: cty.String, Computed: true},
+			"name": {Type: cty.String, Optional: true},
+		},
+	}
+	data, diags := EncodeDynamicValue(cty.ObjectVal(map[string]cty.Value{
+		"id":   cty.UnknownVal(cty.String),
+		"name": cty.StringVal("a"),
+	}), schema)
+	if diags.HasErrors() {
+		t.Fatalf("unexpected errors: %s", diags.Err())
+	}
+
+	o := &Options{UnknownAsNull: true}
+	got, diags := o.DecodeDynamicValue(data, schema)
+	if diags.HasErrors() {
+		t.Fatalf("unexpected errors: %s", diags.Err())
+	}
+	want := cty.ObjectVal(map[string]cty.Value{
+		"id":   cty.NullVal(cty.String),
+		"name": cty.StringVal("a"),
+	})
+	if !got.RawEquals(want) {
+		t.Errorf("wrong result\ngot:  %#v\nwant: %#v", got, want)
+	}
+	if len(diags) != 1 || diags[0].Severity != Warning {
+		t.Fatalf("wrong diagnostics %#v; want one warning", diags)
+	}
+	if !strings.Contains(diags[0].Detail, "null: id.") {
+		t.Errorf("warning does not list the path: %s", diags[0].Detail)
+	}
+
+	// Without the option, the unknown value is retained.
+	got, diags = (&Options{}).DecodeDynamicValue(data, schema)
+	if len(diags) != 0 {
+		t.Fatalf("unexpected diagnostics %#v", diags)
+	}
+	if got.GetAttr("id").IsKnown() {
+		t.Errorf("unknown replaced without the option")
+	}
+}
@@ -0,0 +1,81 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// ValidationTargetKind identifies the kind of configuration that a
+// ValidationTarget refers to.
+type ValidationTargetKind int
+
+const (
+	ProviderConfigTarget ValidationTargetKind = iota
+	ManagedResourceConfigTarget
+	DataResourceConfigTarget
+)
+
+// ValidationTarget identifies one of the configurations validated by a call
+// to a provider's ValidateAll method.
+type ValidationTarget struct {
+	Kind ValidationTargetKind
+
+	// TypeName is the resource type name for managed and data resource
+	// targets, and empty for the provider configuration target.
+	TypeName string
+}
+
+func (t ValidationTarget) String() string {
+	switch t.Kind {
+	case ProviderConfigTarget:
+		return "provider"
+	case ManagedResourceConfigTarget:
+		return fmt.Sprintf("resource[%s]", t.TypeName)
+	case DataResourceConfigTarget:
+		return fmt.Sprintf("data[%s]", t.TypeName)
+	default:
+		return fmt.Sprintf("ValidationTarget(%d)[%s]", int(t.Kind), t.TypeName)
+	}
+}
+
+// validateAllParallelism is the maximum number of resource type validation
+// requests that RunValidations will have in progress at once.
+const validateAllParallelism = 4
+
+// RunValidations calls each of the given validation functions, with a
+// bounded number running concurrently, and returns the diagnostics from
+// each keyed by its target. Every given target is present in the result,
+// with nil diagnostics if its validation succeeded without warnings.
+func RunValidations(ctx context.Context, checks map[ValidationTarget]func(context.Context) Diagnostics) map[ValidationTarget]Diagnostics {
+	ret := make(map[ValidationTarget]Diagnostics, len(checks))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, validateAllParallelism)
+	for target, check := range checks {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(target ValidationTarget, check func(context.Context) Diagnostics) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			diags := check(ctx)
+			mu.Lock()
+			ret[target] = diags
+			mu.Unlock()
+		}(target, check)
+	}
+	wg.Wait()
+	return ret
+}
+
+// UnknownResourceTypeDiagnostics returns error diagnostics reporting that
+// the provider has no resource type of the given mode and name.
+func UnknownResourceTypeDiagnostics(mode ResourceMode, typeName string) Diagnostics {
+	return Diagnostics{
+		{
+			Severity: Error,
+			Summary:  "Unknown resource type",
+			Detail:   fmt.Sprintf("This provider does not support %s resource type %q.", mode, typeName),
+		},
+	}
+}
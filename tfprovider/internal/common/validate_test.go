@@ -0,0 +1,67 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRunValidations(t *testing.T) {
+	var mu sync.Mutex
+	running, maxRunning := 0, 0
+	checks := make(map[ValidationTarget]func(context.Context) Diagnostics)
+	for i := 0; i < 20; i++ {
+		target := ValidationTarget{Kind: ManagedResourceConfigTarget, TypeName: fmt.Sprintf("test_%d", i)}
+		fail := i%2 == 0
+		checks[target] = func(context.Context) Diagnostics {
+			mu.Lock()
+			running++
+			if running > maxRunning {
+				maxRunning = running
+			}
+			mu.Unlock()
+			time.Sleep(time.Millisecond)
+			mu.Lock()
+			running--
+			mu.Unlock()
+			if fail {
+				return Diagnostics{{Severity: Error, Summary: "Invalid"}}
+			}
+			return nil
+		}
+	}
+
+	got := RunValidations(context.Background(), checks)
+	if len(got) != len(checks) {
+		t.Errorf("got %d results; want %d", len(got), len(checks))
+	}
+	for i := 0; i < 20; i++ {
+		target := ValidationTarget{Kind: ManagedResourceConfigTarget, TypeName: fmt.Sprintf("test_%d", i)}
+		diags, ok := got[target]
+		if !ok {
+			t.Errorf("no result for %s", target)
+			continue
+		}
+		if want := i%2 == 0; diags.HasErrors() != want {
+			t.Errorf("wrong result for %s: errors %t; want %t", target, diags.HasErrors(), want)
+		}
+	}
+	if maxRunning > validateAllParallelism {
+		t.Errorf("%d validations ran at once; want at most %d", maxRunning, validateAllParallelism)
+	}
+}
+
+func TestValidationTargetString(t *testing.T) {
+	tests := map[ValidationTarget]string{
+		{Kind: ProviderConfigTarget}:                                "provider",
+		{Kind: ManagedResourceConfigTarget, TypeName: "test_thing"}: "resource[test_thing]",
+		{Kind: DataResourceConfigTarget, TypeName: "test_data"}:     "data[test_data]",
+	}
+	for target, want := range tests {
+		if got := target.String(); got != want {
+			t.Errorf("wrong string %q; want %q", got, want)
+		}
+	}
+}
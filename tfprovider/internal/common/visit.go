@@ -0,0 +1,331 @@
+package common
+
+import (
+	"bytes"
+	"errors"
+
+	"github.com/vmihailenco/msgpack"
+	msgpackCodes "github.com/vmihailenco/msgpack/codes"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// ValueVisitor is the signature of a function that VisitDynamicValue calls
+// for each leaf value it decodes. Returning an error halts decoding, and
+// VisitDynamicValue then returns an error diagnostic describing it.
+//
+// The given path may not be used after the function returns, since its
+// backing array is reused for other calls.
+type ValueVisitor func(path cty.Path, val cty.Value) error
+
+// VisitDynamicValue decodes the given raw dynamic value data against the
+// given schema, passing each leaf value to the given visitor without ever
+// constructing the value as a whole. This allows examining very large values
+// using less memory than DecodeDynamicValue would.
+//
+// The leaf values are primitive values, null or unknown values of any type,
+// and empty collections. Elements of sets are visited with an index step
+// whose key is unknown, because set elements have no index of their own.
+//
+// Incremental decoding is possible only for the msgpack format. Data in the
+// JSON format is decoded as a whole and then visited.
+func VisitDynamicValue(data DynamicValueData, schema ImpliedTyper, visit ValueVisitor) Diagnostics {
+	ty := schema.ImpliedType()
+
+	// Errors from the visitor are the caller's own decision to stop, so we
+	// mark them to avoid reporting them as problems with the value.
+	callerVisit := visit
+	visit = func(path cty.Path, v cty.Value) error {
+		if err := callerVisit(path, v); err != nil {
+			return visitorError{err: err, path: path.Copy()}
+		}
+		return nil
+	}
+
+	if len(data.Msgpack) == 0 {
+		val, diags := DecodeDynamicValue(data, schema)
+		if diags.HasErrors() {
+			return diags
+		}
+		err := cty.Walk(val, func(path cty.Path, v cty.Value) (bool, error) {
+			if !isVisitorLeaf(v) {
+				return true, nil
+			}
+			return false, visit(path, v)
+		})
+		if err != nil {
+			return visitErrorDiagnostics(err)
+		}
+		return diags
+	}
+
+	dec := msgpack.NewDecoder(bytes.NewReader(data.Msgpack))
+	if err := visitMsgpack(dec, ty, nil, visit); err != nil {
+		return visitErrorDiagnostics(err)
+	}
+	return nil
+}
+
+// isVisitorLeaf returns true if the given value has no elements that
+// VisitDynamicValue would visit individually.
+func isVisitorLeaf(v cty.Value) bool {
+	if v.IsNull() || !v.IsKnown() {
+		return true
+	}
+	ty := v.Type()
+	if ty.IsPrimitiveType() {
+		return true
+	}
+	return v.LengthInt() == 0
+}
+
+// visitorError wraps an error returned by the caller's ValueVisitor,
+// along with the path of the value it was visiting.
+type visitorError struct {
+	err  error
+	path cty.Path
+}
+
+func (e visitorError) Error() string {
+	return e.err.Error()
+}
+
+func (e visitorError) Unwrap() error {
+	return e.err
+}
+
+func visitErrorDiagnostics(err error) Diagnostics {
+	var visitErr visitorError
+	if errors.As(err, &visitErr) {
+		diag := Diagnostic{
+			Severity:  Error,
+			Summary:   "Value visit stopped",
+			Detail:    "Decoding stopped because the value visitor returned an error: " + visitErr.err.Error(),
+			Attribute: visitErr.path,
+		}
+		if pathErr, ok := visitErr.err.(cty.PathError); ok {
+			diag.Attribute = pathErr.Path
+		}
+		return Diagnostics{diag}
+	}
+
+	diag := Diagnostic{
+		Severity: Error,
+		Summary:  "Provider returned invalid object",
+		Detail:   "Provider's msgpack response does not conform to the expected type: " + err.Error(),
+	}
+	if pathErr, ok := err.(cty.PathError); ok {
+		diag.Attribute = pathErr.Path
+	}
+	return Diagnostics{diag}
+}
+
+// visitMsgpack follows the same rules as the cty msgpack decoder, but
+// visits the leaf values rather than building a result.
+func visitMsgpack(dec *msgpack.Decoder, ty cty.Type, path cty.Path, visit ValueVisitor) error {
+	peek, err := dec.PeekCode()
+	if err != nil {
+		return path.NewError(err)
+	}
+	if msgpackCodes.IsExt(peek) {
+		// The only extension used in this encoding is for unknown values.
+		if err := dec.Skip(); err != nil {
+			return path.NewError(err)
+		}
+		return visit(path, cty.UnknownVal(ty))
+	}
+	if ty == cty.DynamicPseudoType {
+		return visitMsgpackDynamic(dec, path, visit)
+	}
+	if peek == msgpackCodes.Nil {
+		if err := dec.Skip(); err != nil {
+			return path.NewError(err)
+		}
+		return visit(path, cty.NullVal(ty))
+	}
+
+	switch {
+	case ty.IsPrimitiveType():
+		val, err := decodeMsgpackPrimitive(dec, ty, path)
+		if err != nil {
+			return err
+		}
+		return visit(path, val)
+
+	case ty.IsListType() || ty.IsSetType() || ty.IsTupleType():
+		length, err := dec.DecodeArrayLen()
+		if err != nil {
+			return path.NewErrorf("a %s is required", ty.FriendlyName())
+		}
+		var etys []cty.Type
+		if ty.IsTupleType() {
+			etys = ty.TupleElementTypes()
+			if length > 0 && length != len(etys) {
+				return path.NewErrorf("a tuple of length %d is required", len(etys))
+			}
+		}
+		switch {
+		case length < 0:
+			return visit(path, cty.NullVal(ty))
+		case length == 0:
+			return visit(path, emptyCollectionVal(ty))
+		}
+		path = append(path, nil)
+		for i := 0; i < length; i++ {
+			var ety cty.Type
+			switch {
+			case ty.IsTupleType():
+				ety = etys[i]
+				path[len(path)-1] = cty.IndexStep{Key: cty.NumberIntVal(int64(i))}
+			case ty.IsSetType():
+				ety = ty.ElementType()
+				path[len(path)-1] = cty.IndexStep{Key: cty.UnknownVal(ety)}
+			default:
+				ety = ty.ElementType()
+				path[len(path)-1] = cty.IndexStep{Key: cty.NumberIntVal(int64(i))}
+			}
+			if err := visitMsgpack(dec, ety, path, visit); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case ty.IsMapType() || ty.IsObjectType():
+		length, err := dec.DecodeMapLen()
+		if err != nil {
+			return path.NewErrorf("a %s is required", ty.FriendlyName())
+		}
+		var atys map[string]cty.Type
+		if ty.IsObjectType() {
+			atys = ty.AttributeTypes()
+			if length > 0 && length != len(atys) {
+				return path.NewErrorf("an object with %d attributes is required (%d given)", len(atys), length)
+			}
+		}
+		switch {
+		case length < 0:
+			return visit(path, cty.NullVal(ty))
+		case length == 0:
+			return visit(path, emptyCollectionVal(ty))
+		}
+		path = append(path, nil)
+		for i := 0; i < length; i++ {
+			key, err := dec.DecodeString()
+			if err != nil {
+				return path[:len(path)-1].NewErrorf("all keys must be strings")
+			}
+			var ety cty.Type
+			if ty.IsObjectType() {
+				aty, exists := atys[key]
+				if !exists {
+					return path[:len(path)-1].NewErrorf("unsupported attribute %q", key)
+				}
+				ety = aty
+				path[len(path)-1] = cty.GetAttrStep{Name: key}
+			} else {
+				ety = ty.ElementType()
+				path[len(path)-1] = cty.IndexStep{Key: cty.StringVal(key)}
+			}
+			if err := visitMsgpack(dec, ety, path, visit); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	default:
+		return path.NewErrorf("unsupported type %s", ty.FriendlyName())
+	}
+}
+
+func visitMsgpackDynamic(dec *msgpack.Decoder, path cty.Path, visit ValueVisitor) error {
+	length, err := dec.DecodeArrayLen()
+	if err != nil {
+		return path.NewError(err)
+	}
+	switch {
+	case length == -1:
+		return visit(path, cty.NullVal(cty.DynamicPseudoType))
+	case length != 2:
+		return path.NewErrorf("dynamic value array must have exactly two elements")
+	}
+
+	typeJSON, err := dec.DecodeBytes()
+	if err != nil {
+		return path.NewError(err)
+	}
+	var ty cty.Type
+	if err := (&ty).UnmarshalJSON(typeJSON); err != nil {
+		return path.NewError(err)
+	}
+	return visitMsgpack(dec, ty, path, visit)
+}
+
+func decodeMsgpackPrimitive(dec *msgpack.Decoder, ty cty.Type, path cty.Path) (cty.Value, error) {
+	switch ty {
+	case cty.Bool:
+		rv, err := dec.DecodeBool()
+		if err != nil {
+			return cty.DynamicVal, path.NewErrorf("bool is required")
+		}
+		return cty.BoolVal(rv), nil
+	case cty.Number:
+		// The encoder uses integer and float encodings where they can
+		// represent the number exactly, and a string otherwise.
+		peek, err := dec.PeekCode()
+		if err != nil {
+			return cty.DynamicVal, path.NewErrorf("number is required")
+		}
+		switch {
+		case msgpackCodes.IsFixedNum(peek), peek == msgpackCodes.Int8, peek == msgpackCodes.Int16, peek == msgpackCodes.Int32, peek == msgpackCodes.Int64:
+			rv, err := dec.DecodeInt64()
+			if err != nil {
+				return cty.DynamicVal, path.NewErrorf("number is required")
+			}
+			return cty.NumberIntVal(rv), nil
+		case peek == msgpackCodes.Uint8, peek == msgpackCodes.Uint16, peek == msgpackCodes.Uint32, peek == msgpackCodes.Uint64:
+			rv, err := dec.DecodeUint64()
+			if err != nil {
+				return cty.DynamicVal, path.NewErrorf("number is required")
+			}
+			return cty.NumberUIntVal(rv), nil
+		case peek == msgpackCodes.Float, peek == msgpackCodes.Double:
+			rv, err := dec.DecodeFloat64()
+			if err != nil {
+				return cty.DynamicVal, path.NewErrorf("number is required")
+			}
+			return cty.NumberFloatVal(rv), nil
+		default:
+			rv, err := dec.DecodeString()
+			if err != nil {
+				return cty.DynamicVal, path.NewErrorf("number is required")
+			}
+			v, err := cty.ParseNumberVal(rv)
+			if err != nil {
+				return cty.DynamicVal, path.NewErrorf("number is required")
+			}
+			return v, nil
+		}
+	case cty.String:
+		rv, err := dec.DecodeString()
+		if err != nil {
+			return cty.DynamicVal, path.NewErrorf("string is required")
+		}
+		return cty.StringVal(rv), nil
+	default:
+		return cty.DynamicVal, path.NewErrorf("unsupported type %s", ty.FriendlyName())
+	}
+}
+
+func emptyCollectionVal(ty cty.Type) cty.Value {
+	switch {
+	case ty.IsListType():
+		return cty.ListValEmpty(ty.ElementType())
+	case ty.IsSetType():
+		return cty.SetValEmpty(ty.ElementType())
+	case ty.IsMapType():
+		return cty.MapValEmpty(ty.ElementType())
+	case ty.IsTupleType():
+		return cty.EmptyTupleVal
+	default:
+		return cty.EmptyObjectVal
+	}
+}
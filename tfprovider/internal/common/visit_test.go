@@ -0,0 +1,374 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"runtime/metrics"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/apparentlymart/terraform-schema-go/tfschema"
+	"github.com/zclconf/go-cty/cty"
+)
+
+var visitTestSchema = &tfschema.Block{
+	Attributes: map[string]*tfschema.Attribute{
+		"id":    {Type: cty.String, Computed: true},
+		"count": {Type: cty.Number, Optional: true},
+		"on":    {Type: cty.Bool, Optional: true},
+		"zones": {Type: cty.List(cty.String), Optional: true},
+		"tags":  {Type: cty.Map(cty.String), Optional: true},
+		"extra": {Type: cty.DynamicPseudoType, Optional: true},
+	},
+	BlockTypes: map[string]*tfschema.NestedBlock{
+		"rule": {
+			Nesting: tfschema.NestingList,
+			Block: tfschema.Block{
+				Attributes: map[string]*tfschema.Attribute{
+					"port":  {Type: cty.Number, Required: true},
+					"cidrs": {Type: cty.List(cty.String), Optional: true},
+				},
+			},
+		},
+	},
+}
+
+// visitLeaves returns a sorted description of each leaf value that
+// VisitDynamicValue reports for data, or of each leaf that cty.Walk finds in
+// val if val is not cty.NilVal.
+func visitLeaves(t *testing.T, data DynamicValueData, val cty.Value) []string {
+	t.Helper()
+	var ret []string
+	record := func(path cty.Path, v cty.Value) error {
+		ret = append(ret, fmt.Sprintf("%s = %#v", FormatPath(path), v))
+		return nil
+	}
+	if val == cty.NilVal {
+		if diags := VisitDynamicValue(data, visitTestSchema, record); diags.HasErrors() {
+			t.Fatalf("unexpected errors: %s", diags.Err())
+		}
+	} else {
+		cty.Walk(val, func(path cty.Path, v cty.Value) (bool, error) {
+			if !isVisitorLeaf(v) {
+				return true, nil
+			}
+			return false, record(path, v)
+		})
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+func TestVisitDynamicValue(t *testing.T) {
+	tests := map[string]cty.Value{
+		"null": cty.NullVal(visitTestSchema.ImpliedType()),
+		"populated": cty.ObjectVal(map[string]cty.Value{
+			"id":    cty.StringVal("abc"),
+			"count": cty.NumberFloatVal(1.5),
+			"on":    cty.True,
+			"zones": cty.ListVal([]cty.Value{cty.StringVal("a"), cty.StringVal("b")}),
+			"tags": cty.MapVal(map[string]cty.Value{
+				"Name":  cty.StringVal("example"),
+				"Owner": cty.NullVal(cty.String),
+			}),
+			"extra": cty.ObjectVal(map[string]cty.Value{
+				"nested": cty.TupleVal([]cty.Value{cty.NumberIntVal(-1), cty.StringVal("two")}),
+				"big":    cty.MustParseNumberVal("123456789012345678901234567890"),
+			}),
+			"rule": cty.ListVal([]cty.Value{
+				cty.ObjectVal(map[string]cty.Value{
+					"port":  cty.NumberIntVal(443),
+					"cidrs": cty.ListValEmpty(cty.String),
+				}),
+				cty.ObjectVal(map[string]cty.Value{
+					"port":  cty.NumberUIntVal(1 << 63),
+					"cidrs": cty.NullVal(cty.List(cty.String)),
+				}),
+			}),
+		}),
+		"unknown": cty.ObjectVal(map[string]cty.Value{
+			"id":    cty.UnknownVal(cty.String),
+			"count": cty.NullVal(cty.Number),
+			"on":    cty.NullVal(cty.Bool),
+			"zones": cty.ListVal([]cty.Value{cty.UnknownVal(cty.String)}),
+			"tags":  cty.UnknownVal(cty.Map(cty.String)),
+			"extra": cty.DynamicVal,
+			"rule":  cty.UnknownVal(cty.List(visitTestSchema.BlockTypes["rule"].Block.ImpliedType())),
+		}),
+	}
+
+	for name, val := range tests {
+		t.Run(name, func(t *testing.T) {
+			data, diags := EncodeDynamicValue(val, visitTestSchema)
+			if diags.HasErrors() {
+				t.Fatal(diags.Err())
+			}
+			// The visited leaves must be those of a full decode.
+			decoded, diags := DecodeDynamicValue(data, visitTestSchema)
+			if diags.HasErrors() {
+				t.Fatal(diags.Err())
+			}
+			got := visitLeaves(t, data, cty.NilVal)
+			want := visitLeaves(t, data, decoded)
+			if fmt.Sprint(got) != fmt.Sprint(want) {
+				t.Errorf("wrong leaves\ngot:  %q\nwant: %q", got, want)
+			}
+		})
+	}
+}
+
+func TestVisitDynamicValueJSON(t *testing.T) {
+	data := DynamicValueData{
+		JSON: []byte(`{"id":"abc","count":2,"on":null,"zones":["a"],"tags":{},"extra":null,"rule":[]}`),
+	}
+	got := visitLeaves(t, data, cty.NilVal)
+	want := []string{
+		`count = cty.NumberIntVal(2)`,
+		`extra = cty.NullVal(cty.DynamicPseudoType)`,
+		`id = cty.StringVal("abc")`,
+		`on = cty.NullVal(cty.Bool)`,
+		`rule = cty.ListValEmpty(cty.Object(map[string]cty.Type{"cidrs":cty.List(cty.String), "port":cty.Number}))`,
+		`tags = cty.MapValEmpty(cty.String)`,
+		`zones[0] = cty.StringVal("a")`,
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("wrong leaves\ngot:  %q\nwant: %q", got, want)
+	}
+}
+
+func TestVisitDynamicValueSet(t *testing.T) {
+	schema := &tfschema.Block{
+		Attributes: map[string]*tfschema.Attribute{
+			"names": {Type: cty.Set(cty.String), Optional: true},
+		},
+	}
+	val := cty.ObjectVal(map[string]cty.Value{
+		"names": cty.SetVal([]cty.Value{cty.StringVal("a"), cty.StringVal("b")}),
+	})
+	data, diags := EncodeDynamicValue(val, schema)
+	if diags.HasErrors() {
+		t.Fatal(diags.Err())
+	}
+
+	got := cty.SetValEmpty(cty.String)
+	diags = VisitDynamicValue(data, schema, func(path cty.Path, v cty.Value) error {
+		if len(path) != 2 {
+			t.Errorf("wrong path length %d; want 2", len(path))
+			return nil
+		}
+		if key := path[1].(cty.IndexStep).Key; key.IsKnown() {
+			t.Errorf("set element has known key %#v", key)
+		}
+		got = cty.SetVal(append(got.AsValueSlice(), v))
+		return nil
+	})
+	if diags.HasErrors() {
+		t.Fatalf("unexpected errors: %s", diags.Err())
+	}
+	if want := val.GetAttr("names"); !got.RawEquals(want) {
+		t.Errorf("wrong elements %#v; want %#v", got, want)
+	}
+}
+
+func TestVisitDynamicValueErrors(t *testing.T) {
+	val := cty.ObjectVal(map[string]cty.Value{
+		"id":    cty.StringVal("abc"),
+		"count": cty.NullVal(cty.Number),
+		"on":    cty.NullVal(cty.Bool),
+		"zones": cty.ListVal([]cty.Value{cty.StringVal("a"), cty.StringVal("b")}),
+		"tags":  cty.NullVal(cty.Map(cty.String)),
+		"extra": cty.NullVal(cty.DynamicPseudoType),
+		"rule":  cty.ListValEmpty(visitTestSchema.BlockTypes["rule"].Block.ImpliedType()),
+	})
+	data, diags := EncodeDynamicValue(val, visitTestSchema)
+	if diags.HasErrors() {
+		t.Fatal(diags.Err())
+	}
+
+	t.Run("visitor error", func(t *testing.T) {
+		stop := errors.New("stop here")
+		calls := 0
+		diags := VisitDynamicValue(data, visitTestSchema, func(path cty.Path, v cty.Value) error {
+			calls++
+			if FormatPath(path) == "zones[0]" {
+				return path.NewError(stop)
+			}
+			return nil
+		})
+		if !diags.HasErrors() {
+			t.Fatal("visit succeeded; want an error")
+		}
+		if got, want := FormatPath(diags[0].Attribute), "zones[0]"; got != want {
+			t.Errorf("wrong error path %s; want %s", got, want)
+		}
+		if got, want := diags[0].Summary, "Value visit stopped"; got != want {
+			t.Errorf("wrong error summary %q; want %q", got, want)
+		}
+		total := 0
+		cty.Walk(val, func(path cty.Path, v cty.Value) (bool, error) {
+			if isVisitorLeaf(v) {
+				total++
+			}
+			return true, nil
+		})
+		if calls >= total {
+			t.Errorf("visited all %d leaves; want decoding to halt at the error", calls)
+		}
+	})
+	t.Run("visitor error in JSON", func(t *testing.T) {
+		data := DynamicValueData{
+			JSON: []byte(`{"id":"abc","count":2,"on":null,"zones":["a"],"tags":{},"extra":null,"rule":[]}`),
+		}
+		diags := VisitDynamicValue(data, visitTestSchema, func(path cty.Path, v cty.Value) error {
+			if FormatPath(path) == "id" {
+				return errors.New("stop here")
+			}
+			return nil
+		})
+		if len(diags) != 1 {
+			t.Fatalf("wrong diagnostics %#v", diags)
+		}
+		if got, want := diags[0].Summary, "Value visit stopped"; got != want {
+			t.Errorf("wrong error summary %q; want %q", got, want)
+		}
+		if got, want := FormatPath(diags[0].Attribute), "id"; got != want {
+			t.Errorf("wrong error path %s; want %s", got, want)
+		}
+	})
+	t.Run("wrong type", func(t *testing.T) {
+		other := &tfschema.Block{
+			Attributes: map[string]*tfschema.Attribute{
+				"id": {Type: cty.String, Computed: true},
+			},
+		}
+		diags := VisitDynamicValue(data, other, func(cty.Path, cty.Value) error {
+			return nil
+		})
+		if !diags.HasErrors() {
+			t.Fatal("visit succeeded; want an error")
+		}
+	})
+	t.Run("truncated", func(t *testing.T) {
+		truncated := DynamicValueData{Msgpack: data.Msgpack[:len(data.Msgpack)/2]}
+		diags := VisitDynamicValue(truncated, visitTestSchema, func(cty.Path, cty.Value) error {
+			return nil
+		})
+		if !diags.HasErrors() {
+			t.Fatal("visit succeeded; want an error")
+		}
+	})
+}
+
+// largeVisitTestState returns the raw encoding of a resource state with many
+// nested objects, as a large resource might return from ReadResource.
+func largeVisitTestState(b *testing.B) DynamicValueData {
+	rules := make([]cty.Value, 20000)
+	for i := range rules {
+		rules[i] = cty.ObjectVal(map[string]cty.Value{
+			"port": cty.NumberIntVal(int64(i)),
+			"cidrs": cty.ListVal([]cty.Value{
+				cty.StringVal(fmt.Sprintf("10.%d.%d.0/24", i/256%256, i%256)),
+				cty.StringVal(fmt.Sprintf("192.168.%d.0/24", i%256)),
+			}),
+		})
+	}
+	val := cty.ObjectVal(map[string]cty.Value{
+		"id":    cty.StringVal("large"),
+		"count": cty.NumberIntVal(int64(len(rules))),
+		"on":    cty.True,
+		"zones": cty.ListValEmpty(cty.String),
+		"tags":  cty.MapValEmpty(cty.String),
+		"extra": cty.NullVal(cty.DynamicPseudoType),
+		"rule":  cty.ListVal(rules),
+	})
+	data, diags := EncodeDynamicValue(val, visitTestSchema)
+	if diags.HasErrors() {
+		b.Fatal(diags.Err())
+	}
+	return data
+}
+
+// BenchmarkVisitDynamicValue compares examining every leaf of a large state
+// by decoding it as a whole and by visiting it incrementally. It reports, as
+// peak-B/op, the most heap memory in use at any point during each operation
+// beyond what was in use before it started.
+func BenchmarkVisitDynamicValue(b *testing.B) {
+	data := largeVisitTestState(b)
+	leaves := 0
+	count := func(cty.Path, cty.Value) error {
+		leaves++
+		return nil
+	}
+
+	b.Run("decode", func(b *testing.B) {
+		benchmarkPeakHeap(b, func() {
+			val, diags := DecodeDynamicValue(data, visitTestSchema)
+			if diags.HasErrors() {
+				b.Fatal(diags.Err())
+			}
+			cty.Walk(val, func(path cty.Path, v cty.Value) (bool, error) {
+				if !isVisitorLeaf(v) {
+					return true, nil
+				}
+				return false, count(path, v)
+			})
+		})
+	})
+	b.Run("visit", func(b *testing.B) {
+		benchmarkPeakHeap(b, func() {
+			if diags := VisitDynamicValue(data, visitTestSchema, count); diags.HasErrors() {
+				b.Fatal(diags.Err())
+			}
+		})
+	})
+}
+
+func benchmarkPeakHeap(b *testing.B, op func()) {
+	sample := []metrics.Sample{{Name: "/memory/classes/heap/objects:bytes"}}
+	heap := func() uint64 {
+		metrics.Read(sample)
+		return sample[0].Value.Uint64()
+	}
+
+	var peak uint64
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		runtime.GC()
+		base := heap()
+		var max uint64
+		done := make(chan struct{})
+		var wg sync.WaitGroup
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				if h := heap(); h > max {
+					max = h
+				}
+				select {
+				case <-done:
+					return
+				case <-time.After(100 * time.Microsecond):
+				}
+			}
+		}()
+		b.StartTimer()
+
+		op()
+
+		b.StopTimer()
+		close(done)
+		wg.Wait()
+		if max > base {
+			peak += max - base
+		}
+		b.StartTimer()
+	}
+	b.StopTimer()
+	b.ReportMetric(float64(peak)/float64(b.N), "peak-B/op")
+}
@@ -0,0 +1,178 @@
+package protocol5
+
+import (
+	"context"
+
+	"github.com/golang/protobuf/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/apparentlymart/terraform-provider/internal/tfplugin5"
+	"github.com/apparentlymart/terraform-provider/tfprovider/internal/common"
+)
+
+// interceptedClient is a tfplugin5.ProviderClient that passes every call
+// through an interceptor before (optionally) delegating to another client.
+type interceptedClient struct {
+	next      tfplugin5.ProviderClient
+	intercept common.RPCInterceptor
+}
+
+// NewInterceptedClient wraps the given client so that each of its calls
+// passes through the given interceptor.
+//
+// If next is nil then there is no plugin behind the returned client, and so
+// the interceptor must answer every call itself without calling its invoker.
+func NewInterceptedClient(next tfplugin5.ProviderClient, intercept common.RPCInterceptor) tfplugin5.ProviderClient {
+	return &interceptedClient{
+		next:      next,
+		intercept: intercept,
+	}
+}
+
+func (c *interceptedClient) call(ctx context.Context, method string, req, resp proto.Message, next func(context.Context, proto.Message) (proto.Message, error)) error {
+	return c.intercept(ctx, method, req, resp, func(ctx context.Context, req, resp proto.Message) error {
+		if c.next == nil {
+			return status.Errorf(codes.Unavailable, "no provider plugin is available to handle %s", method)
+		}
+		result, err := next(ctx, req)
+		if err != nil {
+			return err
+		}
+		proto.Merge(resp, result)
+		return nil
+	})
+}
+
+func (c *interceptedClient) GetSchema(ctx context.Context, in *tfplugin5.GetProviderSchema_Request, opts ...grpc.CallOption) (*tfplugin5.GetProviderSchema_Response, error) {
+	out := new(tfplugin5.GetProviderSchema_Response)
+	err := c.call(ctx, "GetSchema", in, out, func(ctx context.Context, req proto.Message) (proto.Message, error) {
+		return c.next.GetSchema(ctx, req.(*tfplugin5.GetProviderSchema_Request), opts...)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *interceptedClient) PrepareProviderConfig(ctx context.Context, in *tfplugin5.PrepareProviderConfig_Request, opts ...grpc.CallOption) (*tfplugin5.PrepareProviderConfig_Response, error) {
+	out := new(tfplugin5.PrepareProviderConfig_Response)
+	err := c.call(ctx, "PrepareProviderConfig", in, out, func(ctx context.Context, req proto.Message) (proto.Message, error) {
+		return c.next.PrepareProviderConfig(ctx, req.(*tfplugin5.PrepareProviderConfig_Request), opts...)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *interceptedClient) ValidateResourceTypeConfig(ctx context.Context, in *tfplugin5.ValidateResourceTypeConfig_Request, opts ...grpc.CallOption) (*tfplugin5.ValidateResourceTypeConfig_Response, error) {
+	out := new(tfplugin5.ValidateResourceTypeConfig_Response)
+	err := c.call(ctx, "ValidateResourceTypeConfig", in, out, func(ctx context.Context, req proto.Message) (proto.Message, error) {
+		return c.next.ValidateResourceTypeConfig(ctx, req.(*tfplugin5.ValidateResourceTypeConfig_Request), opts...)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *interceptedClient) ValidateDataSourceConfig(ctx context.Context, in *tfplugin5.ValidateDataSourceConfig_Request, opts ...grpc.CallOption) (*tfplugin5.ValidateDataSourceConfig_Response, error) {
+	out := new(tfplugin5.ValidateDataSourceConfig_Response)
+	err := c.call(ctx, "ValidateDataSourceConfig", in, out, func(ctx context.Context, req proto.Message) (proto.Message, error) {
+		return c.next.ValidateDataSourceConfig(ctx, req.(*tfplugin5.ValidateDataSourceConfig_Request), opts...)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *interceptedClient) UpgradeResourceState(ctx context.Context, in *tfplugin5.UpgradeResourceState_Request, opts ...grpc.CallOption) (*tfplugin5.UpgradeResourceState_Response, error) {
+	out := new(tfplugin5.UpgradeResourceState_Response)
+	err := c.call(ctx, "UpgradeResourceState", in, out, func(ctx context.Context, req proto.Message) (proto.Message, error) {
+		return c.next.UpgradeResourceState(ctx, req.(*tfplugin5.UpgradeResourceState_Request), opts...)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *interceptedClient) Configure(ctx context.Context, in *tfplugin5.Configure_Request, opts ...grpc.CallOption) (*tfplugin5.Configure_Response, error) {
+	out := new(tfplugin5.Configure_Response)
+	err := c.call(ctx, "Configure", in, out, func(ctx context.Context, req proto.Message) (proto.Message, error) {
+		return c.next.Configure(ctx, req.(*tfplugin5.Configure_Request), opts...)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *interceptedClient) ReadResource(ctx context.Context, in *tfplugin5.ReadResource_Request, opts ...grpc.CallOption) (*tfplugin5.ReadResource_Response, error) {
+	out := new(tfplugin5.ReadResource_Response)
+	err := c.call(ctx, "ReadResource", in, out, func(ctx context.Context, req proto.Message) (proto.Message, error) {
+		return c.next.ReadResource(ctx, req.(*tfplugin5.ReadResource_Request), opts...)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *interceptedClient) PlanResourceChange(ctx context.Context, in *tfplugin5.PlanResourceChange_Request, opts ...grpc.CallOption) (*tfplugin5.PlanResourceChange_Response, error) {
+	out := new(tfplugin5.PlanResourceChange_Response)
+	err := c.call(ctx, "PlanResourceChange", in, out, func(ctx context.Context, req proto.Message) (proto.Message, error) {
+		return c.next.PlanResourceChange(ctx, req.(*tfplugin5.PlanResourceChange_Request), opts...)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *interceptedClient) ApplyResourceChange(ctx context.Context, in *tfplugin5.ApplyResourceChange_Request, opts ...grpc.CallOption) (*tfplugin5.ApplyResourceChange_Response, error) {
+	out := new(tfplugin5.ApplyResourceChange_Response)
+	err := c.call(ctx, "ApplyResourceChange", in, out, func(ctx context.Context, req proto.Message) (proto.Message, error) {
+		return c.next.ApplyResourceChange(ctx, req.(*tfplugin5.ApplyResourceChange_Request), opts...)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *interceptedClient) ImportResourceState(ctx context.Context, in *tfplugin5.ImportResourceState_Request, opts ...grpc.CallOption) (*tfplugin5.ImportResourceState_Response, error) {
+	out := new(tfplugin5.ImportResourceState_Response)
+	err := c.call(ctx, "ImportResourceState", in, out, func(ctx context.Context, req proto.Message) (proto.Message, error) {
+		return c.next.ImportResourceState(ctx, req.(*tfplugin5.ImportResourceState_Request), opts...)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *interceptedClient) ReadDataSource(ctx context.Context, in *tfplugin5.ReadDataSource_Request, opts ...grpc.CallOption) (*tfplugin5.ReadDataSource_Response, error) {
+	out := new(tfplugin5.ReadDataSource_Response)
+	err := c.call(ctx, "ReadDataSource", in, out, func(ctx context.Context, req proto.Message) (proto.Message, error) {
+		return c.next.ReadDataSource(ctx, req.(*tfplugin5.ReadDataSource_Request), opts...)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *interceptedClient) Stop(ctx context.Context, in *tfplugin5.Stop_Request, opts ...grpc.CallOption) (*tfplugin5.Stop_Response, error) {
+	out := new(tfplugin5.Stop_Response)
+	err := c.call(ctx, "Stop", in, out, func(ctx context.Context, req proto.Message) (proto.Message, error) {
+		return c.next.Stop(ctx, req.(*tfplugin5.Stop_Request), opts...)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
@@ -7,7 +7,10 @@ import (
 	"github.com/apparentlymart/terraform-provider/tfprovider/internal/common"
 )
 
-func decodeDiagnostics(raws []*tfplugin5.Diagnostic) common.Diagnostics {
+// decodeDiagnostics converts diagnostics returned by the provider plugin,
+// tagging each with the source tag from the given options, if any, and
+// recording them in the options' diagnostic log, if any.
+func decodeDiagnostics(opts *common.Options, raws []*tfplugin5.Diagnostic) common.Diagnostics {
 	if len(raws) == 0 {
 		return nil
 	}
@@ -17,6 +20,7 @@ func decodeDiagnostics(raws []*tfplugin5.Diagnostic) common.Diagnostics {
 			Summary:   raw.Summary,
 			Detail:    raw.Detail,
 			Attribute: decodeAttributePath(raw.Attribute),
+			Source:    opts.SourceTag,
 		}
 
 		switch raw.Severity {
@@ -28,6 +32,10 @@ func decodeDiagnostics(raws []*tfplugin5.Diagnostic) common.Diagnostics {
 
 		diags = append(diags, diag)
 	}
+	if opts.DedupDiagnostics {
+		diags = diags.Dedup()
+	}
+	opts.DiagnosticLog.Append(diags)
 	return diags
 }
 
@@ -0,0 +1,237 @@
+package protocol5
+
+import (
+	"context"
+	"testing"
+
+	"github.com/zclconf/go-cty/cty"
+	ctymsgpack "github.com/zclconf/go-cty/cty/msgpack"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/apparentlymart/terraform-provider/internal/tfplugin5"
+	"github.com/apparentlymart/terraform-provider/tfprovider/internal/common"
+)
+
+// fakeClient is a tfplugin5.ProviderClient that answers each call using the
+// function in the corresponding field, or with an Unimplemented error if
+// that field is nil.
+type fakeClient struct {
+	getSchema                  func(context.Context, *tfplugin5.GetProviderSchema_Request) (*tfplugin5.GetProviderSchema_Response, error)
+	prepareProviderConfig      func(context.Context, *tfplugin5.PrepareProviderConfig_Request) (*tfplugin5.PrepareProviderConfig_Response, error)
+	validateResourceTypeConfig func(context.Context, *tfplugin5.ValidateResourceTypeConfig_Request) (*tfplugin5.ValidateResourceTypeConfig_Response, error)
+	validateDataSourceConfig   func(context.Context, *tfplugin5.ValidateDataSourceConfig_Request) (*tfplugin5.ValidateDataSourceConfig_Response, error)
+	upgradeResourceState       func(context.Context, *tfplugin5.UpgradeResourceState_Request) (*tfplugin5.UpgradeResourceState_Response, error)
+	configure                  func(context.Context, *tfplugin5.Configure_Request) (*tfplugin5.Configure_Response, error)
+	readResource               func(context.Context, *tfplugin5.ReadResource_Request) (*tfplugin5.ReadResource_Response, error)
+	planResourceChange         func(context.Context, *tfplugin5.PlanResourceChange_Request) (*tfplugin5.PlanResourceChange_Response, error)
+	applyResourceChange        func(context.Context, *tfplugin5.ApplyResourceChange_Request) (*tfplugin5.ApplyResourceChange_Response, error)
+	importResourceState        func(context.Context, *tfplugin5.ImportResourceState_Request) (*tfplugin5.ImportResourceState_Response, error)
+	readDataSource             func(context.Context, *tfplugin5.ReadDataSource_Request) (*tfplugin5.ReadDataSource_Response, error)
+	stop                       func(context.Context, *tfplugin5.Stop_Request) (*tfplugin5.Stop_Response, error)
+}
+
+var _ tfplugin5.ProviderClient = (*fakeClient)(nil)
+
+func unimplemented(method string) error {
+	return status.Errorf(codes.Unimplemented, "fake provider does not implement %s", method)
+}
+
+func (c *fakeClient) GetSchema(ctx context.Context, in *tfplugin5.GetProviderSchema_Request, opts ...grpc.CallOption) (*tfplugin5.GetProviderSchema_Response, error) {
+	if c.getSchema == nil {
+		return nil, unimplemented("GetSchema")
+	}
+	return c.getSchema(ctx, in)
+}
+
+func (c *fakeClient) PrepareProviderConfig(ctx context.Context, in *tfplugin5.PrepareProviderConfig_Request, opts ...grpc.CallOption) (*tfplugin5.PrepareProviderConfig_Response, error) {
+	if c.prepareProviderConfig == nil {
+		return nil, unimplemented("PrepareProviderConfig")
+	}
+	return c.prepareProviderConfig(ctx, in)
+}
+
+func (c *fakeClient) ValidateResourceTypeConfig(ctx context.Context, in *tfplugin5.ValidateResourceTypeConfig_Request, opts ...grpc.CallOption) (*tfplugin5.ValidateResourceTypeConfig_Response, error) {
+	if c.validateResourceTypeConfig == nil {
+		return nil, unimplemented("ValidateResourceTypeConfig")
+	}
+	return c.validateResourceTypeConfig(ctx, in)
+}
+
+func (c *fakeClient) ValidateDataSourceConfig(ctx context.Context, in *tfplugin5.ValidateDataSourceConfig_Request, opts ...grpc.CallOption) (*tfplugin5.ValidateDataSourceConfig_Response, error) {
+	if c.validateDataSourceConfig == nil {
+		return nil, unimplemented("ValidateDataSourceConfig")
+	}
+	return c.validateDataSourceConfig(ctx, in)
+}
+
+func (c *fakeClient) UpgradeResourceState(ctx context.Context, in *tfplugin5.UpgradeResourceState_Request, opts ...grpc.CallOption) (*tfplugin5.UpgradeResourceState_Response, error) {
+	if c.upgradeResourceState == nil {
+		return nil, unimplemented("UpgradeResourceState")
+	}
+	return c.upgradeResourceState(ctx, in)
+}
+
+func (c *fakeClient) Configure(ctx context.Context, in *tfplugin5.Configure_Request, opts ...grpc.CallOption) (*tfplugin5.Configure_Response, error) {
+	if c.configure == nil {
+		return nil, unimplemented("Configure")
+	}
+	return c.configure(ctx, in)
+}
+
+func (c *fakeClient) ReadResource(ctx context.Context, in *tfplugin5.ReadResource_Request, opts ...grpc.CallOption) (*tfplugin5.ReadResource_Response, error) {
+	if c.readResource == nil {
+		return nil, unimplemented("ReadResource")
+	}
+	return c.readResource(ctx, in)
+}
+
+func (c *fakeClient) PlanResourceChange(ctx context.Context, in *tfplugin5.PlanResourceChange_Request, opts ...grpc.CallOption) (*tfplugin5.PlanResourceChange_Response, error) {
+	if c.planResourceChange == nil {
+		return nil, unimplemented("PlanResourceChange")
+	}
+	return c.planResourceChange(ctx, in)
+}
+
+func (c *fakeClient) ApplyResourceChange(ctx context.Context, in *tfplugin5.ApplyResourceChange_Request, opts ...grpc.CallOption) (*tfplugin5.ApplyResourceChange_Response, error) {
+	if c.applyResourceChange == nil {
+		return nil, unimplemented("ApplyResourceChange")
+	}
+	return c.applyResourceChange(ctx, in)
+}
+
+func (c *fakeClient) ImportResourceState(ctx context.Context, in *tfplugin5.ImportResourceState_Request, opts ...grpc.CallOption) (*tfplugin5.ImportResourceState_Response, error) {
+	if c.importResourceState == nil {
+		return nil, unimplemented("ImportResourceState")
+	}
+	return c.importResourceState(ctx, in)
+}
+
+func (c *fakeClient) ReadDataSource(ctx context.Context, in *tfplugin5.ReadDataSource_Request, opts ...grpc.CallOption) (*tfplugin5.ReadDataSource_Response, error) {
+	if c.readDataSource == nil {
+		return nil, unimplemented("ReadDataSource")
+	}
+	return c.readDataSource(ctx, in)
+}
+
+func (c *fakeClient) Stop(ctx context.Context, in *tfplugin5.Stop_Request, opts ...grpc.CallOption) (*tfplugin5.Stop_Response, error) {
+	if c.stop == nil {
+		return nil, unimplemented("Stop")
+	}
+	return c.stop(ctx, in)
+}
+
+// testThingType is the type of objects of the "test_thing" managed resource
+// type in testSchemaResponse.
+var testThingType = cty.Object(map[string]cty.Type{
+	"id":   cty.String,
+	"name": cty.String,
+})
+
+// testDataType is the type of objects of the "test_data" data resource type
+// in testSchemaResponse.
+var testDataType = cty.Object(map[string]cty.Type{
+	"name":  cty.String,
+	"value": cty.String,
+})
+
+// testSchemaResponse returns the schema of a small provider, with one
+// managed resource type "test_thing" at schema version 1 and one data
+// resource type "test_data".
+func testSchemaResponse() *tfplugin5.GetProviderSchema_Response {
+	return &tfplugin5.GetProviderSchema_Response{
+		Provider: &tfplugin5.Schema{
+			Block: &tfplugin5.Schema_Block{
+				Attributes: []*tfplugin5.Schema_Attribute{
+					{Name: "region", Type: []byte(`"string"`), Optional: true},
+					{Name: "token", Type: []byte(`"string"`), Optional: true, Sensitive: true},
+				},
+			},
+		},
+		ResourceSchemas: map[string]*tfplugin5.Schema{
+			"test_thing": {
+				Version: 1,
+				Block: &tfplugin5.Schema_Block{
+					Attributes: []*tfplugin5.Schema_Attribute{
+						{Name: "id", Type: []byte(`"string"`), Computed: true},
+						{Name: "name", Type: []byte(`"string"`), Optional: true},
+					},
+				},
+			},
+		},
+		DataSourceSchemas: map[string]*tfplugin5.Schema{
+			"test_data": {
+				Block: &tfplugin5.Schema_Block{
+					Attributes: []*tfplugin5.Schema_Attribute{
+						{Name: "name", Type: []byte(`"string"`), Required: true},
+						{Name: "value", Type: []byte(`"string"`), Computed: true},
+					},
+				},
+			},
+		},
+	}
+}
+
+// newTestProvider creates a provider over the given fake client, which
+// returns testSchemaResponse unless it already has its own schema. The
+// options may be nil.
+func newTestProvider(t *testing.T, client *fakeClient, opts *common.Options) *Provider {
+	t.Helper()
+	if client.getSchema == nil {
+		client.getSchema = func(context.Context, *tfplugin5.GetProviderSchema_Request) (*tfplugin5.GetProviderSchema_Response, error) {
+			return testSchemaResponse(), nil
+		}
+	}
+	p, err := NewProvider(context.Background(), nil, client, opts)
+	if err != nil {
+		t.Fatalf("failed to create provider: %s", err)
+	}
+	t.Cleanup(func() { p.Close() })
+	return p
+}
+
+// configuredTestProvider is like newTestProvider but also configures the
+// provider with a null region and token. The fake client accepts the
+// configuration unless it already has its own Configure function.
+func configuredTestProvider(t *testing.T, client *fakeClient, opts *common.Options) *Provider {
+	t.Helper()
+	if client.configure == nil {
+		client.configure = func(context.Context, *tfplugin5.Configure_Request) (*tfplugin5.Configure_Response, error) {
+			return &tfplugin5.Configure_Response{}, nil
+		}
+	}
+	p := newTestProvider(t, client, opts)
+	diags := p.Configure(context.Background(), common.Config{
+		Value: cty.NullVal(p.ProviderConfigType()),
+	})
+	if diags.HasErrors() {
+		t.Fatalf("failed to configure provider: %s", diags.Err())
+	}
+	return p
+}
+
+// testDynamicValue encodes the given value in msgpack format, as a provider
+// plugin would.
+func testDynamicValue(t *testing.T, val cty.Value) *tfplugin5.DynamicValue {
+	t.Helper()
+	raw, err := ctymsgpack.Marshal(val, val.Type())
+	if err != nil {
+		t.Fatalf("failed to encode %#v: %s", val, err)
+	}
+	return &tfplugin5.DynamicValue{Msgpack: raw}
+}
+
+// decodeTestDynamicValue decodes the given value, which must be in msgpack
+// format, as a provider plugin would.
+func decodeTestDynamicValue(t *testing.T, raw *tfplugin5.DynamicValue, ty cty.Type) cty.Value {
+	t.Helper()
+	if raw == nil {
+		t.Fatalf("no value given")
+	}
+	val, err := ctymsgpack.Unmarshal(raw.Msgpack, ty)
+	if err != nil {
+		t.Fatalf("failed to decode value: %s", err)
+	}
+	return val
+}
@@ -3,6 +3,7 @@ package protocol5
 import (
 	"context"
 	"fmt"
+	"sync/atomic"
 
 	"github.com/apparentlymart/terraform-schema-go/tfschema"
 	"github.com/zclconf/go-cty/cty"
@@ -14,12 +15,22 @@ import (
 type ManagedResourceType struct {
 	client             tfplugin5.ProviderClient
 	typeName           string
+	opts               *common.Options
 	schema             *common.ManagedResourceTypeSchema
 	providerMetaSchema *tfschema.Block
+
+	// configState belongs to the provider that this resource type was
+	// obtained from, so we can see if it has been fully configured.
+	configState *atomic.Int32
 }
 
 func (rt *ManagedResourceType) ValidateConfig(ctx context.Context, config cty.Value) common.Diagnostics {
-	dv, diags := encodeDynamicValue(config, rt.schema.Content)
+	// Rules registered by the caller are checked locally first, since
+	// the provider knows nothing of them.
+	if diags := rt.opts.CheckConfigRules(common.ManagedResourceMode, rt.typeName, config); diags.HasErrors() {
+		return diags
+	}
+	dv, diags := encodeDynamicValue(rt.opts, rt.typeName, config, rt.schema)
 	if diags.HasErrors() {
 		return diags
 	}
@@ -31,66 +42,122 @@ func (rt *ManagedResourceType) ValidateConfig(ctx context.Context, config cty.Va
 	if err != nil {
 		return diags
 	}
-	diags = append(diags, decodeDiagnostics(resp.Diagnostics)...)
+	diags = append(diags, decodeDiagnostics(rt.opts, resp.Diagnostics).WithResource(rt.opts, rt.typeName, "")...)
 	return diags
 }
 
-func (rt *ManagedResourceType) Read(ctx context.Context, req common.ManagedResourceReadRequest) (common.ManagedResourceReadResponse, common.Diagnostics) {
+func (rt *ManagedResourceType) SchemaVersion() int64 {
+	return rt.schema.Version
+}
+
+func (rt *ManagedResourceType) Read(ctx context.Context, req common.ManagedResourceReadRequest) (resp common.ManagedResourceReadResponse, diags common.Diagnostics) {
+	defer rt.opts.RecoverPanic("read", &diags)
+	return rt.read(ctx, req)
+}
+
+func (rt *ManagedResourceType) read(ctx context.Context, req common.ManagedResourceReadRequest) (common.ManagedResourceReadResponse, common.Diagnostics) {
+	if diags := requireConfigured(rt.configState); diags.HasErrors() {
+		return common.ManagedResourceReadResponse{}, diags
+	}
+
 	resp := common.ManagedResourceReadResponse{}
-	dv, diags := encodeDynamicValue(req.PreviousValue, rt.schema.Content)
+	if req.PreviousSchemaVersion != nil {
+		diags := rt.schema.CheckStateVersion(rt.typeName, *req.PreviousSchemaVersion)
+		if diags.HasErrors() {
+			return resp, diags
+		}
+	}
+
+	dv, diags := encodeDynamicValue(rt.opts, rt.typeName, req.PreviousValue, rt.schema)
 	if diags.HasErrors() {
 		return resp, diags
 	}
+	diags = append(diags, rt.schema.CheckPrivateVersion(rt.opts, rt.typeName, req.OpaquePrivate, req.PrivateSchemaVersion)...)
+
+	providerMetaDV, moreDiags := encodeProviderMeta(rt.opts, req.ProviderMeta, rt.providerMetaSchema)
+	diags = append(diags, moreDiags...)
+	if moreDiags.HasErrors() {
+		return resp, diags
+	}
 
 	rawResp, err := rt.client.ReadResource(ctx, &tfplugin5.ReadResource_Request{
 		TypeName:     rt.typeName,
 		CurrentState: dv,
 		Private:      req.OpaquePrivate,
+		ProviderMeta: providerMetaDV,
 	})
 	diags = append(diags, common.RPCErrorDiagnostics(err)...)
 	if err != nil {
 		return resp, diags
 	}
-	diags = append(diags, decodeDiagnostics(rawResp.Diagnostics)...)
+	diags = append(diags, decodeDiagnostics(rt.opts, rawResp.Diagnostics).WithResource(rt.opts, rt.typeName, req.InstanceKey)...)
 
 	if raw := rawResp.NewState; raw != nil {
-		v, moreDiags := decodeDynamicValue(raw, rt.schema.Content)
-		resp.RefreshedValue = v
-		diags = append(diags, moreDiags...)
+		if req.NewStateVisitor != nil {
+			diags = append(diags, visitDynamicValue(rt.opts, raw, rt.schema, req.NewStateVisitor)...)
+		} else {
+			v, moreDiags := decodeDynamicValue(rt.opts, raw, rt.schema)
+			resp.RefreshedValue = v
+			diags = append(diags, moreDiags...)
+		}
 	}
 	resp.OpaquePrivate = rawResp.Private
+	resp.PrivateSchemaVersion = rt.schema.Version
 	return resp, diags
 }
 
 func (rt *ManagedResourceType) Plan(ctx context.Context, req common.ManagedResourcePlanRequest) (common.ManagedResourcePlanResponse, common.Diagnostics) {
+	// Panics are recovered around the operation alone, so that the
+	// PostPlan hook still runs and receives the resulting diagnostics.
+	plan := func() (resp common.ManagedResourcePlanResponse, diags common.Diagnostics) {
+		defer rt.opts.RecoverPanic("plan", &diags)
+		return rt.plan(ctx, req)
+	}
+	hooks := rt.opts.Hooks
+	if hooks == nil {
+		return plan()
+	}
+	hooks.PrePlan(ctx, rt.typeName, req)
+	resp, diags := plan()
+	hooks.PostPlan(ctx, rt.typeName, req, resp, diags)
+	return resp, diags
+}
+
+func (rt *ManagedResourceType) plan(ctx context.Context, req common.ManagedResourcePlanRequest) (common.ManagedResourcePlanResponse, common.Diagnostics) {
+	if diags := requireConfigured(rt.configState); diags.HasErrors() {
+		return common.ManagedResourcePlanResponse{}, diags
+	}
+	if rt.opts.ValidatePlanRequests {
+		if diags := common.ValidatePlanRequest(req, rt.schema.Content); diags.HasErrors() {
+			return common.ManagedResourcePlanResponse{}, diags
+		}
+	}
+
 	var diags common.Diagnostics
 
-	priorDV, moreDiags := encodeDynamicValue(req.PriorState, rt.schema.Content)
+	priorDV, moreDiags := encodeDynamicValue(rt.opts, rt.typeName, req.PriorState, rt.schema)
 	diags = append(diags, moreDiags...)
 	if moreDiags.HasErrors() {
 		return common.ManagedResourcePlanResponse{}, diags
 	}
+	diags = append(diags, rt.schema.CheckPrivateVersion(rt.opts, rt.typeName, req.OpaquePrivate, req.PrivateSchemaVersion)...)
 
-	proposedDV, moreDiags := encodeDynamicValue(req.ProposedNewState, rt.schema.Content)
+	proposedDV, moreDiags := encodeDynamicValue(rt.opts, rt.typeName, req.ProposedNewState, rt.schema)
 	diags = append(diags, moreDiags...)
 	if moreDiags.HasErrors() {
 		return common.ManagedResourcePlanResponse{}, diags
 	}
 
-	configDV, moreDiags := encodeDynamicValue(req.Config, rt.schema.Content)
+	configDV, moreDiags := encodeDynamicValue(rt.opts, rt.typeName, req.Config, rt.schema)
 	diags = append(diags, moreDiags...)
 	if moreDiags.HasErrors() {
 		return common.ManagedResourcePlanResponse{}, diags
 	}
 
-	var providerMetaDV *tfplugin5.DynamicValue
-	if !req.ProviderMeta.IsNull() && rt.providerMetaSchema != nil {
-		var moreDiags common.Diagnostics
-		providerMetaDV, moreDiags = encodeDynamicValue(req.ProviderMeta, rt.providerMetaSchema)
-		diags = append(diags, moreDiags...)
-		if moreDiags.HasErrors() {
-			return common.ManagedResourcePlanResponse{}, diags
-		}
+	providerMetaDV, moreDiags := encodeProviderMeta(rt.opts, req.ProviderMeta, rt.providerMetaSchema)
+	diags = append(diags, moreDiags...)
+	if moreDiags.HasErrors() {
+		return common.ManagedResourcePlanResponse{}, diags
 	}
 
 	resp, err := rt.client.PlanResourceChange(ctx, &tfplugin5.PlanResourceChange_Request{
@@ -106,14 +173,23 @@ func (rt *ManagedResourceType) Plan(ctx context.Context, req common.ManagedResou
 		return common.ManagedResourcePlanResponse{}, diags
 	}
 
-	diags = append(diags, decodeDiagnostics(resp.Diagnostics)...)
+	diags = append(diags, decodeDiagnostics(rt.opts, resp.Diagnostics).WithResource(rt.opts, rt.typeName, req.InstanceKey)...)
 
 	result := common.ManagedResourcePlanResponse{
-		OpaquePrivate: resp.PlannedPrivate,
+		OpaquePrivate:    resp.PlannedPrivate,
+		LegacyTypeSystem: resp.LegacyTypeSystem,
+
+		PrivateSchemaVersion: rt.schema.Version,
 	}
 
 	if resp.PlannedState != nil {
-		plannedState, moreDiags := decodeDynamicValue(resp.PlannedState, rt.schema.Content)
+		var plannedState cty.Value
+		var moreDiags common.Diagnostics
+		if resp.LegacyTypeSystem {
+			plannedState, moreDiags = decodeDynamicValueLegacy(rt.opts, resp.PlannedState, rt.schema)
+		} else {
+			plannedState, moreDiags = decodeDynamicValue(rt.opts, resp.PlannedState, rt.schema)
+		}
 		diags = append(diags, moreDiags...)
 		result.PlannedState = plannedState
 	}
@@ -123,38 +199,65 @@ func (rt *ManagedResourceType) Plan(ctx context.Context, req common.ManagedResou
 		result.RequiresReplace = append(result.RequiresReplace, path)
 	}
 
+	if !diags.HasErrors() {
+		diags = append(diags, rt.opts.CheckCreatePlan(req, result)...)
+	}
+	if !diags.HasErrors() {
+		rt.opts.PlannedPrivate.RecordPlan(rt.typeName, req.InstanceKey, result.OpaquePrivate)
+	}
 	return result, diags
 }
 
 func (rt *ManagedResourceType) Apply(ctx context.Context, req common.ManagedResourceApplyRequest) (common.ManagedResourceApplyResponse, common.Diagnostics) {
+	// Panics are recovered around the operation alone, so that the
+	// PostApply hook still runs and receives the resulting diagnostics.
+	apply := func() (resp common.ManagedResourceApplyResponse, diags common.Diagnostics) {
+		defer rt.opts.RecoverPanic("apply", &diags)
+		return rt.apply(ctx, req)
+	}
+	hooks := rt.opts.Hooks
+	if hooks == nil {
+		return apply()
+	}
+	hooks.PreApply(ctx, rt.typeName, req)
+	resp, diags := apply()
+	hooks.PostApply(ctx, rt.typeName, req, resp, diags)
+	return resp, diags
+}
+
+func (rt *ManagedResourceType) apply(ctx context.Context, req common.ManagedResourceApplyRequest) (common.ManagedResourceApplyResponse, common.Diagnostics) {
+	if diags := requireConfigured(rt.configState); diags.HasErrors() {
+		return common.ManagedResourceApplyResponse{}, diags
+	}
+	if diags := rt.opts.PlannedPrivate.CheckApply(rt.typeName, req.InstanceKey, req.OpaquePrivate); diags.HasErrors() {
+		return common.ManagedResourceApplyResponse{}, diags
+	}
+
 	var diags common.Diagnostics
 
-	priorDV, moreDiags := encodeDynamicValue(req.PriorState, rt.schema.Content)
+	priorDV, moreDiags := encodeDynamicValue(rt.opts, rt.typeName, req.PriorState, rt.schema)
 	diags = append(diags, moreDiags...)
 	if moreDiags.HasErrors() {
 		return common.ManagedResourceApplyResponse{}, diags
 	}
+	diags = append(diags, rt.schema.CheckPrivateVersion(rt.opts, rt.typeName, req.OpaquePrivate, req.PrivateSchemaVersion)...)
 
-	plannedDV, moreDiags := encodeDynamicValue(req.PlannedState, rt.schema.Content)
+	plannedDV, moreDiags := encodeDynamicValue(rt.opts, rt.typeName, req.PlannedState, rt.schema)
 	diags = append(diags, moreDiags...)
 	if moreDiags.HasErrors() {
 		return common.ManagedResourceApplyResponse{}, diags
 	}
 
-	configDV, moreDiags := encodeDynamicValue(req.Config, rt.schema.Content)
+	configDV, moreDiags := encodeDynamicValue(rt.opts, rt.typeName, req.Config, rt.schema)
 	diags = append(diags, moreDiags...)
 	if moreDiags.HasErrors() {
 		return common.ManagedResourceApplyResponse{}, diags
 	}
 
-	var providerMetaDV *tfplugin5.DynamicValue
-	if !req.ProviderMeta.IsNull() && rt.providerMetaSchema != nil {
-		var moreDiags common.Diagnostics
-		providerMetaDV, moreDiags = encodeDynamicValue(req.ProviderMeta, rt.providerMetaSchema)
-		diags = append(diags, moreDiags...)
-		if moreDiags.HasErrors() {
-			return common.ManagedResourceApplyResponse{}, diags
-		}
+	providerMetaDV, moreDiags := encodeProviderMeta(rt.opts, req.ProviderMeta, rt.providerMetaSchema)
+	diags = append(diags, moreDiags...)
+	if moreDiags.HasErrors() {
+		return common.ManagedResourceApplyResponse{}, diags
 	}
 
 	resp, err := rt.client.ApplyResourceChange(ctx, &tfplugin5.ApplyResourceChange_Request{
@@ -170,14 +273,22 @@ func (rt *ManagedResourceType) Apply(ctx context.Context, req common.ManagedReso
 		return common.ManagedResourceApplyResponse{}, diags
 	}
 
-	diags = append(diags, decodeDiagnostics(resp.Diagnostics)...)
+	diags = append(diags, decodeDiagnostics(rt.opts, resp.Diagnostics).WithResource(rt.opts, rt.typeName, req.InstanceKey)...)
 
 	result := common.ManagedResourceApplyResponse{
-		OpaquePrivate: resp.Private,
+		OpaquePrivate:        resp.Private,
+		PrivateSchemaVersion: rt.schema.Version,
+	}
+	if result.OpaquePrivate == nil {
+		// Some providers omit the private data when applying a change that
+		// doesn't affect it, in which case we carry forward the planned
+		// private data, as Terraform does, so it's available to later
+		// operations.
+		result.OpaquePrivate = req.OpaquePrivate
 	}
 
 	if resp.NewState != nil {
-		newState, moreDiags := decodeDynamicValue(resp.NewState, rt.schema.Content)
+		newState, moreDiags := decodeDynamicValue(rt.opts, resp.NewState, rt.schema)
 		diags = append(diags, moreDiags...)
 		result.NewState = newState
 	}
@@ -185,19 +296,38 @@ func (rt *ManagedResourceType) Apply(ctx context.Context, req common.ManagedReso
 	return result, diags
 }
 
-func (rt *ManagedResourceType) Import(ctx context.Context, req common.ManagedResourceImportRequest) (common.ManagedResourceImportResponse, common.Diagnostics) {
+func (rt *ManagedResourceType) Import(ctx context.Context, req common.ManagedResourceImportRequest) (resp common.ManagedResourceImportResponse, diags common.Diagnostics) {
+	defer rt.opts.RecoverPanic("import", &diags)
+	return rt.opts.RetryImport(ctx, func() (common.ManagedResourceImportResponse, common.Diagnostics) {
+		return rt.importState(ctx, req)
+	})
+}
+
+func (rt *ManagedResourceType) importState(ctx context.Context, req common.ManagedResourceImportRequest) (common.ManagedResourceImportResponse, common.Diagnostics) {
+	if diags := requireConfigured(rt.configState); diags.HasErrors() {
+		return common.ManagedResourceImportResponse{}, diags
+	}
+
 	var diags common.Diagnostics
 
-	resp, err := rt.client.ImportResourceState(ctx, &tfplugin5.ImportResourceState_Request{
-		TypeName: rt.typeName,
-		Id:       req.ID,
+	// Importing a large remote object can be slow, so if the caller gives
+	// up we ask the provider to stop rather than just abandoning the call.
+	var resp *tfplugin5.ImportResourceState_Response
+	var err error
+	common.CallWithStop(ctx, common.StopGracePeriod, func(ctx context.Context) {
+		rt.client.Stop(ctx, &tfplugin5.Stop_Request{})
+	}, func(ctx context.Context) {
+		resp, err = rt.client.ImportResourceState(ctx, &tfplugin5.ImportResourceState_Request{
+			TypeName: rt.typeName,
+			Id:       req.ID,
+		})
 	})
 	diags = append(diags, common.RPCErrorDiagnostics(err)...)
 	if err != nil {
 		return common.ManagedResourceImportResponse{}, diags
 	}
 
-	diags = append(diags, decodeDiagnostics(resp.Diagnostics)...)
+	diags = append(diags, decodeDiagnostics(rt.opts, resp.Diagnostics).WithResource(rt.opts, rt.typeName, req.InstanceKey)...)
 
 	result := common.ManagedResourceImportResponse{}
 
@@ -211,13 +341,18 @@ func (rt *ManagedResourceType) Import(ctx context.Context, req common.ManagedRes
 			})
 			continue
 		}
-		state, moreDiags := decodeDynamicValue(imported.State, rt.schema.Content)
+		state, moreDiags := decodeDynamicValue(rt.opts, imported.State, rt.schema)
+		if !moreDiags.HasErrors() && rt.opts.StrictImports {
+			moreDiags = append(moreDiags, common.CheckImportedState(state, rt.schema.Content).WithResource(rt.opts, rt.typeName, req.InstanceKey)...)
+		}
 		diags = append(diags, moreDiags...)
 		if !moreDiags.HasErrors() {
 			result.ImportedResources = append(result.ImportedResources, common.ImportedResource{
 				TypeName:      imported.TypeName,
 				State:         state,
 				OpaquePrivate: imported.Private,
+
+				PrivateSchemaVersion: rt.schema.Version,
 			})
 		}
 	}
@@ -225,6 +360,28 @@ func (rt *ManagedResourceType) Import(ctx context.Context, req common.ManagedRes
 	return result, diags
 }
 
+// upgradeState asks the provider to upgrade the given raw JSON state,
+// produced under the given schema version, to the current schema version.
+func (rt *ManagedResourceType) upgradeState(ctx context.Context, rawStateJSON []byte, version int64) (cty.Value, common.Diagnostics) {
+	resp, err := rt.client.UpgradeResourceState(ctx, &tfplugin5.UpgradeResourceState_Request{
+		TypeName: rt.typeName,
+		Version:  version,
+		RawState: &tfplugin5.RawState{
+			Json: rawStateJSON,
+		},
+	})
+	diags := common.RPCErrorDiagnostics(err)
+	if err != nil {
+		return cty.NilVal, diags
+	}
+	diags = append(diags, decodeDiagnostics(rt.opts, resp.Diagnostics)...)
+	if diags.HasErrors() {
+		return cty.NilVal, diags
+	}
+	state, moreDiags := decodeDynamicValue(rt.opts, resp.UpgradedState, rt.schema)
+	return state, append(diags, moreDiags...)
+}
+
 func (rt *ManagedResourceType) Sealed() common.Sealed {
 	return common.Sealed{}
 }
@@ -0,0 +1,106 @@
+package protocol5
+
+import (
+	"context"
+	"testing"
+
+	"github.com/zclconf/go-cty/cty"
+
+	"github.com/apparentlymart/terraform-provider/internal/tfplugin5"
+)
+
+func TestProviderPrepareConfig(t *testing.T) {
+	configType := cty.Object(map[string]cty.Type{
+		"region": cty.String,
+		"token":  cty.String,
+	})
+	config := func(region, token cty.Value) cty.Value {
+		return cty.ObjectVal(map[string]cty.Value{
+			"region": region,
+			"token":  token,
+		})
+	}
+	given := config(cty.NullVal(cty.String), cty.UnknownVal(cty.String))
+	withDefault := config(cty.StringVal("us-east-1"), cty.UnknownVal(cty.String))
+
+	tests := map[string]struct {
+		// prepare returns the provider's prepared config, or nil to omit it.
+		prepare      func(t *testing.T, given *tfplugin5.DynamicValue) *tfplugin5.DynamicValue
+		want         cty.Value
+		wantModified bool
+	}{
+		"omitted": {
+			func(t *testing.T, given *tfplugin5.DynamicValue) *tfplugin5.DynamicValue {
+				return nil
+			},
+			given,
+			false,
+		},
+		"echoed": {
+			func(t *testing.T, given *tfplugin5.DynamicValue) *tfplugin5.DynamicValue {
+				return given
+			},
+			given,
+			false,
+		},
+		"default inserted": {
+			func(t *testing.T, given *tfplugin5.DynamicValue) *tfplugin5.DynamicValue {
+				return testDynamicValue(t, withDefault)
+			},
+			withDefault,
+			true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			client := &fakeClient{
+				prepareProviderConfig: func(ctx context.Context, req *tfplugin5.PrepareProviderConfig_Request) (*tfplugin5.PrepareProviderConfig_Response, error) {
+					if got := decodeTestDynamicValue(t, req.Config, configType); !got.RawEquals(given) {
+						t.Errorf("wrong config sent %#v; want %#v", got, given)
+					}
+					return &tfplugin5.PrepareProviderConfig_Response{
+						PreparedConfig: test.prepare(t, req.Config),
+					}, nil
+				},
+			}
+			p := newTestProvider(t, client, nil)
+
+			got, diags := p.PrepareConfig(context.Background(), given)
+			if diags.HasErrors() {
+				t.Fatalf("unexpected errors: %s", diags.Err())
+			}
+			if !got.Value.RawEquals(test.want) {
+				t.Errorf("wrong value %#v; want %#v", got.Value, test.want)
+			}
+			if got.Modified != test.wantModified {
+				t.Errorf("wrong Modified %t; want %t", got.Modified, test.wantModified)
+			}
+		})
+	}
+}
+
+func TestProviderPrepareConfigInvalid(t *testing.T) {
+	client := &fakeClient{
+		prepareProviderConfig: func(ctx context.Context, req *tfplugin5.PrepareProviderConfig_Request) (*tfplugin5.PrepareProviderConfig_Response, error) {
+			return &tfplugin5.PrepareProviderConfig_Response{
+				Diagnostics: []*tfplugin5.Diagnostic{
+					{Severity: tfplugin5.Diagnostic_ERROR, Summary: "Invalid region"},
+				},
+			}, nil
+		},
+	}
+	p := newTestProvider(t, client, nil)
+
+	given := cty.ObjectVal(map[string]cty.Value{
+		"region": cty.StringVal("nowhere"),
+		"token":  cty.NullVal(cty.String),
+	})
+	got, diags := p.PrepareConfig(context.Background(), given)
+	if len(diags) != 1 || diags[0].Summary != "Invalid region" {
+		t.Errorf("wrong diagnostics %#v", diags)
+	}
+	if !got.Value.RawEquals(given) || got.Modified {
+		t.Errorf("wrong result %#v; want the given config, unmodified", got)
+	}
+}
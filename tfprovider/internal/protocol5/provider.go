@@ -5,8 +5,12 @@ import (
 	"fmt"
 	"sync/atomic"
 
+	"github.com/apparentlymart/terraform-schema-go/tfschema"
 	"github.com/zclconf/go-cty/cty"
 	"go.rpcplugin.org/rpcplugin"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/peer"
 
 	"github.com/apparentlymart/terraform-provider/internal/tfplugin5"
 	"github.com/apparentlymart/terraform-provider/tfprovider/internal/common"
@@ -18,30 +22,90 @@ type Provider struct {
 	client tfplugin5.ProviderClient
 	plugin *rpcplugin.Plugin
 	schema *common.Schema
+	opts   *common.Options
 
-	configured atomic.Bool
+	// schemaDiags are any warnings the provider returned along with its
+	// schema, which we return from each call to Schema.
+	schemaDiags common.Diagnostics
+
+	// rawSchema is the provider's response to the schema request, exactly
+	// as it was received.
+	rawSchema *tfplugin5.GetProviderSchema_Response
+
+	// security describes the connection the schema was loaded over, if
+	// securityKnown is set.
+	security      common.ConnectionSecurity
+	securityKnown bool
+
+	// conn is the gRPC connection to the plugin, or nil if there is no
+	// plugin behind the client.
+	conn *grpc.ClientConn
+
+	// configState is one of the configState constants, recording whether
+	// and how the provider has been configured.
+	configState atomic.Int32
+
+	// closed is set once Close has been called.
+	closed atomic.Bool
 }
 
-func NewProvider(ctx context.Context, plugin *rpcplugin.Plugin, clientProxy interface{}) (*Provider, error) {
+const (
+	configStateUnconfigured int32 = iota
+	configStatePlanOnly
+	configStateConfigured
+)
+
+// NewProvider creates a provider that communicates with a plugin using the
+// given client proxy, which must be a tfplugin5.ProviderClient.
+//
+// The plugin may be nil if there is no child process behind the client,
+// such as when replaying a recording. The options may be nil to accept the
+// default behavior.
+func NewProvider(ctx context.Context, plugin *rpcplugin.Plugin, clientProxy interface{}, opts *common.Options) (*Provider, error) {
 	client, ok := clientProxy.(tfplugin5.ProviderClient)
 	if !ok {
 		return nil, fmt.Errorf("expected tfplugin5.ProviderClient, got %T", clientProxy)
 	}
+	if opts == nil {
+		opts = &common.Options{}
+	}
+	var conn *grpc.ClientConn
+	if proxy, ok := clientProxy.(common.ClientConnProxy); ok {
+		conn = proxy.ClientConn()
+	}
+	if len(opts.Interceptors) != 0 {
+		client = NewInterceptedClient(client, common.ChainRPCInterceptors(opts.Interceptors...))
+	}
 
 	// We proactively fetch the schema here because you can't really do anything
 	// useful to a provider without it: we need it to serialize any values given
 	// in msgpack format.
-	schema, err := loadSchema(ctx, client)
+	var schemaPeer peer.Peer
+	schema, rawSchema, schemaDiags, err := loadSchema(ctx, client, opts, &schemaPeer)
 	if err != nil {
-		plugin.Close() // Clean up plugin on schema loading failure
+		// Clean up plugin on schema loading failure
+		if plugin != nil {
+			plugin.Close()
+		}
+		opts.Close()
 		return nil, err
 	}
 
-	return &Provider{
+	ret := &Provider{
 		client: client,
 		plugin: plugin,
 		schema: schema,
-	}, nil
+		opts:   opts,
+
+		schemaDiags: schemaDiags,
+		rawSchema:   rawSchema,
+	}
+	ret.security, ret.securityKnown = common.ConnectionSecurityFromPeer(&schemaPeer)
+	ret.conn = conn
+	if cb := opts.ConnectionStateCallback; cb != nil {
+		opts.OnCloseFunc(common.WatchConnectionState(conn, cb))
+	}
+	return ret, nil
 }
 
 func (p *Provider) Sealed() common.Sealed {
@@ -49,11 +113,48 @@ func (p *Provider) Sealed() common.Sealed {
 }
 
 func (p *Provider) Schema(ctx context.Context) (*common.Schema, common.Diagnostics) {
-	return p.schema, nil
+	return p.schema, p.schemaDiags
+}
+
+// RawSchema returns the provider's response to the request for its schema,
+// exactly as it was received, including any details that the decoded schema
+// returned by Schema doesn't retain.
+//
+// This method is specific to protocol version 5 and so is not part of the
+// protocol-agnostic Provider interface. The result must not be modified.
+func (p *Provider) RawSchema() *tfplugin5.GetProviderSchema_Response {
+	return p.rawSchema
+}
+
+func (p *Provider) ConnectionSecurity() (common.ConnectionSecurity, bool) {
+	return p.security, p.securityKnown
+}
+
+func (p *Provider) ConnectionState() connectivity.State {
+	return common.ConnectionState(p.conn)
+}
+
+func (p *Provider) GRPCConn() *grpc.ClientConn {
+	if p.closed.Load() {
+		return nil
+	}
+	return p.conn
+}
+
+func (p *Provider) ProviderConfigSchema() *tfschema.Block {
+	return p.schema.ProviderConfig
+}
+
+func (p *Provider) ProviderConfigType() cty.Type {
+	return p.schema.ProviderConfig.ImpliedType()
+}
+
+func (p *Provider) ConfigPrompts() []common.AttrPrompt {
+	return common.ConfigPrompts(p.schema.ProviderConfig)
 }
 
 func (p *Provider) PrepareConfig(ctx context.Context, config cty.Value) (common.Config, common.Diagnostics) {
-	dv, diags := encodeDynamicValue(config, p.schema.ProviderConfig)
+	dv, diags := encodeDynamicValue(p.opts, "", config, p.schema.ProviderConfig)
 	if diags.HasErrors() {
 		return common.Config{Value: config}, diags
 	}
@@ -64,28 +165,79 @@ func (p *Provider) PrepareConfig(ctx context.Context, config cty.Value) (common.
 	if err != nil {
 		return common.Config{Value: config}, diags
 	}
-	diags = append(diags, decodeDiagnostics(resp.Diagnostics)...)
-	if raw := resp.PreparedConfig; raw != nil {
-		v, moreDiags := decodeDynamicValue(raw, p.schema.ProviderConfig)
-		diags = append(diags, moreDiags...)
-		return common.Config{Value: v}, diags
+	diags = append(diags, decodeDiagnostics(p.opts, resp.Diagnostics)...)
+	raw := resp.PreparedConfig
+	if raw == nil {
+		// The provider made no changes to the configuration.
+		return common.Config{Value: config}, diags
+	}
+	v, moreDiags := decodeDynamicValue(p.opts, raw, p.schema.ProviderConfig)
+	diags = append(diags, moreDiags...)
+	if moreDiags.HasErrors() {
+		return common.Config{Value: config}, diags
 	}
-	return common.Config{Value: cty.DynamicVal}, diags
+	return common.Config{
+		Value:    v,
+		Modified: common.ConfigModified(config, v),
+	}, diags
 }
 
-func (p *Provider) Configure(ctx context.Context, config common.Config) common.Diagnostics {
-	if p.configured.Swap(true) {
+func (p *Provider) TryConfigure(ctx context.Context, config cty.Value) common.Diagnostics {
+	if !config.IsWhollyKnown() {
 		return common.Diagnostics{
 			{
 				Severity: common.Error,
-				Summary:  "Provider already configured",
-				Detail:   "This operation requires an unconfigured provider, but this provider was already configured.",
+				Summary:  "Provider configuration not fully known",
+				Detail:   "The provider configuration contains unknown values, so it cannot be used to configure the provider. Use ConfigurePlanOnly to configure the provider only for planning.",
 			},
 		}
 	}
+	prepared, diags := p.PrepareConfig(ctx, config)
+	if diags.HasErrors() {
+		return diags
+	}
+	// The provider may have normalized the configuration, so we check that
+	// the result is still something that Configure could send.
+	_, moreDiags := encodeDynamicValue(p.opts, "", prepared.Value, p.schema.ProviderConfig)
+	diags = append(diags, moreDiags...)
+	if diags.HasErrors() {
+		return diags
+	}
+
+	// Configure sends the configuration with its secrets resolved, so we
+	// also check that they can be resolved and that the result can still
+	// be encoded, without sending the resolved secrets to the provider.
+	if len(p.opts.SecretResolvers) == 0 {
+		return diags
+	}
+	val, moreDiags := p.opts.ResolveSecrets(prepared.Value)
+	diags = append(diags, moreDiags...)
+	if moreDiags.HasErrors() {
+		return diags
+	}
+	_, moreDiags = encodeDynamicValue(p.opts, "", val, p.schema.ProviderConfig)
+	return append(diags, moreDiags...)
+}
+
+func (p *Provider) Configure(ctx context.Context, config common.Config) common.Diagnostics {
+	// A provider configured only for planning may still be fully
+	// configured once its configuration is known.
+	prev := p.configState.Load()
+	if prev == configStateConfigured || !p.configState.CompareAndSwap(prev, configStateConfigured) {
+		return alreadyConfiguredDiagnostics()
+	}
 
-	dv, diags := encodeDynamicValue(config.Value, p.schema.ProviderConfig)
+	// Secrets are resolved only now, so that they aren't held in memory
+	// any longer than necessary.
+	val, diags := p.opts.ResolveSecrets(config.Value)
+	if diags.HasErrors() {
+		p.configState.Store(prev)
+		return diags
+	}
+	dv, moreDiags := encodeDynamicValue(p.opts, "", val, p.schema.ProviderConfig)
+	diags = append(diags, moreDiags...)
 	if diags.HasErrors() {
+		p.configState.Store(prev)
 		return diags
 	}
 	resp, err := p.client.Configure(ctx, &tfplugin5.Configure_Request{
@@ -93,50 +245,328 @@ func (p *Provider) Configure(ctx context.Context, config common.Config) common.D
 	})
 	diags = append(diags, common.RPCErrorDiagnostics(err)...)
 	if err != nil {
+		p.configState.Store(prev)
 		return diags
 	}
-	diags = append(diags, decodeDiagnostics(resp.Diagnostics)...)
+	diags = append(diags, decodeDiagnostics(p.opts, resp.Diagnostics)...)
+	if !diags.HasErrors() && p.opts.ConfigureProbe != nil {
+		diags = append(diags, common.ConfigureProbeDiagnostics(p.opts.ConfigureProbe(ctx, p))...)
+	}
 	if diags.HasErrors() {
 		// Reset configured state on error
-		p.configured.Store(false)
+		p.configState.Store(prev)
+	}
+	return diags
+}
+
+func (p *Provider) ConfigurePlanOnly(ctx context.Context, config common.Config) common.Diagnostics {
+	if config.Value.IsWhollyKnown() {
+		return p.Configure(ctx, config)
+	}
+	if !p.configState.CompareAndSwap(configStateUnconfigured, configStatePlanOnly) {
+		return alreadyConfiguredDiagnostics()
+	}
+
+	// We can't send a partially-unknown configuration to the provider, so
+	// we can only check that it conforms to the configuration schema.
+	_, diags := encodeDynamicValue(p.opts, "", config.Value, p.schema.ProviderConfig)
+	if diags.HasErrors() {
+		p.configState.Store(configStateUnconfigured)
 	}
 	return diags
 }
 
+func alreadyConfiguredDiagnostics() common.Diagnostics {
+	return common.Diagnostics{
+		{
+			Severity: common.Error,
+			Summary:  "Provider already configured",
+			Detail:   "This operation requires an unconfigured provider, but this provider was already configured.",
+		},
+	}
+}
+
+// requireConfigured returns error diagnostics if the given configuration
+// state shows that the provider was configured only for planning, and so
+// cannot yet handle operations that call into the provider's own logic.
+func requireConfigured(state *atomic.Int32) common.Diagnostics {
+	if state.Load() == configStateConfigured {
+		return nil
+	}
+	return common.Diagnostics{
+		{
+			Severity: common.Error,
+			Summary:  "Provider not fully configured",
+			Detail:   "This operation requires a fully-configured provider, but this provider was configured only for planning because its configuration contains unknown values. Call Configure with a wholly-known configuration first.",
+		},
+	}
+}
+
+func (p *Provider) ManagedResourceSchemaVersion(typeName string) (int64, error) {
+	schema, ok := p.schema.ManagedResourceTypes[typeName]
+	if !ok {
+		return 0, common.UnknownResourceTypeError{Mode: common.ManagedResourceMode, TypeName: typeName}
+	}
+	return schema.Version, nil
+}
+
+func (p *Provider) ResourceSchemaVersion(typeName string, version int64) (*tfschema.Block, error) {
+	schema, ok := p.schema.ManagedResourceTypes[typeName]
+	if !ok {
+		return nil, common.UnknownResourceTypeError{Mode: common.ManagedResourceMode, TypeName: typeName}
+	}
+	if version != schema.Version {
+		return nil, common.SchemaVersionUnavailableError{
+			TypeName:       typeName,
+			Version:        version,
+			CurrentVersion: schema.Version,
+		}
+	}
+	return schema.Content, nil
+}
+
+func (p *Provider) ProvidersSchemaJSON() ([]byte, error) {
+	if p.opts.ProviderAddress == "" {
+		return nil, fmt.Errorf("provider source address is not known; set it using WithProviderAddress")
+	}
+	return p.schema.ProvidersSchemaJSON(p.opts.ProviderAddress)
+}
+
+func (p *Provider) AllTypeNames() common.TypeInventory {
+	return p.schema.TypeInventory()
+}
+
+func (p *Provider) SchemaVersions() map[string]int64 {
+	ret := make(map[string]int64, len(p.schema.ManagedResourceTypes))
+	for name, schema := range p.schema.ManagedResourceTypes {
+		ret[name] = schema.Version
+	}
+	return ret
+}
+
 func (p *Provider) ManagedResourceType(typeName string) (common.ManagedResourceType, error) {
-	if !p.configured.Load() {
+	if p.configState.Load() == configStateUnconfigured {
 		return nil, fmt.Errorf("provider not configured")
 	}
 
 	schema, ok := p.schema.ManagedResourceTypes[typeName]
 	if !ok {
-		return nil, fmt.Errorf("managed resource type %q not found", typeName)
+		return nil, common.UnknownResourceTypeError{Mode: common.ManagedResourceMode, TypeName: typeName}
 	}
+	return p.newManagedResourceType(typeName, schema), nil
+}
+
+func (p *Provider) newManagedResourceType(typeName string, schema *common.ManagedResourceTypeSchema) *ManagedResourceType {
 	return &ManagedResourceType{
 		client:             p.client,
 		typeName:           typeName,
 		schema:             schema,
 		providerMetaSchema: p.schema.ProviderMeta,
-	}, nil
+		opts:               p.opts,
+		configState:        &p.configState,
+	}
 }
 
 func (p *Provider) DataResourceType(typeName string) (common.DataResourceType, error) {
-	if !p.configured.Load() {
+	if p.configState.Load() == configStateUnconfigured {
 		return nil, fmt.Errorf("provider not configured")
 	}
 
 	schema, ok := p.schema.DataResourceTypes[typeName]
 	if !ok {
-		return nil, fmt.Errorf("data resource type %q not found", typeName)
+		return nil, common.UnknownResourceTypeError{Mode: common.DataResourceMode, TypeName: typeName}
 	}
+	return p.newDataResourceType(typeName, schema), nil
+}
+
+func (p *Provider) newDataResourceType(typeName string, schema *common.DataResourceTypeSchema) *DataResourceType {
 	return &DataResourceType{
 		client:             p.client,
 		typeName:           typeName,
 		schema:             schema,
 		providerMetaSchema: p.schema.ProviderMeta,
-	}, nil
+		opts:               p.opts,
+		configState:        &p.configState,
+	}
+}
+
+func (p *Provider) ResourceExists(ctx context.Context, typeName string, state cty.Value, private []byte) (bool, common.Diagnostics) {
+	rt, err := p.ManagedResourceType(typeName)
+	if err != nil {
+		return false, common.Diagnostics{
+			{
+				Severity: common.Error,
+				Summary:  "Invalid resource type",
+				Detail:   err.Error(),
+			},
+		}
+	}
+	resp, diags := rt.Read(ctx, common.ManagedResourceReadRequest{
+		PreviousValue: state,
+		OpaquePrivate: private,
+	})
+	if diags.HasErrors() {
+		return false, diags
+	}
+	// A provider signals that the remote object no longer exists by
+	// returning a null new state.
+	return !resp.RefreshedValue.IsNull(), diags
+}
+
+func (p *Provider) DetectDrift(ctx context.Context, typeName string, desired cty.Value, private []byte) ([]common.AttrChange, common.Diagnostics) {
+	rt, err := p.ManagedResourceType(typeName)
+	if err != nil {
+		return nil, common.Diagnostics{
+			{
+				Severity: common.Error,
+				Summary:  "Invalid resource type",
+				Detail:   err.Error(),
+			},
+		}
+	}
+	resp, diags := rt.Read(ctx, common.ManagedResourceReadRequest{
+		PreviousValue: desired,
+		OpaquePrivate: private,
+	})
+	if diags.HasErrors() {
+		return nil, diags
+	}
+	// If the remote object has been deleted then the refreshed value is
+	// null, and so Diff reports the whole object as changed.
+	return common.Diff(desired, resp.RefreshedValue), diags
+}
+
+func (p *Provider) PlanAndDiff(ctx context.Context, typeName string, req common.ManagedResourcePlanRequest) (common.ManagedResourcePlanResponse, []common.AttrChange, common.Diagnostics) {
+	rt, err := p.ManagedResourceType(typeName)
+	if err != nil {
+		return common.ManagedResourcePlanResponse{}, nil, common.Diagnostics{
+			{
+				Severity: common.Error,
+				Summary:  "Invalid resource type",
+				Detail:   err.Error(),
+			},
+		}
+	}
+	resp, diags := rt.Plan(ctx, req)
+	if diags.HasErrors() {
+		return resp, nil, diags
+	}
+	return resp, common.PlanDiff(req, resp), diags
+}
+
+func (p *Provider) Refresh(ctx context.Context, typeName string, rawStateJSON []byte, storedVersion int64, private []byte) (common.ManagedResourceReadResponse, common.Diagnostics) {
+	if diags := requireConfigured(&p.configState); diags.HasErrors() {
+		return common.ManagedResourceReadResponse{}, diags
+	}
+	schema, ok := p.schema.ManagedResourceTypes[typeName]
+	if !ok {
+		return common.ManagedResourceReadResponse{}, common.UnknownResourceTypeDiagnostics(common.ManagedResourceMode, typeName)
+	}
+	rt := p.newManagedResourceType(typeName, schema)
+
+	var state cty.Value
+	var diags common.Diagnostics
+	currentVersion := rt.schema.Version
+	switch {
+	case storedVersion > currentVersion:
+		return common.ManagedResourceReadResponse{}, common.Diagnostics{
+			{
+				Severity: common.Error,
+				Summary:  "Resource state from newer provider version",
+				Detail:   fmt.Sprintf("The stored state for this %s object was produced under schema version %d, but this version of the provider only supports schema versions up to %d. Use a newer version of the provider.", typeName, storedVersion, currentVersion),
+			},
+		}
+	case storedVersion < currentVersion:
+		state, diags = rt.upgradeState(ctx, rawStateJSON, storedVersion)
+	default:
+		state, diags = common.DecodeDynamicValue(common.DynamicValueData{JSON: rawStateJSON}, rt.schema)
+	}
+	if diags.HasErrors() {
+		return common.ManagedResourceReadResponse{}, diags
+	}
+
+	resp, moreDiags := rt.Read(ctx, common.ManagedResourceReadRequest{
+		PreviousValue:         state,
+		OpaquePrivate:         private,
+		PreviousSchemaVersion: &currentVersion,
+	})
+	return resp, append(diags, moreDiags...)
+}
+
+func (p *Provider) ReadDataSources(ctx context.Context, inputs []common.DataReadInput) []common.DataReadResult {
+	return common.RunDataReads(ctx, len(inputs), func(ctx context.Context, i int) (common.DataResourceReadResponse, common.Diagnostics) {
+		in := inputs[i]
+		rt, err := p.DataResourceType(in.TypeName)
+		if err != nil {
+			return common.DataResourceReadResponse{}, common.Diagnostics{
+				{
+					Severity: common.Error,
+					Summary:  "Invalid data resource type",
+					Detail:   err.Error(),
+				},
+			}
+		}
+		return rt.Read(ctx, in.Request)
+	})
+}
+
+func (p *Provider) ValidateAll(ctx context.Context, config cty.Value, resourceConfigs, dataConfigs map[string]cty.Value) map[common.ValidationTarget]common.Diagnostics {
+	checks := make(map[common.ValidationTarget]func(context.Context) common.Diagnostics, len(resourceConfigs)+len(dataConfigs))
+	for typeName, val := range resourceConfigs {
+		typeName, val := typeName, val
+		target := common.ValidationTarget{Kind: common.ManagedResourceConfigTarget, TypeName: typeName}
+		schema, ok := p.schema.ManagedResourceTypes[typeName]
+		if !ok {
+			checks[target] = func(context.Context) common.Diagnostics {
+				return common.UnknownResourceTypeDiagnostics(common.ManagedResourceMode, typeName)
+			}
+			continue
+		}
+		rt := p.newManagedResourceType(typeName, schema)
+		checks[target] = func(ctx context.Context) common.Diagnostics {
+			return rt.ValidateConfig(ctx, val)
+		}
+	}
+	for typeName, val := range dataConfigs {
+		typeName, val := typeName, val
+		target := common.ValidationTarget{Kind: common.DataResourceConfigTarget, TypeName: typeName}
+		schema, ok := p.schema.DataResourceTypes[typeName]
+		if !ok {
+			checks[target] = func(context.Context) common.Diagnostics {
+				return common.UnknownResourceTypeDiagnostics(common.DataResourceMode, typeName)
+			}
+			continue
+		}
+		rt := p.newDataResourceType(typeName, schema)
+		checks[target] = func(ctx context.Context) common.Diagnostics {
+			return rt.ValidateConfig(ctx, val)
+		}
+	}
+
+	// The provider configuration is validated first, and then all of the
+	// resource configurations together.
+	_, providerDiags := p.PrepareConfig(ctx, config)
+	ret := common.RunValidations(ctx, checks)
+	ret[common.ValidationTarget{Kind: common.ProviderConfigTarget}] = providerDiags
+	return ret
+}
+
+func (p *Provider) Kill() error {
+	return p.opts.KillProcess()
+}
+
+func (p *Provider) AllDiagnostics() common.Diagnostics {
+	return p.opts.DiagnosticLog.All()
 }
 
 func (p *Provider) Close() error {
-	return p.plugin.Close()
+	p.closed.Store(true)
+	var err error
+	if p.plugin != nil {
+		err = p.opts.ExitError(p.plugin.Close())
+	}
+	if closeErr := p.opts.Close(); err == nil {
+		err = closeErr
+	}
+	return err
 }
@@ -0,0 +1,324 @@
+package protocol5
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/apparentlymart/terraform-schema-go/tfschema"
+	"github.com/zclconf/go-cty/cty"
+
+	"github.com/apparentlymart/terraform-provider/internal/tfplugin5"
+	"github.com/apparentlymart/terraform-provider/tfprovider/internal/common"
+)
+
+// providerMetaSchemaResponse is a GetProviderSchema function returning
+// testSchemaResponse with the addition of a provider_meta schema with a
+// single optional string attribute "module_name".
+func providerMetaSchemaResponse(context.Context, *tfplugin5.GetProviderSchema_Request) (*tfplugin5.GetProviderSchema_Response, error) {
+	resp := testSchemaResponse()
+	resp.ProviderMeta = &tfplugin5.Schema{
+		Block: &tfplugin5.Schema_Block{
+			Attributes: []*tfplugin5.Schema_Attribute{
+				{Name: "module_name", Type: []byte(`"string"`), Optional: true},
+			},
+		},
+	}
+	return resp, nil
+}
+
+func TestManagedResourceTypePlanProviderMeta(t *testing.T) {
+	metaType := cty.Object(map[string]cty.Type{
+		"module_name": cty.String,
+	})
+	var gotMeta cty.Value
+	client := &fakeClient{
+		getSchema: providerMetaSchemaResponse,
+		planResourceChange: func(ctx context.Context, req *tfplugin5.PlanResourceChange_Request) (*tfplugin5.PlanResourceChange_Response, error) {
+			gotMeta = decodeTestDynamicValue(t, req.ProviderMeta, metaType)
+			return &tfplugin5.PlanResourceChange_Response{
+				PlannedState: req.ProposedNewState,
+			}, nil
+		},
+	}
+	p := configuredTestProvider(t, client, nil)
+
+	schema, _ := p.Schema(context.Background())
+	if schema.ProviderMeta == nil {
+		t.Fatal("schema has no provider_meta block")
+	}
+	if got := schema.ProviderMeta.ImpliedType(); !got.Equals(metaType) {
+		t.Fatalf("wrong provider_meta type %#v; want %#v", got, metaType)
+	}
+
+	rt, err := p.ManagedResourceType("test_thing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	meta := cty.ObjectVal(map[string]cty.Value{
+		"module_name": cty.StringVal("example"),
+	})
+	proposed := cty.ObjectVal(map[string]cty.Value{
+		"id":   cty.UnknownVal(cty.String),
+		"name": cty.StringVal("a"),
+	})
+	_, diags := rt.Plan(context.Background(), common.ManagedResourcePlanRequest{
+		PriorState:       cty.NullVal(testThingType),
+		ProposedNewState: proposed,
+		Config:           proposed,
+		ProviderMeta:     meta,
+	})
+	if diags.HasErrors() {
+		t.Fatalf("unexpected errors: %s", diags.Err())
+	}
+	if !gotMeta.RawEquals(meta) {
+		t.Errorf("provider received wrong provider_meta\ngot:  %#v\nwant: %#v", gotMeta, meta)
+	}
+}
+
+func TestNewProviderWithoutProviderMeta(t *testing.T) {
+	p := newTestProvider(t, &fakeClient{}, nil)
+	schema, _ := p.Schema(context.Background())
+	if schema.ProviderMeta != nil {
+		t.Errorf("provider_meta schema is %#v; want nil", schema.ProviderMeta)
+	}
+}
+
+func TestReadUndeclaredProviderMeta(t *testing.T) {
+	calls := 0
+	client := &fakeClient{
+		readResource: func(ctx context.Context, req *tfplugin5.ReadResource_Request) (*tfplugin5.ReadResource_Response, error) {
+			calls++
+			return &tfplugin5.ReadResource_Response{NewState: req.CurrentState}, nil
+		},
+		readDataSource: func(ctx context.Context, req *tfplugin5.ReadDataSource_Request) (*tfplugin5.ReadDataSource_Response, error) {
+			calls++
+			return &tfplugin5.ReadDataSource_Response{State: req.Config}, nil
+		},
+	}
+	p := configuredTestProvider(t, client, nil)
+	meta := cty.ObjectVal(map[string]cty.Value{
+		"module_name": cty.StringVal("example"),
+	})
+
+	managed, err := p.ManagedResourceType("test_thing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, diags := managed.Read(context.Background(), common.ManagedResourceReadRequest{
+		PreviousValue: testThingVal("a", "b"),
+		ProviderMeta:  meta,
+	})
+	checkUndeclaredProviderMetaDiags(t, diags)
+
+	data, err := p.DataResourceType("test_data")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, diags = data.Read(context.Background(), common.DataResourceReadRequest{
+		Config: cty.ObjectVal(map[string]cty.Value{
+			"name":  cty.StringVal("a"),
+			"value": cty.NullVal(cty.String),
+		}),
+		ProviderMeta: meta,
+	})
+	checkUndeclaredProviderMetaDiags(t, diags)
+
+	if calls != 0 {
+		t.Errorf("provider was called %d times; want 0", calls)
+	}
+}
+
+func checkUndeclaredProviderMetaDiags(t *testing.T, diags common.Diagnostics) {
+	t.Helper()
+	if !diags.HasErrors() {
+		t.Fatal("read succeeded; want an error")
+	}
+	if got, want := diags[len(diags)-1].Summary, "Unexpected provider_meta value"; got != want {
+		t.Errorf("wrong error summary %q; want %q", got, want)
+	}
+}
+
+func TestManagedResourceTypePlanNonConformingProviderMeta(t *testing.T) {
+	calls := 0
+	client := &fakeClient{
+		getSchema: providerMetaSchemaResponse,
+		planResourceChange: func(ctx context.Context, req *tfplugin5.PlanResourceChange_Request) (*tfplugin5.PlanResourceChange_Response, error) {
+			calls++
+			return &tfplugin5.PlanResourceChange_Response{PlannedState: req.ProposedNewState}, nil
+		},
+	}
+	p := configuredTestProvider(t, client, nil)
+	rt, err := p.ManagedResourceType("test_thing")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	proposed := cty.ObjectVal(map[string]cty.Value{
+		"id":   cty.UnknownVal(cty.String),
+		"name": cty.StringVal("a"),
+	})
+	_, diags := rt.Plan(context.Background(), common.ManagedResourcePlanRequest{
+		PriorState:       cty.NullVal(testThingType),
+		ProposedNewState: proposed,
+		Config:           proposed,
+		ProviderMeta: cty.ObjectVal(map[string]cty.Value{
+			"module_name": cty.ListValEmpty(cty.String),
+		}),
+	})
+	if len(diags) != 1 {
+		t.Fatalf("got %d diagnostics; want 1", len(diags))
+	}
+	diag := diags[0]
+	if got, want := diag.Summary, "Invalid provider_meta value"; got != want {
+		t.Errorf("wrong summary %q; want %q", got, want)
+	}
+	if got, want := diag.Attribute, cty.GetAttrPath("module_name"); !got.Equals(want) {
+		t.Errorf("wrong attribute %s; want %s", common.FormatPath(got), common.FormatPath(want))
+	}
+	if !strings.Contains(diag.Detail, "at module_name") {
+		t.Errorf("detail %q does not name the offending attribute", diag.Detail)
+	}
+	if calls != 0 {
+		t.Errorf("provider was called %d times; want 0", calls)
+	}
+}
+
+// countProviderMetaEncodes returns options with a value sanitizer that
+// counts the provider_meta values encoded, using the given cache.
+func countProviderMetaEncodes(cache *common.EncodeCache, count *int) *common.Options {
+	return &common.Options{
+		ProviderMetaCache: cache,
+		ValueSanitizer: func(val cty.Value, schema *tfschema.Block) cty.Value {
+			if _, ok := schema.Attributes["module_name"]; ok {
+				*count++
+			}
+			return val
+		},
+	}
+}
+
+func TestManagedResourceTypeApplyProviderMetaCache(t *testing.T) {
+	metaType := cty.Object(map[string]cty.Type{
+		"module_name": cty.String,
+	})
+	var gotMeta []cty.Value
+	client := &fakeClient{
+		getSchema: providerMetaSchemaResponse,
+		applyResourceChange: func(ctx context.Context, req *tfplugin5.ApplyResourceChange_Request) (*tfplugin5.ApplyResourceChange_Response, error) {
+			gotMeta = append(gotMeta, decodeTestDynamicValue(t, req.ProviderMeta, metaType))
+			return &tfplugin5.ApplyResourceChange_Response{NewState: req.PlannedState}, nil
+		},
+	}
+
+	metaVal := func(name string) cty.Value {
+		return cty.ObjectVal(map[string]cty.Value{
+			"module_name": cty.StringVal(name),
+		})
+	}
+	metas := []cty.Value{metaVal("a"), metaVal("a"), metaVal("a"), metaVal("b"), metaVal("b")}
+
+	for _, cached := range []bool{false, true} {
+		var cache *common.EncodeCache
+		if cached {
+			cache = common.NewEncodeCache()
+		}
+		encodes := 0
+		gotMeta = nil
+		p := configuredTestProvider(t, client, countProviderMetaEncodes(cache, &encodes))
+		rt, err := p.ManagedResourceType("test_thing")
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, meta := range metas {
+			_, diags := rt.Apply(context.Background(), common.ManagedResourceApplyRequest{
+				PriorState:   testThingVal("a", "before"),
+				PlannedState: testThingVal("a", "after"),
+				Config:       testThingVal("a", "after"),
+				ProviderMeta: meta,
+			})
+			if diags.HasErrors() {
+				t.Fatalf("unexpected errors: %s", diags.Err())
+			}
+		}
+
+		// Only a change of value causes it to be encoded again.
+		wantEncodes := len(metas)
+		if cached {
+			wantEncodes = 2
+		}
+		if encodes != wantEncodes {
+			t.Errorf("provider_meta encoded %d times with cache %t; want %d", encodes, cached, wantEncodes)
+		}
+		for i, got := range gotMeta {
+			if !got.RawEquals(metas[i]) {
+				t.Errorf("provider received wrong provider_meta %d with cache %t\ngot:  %#v\nwant: %#v", i, cached, got, metas[i])
+			}
+		}
+	}
+}
+
+func BenchmarkApplyLoopProviderMeta(b *testing.B) {
+	client := &fakeClient{
+		getSchema: providerMetaSchemaResponse,
+		configure: func(context.Context, *tfplugin5.Configure_Request) (*tfplugin5.Configure_Response, error) {
+			return &tfplugin5.Configure_Response{}, nil
+		},
+		applyResourceChange: func(ctx context.Context, req *tfplugin5.ApplyResourceChange_Request) (*tfplugin5.ApplyResourceChange_Response, error) {
+			return &tfplugin5.ApplyResourceChange_Response{NewState: req.PlannedState}, nil
+		},
+	}
+	meta := cty.ObjectVal(map[string]cty.Value{
+		"module_name": cty.StringVal("module.example"),
+	})
+
+	// Each iteration applies changes to many resources in the same module,
+	// and so with the same provider_meta value.
+	const resources = 100
+	reqs := make([]common.ManagedResourceApplyRequest, resources)
+	for i := range reqs {
+		id := fmt.Sprintf("thing-%d", i)
+		reqs[i] = common.ManagedResourceApplyRequest{
+			PriorState:   testThingVal(id, "before"),
+			PlannedState: testThingVal(id, "after"),
+			Config:       testThingVal(id, "after"),
+			ProviderMeta: meta,
+		}
+	}
+
+	run := func(b *testing.B, cache *common.EncodeCache) {
+		encodes := 0
+		p, err := NewProvider(context.Background(), nil, client, countProviderMetaEncodes(cache, &encodes))
+		if err != nil {
+			b.Fatal(err)
+		}
+		defer p.Close()
+		if diags := p.Configure(context.Background(), common.Config{Value: cty.NullVal(p.ProviderConfigType())}); diags.HasErrors() {
+			b.Fatal(diags.Err())
+		}
+		rt, err := p.ManagedResourceType("test_thing")
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		encodes = 0
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			for _, req := range reqs {
+				if _, diags := rt.Apply(context.Background(), req); diags.HasErrors() {
+					b.Fatal(diags.Err())
+				}
+			}
+		}
+		b.StopTimer()
+		b.ReportMetric(float64(encodes)/float64(b.N), "meta-encodes/op")
+	}
+	b.Run("cached", func(b *testing.B) {
+		run(b, common.NewEncodeCache())
+	})
+	b.Run("uncached", func(b *testing.B) {
+		run(b, nil)
+	})
+}
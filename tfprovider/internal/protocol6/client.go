@@ -0,0 +1,178 @@
+package protocol6
+
+import (
+	"context"
+
+	"github.com/golang/protobuf/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/apparentlymart/terraform-provider/internal/tfplugin6"
+	"github.com/apparentlymart/terraform-provider/tfprovider/internal/common"
+)
+
+// interceptedClient is a tfplugin6.ProviderClient that passes every call
+// through an interceptor before (optionally) delegating to another client.
+type interceptedClient struct {
+	next      tfplugin6.ProviderClient
+	intercept common.RPCInterceptor
+}
+
+// NewInterceptedClient wraps the given client so that each of its calls
+// passes through the given interceptor.
+//
+// If next is nil then there is no plugin behind the returned client, and so
+// the interceptor must answer every call itself without calling its invoker.
+func NewInterceptedClient(next tfplugin6.ProviderClient, intercept common.RPCInterceptor) tfplugin6.ProviderClient {
+	return &interceptedClient{
+		next:      next,
+		intercept: intercept,
+	}
+}
+
+func (c *interceptedClient) call(ctx context.Context, method string, req, resp proto.Message, next func(context.Context, proto.Message) (proto.Message, error)) error {
+	return c.intercept(ctx, method, req, resp, func(ctx context.Context, req, resp proto.Message) error {
+		if c.next == nil {
+			return status.Errorf(codes.Unavailable, "no provider plugin is available to handle %s", method)
+		}
+		result, err := next(ctx, req)
+		if err != nil {
+			return err
+		}
+		proto.Merge(resp, result)
+		return nil
+	})
+}
+
+func (c *interceptedClient) GetProviderSchema(ctx context.Context, in *tfplugin6.GetProviderSchema_Request, opts ...grpc.CallOption) (*tfplugin6.GetProviderSchema_Response, error) {
+	out := new(tfplugin6.GetProviderSchema_Response)
+	err := c.call(ctx, "GetProviderSchema", in, out, func(ctx context.Context, req proto.Message) (proto.Message, error) {
+		return c.next.GetProviderSchema(ctx, req.(*tfplugin6.GetProviderSchema_Request), opts...)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *interceptedClient) ValidateProviderConfig(ctx context.Context, in *tfplugin6.ValidateProviderConfig_Request, opts ...grpc.CallOption) (*tfplugin6.ValidateProviderConfig_Response, error) {
+	out := new(tfplugin6.ValidateProviderConfig_Response)
+	err := c.call(ctx, "ValidateProviderConfig", in, out, func(ctx context.Context, req proto.Message) (proto.Message, error) {
+		return c.next.ValidateProviderConfig(ctx, req.(*tfplugin6.ValidateProviderConfig_Request), opts...)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *interceptedClient) ValidateResourceConfig(ctx context.Context, in *tfplugin6.ValidateResourceConfig_Request, opts ...grpc.CallOption) (*tfplugin6.ValidateResourceConfig_Response, error) {
+	out := new(tfplugin6.ValidateResourceConfig_Response)
+	err := c.call(ctx, "ValidateResourceConfig", in, out, func(ctx context.Context, req proto.Message) (proto.Message, error) {
+		return c.next.ValidateResourceConfig(ctx, req.(*tfplugin6.ValidateResourceConfig_Request), opts...)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *interceptedClient) ValidateDataResourceConfig(ctx context.Context, in *tfplugin6.ValidateDataResourceConfig_Request, opts ...grpc.CallOption) (*tfplugin6.ValidateDataResourceConfig_Response, error) {
+	out := new(tfplugin6.ValidateDataResourceConfig_Response)
+	err := c.call(ctx, "ValidateDataResourceConfig", in, out, func(ctx context.Context, req proto.Message) (proto.Message, error) {
+		return c.next.ValidateDataResourceConfig(ctx, req.(*tfplugin6.ValidateDataResourceConfig_Request), opts...)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *interceptedClient) UpgradeResourceState(ctx context.Context, in *tfplugin6.UpgradeResourceState_Request, opts ...grpc.CallOption) (*tfplugin6.UpgradeResourceState_Response, error) {
+	out := new(tfplugin6.UpgradeResourceState_Response)
+	err := c.call(ctx, "UpgradeResourceState", in, out, func(ctx context.Context, req proto.Message) (proto.Message, error) {
+		return c.next.UpgradeResourceState(ctx, req.(*tfplugin6.UpgradeResourceState_Request), opts...)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *interceptedClient) ConfigureProvider(ctx context.Context, in *tfplugin6.ConfigureProvider_Request, opts ...grpc.CallOption) (*tfplugin6.ConfigureProvider_Response, error) {
+	out := new(tfplugin6.ConfigureProvider_Response)
+	err := c.call(ctx, "ConfigureProvider", in, out, func(ctx context.Context, req proto.Message) (proto.Message, error) {
+		return c.next.ConfigureProvider(ctx, req.(*tfplugin6.ConfigureProvider_Request), opts...)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *interceptedClient) ReadResource(ctx context.Context, in *tfplugin6.ReadResource_Request, opts ...grpc.CallOption) (*tfplugin6.ReadResource_Response, error) {
+	out := new(tfplugin6.ReadResource_Response)
+	err := c.call(ctx, "ReadResource", in, out, func(ctx context.Context, req proto.Message) (proto.Message, error) {
+		return c.next.ReadResource(ctx, req.(*tfplugin6.ReadResource_Request), opts...)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *interceptedClient) PlanResourceChange(ctx context.Context, in *tfplugin6.PlanResourceChange_Request, opts ...grpc.CallOption) (*tfplugin6.PlanResourceChange_Response, error) {
+	out := new(tfplugin6.PlanResourceChange_Response)
+	err := c.call(ctx, "PlanResourceChange", in, out, func(ctx context.Context, req proto.Message) (proto.Message, error) {
+		return c.next.PlanResourceChange(ctx, req.(*tfplugin6.PlanResourceChange_Request), opts...)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *interceptedClient) ApplyResourceChange(ctx context.Context, in *tfplugin6.ApplyResourceChange_Request, opts ...grpc.CallOption) (*tfplugin6.ApplyResourceChange_Response, error) {
+	out := new(tfplugin6.ApplyResourceChange_Response)
+	err := c.call(ctx, "ApplyResourceChange", in, out, func(ctx context.Context, req proto.Message) (proto.Message, error) {
+		return c.next.ApplyResourceChange(ctx, req.(*tfplugin6.ApplyResourceChange_Request), opts...)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *interceptedClient) ImportResourceState(ctx context.Context, in *tfplugin6.ImportResourceState_Request, opts ...grpc.CallOption) (*tfplugin6.ImportResourceState_Response, error) {
+	out := new(tfplugin6.ImportResourceState_Response)
+	err := c.call(ctx, "ImportResourceState", in, out, func(ctx context.Context, req proto.Message) (proto.Message, error) {
+		return c.next.ImportResourceState(ctx, req.(*tfplugin6.ImportResourceState_Request), opts...)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *interceptedClient) ReadDataSource(ctx context.Context, in *tfplugin6.ReadDataSource_Request, opts ...grpc.CallOption) (*tfplugin6.ReadDataSource_Response, error) {
+	out := new(tfplugin6.ReadDataSource_Response)
+	err := c.call(ctx, "ReadDataSource", in, out, func(ctx context.Context, req proto.Message) (proto.Message, error) {
+		return c.next.ReadDataSource(ctx, req.(*tfplugin6.ReadDataSource_Request), opts...)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *interceptedClient) StopProvider(ctx context.Context, in *tfplugin6.StopProvider_Request, opts ...grpc.CallOption) (*tfplugin6.StopProvider_Response, error) {
+	out := new(tfplugin6.StopProvider_Response)
+	err := c.call(ctx, "StopProvider", in, out, func(ctx context.Context, req proto.Message) (proto.Message, error) {
+		return c.next.StopProvider(ctx, req.(*tfplugin6.StopProvider_Request), opts...)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
@@ -2,6 +2,7 @@ package protocol6
 
 import (
 	"context"
+	"sync/atomic"
 
 	"github.com/apparentlymart/terraform-schema-go/tfschema"
 	"github.com/zclconf/go-cty/cty"
@@ -13,12 +14,22 @@ import (
 type DataResourceType struct {
 	client             tfplugin6.ProviderClient
 	typeName           string
+	opts               *common.Options
 	schema             *common.DataResourceTypeSchema
 	providerMetaSchema *tfschema.Block
+
+	// configState belongs to the provider that this resource type was
+	// obtained from, so we can see if it has been fully configured.
+	configState *atomic.Int32
 }
 
 func (rt *DataResourceType) ValidateConfig(ctx context.Context, config cty.Value) common.Diagnostics {
-	dv, diags := encodeDynamicValue(config, rt.schema.Content)
+	// Rules registered by the caller are checked locally first, since
+	// the provider knows nothing of them.
+	if diags := rt.opts.CheckConfigRules(common.DataResourceMode, rt.typeName, config); diags.HasErrors() {
+		return diags
+	}
+	dv, diags := encodeDynamicValue(rt.opts, rt.typeName, config, rt.schema)
 	if diags.HasErrors() {
 		return diags
 	}
@@ -30,27 +41,32 @@ func (rt *DataResourceType) ValidateConfig(ctx context.Context, config cty.Value
 	if err != nil {
 		return diags
 	}
-	diags = append(diags, decodeDiagnostics(resp.Diagnostics)...)
+	diags = append(diags, decodeDiagnostics(rt.opts, resp.Diagnostics)...)
 	return diags
 }
 
-func (rt *DataResourceType) Read(ctx context.Context, req common.DataResourceReadRequest) (common.DataResourceReadResponse, common.Diagnostics) {
+func (rt *DataResourceType) Read(ctx context.Context, req common.DataResourceReadRequest) (resp common.DataResourceReadResponse, diags common.Diagnostics) {
+	defer rt.opts.RecoverPanic("read", &diags)
+	return rt.read(ctx, req)
+}
+
+func (rt *DataResourceType) read(ctx context.Context, req common.DataResourceReadRequest) (common.DataResourceReadResponse, common.Diagnostics) {
+	if diags := requireConfigured(rt.configState); diags.HasErrors() {
+		return common.DataResourceReadResponse{}, diags
+	}
+
 	var diags common.Diagnostics
 
-	configDV, moreDiags := encodeDynamicValue(req.Config, rt.schema.Content)
+	configDV, moreDiags := encodeDynamicValue(rt.opts, rt.typeName, req.Config, rt.schema)
 	diags = append(diags, moreDiags...)
 	if moreDiags.HasErrors() {
 		return common.DataResourceReadResponse{}, diags
 	}
 
-	var providerMetaDV *tfplugin6.DynamicValue
-	if !req.ProviderMeta.IsNull() && rt.providerMetaSchema != nil {
-		var moreDiags common.Diagnostics
-		providerMetaDV, moreDiags = encodeDynamicValue(req.ProviderMeta, rt.providerMetaSchema)
-		diags = append(diags, moreDiags...)
-		if moreDiags.HasErrors() {
-			return common.DataResourceReadResponse{}, diags
-		}
+	providerMetaDV, moreDiags := encodeProviderMeta(rt.opts, req.ProviderMeta, rt.providerMetaSchema)
+	diags = append(diags, moreDiags...)
+	if moreDiags.HasErrors() {
+		return common.DataResourceReadResponse{}, diags
 	}
 
 	resp, err := rt.client.ReadDataSource(ctx, &tfplugin6.ReadDataSource_Request{
@@ -63,12 +79,12 @@ func (rt *DataResourceType) Read(ctx context.Context, req common.DataResourceRea
 		return common.DataResourceReadResponse{}, diags
 	}
 
-	diags = append(diags, decodeDiagnostics(resp.Diagnostics)...)
+	diags = append(diags, decodeDiagnostics(rt.opts, resp.Diagnostics)...)
 
 	result := common.DataResourceReadResponse{}
 
 	if resp.State != nil {
-		state, moreDiags := decodeDynamicValue(resp.State, rt.schema.Content)
+		state, moreDiags := decodeDynamicValue(rt.opts, resp.State, rt.schema)
 		diags = append(diags, moreDiags...)
 		result.State = state
 	}
@@ -78,4 +94,4 @@ func (rt *DataResourceType) Read(ctx context.Context, req common.DataResourceRea
 
 func (rt *DataResourceType) Sealed() common.Sealed {
 	return common.Sealed{}
-}
\ No newline at end of file
+}
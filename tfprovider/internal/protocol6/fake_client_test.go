@@ -0,0 +1,237 @@
+package protocol6
+
+import (
+	"context"
+	"testing"
+
+	"github.com/zclconf/go-cty/cty"
+	ctymsgpack "github.com/zclconf/go-cty/cty/msgpack"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/apparentlymart/terraform-provider/internal/tfplugin6"
+	"github.com/apparentlymart/terraform-provider/tfprovider/internal/common"
+)
+
+// fakeClient is a tfplugin6.ProviderClient that answers each call using the
+// function in the corresponding field, or with an Unimplemented error if
+// that field is nil.
+type fakeClient struct {
+	getProviderSchema          func(context.Context, *tfplugin6.GetProviderSchema_Request) (*tfplugin6.GetProviderSchema_Response, error)
+	validateProviderConfig     func(context.Context, *tfplugin6.ValidateProviderConfig_Request) (*tfplugin6.ValidateProviderConfig_Response, error)
+	validateResourceConfig     func(context.Context, *tfplugin6.ValidateResourceConfig_Request) (*tfplugin6.ValidateResourceConfig_Response, error)
+	validateDataResourceConfig func(context.Context, *tfplugin6.ValidateDataResourceConfig_Request) (*tfplugin6.ValidateDataResourceConfig_Response, error)
+	upgradeResourceState       func(context.Context, *tfplugin6.UpgradeResourceState_Request) (*tfplugin6.UpgradeResourceState_Response, error)
+	configureProvider          func(context.Context, *tfplugin6.ConfigureProvider_Request) (*tfplugin6.ConfigureProvider_Response, error)
+	readResource               func(context.Context, *tfplugin6.ReadResource_Request) (*tfplugin6.ReadResource_Response, error)
+	planResourceChange         func(context.Context, *tfplugin6.PlanResourceChange_Request) (*tfplugin6.PlanResourceChange_Response, error)
+	applyResourceChange        func(context.Context, *tfplugin6.ApplyResourceChange_Request) (*tfplugin6.ApplyResourceChange_Response, error)
+	importResourceState        func(context.Context, *tfplugin6.ImportResourceState_Request) (*tfplugin6.ImportResourceState_Response, error)
+	readDataSource             func(context.Context, *tfplugin6.ReadDataSource_Request) (*tfplugin6.ReadDataSource_Response, error)
+	stopProvider               func(context.Context, *tfplugin6.StopProvider_Request) (*tfplugin6.StopProvider_Response, error)
+}
+
+var _ tfplugin6.ProviderClient = (*fakeClient)(nil)
+
+func unimplemented(method string) error {
+	return status.Errorf(codes.Unimplemented, "fake provider does not implement %s", method)
+}
+
+func (c *fakeClient) GetProviderSchema(ctx context.Context, in *tfplugin6.GetProviderSchema_Request, opts ...grpc.CallOption) (*tfplugin6.GetProviderSchema_Response, error) {
+	if c.getProviderSchema == nil {
+		return nil, unimplemented("GetProviderSchema")
+	}
+	return c.getProviderSchema(ctx, in)
+}
+
+func (c *fakeClient) ValidateProviderConfig(ctx context.Context, in *tfplugin6.ValidateProviderConfig_Request, opts ...grpc.CallOption) (*tfplugin6.ValidateProviderConfig_Response, error) {
+	if c.validateProviderConfig == nil {
+		return nil, unimplemented("ValidateProviderConfig")
+	}
+	return c.validateProviderConfig(ctx, in)
+}
+
+func (c *fakeClient) ValidateResourceConfig(ctx context.Context, in *tfplugin6.ValidateResourceConfig_Request, opts ...grpc.CallOption) (*tfplugin6.ValidateResourceConfig_Response, error) {
+	if c.validateResourceConfig == nil {
+		return nil, unimplemented("ValidateResourceConfig")
+	}
+	return c.validateResourceConfig(ctx, in)
+}
+
+func (c *fakeClient) ValidateDataResourceConfig(ctx context.Context, in *tfplugin6.ValidateDataResourceConfig_Request, opts ...grpc.CallOption) (*tfplugin6.ValidateDataResourceConfig_Response, error) {
+	if c.validateDataResourceConfig == nil {
+		return nil, unimplemented("ValidateDataResourceConfig")
+	}
+	return c.validateDataResourceConfig(ctx, in)
+}
+
+func (c *fakeClient) UpgradeResourceState(ctx context.Context, in *tfplugin6.UpgradeResourceState_Request, opts ...grpc.CallOption) (*tfplugin6.UpgradeResourceState_Response, error) {
+	if c.upgradeResourceState == nil {
+		return nil, unimplemented("UpgradeResourceState")
+	}
+	return c.upgradeResourceState(ctx, in)
+}
+
+func (c *fakeClient) ConfigureProvider(ctx context.Context, in *tfplugin6.ConfigureProvider_Request, opts ...grpc.CallOption) (*tfplugin6.ConfigureProvider_Response, error) {
+	if c.configureProvider == nil {
+		return nil, unimplemented("ConfigureProvider")
+	}
+	return c.configureProvider(ctx, in)
+}
+
+func (c *fakeClient) ReadResource(ctx context.Context, in *tfplugin6.ReadResource_Request, opts ...grpc.CallOption) (*tfplugin6.ReadResource_Response, error) {
+	if c.readResource == nil {
+		return nil, unimplemented("ReadResource")
+	}
+	return c.readResource(ctx, in)
+}
+
+func (c *fakeClient) PlanResourceChange(ctx context.Context, in *tfplugin6.PlanResourceChange_Request, opts ...grpc.CallOption) (*tfplugin6.PlanResourceChange_Response, error) {
+	if c.planResourceChange == nil {
+		return nil, unimplemented("PlanResourceChange")
+	}
+	return c.planResourceChange(ctx, in)
+}
+
+func (c *fakeClient) ApplyResourceChange(ctx context.Context, in *tfplugin6.ApplyResourceChange_Request, opts ...grpc.CallOption) (*tfplugin6.ApplyResourceChange_Response, error) {
+	if c.applyResourceChange == nil {
+		return nil, unimplemented("ApplyResourceChange")
+	}
+	return c.applyResourceChange(ctx, in)
+}
+
+func (c *fakeClient) ImportResourceState(ctx context.Context, in *tfplugin6.ImportResourceState_Request, opts ...grpc.CallOption) (*tfplugin6.ImportResourceState_Response, error) {
+	if c.importResourceState == nil {
+		return nil, unimplemented("ImportResourceState")
+	}
+	return c.importResourceState(ctx, in)
+}
+
+func (c *fakeClient) ReadDataSource(ctx context.Context, in *tfplugin6.ReadDataSource_Request, opts ...grpc.CallOption) (*tfplugin6.ReadDataSource_Response, error) {
+	if c.readDataSource == nil {
+		return nil, unimplemented("ReadDataSource")
+	}
+	return c.readDataSource(ctx, in)
+}
+
+func (c *fakeClient) StopProvider(ctx context.Context, in *tfplugin6.StopProvider_Request, opts ...grpc.CallOption) (*tfplugin6.StopProvider_Response, error) {
+	if c.stopProvider == nil {
+		return nil, unimplemented("StopProvider")
+	}
+	return c.stopProvider(ctx, in)
+}
+
+// testThingType is the type of objects of the "test_thing" managed resource
+// type in testSchemaResponse.
+var testThingType = cty.Object(map[string]cty.Type{
+	"id":   cty.String,
+	"name": cty.String,
+})
+
+// testDataType is the type of objects of the "test_data" data resource type
+// in testSchemaResponse.
+var testDataType = cty.Object(map[string]cty.Type{
+	"name":  cty.String,
+	"value": cty.String,
+})
+
+// testSchemaResponse returns the schema of a small provider, with one
+// managed resource type "test_thing" at schema version 1 and one data
+// resource type "test_data".
+func testSchemaResponse() *tfplugin6.GetProviderSchema_Response {
+	return &tfplugin6.GetProviderSchema_Response{
+		Provider: &tfplugin6.Schema{
+			Block: &tfplugin6.Schema_Block{
+				Attributes: []*tfplugin6.Schema_Attribute{
+					{Name: "region", Type: []byte(`"string"`), Optional: true},
+					{Name: "token", Type: []byte(`"string"`), Optional: true, Sensitive: true},
+				},
+			},
+		},
+		ResourceSchemas: map[string]*tfplugin6.Schema{
+			"test_thing": {
+				Version: 1,
+				Block: &tfplugin6.Schema_Block{
+					Attributes: []*tfplugin6.Schema_Attribute{
+						{Name: "id", Type: []byte(`"string"`), Computed: true},
+						{Name: "name", Type: []byte(`"string"`), Optional: true},
+					},
+				},
+			},
+		},
+		DataSourceSchemas: map[string]*tfplugin6.Schema{
+			"test_data": {
+				Block: &tfplugin6.Schema_Block{
+					Attributes: []*tfplugin6.Schema_Attribute{
+						{Name: "name", Type: []byte(`"string"`), Required: true},
+						{Name: "value", Type: []byte(`"string"`), Computed: true},
+					},
+				},
+			},
+		},
+	}
+}
+
+// newTestProvider creates a provider over the given fake client, which
+// returns testSchemaResponse unless it already has its own schema. The
+// options may be nil.
+func newTestProvider(t *testing.T, client *fakeClient, opts *common.Options) *Provider {
+	t.Helper()
+	if client.getProviderSchema == nil {
+		client.getProviderSchema = func(context.Context, *tfplugin6.GetProviderSchema_Request) (*tfplugin6.GetProviderSchema_Response, error) {
+			return testSchemaResponse(), nil
+		}
+	}
+	p, err := NewProvider(context.Background(), nil, client, opts)
+	if err != nil {
+		t.Fatalf("failed to create provider: %s", err)
+	}
+	t.Cleanup(func() { p.Close() })
+	return p
+}
+
+// configuredTestProvider is like newTestProvider but also configures the
+// provider with a null region and token. The fake client accepts the
+// configuration unless it already has its own ConfigureProvider function.
+func configuredTestProvider(t *testing.T, client *fakeClient, opts *common.Options) *Provider {
+	t.Helper()
+	if client.configureProvider == nil {
+		client.configureProvider = func(context.Context, *tfplugin6.ConfigureProvider_Request) (*tfplugin6.ConfigureProvider_Response, error) {
+			return &tfplugin6.ConfigureProvider_Response{}, nil
+		}
+	}
+	p := newTestProvider(t, client, opts)
+	diags := p.Configure(context.Background(), common.Config{
+		Value: cty.NullVal(p.ProviderConfigType()),
+	})
+	if diags.HasErrors() {
+		t.Fatalf("failed to configure provider: %s", diags.Err())
+	}
+	return p
+}
+
+// testDynamicValue encodes the given value in msgpack format, as a provider
+// plugin would.
+func testDynamicValue(t *testing.T, val cty.Value) *tfplugin6.DynamicValue {
+	t.Helper()
+	raw, err := ctymsgpack.Marshal(val, val.Type())
+	if err != nil {
+		t.Fatalf("failed to encode %#v: %s", val, err)
+	}
+	return &tfplugin6.DynamicValue{Msgpack: raw}
+}
+
+// decodeTestDynamicValue decodes the given value, which must be in msgpack
+// format, as a provider plugin would.
+func decodeTestDynamicValue(t *testing.T, raw *tfplugin6.DynamicValue, ty cty.Type) cty.Value {
+	t.Helper()
+	if raw == nil {
+		t.Fatalf("no value given")
+	}
+	val, err := ctymsgpack.Unmarshal(raw.Msgpack, ty)
+	if err != nil {
+		t.Fatalf("failed to decode value: %s", err)
+	}
+	return val
+}
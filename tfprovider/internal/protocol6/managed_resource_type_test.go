@@ -0,0 +1,1124 @@
+package protocol6
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/apparentlymart/terraform-schema-go/tfschema"
+	"github.com/zclconf/go-cty/cty"
+
+	"github.com/apparentlymart/terraform-provider/internal/tfplugin6"
+	"github.com/apparentlymart/terraform-provider/tfprovider/internal/common"
+)
+
+// testThingVal returns a test_thing object with the given id and name.
+func testThingVal(id, name string) cty.Value {
+	return cty.ObjectVal(map[string]cty.Value{
+		"id":   cty.StringVal(id),
+		"name": cty.StringVal(name),
+	})
+}
+
+func TestManagedResourceTypeReadSchemaVersion(t *testing.T) {
+	reads := 0
+	client := &fakeClient{
+		readResource: func(ctx context.Context, req *tfplugin6.ReadResource_Request) (*tfplugin6.ReadResource_Response, error) {
+			reads++
+			return &tfplugin6.ReadResource_Response{NewState: req.CurrentState}, nil
+		},
+	}
+	p := configuredTestProvider(t, client, nil)
+	rt, err := p.ManagedResourceType("test_thing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := rt.SchemaVersion(), int64(1); got != want {
+		t.Errorf("wrong schema version %d; want %d", got, want)
+	}
+
+	tests := map[string]struct {
+		version  int64
+		wantErr  string
+		wantRead bool
+	}{
+		"current": {
+			version:  1,
+			wantRead: true,
+		},
+		"older": {
+			version: 0,
+			wantErr: "Upgrade the state using UpgradeResourceState",
+		},
+		"newer": {
+			version: 2,
+			wantErr: "A newer version of the provider is required",
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			reads = 0
+			version := test.version
+			_, diags := rt.Read(context.Background(), common.ManagedResourceReadRequest{
+				PreviousValue:         testThingVal("a", "b"),
+				PreviousSchemaVersion: &version,
+			})
+			if test.wantErr == "" {
+				if diags.HasErrors() {
+					t.Fatalf("unexpected errors: %s", diags.Err())
+				}
+			} else {
+				if !diags.HasErrors() {
+					t.Fatal("Read succeeded; want an error")
+				}
+				if got := diags.Err().Error(); !strings.Contains(got, test.wantErr) {
+					t.Errorf("wrong error %q; want it to contain %q", got, test.wantErr)
+				}
+			}
+			if got := reads > 0; got != test.wantRead {
+				t.Errorf("provider read the resource: %t; want %t", got, test.wantRead)
+			}
+		})
+	}
+}
+
+func TestManagedResourceTypePlanLegacyTypeSystem(t *testing.T) {
+	// A provider using the legacy SDK may omit attributes it doesn't set,
+	// which doesn't conform to the schema.
+	loose := cty.ObjectVal(map[string]cty.Value{
+		"id": cty.StringVal("abc"),
+	})
+	proposed := cty.ObjectVal(map[string]cty.Value{
+		"id":   cty.UnknownVal(cty.String),
+		"name": cty.NullVal(cty.String),
+	})
+
+	for _, legacy := range []bool{true, false} {
+		client := &fakeClient{
+			planResourceChange: func(ctx context.Context, req *tfplugin6.PlanResourceChange_Request) (*tfplugin6.PlanResourceChange_Response, error) {
+				return &tfplugin6.PlanResourceChange_Response{
+					PlannedState:     testDynamicValue(t, loose),
+					LegacyTypeSystem: legacy,
+				}, nil
+			},
+		}
+		p := configuredTestProvider(t, client, nil)
+		rt, err := p.ManagedResourceType("test_thing")
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, diags := rt.Plan(context.Background(), common.ManagedResourcePlanRequest{
+			PriorState:       cty.NullVal(testThingType),
+			ProposedNewState: proposed,
+			Config:           proposed,
+		})
+
+		if !legacy {
+			if !diags.HasErrors() {
+				t.Errorf("non-conforming plan accepted from a provider not using the legacy type system")
+			}
+			continue
+		}
+		if diags.HasErrors() {
+			t.Fatalf("unexpected errors: %s", diags.Err())
+		}
+		if len(diags) != 1 || diags[0].Severity != common.Warning || diags[0].Summary != "Provider returned inconsistent object" {
+			t.Errorf("wrong diagnostics %#v; want a single inconsistent object warning", diags)
+		}
+		want := cty.ObjectVal(map[string]cty.Value{
+			"id":   cty.StringVal("abc"),
+			"name": cty.NullVal(cty.String),
+		})
+		if !resp.PlannedState.RawEquals(want) {
+			t.Errorf("wrong planned state\ngot:  %#v\nwant: %#v", resp.PlannedState, want)
+		}
+		if !resp.LegacyTypeSystem {
+			t.Errorf("response doesn't report the legacy type system")
+		}
+	}
+}
+
+func TestManagedResourceTypeReadPrivateSchemaVersion(t *testing.T) {
+	for _, check := range []bool{true, false} {
+		var gotPrivate []byte
+		client := &fakeClient{
+			readResource: func(ctx context.Context, req *tfplugin6.ReadResource_Request) (*tfplugin6.ReadResource_Response, error) {
+				gotPrivate = req.Private
+				return &tfplugin6.ReadResource_Response{
+					NewState: req.CurrentState,
+					Private:  req.Private,
+				}, nil
+			},
+		}
+		p := configuredTestProvider(t, client, &common.Options{CheckPrivateSchemaVersion: check})
+		rt, err := p.ManagedResourceType("test_thing")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		oldVersion := int64(0)
+		resp, diags := rt.Read(context.Background(), common.ManagedResourceReadRequest{
+			PreviousValue:        testThingVal("a", "b"),
+			OpaquePrivate:        []byte(`{"v":0}`),
+			PrivateSchemaVersion: &oldVersion,
+		})
+		if diags.HasErrors() {
+			t.Fatalf("unexpected errors: %s", diags.Err())
+		}
+
+		// The check is only advisory, so the private data is still sent.
+		if string(gotPrivate) != `{"v":0}` {
+			t.Errorf("provider received wrong private data %q", gotPrivate)
+		}
+		if resp.PrivateSchemaVersion != 1 {
+			t.Errorf("wrong private schema version %d in response; want 1", resp.PrivateSchemaVersion)
+		}
+		if !check {
+			if len(diags) != 0 {
+				t.Errorf("unexpected diagnostics without the check: %#v", diags)
+			}
+			continue
+		}
+		if len(diags) != 1 || diags[0].Severity != common.Warning || diags[0].Summary != "Private data from different schema version" {
+			t.Errorf("wrong diagnostics %#v; want a single version mismatch warning", diags)
+		}
+	}
+}
+
+func TestManagedResourceTypeReadSourceTag(t *testing.T) {
+	client := &fakeClient{
+		readResource: func(ctx context.Context, req *tfplugin6.ReadResource_Request) (*tfplugin6.ReadResource_Response, error) {
+			return &tfplugin6.ReadResource_Response{
+				NewState: req.CurrentState,
+				Diagnostics: []*tfplugin6.Diagnostic{
+					{
+						Severity: tfplugin6.Diagnostic_WARNING,
+						Summary:  "Object is drifting",
+					},
+				},
+			}, nil
+		},
+	}
+
+	tests := map[string]string{
+		"untagged": "",
+		"tagged":   "registry.terraform.io/example/test 1.0.0",
+	}
+	for name, tag := range tests {
+		t.Run(name, func(t *testing.T) {
+			p := configuredTestProvider(t, client, &common.Options{SourceTag: tag})
+			rt, err := p.ManagedResourceType("test_thing")
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, diags := rt.Read(context.Background(), common.ManagedResourceReadRequest{
+				PreviousValue: testThingVal("a", "b"),
+			})
+			if len(diags) != 1 {
+				t.Fatalf("got %d diagnostics; want 1", len(diags))
+			}
+			if got := diags[0].Source; got != tag {
+				t.Errorf("wrong source %q; want %q", got, tag)
+			}
+		})
+	}
+}
+
+// recordingHooks is a common.Hooks that records each call in a shared log.
+type recordingHooks struct {
+	log *[]string
+
+	appliedState cty.Value
+	postDiags    common.Diagnostics
+}
+
+func (h *recordingHooks) PrePlan(ctx context.Context, typeName string, req common.ManagedResourcePlanRequest) {
+	*h.log = append(*h.log, "PrePlan "+typeName)
+}
+
+func (h *recordingHooks) PostPlan(ctx context.Context, typeName string, req common.ManagedResourcePlanRequest, resp common.ManagedResourcePlanResponse, diags common.Diagnostics) {
+	*h.log = append(*h.log, "PostPlan "+typeName)
+	h.postDiags = diags
+}
+
+func (h *recordingHooks) PreApply(ctx context.Context, typeName string, req common.ManagedResourceApplyRequest) {
+	*h.log = append(*h.log, "PreApply "+typeName)
+}
+
+func (h *recordingHooks) PostApply(ctx context.Context, typeName string, req common.ManagedResourceApplyRequest, resp common.ManagedResourceApplyResponse, diags common.Diagnostics) {
+	*h.log = append(*h.log, "PostApply "+typeName)
+	h.appliedState = resp.NewState
+	h.postDiags = diags
+}
+
+func TestManagedResourceTypeHooks(t *testing.T) {
+	var log []string
+	client := &fakeClient{
+		planResourceChange: func(ctx context.Context, req *tfplugin6.PlanResourceChange_Request) (*tfplugin6.PlanResourceChange_Response, error) {
+			log = append(log, "PlanResourceChange")
+			return &tfplugin6.PlanResourceChange_Response{PlannedState: req.ProposedNewState}, nil
+		},
+		applyResourceChange: func(ctx context.Context, req *tfplugin6.ApplyResourceChange_Request) (*tfplugin6.ApplyResourceChange_Response, error) {
+			log = append(log, "ApplyResourceChange")
+			return &tfplugin6.ApplyResourceChange_Response{
+				NewState: testDynamicValue(t, testThingVal("new-id", "a")),
+			}, nil
+		},
+	}
+	hooks := &recordingHooks{log: &log}
+	p := configuredTestProvider(t, client, &common.Options{Hooks: hooks})
+	rt, err := p.ManagedResourceType("test_thing")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	proposed := cty.ObjectVal(map[string]cty.Value{
+		"id":   cty.UnknownVal(cty.String),
+		"name": cty.StringVal("a"),
+	})
+	planResp, diags := rt.Plan(ctx, common.ManagedResourcePlanRequest{
+		PriorState:       cty.NullVal(testThingType),
+		ProposedNewState: proposed,
+		Config:           proposed,
+	})
+	if diags.HasErrors() {
+		t.Fatalf("unexpected errors from Plan: %s", diags.Err())
+	}
+	_, diags = rt.Apply(ctx, common.ManagedResourceApplyRequest{
+		PriorState:    cty.NullVal(testThingType),
+		PlannedState:  planResp.PlannedState,
+		Config:        proposed,
+		OpaquePrivate: planResp.OpaquePrivate,
+	})
+	if diags.HasErrors() {
+		t.Fatalf("unexpected errors from Apply: %s", diags.Err())
+	}
+
+	want := []string{
+		"PrePlan test_thing",
+		"PlanResourceChange",
+		"PostPlan test_thing",
+		"PreApply test_thing",
+		"ApplyResourceChange",
+		"PostApply test_thing",
+	}
+	if !reflect.DeepEqual(log, want) {
+		t.Errorf("wrong calls\ngot:  %q\nwant: %q", log, want)
+	}
+	if want := testThingVal("new-id", "a"); !hooks.appliedState.RawEquals(want) {
+		t.Errorf("PostApply got wrong new state %#v; want %#v", hooks.appliedState, want)
+	}
+}
+
+func TestManagedResourceTypePlanValidateRequest(t *testing.T) {
+	calls := 0
+	client := &fakeClient{
+		planResourceChange: func(ctx context.Context, req *tfplugin6.PlanResourceChange_Request) (*tfplugin6.PlanResourceChange_Response, error) {
+			calls++
+			return &tfplugin6.PlanResourceChange_Response{PlannedState: req.ProposedNewState}, nil
+		},
+	}
+	p := configuredTestProvider(t, client, &common.Options{ValidatePlanRequests: true})
+	rt, err := p.ManagedResourceType("test_thing")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The proposed new state doesn't include the configured name, which
+	// the provider would otherwise be left to report.
+	_, diags := rt.Plan(context.Background(), common.ManagedResourcePlanRequest{
+		PriorState: cty.NullVal(testThingType),
+		ProposedNewState: cty.ObjectVal(map[string]cty.Value{
+			"id":   cty.UnknownVal(cty.String),
+			"name": cty.NullVal(cty.String),
+		}),
+		Config: cty.ObjectVal(map[string]cty.Value{
+			"id":   cty.NullVal(cty.String),
+			"name": cty.StringVal("a"),
+		}),
+	})
+	if !diags.HasErrors() {
+		t.Fatal("Plan succeeded; want an error")
+	}
+	if got, want := diags[0].Summary, "Invalid plan request"; got != want {
+		t.Errorf("wrong error summary %q; want %q", got, want)
+	}
+	if calls != 0 {
+		t.Errorf("provider was called %d times; want 0", calls)
+	}
+}
+
+func TestManagedResourceTypeLifecycle(t *testing.T) {
+	ctx := context.Background()
+	checkTypeName := func(got string) {
+		t.Helper()
+		if got != "test_thing" {
+			t.Errorf("wrong type name %q", got)
+		}
+	}
+	client := &fakeClient{
+		validateResourceConfig: func(ctx context.Context, req *tfplugin6.ValidateResourceConfig_Request) (*tfplugin6.ValidateResourceConfig_Response, error) {
+			checkTypeName(req.TypeName)
+			config := decodeTestDynamicValue(t, req.Config, testThingType)
+			if !config.GetAttr("name").IsNull() {
+				return &tfplugin6.ValidateResourceConfig_Response{}, nil
+			}
+			return &tfplugin6.ValidateResourceConfig_Response{
+				Diagnostics: []*tfplugin6.Diagnostic{
+					{Severity: tfplugin6.Diagnostic_ERROR, Summary: "Missing name"},
+				},
+			}, nil
+		},
+		planResourceChange: func(ctx context.Context, req *tfplugin6.PlanResourceChange_Request) (*tfplugin6.PlanResourceChange_Response, error) {
+			checkTypeName(req.TypeName)
+			proposed := decodeTestDynamicValue(t, req.ProposedNewState, testThingType)
+			planned := cty.ObjectVal(map[string]cty.Value{
+				"id":   cty.UnknownVal(cty.String),
+				"name": proposed.GetAttr("name"),
+			})
+			return &tfplugin6.PlanResourceChange_Response{
+				PlannedState:   testDynamicValue(t, planned),
+				PlannedPrivate: []byte("planned"),
+			}, nil
+		},
+		applyResourceChange: func(ctx context.Context, req *tfplugin6.ApplyResourceChange_Request) (*tfplugin6.ApplyResourceChange_Response, error) {
+			checkTypeName(req.TypeName)
+			if got, want := string(req.PlannedPrivate), "planned"; got != want {
+				t.Errorf("wrong planned private data %q; want %q", got, want)
+			}
+			planned := decodeTestDynamicValue(t, req.PlannedState, testThingType)
+			return &tfplugin6.ApplyResourceChange_Response{
+				NewState: testDynamicValue(t, testThingVal("thing-1", planned.GetAttr("name").AsString())),
+				Private:  []byte("applied"),
+			}, nil
+		},
+		readResource: func(ctx context.Context, req *tfplugin6.ReadResource_Request) (*tfplugin6.ReadResource_Response, error) {
+			checkTypeName(req.TypeName)
+			if got, want := string(req.Private), "applied"; got != want {
+				t.Errorf("wrong private data %q; want %q", got, want)
+			}
+			return &tfplugin6.ReadResource_Response{
+				NewState: req.CurrentState,
+				Private:  req.Private,
+			}, nil
+		},
+		importResourceState: func(ctx context.Context, req *tfplugin6.ImportResourceState_Request) (*tfplugin6.ImportResourceState_Response, error) {
+			checkTypeName(req.TypeName)
+			return &tfplugin6.ImportResourceState_Response{
+				ImportedResources: []*tfplugin6.ImportResourceState_ImportedResource{
+					{
+						TypeName: "test_thing",
+						State:    testDynamicValue(t, testThingVal(req.Id, "imported")),
+						Private:  []byte("imported"),
+					},
+				},
+			}, nil
+		},
+	}
+	p := configuredTestProvider(t, client, nil)
+	rt, err := p.ManagedResourceType("test_thing")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config := cty.ObjectVal(map[string]cty.Value{
+		"id":   cty.NullVal(cty.String),
+		"name": cty.StringVal("example"),
+	})
+	if diags := rt.ValidateConfig(ctx, config); len(diags) != 0 {
+		t.Fatalf("unexpected diagnostics from ValidateConfig: %s", diags.Err())
+	}
+	diags := rt.ValidateConfig(ctx, cty.ObjectVal(map[string]cty.Value{
+		"id":   cty.NullVal(cty.String),
+		"name": cty.NullVal(cty.String),
+	}))
+	if len(diags) != 1 || diags[0].Summary != "Missing name" {
+		t.Errorf("wrong diagnostics from ValidateConfig %#v", diags)
+	}
+
+	planResp, diags := rt.Plan(ctx, common.ManagedResourcePlanRequest{
+		PriorState:       cty.NullVal(testThingType),
+		ProposedNewState: config,
+		Config:           config,
+	})
+	if diags.HasErrors() {
+		t.Fatalf("unexpected errors from Plan: %s", diags.Err())
+	}
+	wantPlanned := cty.ObjectVal(map[string]cty.Value{
+		"id":   cty.UnknownVal(cty.String),
+		"name": cty.StringVal("example"),
+	})
+	if !planResp.PlannedState.RawEquals(wantPlanned) {
+		t.Errorf("wrong planned state %#v; want %#v", planResp.PlannedState, wantPlanned)
+	}
+
+	applyResp, diags := rt.Apply(ctx, common.ManagedResourceApplyRequest{
+		PriorState:    cty.NullVal(testThingType),
+		PlannedState:  planResp.PlannedState,
+		Config:        config,
+		OpaquePrivate: planResp.OpaquePrivate,
+	})
+	if diags.HasErrors() {
+		t.Fatalf("unexpected errors from Apply: %s", diags.Err())
+	}
+	want := testThingVal("thing-1", "example")
+	if !applyResp.NewState.RawEquals(want) {
+		t.Errorf("wrong new state %#v; want %#v", applyResp.NewState, want)
+	}
+
+	readResp, diags := rt.Read(ctx, common.ManagedResourceReadRequest{
+		PreviousValue: applyResp.NewState,
+		OpaquePrivate: applyResp.OpaquePrivate,
+	})
+	if diags.HasErrors() {
+		t.Fatalf("unexpected errors from Read: %s", diags.Err())
+	}
+	if !readResp.RefreshedValue.RawEquals(want) {
+		t.Errorf("wrong refreshed state %#v; want %#v", readResp.RefreshedValue, want)
+	}
+
+	importResp, diags := rt.Import(ctx, common.ManagedResourceImportRequest{ID: "thing-2"})
+	if diags.HasErrors() {
+		t.Fatalf("unexpected errors from Import: %s", diags.Err())
+	}
+	if len(importResp.ImportedResources) != 1 {
+		t.Fatalf("imported %d resources; want 1", len(importResp.ImportedResources))
+	}
+	imported := importResp.ImportedResources[0]
+	if want := testThingVal("thing-2", "imported"); !imported.State.RawEquals(want) {
+		t.Errorf("wrong imported state %#v; want %#v", imported.State, want)
+	}
+	if got, want := string(imported.OpaquePrivate), "imported"; got != want {
+		t.Errorf("wrong imported private data %q; want %q", got, want)
+	}
+}
+
+func TestManagedResourceTypeDiagnosticContext(t *testing.T) {
+	client := &fakeClient{
+		applyResourceChange: func(ctx context.Context, req *tfplugin6.ApplyResourceChange_Request) (*tfplugin6.ApplyResourceChange_Response, error) {
+			return &tfplugin6.ApplyResourceChange_Response{
+				Diagnostics: []*tfplugin6.Diagnostic{
+					{Severity: tfplugin6.Diagnostic_ERROR, Summary: "Quota exceeded", Detail: "Too many things."},
+				},
+			}, nil
+		},
+	}
+	req := common.ManagedResourceApplyRequest{
+		PriorState:   cty.NullVal(testThingType),
+		PlannedState: testThingVal("a", "example"),
+		Config:       cty.NullVal(testThingType),
+		InstanceKey:  "test_thing.example[0]",
+	}
+
+	for _, enabled := range []bool{false, true} {
+		p := configuredTestProvider(t, client, &common.Options{ResourceDiagnosticContext: enabled})
+		rt, err := p.ManagedResourceType("test_thing")
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, diags := rt.Apply(context.Background(), req)
+		if len(diags) != 1 {
+			t.Fatalf("got %d diagnostics; want 1", len(diags))
+		}
+		diag := diags[0]
+		// The provider's own message is left intact either way.
+		if diag.Summary != "Quota exceeded" || diag.Detail != "Too many things." {
+			t.Errorf("wrong diagnostic %#v", diag)
+		}
+		switch {
+		case !enabled && diag.Resource != nil:
+			t.Errorf("diagnostic has resource %#v with context disabled", diag.Resource)
+		case enabled:
+			want := &common.DiagnosticResource{
+				TypeName:    "test_thing",
+				InstanceKey: "test_thing.example[0]",
+			}
+			if !reflect.DeepEqual(diag.Resource, want) {
+				t.Errorf("wrong resource %#v; want %#v", diag.Resource, want)
+			}
+		}
+	}
+}
+
+func TestManagedResourceTypePlannedPrivateCheck(t *testing.T) {
+	applies := 0
+	client := &fakeClient{
+		planResourceChange: func(ctx context.Context, req *tfplugin6.PlanResourceChange_Request) (*tfplugin6.PlanResourceChange_Response, error) {
+			return &tfplugin6.PlanResourceChange_Response{
+				PlannedState:   req.ProposedNewState,
+				PlannedPrivate: []byte("planned"),
+			}, nil
+		},
+		applyResourceChange: func(ctx context.Context, req *tfplugin6.ApplyResourceChange_Request) (*tfplugin6.ApplyResourceChange_Response, error) {
+			applies++
+			return &tfplugin6.ApplyResourceChange_Response{NewState: req.PlannedState}, nil
+		},
+	}
+	p := configuredTestProvider(t, client, &common.Options{
+		PlannedPrivate: common.NewPlannedPrivateTracker(),
+	})
+	rt, err := p.ManagedResourceType("test_thing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	val := testThingVal("a", "example")
+
+	for _, private := range []string{"mutated", "planned"} {
+		planResp, diags := rt.Plan(ctx, common.ManagedResourcePlanRequest{
+			PriorState:       cty.NullVal(testThingType),
+			ProposedNewState: val,
+			Config:           val,
+			InstanceKey:      "test_thing.a",
+		})
+		if diags.HasErrors() {
+			t.Fatalf("unexpected errors from Plan: %s", diags.Err())
+		}
+		if got, want := string(planResp.OpaquePrivate), "planned"; got != want {
+			t.Fatalf("wrong planned private data %q; want %q", got, want)
+		}
+
+		_, diags = rt.Apply(ctx, common.ManagedResourceApplyRequest{
+			PriorState:    cty.NullVal(testThingType),
+			PlannedState:  planResp.PlannedState,
+			Config:        val,
+			OpaquePrivate: []byte(private),
+			InstanceKey:   "test_thing.a",
+		})
+		if private == "planned" {
+			if diags.HasErrors() {
+				t.Errorf("unexpected errors from Apply with unchanged private data: %s", diags.Err())
+			}
+			continue
+		}
+		if len(diags) != 1 || diags[0].Summary != "Planned private data changed" {
+			t.Errorf("wrong diagnostics from Apply with changed private data: %#v", diags)
+		}
+		if applies != 0 {
+			t.Errorf("provider was asked to apply a change with changed private data")
+		}
+	}
+	if applies != 1 {
+		t.Errorf("provider applied %d changes; want 1", applies)
+	}
+}
+
+func TestManagedResourceTypeApplyCarriesPrivate(t *testing.T) {
+	var private []byte
+	client := &fakeClient{
+		applyResourceChange: func(ctx context.Context, req *tfplugin6.ApplyResourceChange_Request) (*tfplugin6.ApplyResourceChange_Response, error) {
+			return &tfplugin6.ApplyResourceChange_Response{
+				NewState: req.PlannedState,
+				Private:  private,
+			}, nil
+		},
+	}
+	p := configuredTestProvider(t, client, nil)
+	rt, err := p.ManagedResourceType("test_thing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := common.ManagedResourceApplyRequest{
+		PriorState:    testThingVal("a", "before"),
+		PlannedState:  testThingVal("a", "after"),
+		Config:        testThingVal("a", "after"),
+		OpaquePrivate: []byte("planned"),
+	}
+
+	tests := map[string]struct {
+		private []byte
+		want    string
+	}{
+		// Some providers return no private data when it is unaffected.
+		"omitted":  {nil, "planned"},
+		"replaced": {[]byte("applied"), "applied"},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			private = test.private
+			resp, diags := rt.Apply(context.Background(), req)
+			if diags.HasErrors() {
+				t.Fatalf("unexpected errors: %s", diags.Err())
+			}
+			if got := string(resp.OpaquePrivate); got != test.want {
+				t.Errorf("wrong private data %q; want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestManagedResourceTypeValidateConfigRules(t *testing.T) {
+	validations := 0
+	client := &fakeClient{
+		validateResourceConfig: func(ctx context.Context, req *tfplugin6.ValidateResourceConfig_Request) (*tfplugin6.ValidateResourceConfig_Response, error) {
+			validations++
+			return &tfplugin6.ValidateResourceConfig_Response{}, nil
+		},
+	}
+	opts := &common.Options{}
+	opts.AddConfigRules(common.ManagedResourceMode, "test_thing", common.RequiredTogether("id", "name"))
+	p := configuredTestProvider(t, client, opts)
+	rt, err := p.ManagedResourceType("test_thing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	// A configuration that breaks a rule is rejected without asking the
+	// provider.
+	diags := rt.ValidateConfig(ctx, cty.ObjectVal(map[string]cty.Value{
+		"id":   cty.NullVal(cty.String),
+		"name": cty.StringVal("example"),
+	}))
+	if len(diags) != 1 || diags[0].Summary != "Missing required argument" {
+		t.Fatalf("wrong diagnostics %#v", diags)
+	}
+	if got, want := common.FormatPath(diags[0].Attribute), "id"; got != want {
+		t.Errorf("wrong path %s; want %s", got, want)
+	}
+	if validations != 0 {
+		t.Errorf("provider validated a configuration that breaks a rule")
+	}
+
+	// A configuration that satisfies the rules is still validated by the
+	// provider.
+	if diags := rt.ValidateConfig(ctx, testThingVal("a", "example")); diags.HasErrors() {
+		t.Fatalf("unexpected errors: %s", diags.Err())
+	}
+	if validations != 1 {
+		t.Errorf("provider validated the configuration %d times; want 1", validations)
+	}
+}
+
+func TestManagedResourceTypePlanValueSanitizer(t *testing.T) {
+	var gotConfig, gotProposed cty.Value
+	client := &fakeClient{
+		planResourceChange: func(ctx context.Context, req *tfplugin6.PlanResourceChange_Request) (*tfplugin6.PlanResourceChange_Response, error) {
+			gotConfig = decodeTestDynamicValue(t, req.Config, testThingType)
+			gotProposed = decodeTestDynamicValue(t, req.ProposedNewState, testThingType)
+			return &tfplugin6.PlanResourceChange_Response{PlannedState: req.ProposedNewState}, nil
+		},
+	}
+	var schemas []*tfschema.Block
+	opts := &common.Options{
+		ValueSanitizer: func(val cty.Value, schema *tfschema.Block) cty.Value {
+			schemas = append(schemas, schema)
+			val, _ = cty.Transform(val, func(path cty.Path, v cty.Value) (cty.Value, error) {
+				if v.Type() == cty.String && v.IsKnown() && !v.IsNull() && v.AsString() == "" {
+					return cty.NullVal(cty.String), nil
+				}
+				return v, nil
+			})
+			return val
+		},
+	}
+	p := configuredTestProvider(t, client, opts)
+	rt, err := p.ManagedResourceType("test_thing")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	schemas = nil
+	_, diags := rt.Plan(context.Background(), common.ManagedResourcePlanRequest{
+		PriorState: cty.NullVal(testThingType),
+		ProposedNewState: cty.ObjectVal(map[string]cty.Value{
+			"id":   cty.UnknownVal(cty.String),
+			"name": cty.StringVal(""),
+		}),
+		Config: cty.ObjectVal(map[string]cty.Value{
+			"id":   cty.NullVal(cty.String),
+			"name": cty.StringVal(""),
+		}),
+	})
+	if diags.HasErrors() {
+		t.Fatalf("unexpected errors: %s", diags.Err())
+	}
+
+	want := cty.ObjectVal(map[string]cty.Value{
+		"id":   cty.NullVal(cty.String),
+		"name": cty.NullVal(cty.String),
+	})
+	if !gotConfig.RawEquals(want) {
+		t.Errorf("wrong config sent\ngot:  %#v\nwant: %#v", gotConfig, want)
+	}
+	if got := gotProposed.GetAttr("name"); !got.IsNull() {
+		t.Errorf("wrong proposed name sent %#v; want null", got)
+	}
+	if len(schemas) == 0 {
+		t.Fatal("sanitizer was not called")
+	}
+	for _, schema := range schemas {
+		if _, ok := schema.Attributes["name"]; !ok {
+			t.Errorf("sanitizer called with wrong schema %#v", schema)
+		}
+	}
+}
+
+func TestManagedResourceTypeImportStop(t *testing.T) {
+	started := make(chan struct{})
+	stopped := make(chan struct{})
+	client := &fakeClient{
+		importResourceState: func(ctx context.Context, req *tfplugin6.ImportResourceState_Request) (*tfplugin6.ImportResourceState_Response, error) {
+			close(started)
+			// A slow import runs until the provider is asked to stop, and
+			// doesn't notice the cancellation of the caller's context.
+			<-stopped
+			return &tfplugin6.ImportResourceState_Response{
+				Diagnostics: []*tfplugin6.Diagnostic{
+					{Severity: tfplugin6.Diagnostic_ERROR, Summary: "Import stopped"},
+				},
+			}, nil
+		},
+		stopProvider: func(ctx context.Context, req *tfplugin6.StopProvider_Request) (*tfplugin6.StopProvider_Response, error) {
+			close(stopped)
+			return &tfplugin6.StopProvider_Response{}, nil
+		},
+	}
+	p := configuredTestProvider(t, client, nil)
+	rt, err := p.ManagedResourceType("test_thing")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-started
+		cancel()
+	}()
+	_, diags := rt.Import(ctx, common.ManagedResourceImportRequest{ID: "slow"})
+
+	select {
+	case <-stopped:
+	default:
+		t.Fatal("provider was not asked to stop")
+	}
+	if len(diags) != 1 || diags[0].Summary != "Import stopped" {
+		t.Errorf("wrong diagnostics %#v", diags)
+	}
+}
+
+func TestManagedResourceTypeHooksRecoverPanic(t *testing.T) {
+	var log []string
+	client := &fakeClient{
+		planResourceChange: func(ctx context.Context, req *tfplugin6.PlanResourceChange_Request) (*tfplugin6.PlanResourceChange_Response, error) {
+			panic("plan failed")
+		},
+		applyResourceChange: func(ctx context.Context, req *tfplugin6.ApplyResourceChange_Request) (*tfplugin6.ApplyResourceChange_Response, error) {
+			panic("apply failed")
+		},
+	}
+	hooks := &recordingHooks{log: &log}
+	p := configuredTestProvider(t, client, &common.Options{Hooks: hooks})
+	rt, err := p.ManagedResourceType("test_thing")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	proposed := cty.ObjectVal(map[string]cty.Value{
+		"id":   cty.UnknownVal(cty.String),
+		"name": cty.StringVal("a"),
+	})
+	_, diags := rt.Plan(ctx, common.ManagedResourcePlanRequest{
+		PriorState:       cty.NullVal(testThingType),
+		ProposedNewState: proposed,
+		Config:           proposed,
+	})
+	if len(diags) != 1 || diags[0].Summary != "Internal error" {
+		t.Fatalf("wrong diagnostics from Plan %#v", diags)
+	}
+	if len(hooks.postDiags) != 1 || hooks.postDiags[0].Summary != "Internal error" {
+		t.Errorf("wrong diagnostics given to PostPlan %#v", hooks.postDiags)
+	}
+
+	_, diags = rt.Apply(ctx, common.ManagedResourceApplyRequest{
+		PriorState:   cty.NullVal(testThingType),
+		PlannedState: proposed,
+		Config:       proposed,
+	})
+	if len(diags) != 1 || diags[0].Summary != "Internal error" {
+		t.Fatalf("wrong diagnostics from Apply %#v", diags)
+	}
+	if len(hooks.postDiags) != 1 || hooks.postDiags[0].Summary != "Internal error" {
+		t.Errorf("wrong diagnostics given to PostApply %#v", hooks.postDiags)
+	}
+
+	want := []string{
+		"PrePlan test_thing",
+		"PostPlan test_thing",
+		"PreApply test_thing",
+		"PostApply test_thing",
+	}
+	if !reflect.DeepEqual(log, want) {
+		t.Errorf("wrong hook calls\ngot:  %q\nwant: %q", log, want)
+	}
+}
+
+func TestManagedResourceTypeReadRecoverPanic(t *testing.T) {
+	client := &fakeClient{
+		getProviderSchema: func(context.Context, *tfplugin6.GetProviderSchema_Request) (*tfplugin6.GetProviderSchema_Response, error) {
+			resp := testSchemaResponse()
+			resp.ResourceSchemas["test_measure"] = &tfplugin6.Schema{
+				Block: &tfplugin6.Schema_Block{
+					Attributes: []*tfplugin6.Schema_Attribute{
+						{Name: "size", Type: []byte(`"number"`), Computed: true},
+					},
+				},
+			}
+			return resp, nil
+		},
+		readResource: func(ctx context.Context, req *tfplugin6.ReadResource_Request) (*tfplugin6.ReadResource_Response, error) {
+			// A misbehaving provider returns NaN, which cty cannot
+			// represent and so panics while decoding.
+			nan := []byte{0x81, 0xa4, 's', 'i', 'z', 'e', 0xcb, 0x7f, 0xf8, 0, 0, 0, 0, 0, 0}
+			return &tfplugin6.ReadResource_Response{
+				NewState: &tfplugin6.DynamicValue{Msgpack: nan},
+			}, nil
+		},
+	}
+	req := common.ManagedResourceReadRequest{
+		PreviousValue: cty.ObjectVal(map[string]cty.Value{
+			"size": cty.NumberIntVal(1),
+		}),
+	}
+
+	for _, disabled := range []bool{false, true} {
+		p := configuredTestProvider(t, client, &common.Options{DisablePanicRecovery: disabled})
+		rt, err := p.ManagedResourceType("test_measure")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var diags common.Diagnostics
+		panicked := func() (panicked bool) {
+			defer func() {
+				panicked = recover() != nil
+			}()
+			_, diags = rt.Read(context.Background(), req)
+			return false
+		}()
+		if panicked != disabled {
+			t.Errorf("Read panicked = %t with recovery disabled %t", panicked, disabled)
+		}
+		if disabled {
+			continue
+		}
+		if len(diags) != 1 || diags[0].Summary != "Internal error" {
+			t.Fatalf("wrong diagnostics %#v", diags)
+		}
+		if !strings.Contains(diags[0].Detail, "during the read operation") || !strings.Contains(diags[0].Detail, "NaN") {
+			t.Errorf("wrong detail %q", diags[0].Detail)
+		}
+	}
+}
+
+func TestManagedResourceTypeImportRetry(t *testing.T) {
+	var calls int
+	var failures []string
+	client := &fakeClient{
+		importResourceState: func(ctx context.Context, req *tfplugin6.ImportResourceState_Request) (*tfplugin6.ImportResourceState_Response, error) {
+			calls++
+			if calls <= len(failures) {
+				return &tfplugin6.ImportResourceState_Response{
+					Diagnostics: []*tfplugin6.Diagnostic{
+						{Severity: tfplugin6.Diagnostic_ERROR, Summary: failures[calls-1]},
+					},
+				}, nil
+			}
+			return &tfplugin6.ImportResourceState_Response{
+				ImportedResources: []*tfplugin6.ImportResourceState_ImportedResource{
+					{
+						TypeName: "test_thing",
+						State:    testDynamicValue(t, testThingVal(req.Id, "imported")),
+					},
+				},
+			}, nil
+		},
+	}
+	opts := &common.Options{
+		ImportRetry: &common.RetryPolicy{
+			MaxAttempts: 5,
+			Delay:       time.Millisecond,
+		},
+		ImportRetryable: func(diag common.Diagnostic) bool {
+			return diag.Summary == "Cannot import non-existent remote object"
+		},
+	}
+
+	tests := map[string]struct {
+		opts        *common.Options
+		failures    []string
+		wantCalls   int
+		wantSummary string
+	}{
+		"retryable failures": {
+			opts:      opts,
+			failures:  []string{"Cannot import non-existent remote object", "Cannot import non-existent remote object"},
+			wantCalls: 3,
+		},
+		"other failure": {
+			opts:        opts,
+			failures:    []string{"Access denied"},
+			wantCalls:   1,
+			wantSummary: "Access denied",
+		},
+		"attempts exhausted": {
+			opts:        opts,
+			failures:    []string{"Cannot import non-existent remote object", "Cannot import non-existent remote object", "Cannot import non-existent remote object", "Cannot import non-existent remote object", "Cannot import non-existent remote object"},
+			wantCalls:   5,
+			wantSummary: "Cannot import non-existent remote object",
+		},
+		"not enabled": {
+			failures:    []string{"Cannot import non-existent remote object"},
+			wantCalls:   1,
+			wantSummary: "Cannot import non-existent remote object",
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			p := configuredTestProvider(t, client, test.opts)
+			rt, err := p.ManagedResourceType("test_thing")
+			if err != nil {
+				t.Fatal(err)
+			}
+			calls, failures = 0, test.failures
+
+			resp, diags := rt.Import(context.Background(), common.ManagedResourceImportRequest{ID: "i-123"})
+			if calls != test.wantCalls {
+				t.Errorf("provider called %d times; want %d", calls, test.wantCalls)
+			}
+			if test.wantSummary != "" {
+				if len(diags) != 1 || diags[0].Summary != test.wantSummary {
+					t.Errorf("wrong diagnostics %#v; want %q", diags, test.wantSummary)
+				}
+				return
+			}
+			if diags.HasErrors() {
+				t.Fatalf("unexpected errors: %s", diags.Err())
+			}
+			if len(resp.ImportedResources) != 1 {
+				t.Fatalf("imported %d objects; want 1", len(resp.ImportedResources))
+			}
+		})
+	}
+}
+
+func TestManagedResourceTypePlanCreateNull(t *testing.T) {
+	client := &fakeClient{
+		planResourceChange: func(ctx context.Context, req *tfplugin6.PlanResourceChange_Request) (*tfplugin6.PlanResourceChange_Response, error) {
+			return &tfplugin6.PlanResourceChange_Response{
+				PlannedState: testDynamicValue(t, cty.NullVal(testThingType)),
+			}, nil
+		},
+	}
+	proposed := cty.ObjectVal(map[string]cty.Value{
+		"id":   cty.UnknownVal(cty.String),
+		"name": cty.StringVal("a"),
+	})
+	config := cty.ObjectVal(map[string]cty.Value{
+		"id":   cty.NullVal(cty.String),
+		"name": cty.StringVal("a"),
+	})
+
+	tests := map[string]struct {
+		strict       bool
+		prior        cty.Value
+		wantSeverity common.DiagnosticSeverity
+		wantDiags    int
+	}{
+		"create": {
+			prior:        cty.NullVal(testThingType),
+			wantSeverity: common.Warning,
+			wantDiags:    1,
+		},
+		"create strict": {
+			strict:       true,
+			prior:        cty.NullVal(testThingType),
+			wantSeverity: common.Error,
+			wantDiags:    1,
+		},
+		// A null planned state for an existing object is a plan to destroy
+		// it, which isn't diagnosed here.
+		"update": {
+			strict: true,
+			prior:  testThingVal("a", "b"),
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			p := configuredTestProvider(t, client, &common.Options{StrictCreatePlans: test.strict})
+			rt, err := p.ManagedResourceType("test_thing")
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, diags := rt.Plan(context.Background(), common.ManagedResourcePlanRequest{
+				PriorState:       test.prior,
+				ProposedNewState: proposed,
+				Config:           config,
+			})
+			if len(diags) != test.wantDiags {
+				t.Fatalf("got %d diagnostics; want %d: %#v", len(diags), test.wantDiags, diags)
+			}
+			if test.wantDiags == 0 {
+				return
+			}
+			if got := diags[0]; got.Severity != test.wantSeverity || got.Summary != "Provider planned no object for a create" {
+				t.Errorf("wrong diagnostic %#v", got)
+			}
+		})
+	}
+}
+
+func TestManagedResourceTypeImportStrict(t *testing.T) {
+	// The provider neglects to set the computed id attribute.
+	client := &fakeClient{
+		importResourceState: func(ctx context.Context, req *tfplugin6.ImportResourceState_Request) (*tfplugin6.ImportResourceState_Response, error) {
+			return &tfplugin6.ImportResourceState_Response{
+				ImportedResources: []*tfplugin6.ImportResourceState_ImportedResource{
+					{
+						TypeName: "test_thing",
+						State: testDynamicValue(t, cty.ObjectVal(map[string]cty.Value{
+							"id":   cty.NullVal(cty.String),
+							"name": cty.StringVal("imported"),
+						})),
+					},
+				},
+			}, nil
+		},
+	}
+
+	t.Run("lenient", func(t *testing.T) {
+		p := configuredTestProvider(t, client, nil)
+		rt, err := p.ManagedResourceType("test_thing")
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, diags := rt.Import(context.Background(), common.ManagedResourceImportRequest{ID: "i-123"})
+		if len(diags) != 0 {
+			t.Fatalf("unexpected diagnostics %#v", diags)
+		}
+		if len(resp.ImportedResources) != 1 {
+			t.Fatalf("imported %d objects; want 1", len(resp.ImportedResources))
+		}
+	})
+	t.Run("strict", func(t *testing.T) {
+		p := configuredTestProvider(t, client, &common.Options{StrictImports: true})
+		rt, err := p.ManagedResourceType("test_thing")
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, diags := rt.Import(context.Background(), common.ManagedResourceImportRequest{ID: "i-123"})
+		if len(diags) != 1 || diags[0].Severity != common.Error || diags[0].Summary != "Incomplete imported state" {
+			t.Fatalf("wrong diagnostics %#v", diags)
+		}
+		if got, want := common.FormatPath(diags[0].Attribute), "id"; got != want {
+			t.Errorf("wrong path %s; want %s", got, want)
+		}
+		if len(resp.ImportedResources) != 0 {
+			t.Errorf("imported %d objects despite errors; want 0", len(resp.ImportedResources))
+		}
+	})
+}
@@ -2,14 +2,60 @@ package protocol6
 
 import (
 	"context"
+	"fmt"
 
 	"google.golang.org/grpc"
 
 	"github.com/apparentlymart/terraform-provider/internal/tfplugin6"
+	"github.com/apparentlymart/terraform-provider/tfprovider/internal/common"
 )
 
-type PluginClient struct{}
+// PluginClient is the rpcplugin client version implementation for protocol
+// version 6.
+//
+// To exercise a Provider without a plugin process at all, such as in tests
+// using an in-memory gRPC connection, pass a tfplugin6.ProviderClient
+// directly to NewProvider instead.
+type PluginClient struct {
+	// NewClient, if set, is used instead of tfplugin6.NewProviderClient to
+	// create the client for the plugin's connection, so that tests can
+	// substitute a fake or wrapped client. The result must implement
+	// tfplugin6.ProviderClient.
+	//
+	// The result type is interface{} so that the tfprovider package's
+	// WithClientFactory option can offer this to callers that cannot name
+	// the generated client types.
+	NewClient func(conn *grpc.ClientConn) interface{}
+}
 
 func (c PluginClient) ClientProxy(ctx context.Context, conn *grpc.ClientConn) (interface{}, error) {
-	return tfplugin6.NewProviderClient(conn), nil
+	if c.NewClient == nil {
+		return connectedClient{
+			ProviderClient: tfplugin6.NewProviderClient(conn),
+			conn:           conn,
+		}, nil
+	}
+
+	raw := c.NewClient(conn)
+	client, ok := raw.(tfplugin6.ProviderClient)
+	if !ok {
+		return nil, fmt.Errorf("client factory returned %T, which is not a protocol version 6 provider client", raw)
+	}
+	return connectedClient{
+		ProviderClient: client,
+		conn:           conn,
+	}, nil
+}
+
+// connectedClient is a tfplugin6.ProviderClient that also retains the
+// connection it uses, so that the provider can report its state.
+type connectedClient struct {
+	tfplugin6.ProviderClient
+	conn *grpc.ClientConn
+}
+
+var _ common.ClientConnProxy = connectedClient{}
+
+func (c connectedClient) ClientConn() *grpc.ClientConn {
+	return c.conn
 }
@@ -0,0 +1,42 @@
+package protocol6
+
+import (
+	"context"
+	"testing"
+
+	"github.com/zclconf/go-cty/cty"
+)
+
+func TestProviderPrepareConfig(t *testing.T) {
+	// Protocol version 6 has no separate preparation step, so the provider
+	// isn't asked and the configuration is never modified.
+	p := newTestProvider(t, &fakeClient{}, nil)
+
+	given := cty.ObjectVal(map[string]cty.Value{
+		"region": cty.NullVal(cty.String),
+		"token":  cty.UnknownVal(cty.String),
+	})
+	got, diags := p.PrepareConfig(context.Background(), given)
+	if len(diags) != 0 {
+		t.Fatalf("unexpected diagnostics: %s", diags.Err())
+	}
+	if !got.Value.RawEquals(given) {
+		t.Errorf("wrong value %#v; want %#v", got.Value, given)
+	}
+	if got.Modified {
+		t.Error("configuration reported as modified")
+	}
+}
+
+func TestProviderPrepareConfigInvalid(t *testing.T) {
+	p := newTestProvider(t, &fakeClient{}, nil)
+
+	given := cty.StringVal("not an object")
+	got, diags := p.PrepareConfig(context.Background(), given)
+	if !diags.HasErrors() {
+		t.Fatal("PrepareConfig succeeded; want an error")
+	}
+	if !got.Value.RawEquals(given) || got.Modified {
+		t.Errorf("wrong result %#v; want the given config, unmodified", got)
+	}
+}
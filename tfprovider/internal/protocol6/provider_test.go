@@ -0,0 +1,1220 @@
+package protocol6
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zclconf/go-cty/cty"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/apparentlymart/terraform-provider/internal/tfplugin6"
+	"github.com/apparentlymart/terraform-provider/tfprovider/internal/common"
+)
+
+func TestProviderSchemaWarnings(t *testing.T) {
+	client := &fakeClient{
+		getProviderSchema: func(context.Context, *tfplugin6.GetProviderSchema_Request) (*tfplugin6.GetProviderSchema_Response, error) {
+			resp := testSchemaResponse()
+			resp.Diagnostics = []*tfplugin6.Diagnostic{
+				{
+					Severity: tfplugin6.Diagnostic_WARNING,
+					Summary:  "Experimental provider",
+					Detail:   "This provider is experimental.",
+				},
+			}
+			return resp, nil
+		},
+	}
+	p := newTestProvider(t, client, nil)
+
+	schema, diags := p.Schema(context.Background())
+	if _, ok := schema.ManagedResourceTypes["test_thing"]; !ok {
+		t.Errorf("schema is missing test_thing")
+	}
+	if len(diags) != 1 {
+		t.Fatalf("got %d diagnostics; want 1", len(diags))
+	}
+	if got := diags[0]; got.Severity != common.Warning || got.Summary != "Experimental provider" {
+		t.Errorf("wrong diagnostic %#v", got)
+	}
+}
+
+func TestNewProviderSchemaError(t *testing.T) {
+	client := &fakeClient{
+		getProviderSchema: func(context.Context, *tfplugin6.GetProviderSchema_Request) (*tfplugin6.GetProviderSchema_Response, error) {
+			return &tfplugin6.GetProviderSchema_Response{
+				Diagnostics: []*tfplugin6.Diagnostic{
+					{
+						Severity: tfplugin6.Diagnostic_ERROR,
+						Summary:  "Broken provider",
+					},
+				},
+			}, nil
+		},
+	}
+	_, err := NewProvider(context.Background(), nil, client, nil)
+	if err == nil {
+		t.Fatal("NewProvider succeeded; want an error")
+	}
+}
+
+func TestProviderSchemaVersions(t *testing.T) {
+	client := &fakeClient{
+		getProviderSchema: func(context.Context, *tfplugin6.GetProviderSchema_Request) (*tfplugin6.GetProviderSchema_Response, error) {
+			resp := testSchemaResponse()
+			resp.ResourceSchemas["test_other"] = &tfplugin6.Schema{
+				Block: &tfplugin6.Schema_Block{},
+			}
+			return resp, nil
+		},
+	}
+	p := newTestProvider(t, client, nil)
+
+	got, err := p.ManagedResourceSchemaVersion("test_thing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != 1 {
+		t.Errorf("wrong version %d; want 1", got)
+	}
+
+	_, err = p.ManagedResourceSchemaVersion("test_data")
+	want := common.UnknownResourceTypeError{Mode: common.ManagedResourceMode, TypeName: "test_data"}
+	if err != want {
+		t.Errorf("wrong error %#v; want %#v", err, want)
+	}
+
+	versions := p.SchemaVersions()
+	wantVersions := map[string]int64{
+		"test_thing": 1,
+		"test_other": 0,
+	}
+	if !reflect.DeepEqual(versions, wantVersions) {
+		t.Errorf("wrong versions %#v; want %#v", versions, wantVersions)
+	}
+}
+
+func TestProviderConfigurePlanOnly(t *testing.T) {
+	configures := 0
+	client := &fakeClient{
+		configureProvider: func(context.Context, *tfplugin6.ConfigureProvider_Request) (*tfplugin6.ConfigureProvider_Response, error) {
+			configures++
+			return &tfplugin6.ConfigureProvider_Response{}, nil
+		},
+		readResource: func(ctx context.Context, req *tfplugin6.ReadResource_Request) (*tfplugin6.ReadResource_Response, error) {
+			return &tfplugin6.ReadResource_Response{NewState: req.CurrentState}, nil
+		},
+	}
+	p := newTestProvider(t, client, nil)
+	ctx := context.Background()
+
+	unknownConfig := cty.ObjectVal(map[string]cty.Value{
+		"region": cty.UnknownVal(cty.String),
+		"token":  cty.NullVal(cty.String),
+	})
+	if diags := p.TryConfigure(ctx, unknownConfig); !diags.HasErrors() {
+		t.Errorf("TryConfigure succeeded with an unknown configuration; want an error")
+	}
+	if diags := p.ConfigurePlanOnly(ctx, common.Config{Value: unknownConfig}); diags.HasErrors() {
+		t.Fatalf("unexpected errors: %s", diags.Err())
+	}
+	if configures != 0 {
+		t.Fatalf("provider was configured %d times; want 0", configures)
+	}
+	if diags := p.ConfigurePlanOnly(ctx, common.Config{Value: unknownConfig}); !diags.HasErrors() {
+		t.Errorf("second ConfigurePlanOnly succeeded; want an error")
+	}
+
+	rt, err := p.ManagedResourceType("test_thing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	readReq := common.ManagedResourceReadRequest{
+		PreviousValue: cty.ObjectVal(map[string]cty.Value{
+			"id":   cty.StringVal("a"),
+			"name": cty.StringVal("b"),
+		}),
+	}
+	_, diags := rt.Read(ctx, readReq)
+	if !diags.HasErrors() {
+		t.Fatal("Read succeeded on a plan-only provider; want an error")
+	}
+	if got, want := diags[0].Summary, "Provider not fully configured"; got != want {
+		t.Errorf("wrong error summary %q; want %q", got, want)
+	}
+
+	// Once the configuration is known, the provider can be fully configured.
+	knownConfig := cty.ObjectVal(map[string]cty.Value{
+		"region": cty.StringVal("us-west-2"),
+		"token":  cty.NullVal(cty.String),
+	})
+	if diags := p.Configure(ctx, common.Config{Value: knownConfig}); diags.HasErrors() {
+		t.Fatalf("unexpected errors: %s", diags.Err())
+	}
+	if configures != 1 {
+		t.Errorf("provider was configured %d times; want 1", configures)
+	}
+	if _, diags := rt.Read(ctx, readReq); diags.HasErrors() {
+		t.Errorf("unexpected errors: %s", diags.Err())
+	}
+}
+
+func TestProviderConfigurePlanOnlyKnown(t *testing.T) {
+	configures := 0
+	client := &fakeClient{
+		configureProvider: func(context.Context, *tfplugin6.ConfigureProvider_Request) (*tfplugin6.ConfigureProvider_Response, error) {
+			configures++
+			return &tfplugin6.ConfigureProvider_Response{}, nil
+		},
+	}
+	p := newTestProvider(t, client, nil)
+
+	// A wholly-known configuration configures the provider fully.
+	config := cty.NullVal(p.ProviderConfigType())
+	if diags := p.ConfigurePlanOnly(context.Background(), common.Config{Value: config}); diags.HasErrors() {
+		t.Fatalf("unexpected errors: %s", diags.Err())
+	}
+	if configures != 1 {
+		t.Errorf("provider was configured %d times; want 1", configures)
+	}
+}
+
+func TestProviderConnectionSecurityUnknown(t *testing.T) {
+	// The fake client doesn't report any peer information, as a client
+	// that isn't connected to a real plugin wouldn't.
+	p := newTestProvider(t, &fakeClient{}, nil)
+	if got, ok := p.ConnectionSecurity(); ok {
+		t.Errorf("connection security is known (%#v); want unknown", got)
+	}
+}
+
+func TestNewProviderSchemaRetry(t *testing.T) {
+	tests := map[string]struct {
+		policy    *common.RetryPolicy
+		failCode  codes.Code
+		wantCalls int
+		wantErr   bool
+	}{
+		"retried": {
+			policy:    &common.RetryPolicy{MaxAttempts: 5, Delay: time.Millisecond},
+			failCode:  codes.Unavailable,
+			wantCalls: 3,
+		},
+		"too few attempts": {
+			policy:    &common.RetryPolicy{MaxAttempts: 2, Delay: time.Millisecond},
+			failCode:  codes.Unavailable,
+			wantCalls: 2,
+			wantErr:   true,
+		},
+		"no policy": {
+			failCode:  codes.Unavailable,
+			wantCalls: 1,
+			wantErr:   true,
+		},
+		"not retryable": {
+			policy:    &common.RetryPolicy{MaxAttempts: 5, Delay: time.Millisecond},
+			failCode:  codes.InvalidArgument,
+			wantCalls: 1,
+			wantErr:   true,
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			calls := 0
+			client := &fakeClient{
+				getProviderSchema: func(context.Context, *tfplugin6.GetProviderSchema_Request) (*tfplugin6.GetProviderSchema_Response, error) {
+					calls++
+					if calls <= 2 {
+						return nil, status.Error(test.failCode, "not ready yet")
+					}
+					return testSchemaResponse(), nil
+				},
+			}
+			opts := &common.Options{SchemaRetry: test.policy}
+			p, err := NewProvider(context.Background(), nil, client, opts)
+			if test.wantErr {
+				if err == nil {
+					p.Close()
+					t.Fatal("NewProvider succeeded; want an error")
+				}
+			} else {
+				if err != nil {
+					t.Fatalf("unexpected error: %s", err)
+				}
+				p.Close()
+			}
+			if calls != test.wantCalls {
+				t.Errorf("schema requested %d times; want %d", calls, test.wantCalls)
+			}
+		})
+	}
+}
+
+func TestApplyNotRetried(t *testing.T) {
+	calls := 0
+	client := &fakeClient{
+		applyResourceChange: func(context.Context, *tfplugin6.ApplyResourceChange_Request) (*tfplugin6.ApplyResourceChange_Response, error) {
+			calls++
+			return nil, status.Error(codes.Unavailable, "not ready yet")
+		},
+	}
+	opts := &common.Options{
+		SchemaRetry: &common.RetryPolicy{MaxAttempts: 5, Delay: time.Millisecond},
+	}
+	p := configuredTestProvider(t, client, opts)
+	rt, err := p.ManagedResourceType("test_thing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	planned := cty.ObjectVal(map[string]cty.Value{
+		"id":   cty.UnknownVal(cty.String),
+		"name": cty.StringVal("a"),
+	})
+	_, diags := rt.Apply(context.Background(), common.ManagedResourceApplyRequest{
+		PriorState:   cty.NullVal(planned.Type()),
+		PlannedState: planned,
+		Config:       planned,
+	})
+	if !diags.HasErrors() {
+		t.Fatal("Apply succeeded; want an error")
+	}
+	if calls != 1 {
+		t.Errorf("apply requested %d times; want 1", calls)
+	}
+}
+
+func TestProviderValidateAll(t *testing.T) {
+	client := &fakeClient{
+		validateProviderConfig: func(context.Context, *tfplugin6.ValidateProviderConfig_Request) (*tfplugin6.ValidateProviderConfig_Response, error) {
+			return &tfplugin6.ValidateProviderConfig_Response{}, nil
+		},
+		validateResourceConfig: func(context.Context, *tfplugin6.ValidateResourceConfig_Request) (*tfplugin6.ValidateResourceConfig_Response, error) {
+			return &tfplugin6.ValidateResourceConfig_Response{}, nil
+		},
+		validateDataResourceConfig: func(context.Context, *tfplugin6.ValidateDataResourceConfig_Request) (*tfplugin6.ValidateDataResourceConfig_Response, error) {
+			return &tfplugin6.ValidateDataResourceConfig_Response{
+				Diagnostics: []*tfplugin6.Diagnostic{
+					{
+						Severity: tfplugin6.Diagnostic_ERROR,
+						Summary:  "Invalid name",
+					},
+				},
+			}, nil
+		},
+	}
+	p := newTestProvider(t, client, nil)
+
+	config := cty.ObjectVal(map[string]cty.Value{
+		"region": cty.StringVal("us-west-2"),
+		"token":  cty.NullVal(cty.String),
+	})
+	got := p.ValidateAll(context.Background(), config,
+		map[string]cty.Value{
+			"test_thing":   testThingVal("a", "b"),
+			"test_missing": cty.EmptyObjectVal,
+		},
+		map[string]cty.Value{
+			"test_data": cty.ObjectVal(map[string]cty.Value{
+				"name":  cty.StringVal(""),
+				"value": cty.NullVal(cty.String),
+			}),
+		},
+	)
+
+	want := map[string]string{
+		"provider":             "",
+		"resource[test_thing]": "",
+		// Unknown types are reported without asking the provider.
+		"resource[test_missing]": "Unknown resource type",
+		"data[test_data]":        "Invalid name",
+	}
+	if len(got) != len(want) {
+		t.Errorf("got %d targets; want %d", len(got), len(want))
+	}
+	for target, diags := range got {
+		wantSummary, ok := want[target.String()]
+		if !ok {
+			t.Errorf("unexpected target %s", target)
+			continue
+		}
+		switch {
+		case wantSummary == "" && len(diags) != 0:
+			t.Errorf("unexpected diagnostics for %s: %s", target, diags.Err())
+		case wantSummary != "" && (len(diags) != 1 || diags[0].Summary != wantSummary):
+			t.Errorf("wrong diagnostics for %s: %#v; want %q", target, diags, wantSummary)
+		}
+	}
+}
+
+func TestProviderValidateAllProviderConfig(t *testing.T) {
+	validates := 0
+	client := &fakeClient{
+		validateProviderConfig: func(context.Context, *tfplugin6.ValidateProviderConfig_Request) (*tfplugin6.ValidateProviderConfig_Response, error) {
+			validates++
+			return &tfplugin6.ValidateProviderConfig_Response{
+				Diagnostics: []*tfplugin6.Diagnostic{
+					{
+						Severity: tfplugin6.Diagnostic_ERROR,
+						Summary:  "Invalid region",
+					},
+				},
+			}, nil
+		},
+	}
+	p := newTestProvider(t, client, nil)
+
+	config := cty.ObjectVal(map[string]cty.Value{
+		"region": cty.StringVal("nowhere"),
+		"token":  cty.NullVal(cty.String),
+	})
+	got := p.ValidateAll(context.Background(), config, nil, nil)
+	if validates != 1 {
+		t.Errorf("provider validated its configuration %d times; want 1", validates)
+	}
+	diags := got[common.ValidationTarget{Kind: common.ProviderConfigTarget}]
+	if len(diags) != 1 || diags[0].Summary != "Invalid region" {
+		t.Errorf("wrong diagnostics for the provider configuration %#v", diags)
+	}
+}
+
+func TestProviderResourceSchemaVersion(t *testing.T) {
+	p := newTestProvider(t, &fakeClient{}, nil)
+
+	// The current version is available.
+	got, err := p.ResourceSchemaVersion("test_thing", 1)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if want := testThingType; !got.ImpliedType().Equals(want) {
+		t.Errorf("wrong schema type %#v; want %#v", got.ImpliedType(), want)
+	}
+
+	// Older versions are not, because the protocol can't retrieve them.
+	_, err = p.ResourceSchemaVersion("test_thing", 0)
+	wantErr := common.SchemaVersionUnavailableError{
+		TypeName:       "test_thing",
+		Version:        0,
+		CurrentVersion: 1,
+	}
+	if err != wantErr {
+		t.Errorf("wrong error %#v; want %#v", err, wantErr)
+	}
+
+	_, err = p.ResourceSchemaVersion("test_missing", 1)
+	if _, ok := err.(common.UnknownResourceTypeError); !ok {
+		t.Errorf("wrong error %#v; want an UnknownResourceTypeError", err)
+	}
+}
+
+func TestProviderResourceExists(t *testing.T) {
+	client := &fakeClient{
+		readResource: func(ctx context.Context, req *tfplugin6.ReadResource_Request) (*tfplugin6.ReadResource_Response, error) {
+			if string(req.Private) != "private" {
+				t.Errorf("wrong private data %q", req.Private)
+			}
+			state := decodeTestDynamicValue(t, req.CurrentState, testThingType)
+			switch state.GetAttr("id").AsString() {
+			case "exists":
+				return &tfplugin6.ReadResource_Response{NewState: req.CurrentState}, nil
+			case "deleted":
+				return &tfplugin6.ReadResource_Response{
+					NewState: testDynamicValue(t, cty.NullVal(testThingType)),
+				}, nil
+			default:
+				return &tfplugin6.ReadResource_Response{
+					Diagnostics: []*tfplugin6.Diagnostic{
+						{Severity: tfplugin6.Diagnostic_ERROR, Summary: "Read failed"},
+					},
+				}, nil
+			}
+		},
+	}
+	p := configuredTestProvider(t, client, nil)
+	ctx := context.Background()
+
+	tests := map[string]struct {
+		want        bool
+		wantSummary string
+	}{
+		"exists":  {true, ""},
+		"deleted": {false, ""},
+		"broken":  {false, "Read failed"},
+	}
+	for id, test := range tests {
+		t.Run(id, func(t *testing.T) {
+			got, diags := p.ResourceExists(ctx, "test_thing", testThingVal(id, "example"), []byte("private"))
+			if got != test.want {
+				t.Errorf("wrong result %t; want %t", got, test.want)
+			}
+			switch {
+			case test.wantSummary == "" && len(diags) != 0:
+				t.Errorf("unexpected diagnostics: %s", diags.Err())
+			case test.wantSummary != "" && (len(diags) != 1 || diags[0].Summary != test.wantSummary):
+				t.Errorf("wrong diagnostics %#v; want %q", diags, test.wantSummary)
+			}
+		})
+	}
+
+	_, diags := p.ResourceExists(ctx, "test_missing", cty.EmptyObjectVal, nil)
+	if len(diags) != 1 || diags[0].Summary != "Invalid resource type" {
+		t.Errorf("wrong diagnostics for unknown type %#v", diags)
+	}
+}
+
+func TestProviderReadDataSources(t *testing.T) {
+	otherType := cty.Object(map[string]cty.Type{
+		"id":    cty.String,
+		"count": cty.Number,
+	})
+	client := &fakeClient{
+		getProviderSchema: func(context.Context, *tfplugin6.GetProviderSchema_Request) (*tfplugin6.GetProviderSchema_Response, error) {
+			resp := testSchemaResponse()
+			resp.DataSourceSchemas["test_other"] = &tfplugin6.Schema{
+				Block: &tfplugin6.Schema_Block{
+					Attributes: []*tfplugin6.Schema_Attribute{
+						{Name: "id", Type: []byte(`"string"`), Required: true},
+						{Name: "count", Type: []byte(`"number"`), Computed: true},
+					},
+				},
+			}
+			return resp, nil
+		},
+		readDataSource: func(ctx context.Context, req *tfplugin6.ReadDataSource_Request) (*tfplugin6.ReadDataSource_Response, error) {
+			switch req.TypeName {
+			case "test_data":
+				config := decodeTestDynamicValue(t, req.Config, testDataType)
+				name := config.GetAttr("name").AsString()
+				return &tfplugin6.ReadDataSource_Response{
+					State: testDynamicValue(t, cty.ObjectVal(map[string]cty.Value{
+						"name":  config.GetAttr("name"),
+						"value": cty.StringVal("value of " + name),
+					})),
+				}, nil
+			case "test_other":
+				config := decodeTestDynamicValue(t, req.Config, otherType)
+				return &tfplugin6.ReadDataSource_Response{
+					State: testDynamicValue(t, cty.ObjectVal(map[string]cty.Value{
+						"id":    config.GetAttr("id"),
+						"count": cty.NumberIntVal(int64(len(config.GetAttr("id").AsString()))),
+					})),
+				}, nil
+			default:
+				t.Errorf("unexpected read of %q", req.TypeName)
+				return nil, status.Error(codes.NotFound, "no such type")
+			}
+		},
+	}
+	p := configuredTestProvider(t, client, nil)
+
+	dataConfig := func(name string) cty.Value {
+		return cty.ObjectVal(map[string]cty.Value{
+			"name":  cty.StringVal(name),
+			"value": cty.NullVal(cty.String),
+		})
+	}
+	otherConfig := func(id string) cty.Value {
+		return cty.ObjectVal(map[string]cty.Value{
+			"id":    cty.StringVal(id),
+			"count": cty.NullVal(cty.Number),
+		})
+	}
+	var inputs []common.DataReadInput
+	var want []cty.Value
+	for i := 0; i < 10; i++ {
+		name := strings.Repeat("x", i+1)
+		if i%2 == 0 {
+			inputs = append(inputs, common.DataReadInput{
+				TypeName: "test_data",
+				Request:  common.DataResourceReadRequest{Config: dataConfig(name)},
+			})
+			want = append(want, cty.ObjectVal(map[string]cty.Value{
+				"name":  cty.StringVal(name),
+				"value": cty.StringVal("value of " + name),
+			}))
+		} else {
+			inputs = append(inputs, common.DataReadInput{
+				TypeName: "test_other",
+				Request:  common.DataResourceReadRequest{Config: otherConfig(name)},
+			})
+			want = append(want, cty.ObjectVal(map[string]cty.Value{
+				"id":    cty.StringVal(name),
+				"count": cty.NumberIntVal(int64(i + 1)),
+			}))
+		}
+	}
+	inputs = append(inputs, common.DataReadInput{
+		TypeName: "test_missing",
+		Request:  common.DataResourceReadRequest{Config: cty.EmptyObjectVal},
+	})
+
+	got := p.ReadDataSources(context.Background(), inputs)
+	if len(got) != len(inputs) {
+		t.Fatalf("got %d results; want %d", len(got), len(inputs))
+	}
+	for i, want := range want {
+		result := got[i]
+		if result.Diagnostics.HasErrors() {
+			t.Errorf("unexpected errors for input %d: %s", i, result.Diagnostics.Err())
+			continue
+		}
+		if !result.Response.State.RawEquals(want) {
+			t.Errorf("wrong state for input %d\ngot:  %#v\nwant: %#v", i, result.Response.State, want)
+		}
+	}
+	last := got[len(got)-1].Diagnostics
+	if len(last) != 1 || last[0].Summary != "Invalid data resource type" {
+		t.Errorf("wrong diagnostics for unknown type %#v", last)
+	}
+}
+
+func TestProviderRawSchema(t *testing.T) {
+	raw := testSchemaResponse()
+	raw.ResourceSchemas["test_thing"].Block.Description = "A **thing**."
+	raw.ResourceSchemas["test_thing"].Block.DescriptionKind = tfplugin6.StringKind_MARKDOWN
+	client := &fakeClient{
+		getProviderSchema: func(context.Context, *tfplugin6.GetProviderSchema_Request) (*tfplugin6.GetProviderSchema_Response, error) {
+			return raw, nil
+		},
+	}
+	p := newTestProvider(t, client, nil)
+
+	got := p.RawSchema()
+	if got != raw {
+		t.Fatalf("wrong raw schema %#v; want the provider's response", got)
+	}
+	block := got.ResourceSchemas["test_thing"].Block
+	if block.Description != "A **thing**." || block.DescriptionKind != tfplugin6.StringKind_MARKDOWN {
+		t.Errorf("raw schema lost the description: %q (%s)", block.Description, block.DescriptionKind)
+	}
+}
+
+func TestProviderDetectDrift(t *testing.T) {
+	client := &fakeClient{
+		readResource: func(ctx context.Context, req *tfplugin6.ReadResource_Request) (*tfplugin6.ReadResource_Response, error) {
+			state := decodeTestDynamicValue(t, req.CurrentState, testThingType)
+			switch id := state.GetAttr("id").AsString(); id {
+			case "unchanged":
+				return &tfplugin6.ReadResource_Response{NewState: req.CurrentState}, nil
+			case "changed":
+				return &tfplugin6.ReadResource_Response{
+					NewState: testDynamicValue(t, testThingVal(id, "renamed")),
+				}, nil
+			default:
+				return &tfplugin6.ReadResource_Response{
+					NewState: testDynamicValue(t, cty.NullVal(testThingType)),
+				}, nil
+			}
+		},
+	}
+	p := configuredTestProvider(t, client, nil)
+	ctx := context.Background()
+
+	changes, diags := p.DetectDrift(ctx, "test_thing", testThingVal("unchanged", "example"), nil)
+	if len(diags) != 0 {
+		t.Fatalf("unexpected diagnostics: %s", diags.Err())
+	}
+	if len(changes) != 0 {
+		t.Errorf("unexpected changes for unchanged resource: %#v", changes)
+	}
+
+	changes, diags = p.DetectDrift(ctx, "test_thing", testThingVal("changed", "example"), nil)
+	if len(diags) != 0 {
+		t.Fatalf("unexpected diagnostics: %s", diags.Err())
+	}
+	if len(changes) != 1 {
+		t.Fatalf("got %d changes for changed resource; want 1: %#v", len(changes), changes)
+	}
+	if got, want := common.FormatPath(changes[0].Path), "name"; got != want {
+		t.Errorf("wrong path %s; want %s", got, want)
+	}
+	if !changes[0].Before.RawEquals(cty.StringVal("example")) || !changes[0].After.RawEquals(cty.StringVal("renamed")) {
+		t.Errorf("wrong change %#v -> %#v", changes[0].Before, changes[0].After)
+	}
+
+	// A deleted resource is reported as a single change to the whole object.
+	desired := testThingVal("deleted", "example")
+	changes, diags = p.DetectDrift(ctx, "test_thing", desired, nil)
+	if len(diags) != 0 {
+		t.Fatalf("unexpected diagnostics: %s", diags.Err())
+	}
+	if len(changes) != 1 {
+		t.Fatalf("got %d changes for deleted resource; want 1: %#v", len(changes), changes)
+	}
+	if len(changes[0].Path) != 0 || !changes[0].Before.RawEquals(desired) || !changes[0].After.IsNull() {
+		t.Errorf("wrong change for deleted resource %#v", changes[0])
+	}
+
+	_, diags = p.DetectDrift(ctx, "test_missing", cty.EmptyObjectVal, nil)
+	if len(diags) != 1 || diags[0].Summary != "Invalid resource type" {
+		t.Errorf("wrong diagnostics for unknown type %#v", diags)
+	}
+}
+
+func TestProviderSchemaDeprecated(t *testing.T) {
+	client := &fakeClient{
+		getProviderSchema: func(context.Context, *tfplugin6.GetProviderSchema_Request) (*tfplugin6.GetProviderSchema_Response, error) {
+			resp := testSchemaResponse()
+			resp.Provider.Block.Deprecated = true
+			resp.ResourceSchemas["test_thing"].Block.Deprecated = true
+			resp.DataSourceSchemas["test_data"].Block.Deprecated = true
+			return resp, nil
+		},
+	}
+	p := newTestProvider(t, client, nil)
+
+	_, diags := p.Schema(context.Background())
+	if diags.HasErrors() {
+		t.Fatalf("unexpected errors: %s", diags.Err())
+	}
+	wantSummaries := []string{
+		"Deprecated provider",
+		"Deprecated resource type",
+		"Deprecated resource type",
+	}
+	if len(diags) != len(wantSummaries) {
+		t.Fatalf("got %d diagnostics; want %d: %#v", len(diags), len(wantSummaries), diags)
+	}
+	for i, diag := range diags {
+		if diag.Severity != common.Warning || diag.Summary != wantSummaries[i] {
+			t.Errorf("wrong diagnostic %d %#v", i, diag)
+		}
+	}
+	if !strings.Contains(diags[1].Detail, `"test_thing"`) || !strings.Contains(diags[2].Detail, `"test_data"`) {
+		t.Errorf("wrong resource types in %q and %q", diags[1].Detail, diags[2].Detail)
+	}
+}
+
+func TestProviderPlanAndDiff(t *testing.T) {
+	client := &fakeClient{
+		planResourceChange: func(ctx context.Context, req *tfplugin6.PlanResourceChange_Request) (*tfplugin6.PlanResourceChange_Response, error) {
+			proposed := decodeTestDynamicValue(t, req.ProposedNewState, testThingType)
+			planned := cty.ObjectVal(map[string]cty.Value{
+				"id":   cty.UnknownVal(cty.String),
+				"name": proposed.GetAttr("name"),
+			})
+			return &tfplugin6.PlanResourceChange_Response{
+				PlannedState: testDynamicValue(t, planned),
+				RequiresReplace: []*tfplugin6.AttributePath{
+					{
+						Steps: []*tfplugin6.AttributePath_Step{
+							{Selector: &tfplugin6.AttributePath_Step_AttributeName{AttributeName: "name"}},
+						},
+					},
+				},
+			}, nil
+		},
+	}
+	p := configuredTestProvider(t, client, nil)
+
+	resp, changes, diags := p.PlanAndDiff(context.Background(), "test_thing", common.ManagedResourcePlanRequest{
+		PriorState:       testThingVal("a", "old"),
+		ProposedNewState: testThingVal("a", "new"),
+		Config: cty.ObjectVal(map[string]cty.Value{
+			"id":   cty.NullVal(cty.String),
+			"name": cty.StringVal("new"),
+		}),
+	})
+	if diags.HasErrors() {
+		t.Fatalf("unexpected errors: %s", diags.Err())
+	}
+	if got := resp.PlannedState.GetAttr("name"); !got.RawEquals(cty.StringVal("new")) {
+		t.Errorf("wrong planned name %#v", got)
+	}
+
+	if len(changes) != 2 {
+		t.Fatalf("got %d changes; want 2: %#v", len(changes), changes)
+	}
+	id, name := changes[0], changes[1]
+	if got := common.FormatPath(id.Path); got != "id" {
+		t.Errorf("wrong path for first change %s; want id", got)
+	}
+	if !id.KnownAfterApply() || id.RequiresReplace {
+		t.Errorf("wrong id change: known after apply %t, requires replace %t", id.KnownAfterApply(), id.RequiresReplace)
+	}
+	if got := common.FormatPath(name.Path); got != "name" {
+		t.Errorf("wrong path for second change %s; want name", got)
+	}
+	if name.KnownAfterApply() || !name.RequiresReplace {
+		t.Errorf("wrong name change: known after apply %t, requires replace %t", name.KnownAfterApply(), name.RequiresReplace)
+	}
+	if !name.Before.RawEquals(cty.StringVal("old")) || !name.After.RawEquals(cty.StringVal("new")) {
+		t.Errorf("wrong name change %#v -> %#v", name.Before, name.After)
+	}
+
+	_, _, diags = p.PlanAndDiff(context.Background(), "test_missing", common.ManagedResourcePlanRequest{})
+	if len(diags) != 1 || diags[0].Summary != "Invalid resource type" {
+		t.Errorf("wrong diagnostics for unknown type %#v", diags)
+	}
+}
+
+func TestProviderDiagnosticDedup(t *testing.T) {
+	warning := &tfplugin6.Diagnostic{
+		Severity: tfplugin6.Diagnostic_WARNING,
+		Summary:  "Experimental provider",
+		Detail:   "This provider is experimental.",
+	}
+	client := &fakeClient{
+		getProviderSchema: func(context.Context, *tfplugin6.GetProviderSchema_Request) (*tfplugin6.GetProviderSchema_Response, error) {
+			resp := testSchemaResponse()
+			resp.Diagnostics = []*tfplugin6.Diagnostic{warning, warning}
+			return resp, nil
+		},
+	}
+
+	for _, dedup := range []bool{false, true} {
+		p := newTestProvider(t, client, &common.Options{DedupDiagnostics: dedup})
+		_, diags := p.Schema(context.Background())
+		want := 2
+		if dedup {
+			want = 1
+		}
+		if len(diags) != want {
+			t.Errorf("got %d diagnostics with dedup %t; want %d", len(diags), dedup, want)
+		}
+	}
+}
+
+func TestProviderTypeEncodingOverride(t *testing.T) {
+	formats := make(map[string]string)
+	format := func(dv *tfplugin6.DynamicValue) string {
+		switch {
+		case len(dv.Json) != 0 && len(dv.Msgpack) == 0:
+			return "json"
+		case len(dv.Msgpack) != 0 && len(dv.Json) == 0:
+			return "msgpack"
+		default:
+			return "invalid"
+		}
+	}
+	client := &fakeClient{
+		configureProvider: func(ctx context.Context, req *tfplugin6.ConfigureProvider_Request) (*tfplugin6.ConfigureProvider_Response, error) {
+			formats["provider"] = format(req.Config)
+			return &tfplugin6.ConfigureProvider_Response{}, nil
+		},
+		validateResourceConfig: func(ctx context.Context, req *tfplugin6.ValidateResourceConfig_Request) (*tfplugin6.ValidateResourceConfig_Response, error) {
+			formats[req.TypeName] = format(req.Config)
+			return &tfplugin6.ValidateResourceConfig_Response{}, nil
+		},
+		validateDataResourceConfig: func(ctx context.Context, req *tfplugin6.ValidateDataResourceConfig_Request) (*tfplugin6.ValidateDataResourceConfig_Response, error) {
+			formats[req.TypeName] = format(req.Config)
+			return &tfplugin6.ValidateDataResourceConfig_Response{}, nil
+		},
+	}
+	p := configuredTestProvider(t, client, &common.Options{
+		TypeEncodingOverrides: map[string]common.Format{
+			"test_thing": common.FormatJSON,
+		},
+	})
+	ctx := context.Background()
+
+	rt, err := p.ManagedResourceType("test_thing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diags := rt.ValidateConfig(ctx, testThingVal("a", "b")); diags.HasErrors() {
+		t.Fatalf("unexpected errors: %s", diags.Err())
+	}
+	dt, err := p.DataResourceType("test_data")
+	if err != nil {
+		t.Fatal(err)
+	}
+	diags := dt.ValidateConfig(ctx, cty.ObjectVal(map[string]cty.Value{
+		"name":  cty.StringVal("a"),
+		"value": cty.NullVal(cty.String),
+	}))
+	if diags.HasErrors() {
+		t.Fatalf("unexpected errors: %s", diags.Err())
+	}
+
+	want := map[string]string{
+		"provider":   "msgpack",
+		"test_thing": "json",
+		"test_data":  "msgpack",
+	}
+	if !reflect.DeepEqual(formats, want) {
+		t.Errorf("wrong formats\ngot:  %#v\nwant: %#v", formats, want)
+	}
+}
+
+func TestProviderSecretResolver(t *testing.T) {
+	configType := cty.Object(map[string]cty.Type{
+		"region": cty.String,
+		"token":  cty.String,
+	})
+	var validated, configured []cty.Value
+	client := &fakeClient{
+		validateProviderConfig: func(ctx context.Context, req *tfplugin6.ValidateProviderConfig_Request) (*tfplugin6.ValidateProviderConfig_Response, error) {
+			validated = append(validated, decodeTestDynamicValue(t, req.Config, configType))
+			return &tfplugin6.ValidateProviderConfig_Response{}, nil
+		},
+		configureProvider: func(ctx context.Context, req *tfplugin6.ConfigureProvider_Request) (*tfplugin6.ConfigureProvider_Response, error) {
+			configured = append(configured, decodeTestDynamicValue(t, req.Config, configType))
+			return &tfplugin6.ConfigureProvider_Response{}, nil
+		},
+	}
+	resolves := 0
+	p := newTestProvider(t, client, &common.Options{
+		SecretResolvers: []common.SecretResolver{
+			{
+				Scheme: "vault",
+				Resolve: func(ref string) (string, error) {
+					resolves++
+					if ref != "secret/db#password" {
+						return "", fmt.Errorf("no secret at %s", ref)
+					}
+					return "hunter2", nil
+				},
+			},
+		},
+	})
+	ctx := context.Background()
+	config := func(token string) cty.Value {
+		return cty.ObjectVal(map[string]cty.Value{
+			"region": cty.StringVal("us-east-1"),
+			"token":  cty.StringVal(token),
+		})
+	}
+	good := config("vault:secret/db#password")
+	bad := config("vault:secret/missing")
+
+	// TryConfigure resolves the secrets to check them, but sends only the
+	// references to the provider.
+	if diags := p.TryConfigure(ctx, good); diags.HasErrors() {
+		t.Fatalf("unexpected errors from TryConfigure: %s", diags.Err())
+	}
+	if resolves != 1 {
+		t.Errorf("TryConfigure resolved %d secrets; want 1", resolves)
+	}
+	if len(validated) != 1 || !validated[0].RawEquals(good) {
+		t.Errorf("provider validated wrong configuration %#v; want %#v", validated, good)
+	}
+	diags := p.TryConfigure(ctx, bad)
+	if len(diags) != 1 || diags[0].Summary != "Failed to resolve secret" {
+		t.Fatalf("wrong diagnostics from TryConfigure %#v", diags)
+	}
+	if got := common.FormatPath(diags[0].Attribute); got != "token" {
+		t.Errorf("wrong path %s; want token", got)
+	}
+	if len(configured) != 0 {
+		t.Fatalf("TryConfigure configured the provider")
+	}
+
+	// A configuration whose secrets can't be resolved is not sent, and the
+	// provider may still be configured afterwards.
+	diags = p.Configure(ctx, common.Config{Value: bad})
+	if len(diags) != 1 || diags[0].Summary != "Failed to resolve secret" {
+		t.Fatalf("wrong diagnostics from Configure %#v", diags)
+	}
+	if diags := p.Configure(ctx, common.Config{Value: good}); diags.HasErrors() {
+		t.Fatalf("unexpected errors from Configure: %s", diags.Err())
+	}
+	if want := config("hunter2"); len(configured) != 1 || !configured[0].RawEquals(want) {
+		t.Errorf("provider configured with %#v; want %#v", configured, want)
+	}
+}
+
+func TestProviderRefresh(t *testing.T) {
+	var upgrades []*tfplugin6.UpgradeResourceState_Request
+	var reads []cty.Value
+	client := &fakeClient{
+		upgradeResourceState: func(ctx context.Context, req *tfplugin6.UpgradeResourceState_Request) (*tfplugin6.UpgradeResourceState_Response, error) {
+			upgrades = append(upgrades, req)
+			return &tfplugin6.UpgradeResourceState_Response{
+				UpgradedState: testDynamicValue(t, testThingVal("a", "upgraded")),
+			}, nil
+		},
+		readResource: func(ctx context.Context, req *tfplugin6.ReadResource_Request) (*tfplugin6.ReadResource_Response, error) {
+			current := decodeTestDynamicValue(t, req.CurrentState, testThingType)
+			reads = append(reads, current)
+			return &tfplugin6.ReadResource_Response{
+				NewState: testDynamicValue(t, testThingVal("a", "refreshed")),
+				Private:  req.Private,
+			}, nil
+		},
+	}
+	p := configuredTestProvider(t, client, nil)
+	rawState := []byte(`{"id":"a","name":"stored"}`)
+	private := []byte("private")
+
+	t.Run("up to date", func(t *testing.T) {
+		upgrades, reads = nil, nil
+		resp, diags := p.Refresh(context.Background(), "test_thing", rawState, 1, private)
+		if diags.HasErrors() {
+			t.Fatalf("unexpected errors: %s", diags.Err())
+		}
+		if len(upgrades) != 0 {
+			t.Errorf("state upgraded %d times; want 0", len(upgrades))
+		}
+		if len(reads) != 1 || !reads[0].RawEquals(testThingVal("a", "stored")) {
+			t.Fatalf("wrong reads %#v; want the stored state", reads)
+		}
+		if !resp.RefreshedValue.RawEquals(testThingVal("a", "refreshed")) {
+			t.Errorf("wrong refreshed value %#v", resp.RefreshedValue)
+		}
+		if string(resp.OpaquePrivate) != "private" {
+			t.Errorf("wrong private data %q", resp.OpaquePrivate)
+		}
+	})
+	t.Run("stale", func(t *testing.T) {
+		upgrades, reads = nil, nil
+		resp, diags := p.Refresh(context.Background(), "test_thing", rawState, 0, private)
+		if diags.HasErrors() {
+			t.Fatalf("unexpected errors: %s", diags.Err())
+		}
+		if len(upgrades) != 1 {
+			t.Fatalf("state upgraded %d times; want 1", len(upgrades))
+		}
+		if got := upgrades[0]; got.Version != 0 || string(got.RawState.Json) != string(rawState) {
+			t.Errorf("wrong upgrade request version %d with %s", got.Version, got.RawState.Json)
+		}
+		if len(reads) != 1 || !reads[0].RawEquals(testThingVal("a", "upgraded")) {
+			t.Fatalf("wrong reads %#v; want the upgraded state", reads)
+		}
+		if !resp.RefreshedValue.RawEquals(testThingVal("a", "refreshed")) {
+			t.Errorf("wrong refreshed value %#v", resp.RefreshedValue)
+		}
+	})
+	t.Run("newer", func(t *testing.T) {
+		upgrades, reads = nil, nil
+		_, diags := p.Refresh(context.Background(), "test_thing", rawState, 2, private)
+		if len(diags) != 1 || diags[0].Summary != "Resource state from newer provider version" {
+			t.Errorf("wrong diagnostics %#v", diags)
+		}
+		if len(upgrades) != 0 || len(reads) != 0 {
+			t.Errorf("provider called for state from a newer version")
+		}
+	})
+	t.Run("unknown type", func(t *testing.T) {
+		_, diags := p.Refresh(context.Background(), "test_missing", rawState, 0, private)
+		if len(diags) != 1 || diags[0].Summary != "Unknown resource type" {
+			t.Errorf("wrong diagnostics %#v", diags)
+		}
+	})
+	t.Run("unconfigured", func(t *testing.T) {
+		p := newTestProvider(t, client, nil)
+		_, diags := p.Refresh(context.Background(), "test_thing", rawState, 1, private)
+		if len(diags) != 1 || diags[0].Summary != "Provider not fully configured" {
+			t.Errorf("wrong diagnostics %#v", diags)
+		}
+	})
+}
+
+func TestProviderAllDiagnostics(t *testing.T) {
+	client := &fakeClient{
+		configureProvider: func(context.Context, *tfplugin6.ConfigureProvider_Request) (*tfplugin6.ConfigureProvider_Response, error) {
+			return &tfplugin6.ConfigureProvider_Response{
+				Diagnostics: []*tfplugin6.Diagnostic{
+					{Severity: tfplugin6.Diagnostic_WARNING, Summary: "Deprecated region"},
+				},
+			}, nil
+		},
+		readDataSource: func(ctx context.Context, req *tfplugin6.ReadDataSource_Request) (*tfplugin6.ReadDataSource_Response, error) {
+			config := decodeTestDynamicValue(t, req.Config, testDataType)
+			return &tfplugin6.ReadDataSource_Response{
+				State: testDynamicValue(t, cty.ObjectVal(map[string]cty.Value{
+					"name":  config.GetAttr("name"),
+					"value": cty.StringVal("value"),
+				})),
+				Diagnostics: []*tfplugin6.Diagnostic{
+					{Severity: tfplugin6.Diagnostic_WARNING, Summary: "Slow read of " + config.GetAttr("name").AsString()},
+				},
+			}, nil
+		},
+	}
+	read := func(p *Provider, name string) {
+		rt, err := p.DataResourceType("test_data")
+		if err != nil {
+			t.Fatal(err)
+		}
+		// The caller discards the diagnostics from each read.
+		rt.Read(context.Background(), common.DataResourceReadRequest{
+			Config: cty.ObjectVal(map[string]cty.Value{
+				"name":  cty.StringVal(name),
+				"value": cty.NullVal(cty.String),
+			}),
+		})
+	}
+
+	p := configuredTestProvider(t, client, &common.Options{DiagnosticLog: common.NewDiagnosticLog()})
+	read(p, "a")
+	read(p, "b")
+	p.Close()
+
+	var got []string
+	for _, diag := range p.AllDiagnostics() {
+		if diag.Severity != common.Warning {
+			t.Errorf("wrong severity for %q", diag.Summary)
+		}
+		got = append(got, diag.Summary)
+	}
+	want := []string{"Deprecated region", "Slow read of a", "Slow read of b"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("wrong diagnostics\ngot:  %q\nwant: %q", got, want)
+	}
+
+	// Without the option, nothing is accumulated.
+	p = configuredTestProvider(t, client, nil)
+	read(p, "a")
+	if got := p.AllDiagnostics(); len(got) != 0 {
+		t.Errorf("diagnostics accumulated without the option: %#v", got)
+	}
+}
+
+func TestProviderTryConfigure(t *testing.T) {
+	configType := cty.Object(map[string]cty.Type{
+		"region": cty.String,
+		"token":  cty.String,
+	})
+	var validates, configures int
+	client := &fakeClient{
+		validateProviderConfig: func(ctx context.Context, req *tfplugin6.ValidateProviderConfig_Request) (*tfplugin6.ValidateProviderConfig_Response, error) {
+			validates++
+			config := decodeTestDynamicValue(t, req.Config, configType)
+			if region := config.GetAttr("region"); !region.IsNull() && region.AsString() == "nowhere" {
+				return &tfplugin6.ValidateProviderConfig_Response{
+					Diagnostics: []*tfplugin6.Diagnostic{
+						{Severity: tfplugin6.Diagnostic_ERROR, Summary: "Invalid region"},
+					},
+				}, nil
+			}
+			return &tfplugin6.ValidateProviderConfig_Response{}, nil
+		},
+		configureProvider: func(context.Context, *tfplugin6.ConfigureProvider_Request) (*tfplugin6.ConfigureProvider_Response, error) {
+			configures++
+			return &tfplugin6.ConfigureProvider_Response{}, nil
+		},
+	}
+	p := newTestProvider(t, client, nil)
+	ctx := context.Background()
+	config := func(region cty.Value) cty.Value {
+		return cty.ObjectVal(map[string]cty.Value{
+			"region": region,
+			"token":  cty.NullVal(cty.String),
+		})
+	}
+
+	if diags := p.TryConfigure(ctx, config(cty.StringVal("us-west-2"))); len(diags) != 0 {
+		t.Errorf("unexpected diagnostics for a valid configuration %#v", diags)
+	}
+	diags := p.TryConfigure(ctx, config(cty.StringVal("nowhere")))
+	if len(diags) != 1 || diags[0].Summary != "Invalid region" {
+		t.Errorf("wrong diagnostics for an invalid configuration %#v", diags)
+	}
+	if validates != 2 {
+		t.Errorf("provider validated the configuration %d times; want 2", validates)
+	}
+
+	// A value that doesn't conform to the schema can't be encoded, so the
+	// provider is never asked about it.
+	if diags := p.TryConfigure(ctx, config(cty.ListValEmpty(cty.String))); !diags.HasErrors() {
+		t.Errorf("TryConfigure succeeded with a non-conforming configuration; want an error")
+	}
+	if validates != 2 {
+		t.Errorf("provider validated a non-conforming configuration")
+	}
+
+	if configures != 0 {
+		t.Errorf("provider was configured %d times; want 0", configures)
+	}
+	if _, err := p.ManagedResourceType("test_thing"); err == nil {
+		t.Errorf("ManagedResourceType succeeded after TryConfigure; want an error")
+	}
+}
+
+func TestProviderConfigureFirstUse(t *testing.T) {
+	// The configuration state of a new provider must be ready to use
+	// without any further initialization, so none of these may panic.
+	client := &fakeClient{
+		getProviderSchema: func(context.Context, *tfplugin6.GetProviderSchema_Request) (*tfplugin6.GetProviderSchema_Response, error) {
+			return testSchemaResponse(), nil
+		},
+		configureProvider: func(context.Context, *tfplugin6.ConfigureProvider_Request) (*tfplugin6.ConfigureProvider_Response, error) {
+			return &tfplugin6.ConfigureProvider_Response{}, nil
+		},
+	}
+	p, err := NewProvider(context.Background(), nil, client, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	if _, err := p.ManagedResourceType("test_thing"); err == nil {
+		t.Error("ManagedResourceType succeeded before Configure; want an error")
+	}
+	diags := p.Configure(context.Background(), common.Config{
+		Value: cty.NullVal(p.ProviderConfigType()),
+	})
+	if diags.HasErrors() {
+		t.Fatalf("unexpected errors: %s", diags.Err())
+	}
+	if _, err := p.ManagedResourceType("test_thing"); err != nil {
+		t.Errorf("unexpected error after Configure: %s", err)
+	}
+}
+
+func TestProviderConfigureProbe(t *testing.T) {
+	// The provider reports that it was configured, but isn't ready for the
+	// first data source read.
+	ready := false
+	client := &fakeClient{
+		readDataSource: func(ctx context.Context, req *tfplugin6.ReadDataSource_Request) (*tfplugin6.ReadDataSource_Response, error) {
+			if !ready {
+				return &tfplugin6.ReadDataSource_Response{
+					Diagnostics: []*tfplugin6.Diagnostic{
+						{Severity: tfplugin6.Diagnostic_ERROR, Summary: "Client not initialized"},
+					},
+				}, nil
+			}
+			return &tfplugin6.ReadDataSource_Response{State: req.Config}, nil
+		},
+		configureProvider: func(context.Context, *tfplugin6.ConfigureProvider_Request) (*tfplugin6.ConfigureProvider_Response, error) {
+			return &tfplugin6.ConfigureProvider_Response{}, nil
+		},
+	}
+	probes := 0
+	opts := &common.Options{
+		ConfigureProbe: func(ctx context.Context, provider interface{}) common.Diagnostics {
+			probes++
+			rt, err := provider.(*Provider).DataResourceType("test_data")
+			if err != nil {
+				return common.ErrorDiagnostics("Probe failed", "The probe could not read test_data", err)
+			}
+			_, diags := rt.Read(ctx, common.DataResourceReadRequest{
+				Config: cty.ObjectVal(map[string]cty.Value{
+					"name":  cty.StringVal("probe"),
+					"value": cty.NullVal(cty.String),
+				}),
+			})
+			return diags
+		},
+	}
+	p := newTestProvider(t, client, opts)
+	config := common.Config{Value: cty.NullVal(p.ProviderConfigType())}
+
+	diags := p.Configure(context.Background(), config)
+	if len(diags) != 2 || diags[0].Summary != "Provider not ready after configuration" || diags[1].Summary != "Client not initialized" {
+		t.Fatalf("wrong diagnostics %#v", diags)
+	}
+	if probes != 1 {
+		t.Errorf("probe called %d times; want 1", probes)
+	}
+	if _, err := p.ManagedResourceType("test_thing"); err == nil {
+		t.Error("ManagedResourceType succeeded after a failed probe; want an error")
+	}
+
+	ready = true
+	if diags := p.Configure(context.Background(), config); diags.HasErrors() {
+		t.Fatalf("unexpected errors: %s", diags.Err())
+	}
+	if probes != 2 {
+		t.Errorf("probe called %d times; want 2", probes)
+	}
+	if _, err := p.ManagedResourceType("test_thing"); err != nil {
+		t.Errorf("unexpected error after a successful probe: %s", err)
+	}
+}
@@ -3,16 +3,19 @@ package protocol6
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/apparentlymart/terraform-schema-go/tfschema"
 	"github.com/zclconf/go-cty/cty"
 	ctyjson "github.com/zclconf/go-cty/cty/json"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/peer"
 
 	"github.com/apparentlymart/terraform-provider/internal/tfplugin6"
 	"github.com/apparentlymart/terraform-provider/tfprovider/internal/common"
 )
 
-func decodeProviderSchemaBlock(raw *tfplugin6.Schema_Block) *tfschema.Block {
+func decodeProviderSchemaBlock(raw *tfplugin6.Schema_Block, intern *common.InternTable) *tfschema.Block {
 	var ret tfschema.Block
 	if raw == nil {
 		return &ret
@@ -22,17 +25,11 @@ func decodeProviderSchemaBlock(raw *tfplugin6.Schema_Block) *tfschema.Block {
 	ret.BlockTypes = make(map[string]*tfschema.NestedBlock)
 
 	for _, rawAttr := range raw.Attributes {
-		rawType := rawAttr.Type
-		ty, err := ctyjson.UnmarshalType(rawType)
-		if err != nil {
-			// If the provider sends us an invalid type then we'll just
-			// replace it with dynamic, since the provider is misbehaving.
-			ty = cty.DynamicPseudoType
-		}
+		ty := decodeAttributeType(rawAttr, intern)
 
 		ret.Attributes[rawAttr.Name] = &tfschema.Attribute{
 			Type:        ty,
-			Description: rawAttr.Description,
+			Description: intern.String(rawAttr.Description),
 
 			Required:  rawAttr.Required,
 			Optional:  rawAttr.Optional,
@@ -56,7 +53,7 @@ func decodeProviderSchemaBlock(raw *tfplugin6.Schema_Block) *tfschema.Block {
 			mode = tfschema.NestingMap
 		}
 
-		content := decodeProviderSchemaBlock(rawBlock.Block)
+		content := decodeProviderSchemaBlock(rawBlock.Block, intern)
 
 		ret.BlockTypes[rawBlock.TypeName] = &tfschema.NestedBlock{
 			Nesting: mode,
@@ -67,49 +64,199 @@ func decodeProviderSchemaBlock(raw *tfplugin6.Schema_Block) *tfschema.Block {
 	return &ret
 }
 
-func loadSchema(ctx context.Context, client tfplugin6.ProviderClient) (*common.Schema, error) {
-	resp, err := client.GetProviderSchema(ctx, &tfplugin6.GetProviderSchema_Request{})
+// decodeAttributeType returns the type of the given attribute, which is
+// either given directly or implied by its nested attributes.
+func decodeAttributeType(rawAttr *tfplugin6.Schema_Attribute, intern *common.InternTable) cty.Type {
+	if rawAttr.NestedType != nil {
+		return decodeNestedAttributeType(rawAttr.NestedType, intern)
+	}
+
+	rawType := rawAttr.Type
+	ty, err := ctyjson.UnmarshalType(rawType)
 	if err != nil {
-		return nil, err
+		// If the provider sends us an invalid type then we'll just
+		// replace it with dynamic, since the provider is misbehaving.
+		ty = cty.DynamicPseudoType
+	}
+	return intern.Type(string(rawType), ty)
+}
+
+// decodeNestedAttributeType returns the type implied by a nested attribute
+// type, which is an object type for its attributes wrapped in a collection
+// type according to its nesting mode.
+func decodeNestedAttributeType(raw *tfplugin6.Schema_Object, intern *common.InternTable) cty.Type {
+	atys := make(map[string]cty.Type, len(raw.Attributes))
+	for _, rawAttr := range raw.Attributes {
+		atys[rawAttr.Name] = decodeAttributeType(rawAttr, intern)
+	}
+	ety := cty.Object(atys)
+
+	switch raw.Nesting {
+	case tfplugin6.Schema_Object_SINGLE:
+		return ety
+	case tfplugin6.Schema_Object_LIST:
+		// As with nested blocks, a list or map whose elements contain
+		// dynamically-typed attributes cannot be a collection, because each
+		// element may have a different type.
+		if ety.HasDynamicTypes() {
+			return cty.DynamicPseudoType
+		}
+		return cty.List(ety)
+	case tfplugin6.Schema_Object_SET:
+		return cty.Set(ety)
+	case tfplugin6.Schema_Object_MAP:
+		if ety.HasDynamicTypes() {
+			return cty.DynamicPseudoType
+		}
+		return cty.Map(ety)
+	default:
+		// The provider is misbehaving, so we'll accept any value.
+		return cty.DynamicPseudoType
 	}
-	diags := decodeDiagnostics(resp.Diagnostics)
+}
+
+// loadSchema retrieves and decodes the provider's schema. Any warnings the
+// provider returned along with a valid schema are returned as diagnostics,
+// while a failure to retrieve the schema at all is returned as an error.
+//
+// The request is retried according to opts.SchemaRetry, and the schema is
+// interned using opts.SchemaIntern, if set. If peer is non-nil, it is
+// populated with information about the connection the schema was retrieved
+// over.
+//
+// The raw response is also returned, for callers that need details of the
+// schema that the decoded form doesn't retain.
+func loadSchema(ctx context.Context, client tfplugin6.ProviderClient, opts *common.Options, peer *peer.Peer) (*common.Schema, *tfplugin6.GetProviderSchema_Response, common.Diagnostics, error) {
+	var callOpts []grpc.CallOption
+	if peer != nil {
+		callOpts = append(callOpts, grpc.Peer(peer))
+	}
+	var resp *tfplugin6.GetProviderSchema_Response
+	start := time.Now()
+	attempt := 0
+	err := opts.SchemaRetry.Retry(ctx, func() error {
+		attempt++
+		if attempt > 1 {
+			opts.LogDebug(ctx, "retrying provider schema request", "attempt", attempt)
+		}
+		var err error
+		resp, err = client.GetProviderSchema(ctx, &tfplugin6.GetProviderSchema_Request{}, callOpts...)
+		return err
+	})
+	if err != nil {
+		opts.LogDebug(ctx, "failed to load provider schema", "duration", time.Since(start), "attempts", attempt, "error", err)
+		return nil, nil, nil, err
+	}
+	diags := decodeDiagnostics(opts, resp.Diagnostics)
 	if diags.HasErrors() {
-		return nil, fmt.Errorf("failed to retrieve provider schema")
+		opts.LogDebug(ctx, "failed to load provider schema", "duration", time.Since(start), "attempts", attempt, "error", diags.Err())
+		return nil, nil, diags, fmt.Errorf("failed to retrieve provider schema: %s", diags.Err())
 	}
+	intern := opts.SchemaIntern
 	var ret common.Schema
-	ret.ProviderConfig = decodeProviderSchemaBlock(resp.Provider.Block)
-	ret.ProviderMeta = decodeProviderSchemaBlock(resp.ProviderMeta.Block)
+	ret.ProviderConfig = decodeProviderSchemaBlock(resp.GetProvider().GetBlock(), intern)
+	// Providers that don't use provider_meta at all may omit its schema
+	// entirely, in which case we leave ProviderMeta nil so that callers
+	// can distinguish that from a provider_meta block with no attributes.
+	if raw := resp.GetProviderMeta().GetBlock(); raw != nil {
+		ret.ProviderMeta = decodeProviderSchemaBlock(raw, intern)
+	}
+	var deprecatedManaged, deprecatedData []string
 	ret.ManagedResourceTypes = make(map[string]*common.ManagedResourceTypeSchema)
 	for name, raw := range resp.ResourceSchemas {
-		ret.ManagedResourceTypes[name] = &common.ManagedResourceTypeSchema{
-			Version: raw.Version,
-			Content: decodeProviderSchemaBlock(raw.Block),
+		ret.ManagedResourceTypes[name] = common.NewManagedResourceTypeSchema(
+			raw.Version,
+			decodeProviderSchemaBlock(raw.Block, intern),
+		)
+		if raw.GetBlock().GetDeprecated() {
+			deprecatedManaged = append(deprecatedManaged, name)
 		}
 	}
 	ret.DataResourceTypes = make(map[string]*common.DataResourceTypeSchema)
 	for name, raw := range resp.DataSourceSchemas {
-		ret.DataResourceTypes[name] = &common.DataResourceTypeSchema{
-			Content: decodeProviderSchemaBlock(raw.Block),
+		ret.DataResourceTypes[name] = common.NewDataResourceTypeSchema(
+			decodeProviderSchemaBlock(raw.Block, intern),
+		)
+		if raw.GetBlock().GetDeprecated() {
+			deprecatedData = append(deprecatedData, name)
 		}
 	}
-	return &ret, nil
+	diags = append(diags, common.DeprecationWarnings(
+		resp.GetProvider().GetBlock().GetDeprecated(),
+		deprecatedManaged, deprecatedData,
+	)...)
+	opts.LogDebug(ctx, "loaded provider schema",
+		"duration", time.Since(start),
+		"attempts", attempt,
+		"managed_resource_types", len(ret.ManagedResourceTypes),
+		"data_resource_types", len(ret.DataResourceTypes),
+	)
+	return &ret, resp, diags, nil
+}
+
+func encodeDynamicValue(opts *common.Options, typeName string, val cty.Value, schema common.ImpliedTyper) (*tfplugin6.DynamicValue, common.Diagnostics) {
+	data, diags := opts.EncodeDynamicValue(typeName, val, schema)
+	if diags.HasErrors() {
+		return nil, diags
+	}
+	return &tfplugin6.DynamicValue{
+		Json:    data.JSON,
+		Msgpack: data.Msgpack,
+	}, diags
 }
 
-func encodeDynamicValue(val cty.Value, schema *tfschema.Block) (*tfplugin6.DynamicValue, common.Diagnostics) {
-	data, diags := common.EncodeDynamicValue(val, schema)
+// encodeProviderMeta encodes the given provider_meta value, returning nil if
+// it is null. A non-null value is an error if the provider declared no
+// provider_meta schema, since there would be no way to encode it, or if it
+// doesn't conform to that schema.
+func encodeProviderMeta(opts *common.Options, val cty.Value, schema *tfschema.Block) (*tfplugin6.DynamicValue, common.Diagnostics) {
+	if val.IsNull() {
+		return nil, nil
+	}
+	if schema == nil {
+		return nil, common.Diagnostics{
+			{
+				Severity: common.Error,
+				Summary:  "Unexpected provider_meta value",
+				Detail:   "A provider_meta value was given, but this provider does not declare a provider_meta schema.",
+			},
+		}
+	}
+	data, diags := opts.ProviderMetaCache.Encode(val, func() (common.DynamicValueData, common.Diagnostics) {
+		if diags := common.CheckProviderMetaConformance(val, schema); diags.HasErrors() {
+			return common.DynamicValueData{}, diags
+		}
+		return opts.EncodeDynamicValue("", val, schema)
+	})
 	if diags.HasErrors() {
 		return nil, diags
 	}
 	return &tfplugin6.DynamicValue{
 		Json:    data.JSON,
 		Msgpack: data.Msgpack,
-	}, nil
+	}, diags
+}
+
+func decodeDynamicValue(opts *common.Options, raw *tfplugin6.DynamicValue, schema common.ImpliedTyper) (cty.Value, common.Diagnostics) {
+	data := common.DynamicValueData{
+		JSON:    raw.Json,
+		Msgpack: raw.Msgpack,
+	}
+	return opts.DecodeDynamicValue(data, schema)
+}
+
+func decodeDynamicValueLegacy(opts *common.Options, raw *tfplugin6.DynamicValue, schema common.ImpliedTyper) (cty.Value, common.Diagnostics) {
+	data := common.DynamicValueData{
+		JSON:    raw.Json,
+		Msgpack: raw.Msgpack,
+	}
+	return opts.DecodeDynamicValueLegacy(data, schema)
 }
 
-func decodeDynamicValue(raw *tfplugin6.DynamicValue, schema *tfschema.Block) (cty.Value, common.Diagnostics) {
+func visitDynamicValue(opts *common.Options, raw *tfplugin6.DynamicValue, schema common.ImpliedTyper, visit common.ValueVisitor) common.Diagnostics {
 	data := common.DynamicValueData{
 		JSON:    raw.Json,
 		Msgpack: raw.Msgpack,
 	}
-	return common.DecodeDynamicValue(data, schema)
+	return opts.VisitDynamicValue(data, schema, visit)
 }
@@ -0,0 +1,380 @@
+package protocol6
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/zclconf/go-cty/cty"
+
+	"github.com/apparentlymart/terraform-provider/internal/tfplugin6"
+	"github.com/apparentlymart/terraform-provider/tfprovider/internal/common"
+)
+
+// largeSchemaResponse returns a provider schema with many resource types
+// that share attribute types and descriptions, as is typical of large
+// providers.
+func largeSchemaResponse() *tfplugin6.GetProviderSchema_Response {
+	attrs := func() []*tfplugin6.Schema_Attribute {
+		var ret []*tfplugin6.Schema_Attribute
+		for i := 0; i < 40; i++ {
+			ret = append(ret, &tfplugin6.Schema_Attribute{
+				Name:        fmt.Sprintf("attr_%d", i),
+				Type:        []byte(`["map",["list","string"]]`),
+				Description: "A map of lists of strings, described at some length so that the description is worth deduplicating.",
+				Optional:    true,
+			})
+		}
+		ret = append(ret, &tfplugin6.Schema_Attribute{
+			Name:        "tags",
+			Type:        []byte(`["map","string"]`),
+			Description: "Tags to assign to the object.",
+			Optional:    true,
+		})
+		return ret
+	}
+	resp := &tfplugin6.GetProviderSchema_Response{
+		Provider:          &tfplugin6.Schema{Block: &tfplugin6.Schema_Block{}},
+		ResourceSchemas:   make(map[string]*tfplugin6.Schema),
+		DataSourceSchemas: make(map[string]*tfplugin6.Schema),
+	}
+	for i := 0; i < 200; i++ {
+		resp.ResourceSchemas[fmt.Sprintf("test_thing_%d", i)] = &tfplugin6.Schema{
+			Block: &tfplugin6.Schema_Block{
+				Attributes: attrs(),
+				BlockTypes: []*tfplugin6.Schema_NestedBlock{
+					{
+						TypeName: "timeouts",
+						Nesting:  tfplugin6.Schema_NestedBlock_SINGLE,
+						Block:    &tfplugin6.Schema_Block{Attributes: attrs()},
+					},
+				},
+			},
+		}
+		resp.DataSourceSchemas[fmt.Sprintf("test_data_%d", i)] = &tfplugin6.Schema{
+			Block: &tfplugin6.Schema_Block{Attributes: attrs()},
+		}
+	}
+	return resp
+}
+
+func TestLoadSchemaInterning(t *testing.T) {
+	raw, err := proto.Marshal(largeSchemaResponse())
+	if err != nil {
+		t.Fatal(err)
+	}
+	intern := common.NewInternTable(0)
+	opts := &common.Options{SchemaIntern: intern}
+
+	var schemas []*common.Schema
+	for i := 0; i < 2; i++ {
+		schema := loadRawSchema(t, raw, opts)
+		schemas = append(schemas, schema)
+	}
+
+	// Two attribute types and two descriptions are shared by every
+	// attribute of every resource type in both schemas.
+	if got, want := intern.Len(), 4; got != want {
+		t.Errorf("wrong number of interned values %d; want %d", got, want)
+	}
+	a := schemas[0].ManagedResourceTypes["test_thing_0"].Content.Attributes["tags"]
+	b := schemas[1].DataResourceTypes["test_data_199"].Content.Attributes["tags"]
+	if !a.Type.Equals(b.Type) || a.Description != b.Description {
+		t.Errorf("interned attributes differ\na: %#v\nb: %#v", a, b)
+	}
+
+	// Interning must not change the decoded result.
+	plain := loadRawSchema(t, raw, &common.Options{})
+	got := schemas[0].ManagedResourceTypes["test_thing_7"].Content.ImpliedType()
+	want := plain.ManagedResourceTypes["test_thing_7"].Content.ImpliedType()
+	if !got.Equals(want) {
+		t.Errorf("wrong type\ngot:  %#v\nwant: %#v", got, want)
+	}
+}
+
+func loadRawSchema(t testing.TB, raw []byte, opts *common.Options) *common.Schema {
+	t.Helper()
+	// Each schema is decoded from its own wire representation, as it would
+	// be when received from a separate provider process, so that nothing
+	// is shared between them unless it is interned.
+	resp := new(tfplugin6.GetProviderSchema_Response)
+	if err := proto.Unmarshal(raw, resp); err != nil {
+		t.Fatal(err)
+	}
+	client := &fakeClient{
+		getProviderSchema: func(context.Context, *tfplugin6.GetProviderSchema_Request) (*tfplugin6.GetProviderSchema_Response, error) {
+			return resp, nil
+		},
+	}
+	schema, _, _, err := loadSchema(context.Background(), client, opts, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return schema
+}
+
+// BenchmarkLoadSchema reports, as retained-B/op, the heap memory that each
+// decoded schema keeps alive, with and without an intern table shared
+// between them.
+func BenchmarkLoadSchema(b *testing.B) {
+	raw, err := proto.Marshal(largeSchemaResponse())
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Run("plain", func(b *testing.B) {
+		benchmarkLoadSchema(b, raw, &common.Options{})
+	})
+	b.Run("interned", func(b *testing.B) {
+		benchmarkLoadSchema(b, raw, &common.Options{
+			SchemaIntern: common.NewInternTable(0),
+		})
+	})
+}
+
+func benchmarkLoadSchema(b *testing.B, raw []byte, opts *common.Options) {
+	schemas := make([]*common.Schema, b.N)
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := range schemas {
+		schemas[i] = loadRawSchema(b, raw, opts)
+	}
+	b.StopTimer()
+	runtime.GC()
+	runtime.ReadMemStats(&after)
+	retained := int64(after.HeapAlloc) - int64(before.HeapAlloc)
+	b.ReportMetric(float64(retained)/float64(b.N), "retained-B/op")
+	runtime.KeepAlive(schemas)
+}
+
+// BenchmarkPlanLoop plans the same change repeatedly for a resource type
+// with a large schema, both with the implied type cached by loadSchema and
+// with a schema that recomputes it for each encode and decode.
+func BenchmarkPlanLoop(b *testing.B) {
+	schemaResp := largeSchemaResponse()
+	client := &fakeClient{
+		getProviderSchema: func(context.Context, *tfplugin6.GetProviderSchema_Request) (*tfplugin6.GetProviderSchema_Response, error) {
+			return schemaResp, nil
+		},
+		configureProvider: func(context.Context, *tfplugin6.ConfigureProvider_Request) (*tfplugin6.ConfigureProvider_Response, error) {
+			return &tfplugin6.ConfigureProvider_Response{}, nil
+		},
+		planResourceChange: func(ctx context.Context, req *tfplugin6.PlanResourceChange_Request) (*tfplugin6.PlanResourceChange_Response, error) {
+			return &tfplugin6.PlanResourceChange_Response{PlannedState: req.ProposedNewState}, nil
+		},
+	}
+	p, err := NewProvider(context.Background(), nil, client, nil)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer p.Close()
+	diags := p.Configure(context.Background(), common.Config{
+		Value: cty.EmptyObjectVal,
+	})
+	if diags.HasErrors() {
+		b.Fatal(diags.Err())
+	}
+
+	const typeName = "test_thing_0"
+	cached := p.schema.ManagedResourceTypes[typeName]
+	ty := cached.ImpliedType()
+	attrs := make(map[string]cty.Value)
+	for name, aty := range ty.AttributeTypes() {
+		attrs[name] = cty.NullVal(aty)
+	}
+	attrs["tags"] = cty.MapVal(map[string]cty.Value{"Name": cty.StringVal("example")})
+	proposed := cty.ObjectVal(attrs)
+	req := common.ManagedResourcePlanRequest{
+		PriorState:       cty.NullVal(ty),
+		ProposedNewState: proposed,
+		Config:           proposed,
+	}
+
+	run := func(b *testing.B, schema *common.ManagedResourceTypeSchema) {
+		rt := p.newManagedResourceType(typeName, schema)
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			if _, diags := rt.Plan(context.Background(), req); diags.HasErrors() {
+				b.Fatal(diags.Err())
+			}
+		}
+	}
+	b.Run("cached", func(b *testing.B) {
+		run(b, cached)
+	})
+	b.Run("uncached", func(b *testing.B) {
+		run(b, &common.ManagedResourceTypeSchema{
+			Version: cached.Version,
+			Content: cached.Content,
+		})
+	})
+}
+
+func TestDecodeNestedAttributeType(t *testing.T) {
+	attrs := []*tfplugin6.Schema_Attribute{
+		{Name: "port", Type: []byte(`"number"`), Required: true},
+		{Name: "protocol", Type: []byte(`"string"`), Optional: true},
+	}
+	ety := cty.Object(map[string]cty.Type{
+		"port":     cty.Number,
+		"protocol": cty.String,
+	})
+	dynamicAttrs := []*tfplugin6.Schema_Attribute{
+		{Name: "value", Type: []byte(`"dynamic"`), Optional: true},
+	}
+	dynamicEty := cty.Object(map[string]cty.Type{
+		"value": cty.DynamicPseudoType,
+	})
+
+	tests := map[string]struct {
+		raw  *tfplugin6.Schema_Object
+		want cty.Type
+	}{
+		"single": {
+			&tfplugin6.Schema_Object{Attributes: attrs, Nesting: tfplugin6.Schema_Object_SINGLE},
+			ety,
+		},
+		"list": {
+			&tfplugin6.Schema_Object{Attributes: attrs, Nesting: tfplugin6.Schema_Object_LIST},
+			cty.List(ety),
+		},
+		"set": {
+			&tfplugin6.Schema_Object{Attributes: attrs, Nesting: tfplugin6.Schema_Object_SET},
+			cty.Set(ety),
+		},
+		"map": {
+			&tfplugin6.Schema_Object{Attributes: attrs, Nesting: tfplugin6.Schema_Object_MAP},
+			cty.Map(ety),
+		},
+		"single with dynamic": {
+			&tfplugin6.Schema_Object{Attributes: dynamicAttrs, Nesting: tfplugin6.Schema_Object_SINGLE},
+			dynamicEty,
+		},
+		"list with dynamic": {
+			&tfplugin6.Schema_Object{Attributes: dynamicAttrs, Nesting: tfplugin6.Schema_Object_LIST},
+			cty.DynamicPseudoType,
+		},
+		"map with dynamic": {
+			&tfplugin6.Schema_Object{Attributes: dynamicAttrs, Nesting: tfplugin6.Schema_Object_MAP},
+			cty.DynamicPseudoType,
+		},
+		"nested": {
+			&tfplugin6.Schema_Object{
+				Attributes: []*tfplugin6.Schema_Attribute{
+					{
+						Name: "rule",
+						NestedType: &tfplugin6.Schema_Object{
+							Attributes: attrs,
+							Nesting:    tfplugin6.Schema_Object_SET,
+						},
+						Optional: true,
+					},
+				},
+				Nesting: tfplugin6.Schema_Object_LIST,
+			},
+			cty.List(cty.Object(map[string]cty.Type{
+				"rule": cty.Set(ety),
+			})),
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			block := decodeProviderSchemaBlock(&tfplugin6.Schema_Block{
+				Attributes: []*tfplugin6.Schema_Attribute{
+					{Name: "nested", NestedType: test.raw, Optional: true},
+				},
+			}, nil)
+			got := block.Attributes["nested"].Type
+			if !got.Equals(test.want) {
+				t.Errorf("wrong type\ngot:  %#v\nwant: %#v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestDataResourceTypeNestedAttribute(t *testing.T) {
+	ruleType := cty.Object(map[string]cty.Type{
+		"port":     cty.Number,
+		"protocol": cty.String,
+	})
+	dataType := cty.Object(map[string]cty.Type{
+		"name":  cty.String,
+		"rules": cty.List(ruleType),
+	})
+	client := &fakeClient{
+		getProviderSchema: func(context.Context, *tfplugin6.GetProviderSchema_Request) (*tfplugin6.GetProviderSchema_Response, error) {
+			resp := testSchemaResponse()
+			resp.DataSourceSchemas["test_rules"] = &tfplugin6.Schema{
+				Block: &tfplugin6.Schema_Block{
+					Attributes: []*tfplugin6.Schema_Attribute{
+						{Name: "name", Type: []byte(`"string"`), Required: true},
+						{
+							Name: "rules",
+							NestedType: &tfplugin6.Schema_Object{
+								Attributes: []*tfplugin6.Schema_Attribute{
+									{Name: "port", Type: []byte(`"number"`), Computed: true},
+									{Name: "protocol", Type: []byte(`"string"`), Computed: true},
+								},
+								Nesting: tfplugin6.Schema_Object_LIST,
+							},
+							Computed: true,
+						},
+					},
+				},
+			}
+			return resp, nil
+		},
+		readDataSource: func(ctx context.Context, req *tfplugin6.ReadDataSource_Request) (*tfplugin6.ReadDataSource_Response, error) {
+			config := decodeTestDynamicValue(t, req.Config, dataType)
+			return &tfplugin6.ReadDataSource_Response{
+				State: testDynamicValue(t, cty.ObjectVal(map[string]cty.Value{
+					"name": config.GetAttr("name"),
+					"rules": cty.ListVal([]cty.Value{
+						cty.ObjectVal(map[string]cty.Value{
+							"port":     cty.NumberIntVal(443),
+							"protocol": cty.StringVal("tcp"),
+						}),
+					}),
+				})),
+			}, nil
+		},
+	}
+	p := configuredTestProvider(t, client, nil)
+
+	schema := p.schema.DataResourceTypes["test_rules"]
+	if got := schema.ImpliedType(); !got.Equals(dataType) {
+		t.Fatalf("wrong type\ngot:  %#v\nwant: %#v", got, dataType)
+	}
+
+	rt, err := p.DataResourceType("test_rules")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, diags := rt.Read(context.Background(), common.DataResourceReadRequest{
+		Config: cty.ObjectVal(map[string]cty.Value{
+			"name":  cty.StringVal("web"),
+			"rules": cty.NullVal(cty.List(ruleType)),
+		}),
+	})
+	if diags.HasErrors() {
+		t.Fatalf("unexpected errors: %s", diags.Err())
+	}
+	want := cty.ObjectVal(map[string]cty.Value{
+		"name": cty.StringVal("web"),
+		"rules": cty.ListVal([]cty.Value{
+			cty.ObjectVal(map[string]cty.Value{
+				"port":     cty.NumberIntVal(443),
+				"protocol": cty.StringVal("tcp"),
+			}),
+		}),
+	})
+	if !resp.State.RawEquals(want) {
+		t.Errorf("wrong state\ngot:  %#v\nwant: %#v", resp.State, want)
+	}
+}
@@ -0,0 +1,85 @@
+package tfprovider
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"testing"
+)
+
+// recordingHandler is a slog.Handler that retains every record it handles.
+type recordingHandler struct {
+	mu      sync.Mutex
+	records []slog.Record
+}
+
+func (h *recordingHandler) Enabled(context.Context, slog.Level) bool {
+	return true
+}
+
+func (h *recordingHandler) Handle(ctx context.Context, r slog.Record) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.records = append(h.records, r.Clone())
+	return nil
+}
+
+func (h *recordingHandler) WithAttrs([]slog.Attr) slog.Handler { return h }
+func (h *recordingHandler) WithGroup(string) slog.Handler      { return h }
+
+// find returns the attributes of the first record with the given message
+// whose attributes include all of the given ones, or nil if there is none.
+func (h *recordingHandler) find(msg string, match map[string]string) map[string]slog.Value {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+Records:
+	for _, r := range h.records {
+		if r.Message != msg {
+			continue
+		}
+		attrs := make(map[string]slog.Value)
+		r.Attrs(func(a slog.Attr) bool {
+			attrs[a.Key] = a.Value
+			return true
+		})
+		for k, v := range match {
+			if got, ok := attrs[k]; !ok || got.String() != v {
+				continue Records
+			}
+		}
+		return attrs
+	}
+	return nil
+}
+
+func TestSlogLogger(t *testing.T) {
+	handler := &recordingHandler{}
+	provider := newTestProvider6(t, &fakeServer6{}, WithSlogLogger(slog.New(handler)))
+
+	if _, diags := readFakeData(t, provider, "Ada"); diags.HasErrors() {
+		t.Fatalf("unexpected errors: %s", diags.Err())
+	}
+
+	attrs := handler.find("provider RPC call", map[string]string{
+		"method":    "ReadDataSource",
+		"type_name": "fake_data",
+	})
+	if attrs == nil {
+		t.Fatalf("no log record for the ReadDataSource call in %d records", len(handler.records))
+	}
+	if d, ok := attrs["duration"]; !ok || d.Kind() != slog.KindDuration {
+		t.Errorf("wrong duration %v", d)
+	}
+	if err, ok := attrs["error"]; ok {
+		t.Errorf("successful call logged with error %s", err)
+	}
+
+	attrs = handler.find("loaded provider schema", map[string]string{
+		"attempts":               "1",
+		"managed_resource_types": "1",
+		"data_resource_types":    "1",
+	})
+	if attrs == nil {
+		t.Errorf("no log record for loading the schema")
+	}
+}
@@ -0,0 +1,81 @@
+package tfprovider
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+// countingCollector is a MetricsCollector that counts the calls it
+// observes, keyed by method and type name.
+type countingCollector struct {
+	mu        sync.Mutex
+	rpcs      map[string]int
+	durations map[string]int
+	errors    map[string]int
+}
+
+func newCountingCollector() *countingCollector {
+	return &countingCollector{
+		rpcs:      make(map[string]int),
+		durations: make(map[string]int),
+		errors:    make(map[string]int),
+	}
+}
+
+func (c *countingCollector) IncRPC(method, typeName string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.rpcs[method+" "+typeName]++
+}
+
+func (c *countingCollector) ObserveDuration(method, typeName string, d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.durations[method+" "+typeName]++
+}
+
+func (c *countingCollector) IncError(method, typeName string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.errors[method+" "+typeName]++
+}
+
+func TestWithMetrics(t *testing.T) {
+	collector := newCountingCollector()
+	provider := newTestProvider6(t, &fakeServer6{}, WithMetrics(collector))
+
+	if _, diags := readFakeData(t, provider, "Ada"); diags.HasErrors() {
+		t.Fatalf("unexpected errors: %s", diags.Err())
+	}
+	const read = "ReadDataSource fake_data"
+	if got := collector.rpcs[read]; got != 1 {
+		t.Errorf("counted %d %s calls; want 1", got, read)
+	}
+	if got := collector.durations[read]; got != 1 {
+		t.Errorf("observed %d durations for %s; want 1", got, read)
+	}
+	if got := collector.errors[read]; got != 0 {
+		t.Errorf("counted %d errors for %s; want 0", got, read)
+	}
+	if got := collector.rpcs["ConfigureProvider "]; got != 1 {
+		t.Errorf("counted %d ConfigureProvider calls; want 1", got)
+	}
+
+	// The fake server doesn't implement imports, so the call fails.
+	rt, err := provider.ManagedResourceType("fake_thing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, diags := rt.Import(context.Background(), ManagedResourceImportRequest{ID: "abc"}); !diags.HasErrors() {
+		t.Fatal("import succeeded; want an error")
+	}
+	const imp = "ImportResourceState fake_thing"
+	if got := collector.rpcs[imp]; got != 1 {
+		t.Errorf("counted %d %s calls; want 1", got, imp)
+	}
+	if got := collector.errors[imp]; got != 1 {
+		t.Errorf("counted %d errors for %s; want 1", got, imp)
+	}
+}
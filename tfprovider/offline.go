@@ -0,0 +1,21 @@
+package tfprovider
+
+import (
+	"github.com/apparentlymart/terraform-provider/tfprovider/internal/protocol6"
+)
+
+// Offline returns a provider that has the given schema but no plugin behind
+// it, for callers that need only schema-driven behavior such as
+// constructing and encoding values of the provider's types.
+//
+// Methods that depend only on the schema, such as Schema and
+// ProviderConfigType, work as normal, as does PrepareConfig, which for
+// protocol version 6 only checks that the configuration can be encoded.
+// Methods that would need to call into a provider plugin, such as
+// TryConfigure and Configure, return error diagnostics explaining that the
+// provider is offline.
+//
+// The given schema must not be modified while the provider is in use.
+func Offline(schema *Schema, opts ...Option) Provider {
+	return protocol6.NewOfflineProvider(schema, newOptions(opts))
+}
@@ -0,0 +1,85 @@
+package tfprovider
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/apparentlymart/terraform-schema-go/tfschema"
+	"github.com/zclconf/go-cty/cty"
+)
+
+func TestOffline(t *testing.T) {
+	ctx := context.Background()
+	configSchema := &tfschema.Block{
+		Attributes: map[string]*tfschema.Attribute{
+			"region": {Type: cty.String, Optional: true},
+		},
+	}
+	schema := NewSchemaBuilder().
+		ProviderConfig(configSchema).
+		AddManagedResource("test_thing", 3, &tfschema.Block{
+			Attributes: map[string]*tfschema.Attribute{
+				"id": {Type: cty.String, Computed: true},
+			},
+		}).
+		Build()
+	p := Offline(schema)
+	defer p.Close()
+
+	if got, diags := p.Schema(ctx); got != schema || len(diags) != 0 {
+		t.Errorf("wrong schema %#v (diagnostics %#v)", got, diags)
+	}
+	if got, want := p.ProviderConfigType(), configSchema.ImpliedType(); !got.Equals(want) {
+		t.Errorf("wrong config type %#v; want %#v", got, want)
+	}
+	if got, err := p.ManagedResourceSchemaVersion("test_thing"); err != nil || got != 3 {
+		t.Errorf("wrong schema version %d (error %v); want 3", got, err)
+	}
+
+	// Preparing a protocol 6 configuration only encodes it, which works
+	// offline, and catches values that don't conform to the schema.
+	config := cty.ObjectVal(map[string]cty.Value{
+		"region": cty.StringVal("us-west-2"),
+	})
+	prepared, diags := p.PrepareConfig(ctx, config)
+	if diags.HasErrors() {
+		t.Fatalf("unexpected errors from PrepareConfig: %s", diags.Err())
+	}
+	if _, diags := p.PrepareConfig(ctx, cty.StringVal("nope")); !diags.HasErrors() {
+		t.Errorf("PrepareConfig succeeded with an invalid configuration; want an error")
+	}
+
+	diags = p.Configure(ctx, prepared)
+	if !diags.HasErrors() {
+		t.Fatal("Configure succeeded offline; want an error")
+	}
+	if msg := diags.Err().Error(); !strings.Contains(msg, "offline provider") {
+		t.Errorf("error does not mention that the provider is offline: %s", msg)
+	}
+
+	// A failed Configure leaves the provider unconfigured.
+	if _, err := p.ManagedResourceType("test_thing"); err == nil {
+		t.Errorf("ManagedResourceType succeeded on an unconfigured provider; want an error")
+	}
+}
+
+func TestOfflineProvidersSchemaJSON(t *testing.T) {
+	schema := NewSchemaBuilder().Build()
+
+	p := Offline(schema)
+	defer p.Close()
+	if _, err := p.ProvidersSchemaJSON(); err == nil {
+		t.Error("ProvidersSchemaJSON succeeded without a provider address; want an error")
+	}
+
+	p = Offline(schema, WithProviderAddress("registry.terraform.io/example/test"))
+	defer p.Close()
+	src, err := p.ProvidersSchemaJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(src), `"registry.terraform.io/example/test":`) {
+		t.Errorf("result does not describe the provider's address: %s", src)
+	}
+}
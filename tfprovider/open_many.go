@@ -0,0 +1,101 @@
+package tfprovider
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// OpenMany starts each of the given provider plugin executables and loads
+// their schemas, running up to the given number of them concurrently.
+//
+// The result maps each path that started successfully to its provider. If
+// any of the providers fail to start then the returned error is of type
+// StartErrors, describing the failure for each affected path; a failure of
+// one provider does not prevent the others from starting. The caller is
+// responsible for closing all of the returned providers.
+//
+// A path given more than once is started only once. If concurrency is less
+// than one then the providers are started one at a time. The given options
+// apply to all of the providers, so options that are specific to a single
+// provider, such as WithRecorder, should not be used here.
+func OpenMany(ctx context.Context, paths []string, concurrency int, opts ...Option) (map[string]Provider, error) {
+	return openMany(ctx, paths, concurrency, func(ctx context.Context, path string) (Provider, error) {
+		return StartWithOptions(ctx, path, nil, opts...)
+	})
+}
+
+// openMany is the implementation of OpenMany, using the given function to
+// start each provider.
+func openMany(ctx context.Context, paths []string, concurrency int, start func(ctx context.Context, path string) (Provider, error)) (map[string]Provider, error) {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	providers := make(map[string]Provider, len(paths))
+	errs := make(StartErrors)
+	sem := make(chan struct{}, concurrency)
+
+	// Each path is started only once even if it's given more than once,
+	// since the result can hold only one provider for each path.
+	seen := make(map[string]struct{}, len(paths))
+	for _, path := range paths {
+		if _, exists := seen[path]; exists {
+			continue
+		}
+		seen[path] = struct{}{}
+
+		wg.Add(1)
+		go func(path string) {
+			defer wg.Done()
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+			case <-ctx.Done():
+				mu.Lock()
+				errs[path] = ctx.Err()
+				mu.Unlock()
+				return
+			}
+
+			provider, err := start(ctx, path)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				errs[path] = err
+				return
+			}
+			providers[path] = provider
+		}(path)
+	}
+	wg.Wait()
+
+	if len(errs) != 0 {
+		return providers, errs
+	}
+	return providers, nil
+}
+
+// StartErrors is the error type returned by OpenMany when one or more of
+// the requested providers could not be started, mapping each affected
+// executable path to its error.
+type StartErrors map[string]error
+
+func (errs StartErrors) Error() string {
+	paths := make([]string, 0, len(errs))
+	for path := range errs {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	var buf strings.Builder
+	fmt.Fprintf(&buf, "failed to start %d provider(s):", len(errs))
+	for _, path := range paths {
+		fmt.Fprintf(&buf, "\n- %s: %s", path, errs[path])
+	}
+	return buf.String()
+}
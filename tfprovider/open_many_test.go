@@ -0,0 +1,116 @@
+package tfprovider
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestOpenMany(t *testing.T) {
+	var mu sync.Mutex
+	starts := make(map[string]int)
+	inFlight, maxInFlight := 0, 0
+	start := func(ctx context.Context, path string) (Provider, error) {
+		mu.Lock()
+		starts[path]++
+		inFlight++
+		if inFlight > maxInFlight {
+			maxInFlight = inFlight
+		}
+		mu.Unlock()
+
+		// Give the other starts a chance to overlap with this one.
+		time.Sleep(10 * time.Millisecond)
+
+		mu.Lock()
+		inFlight--
+		mu.Unlock()
+		if strings.HasPrefix(path, "bad") {
+			return nil, errors.New("exec format error")
+		}
+		return Offline(&Schema{}), nil
+	}
+
+	paths := []string{"good1", "bad1", "good2", "good1", "bad2", "good3", "bad1"}
+	providers, err := openMany(context.Background(), paths, 2, start)
+	for _, p := range providers {
+		p.Close()
+	}
+
+	for path, count := range starts {
+		if count != 1 {
+			t.Errorf("%s started %d times; want 1", path, count)
+		}
+	}
+	if len(starts) != 5 {
+		t.Errorf("started %d distinct paths; want 5", len(starts))
+	}
+	if maxInFlight > 2 {
+		t.Errorf("%d providers started at once; want at most 2", maxInFlight)
+	}
+
+	for _, path := range []string{"good1", "good2", "good3"} {
+		if providers[path] == nil {
+			t.Errorf("no provider for %s", path)
+		}
+	}
+	if len(providers) != 3 {
+		t.Errorf("got %d providers; want 3", len(providers))
+	}
+
+	var errs StartErrors
+	if !errors.As(err, &errs) {
+		t.Fatalf("wrong error type %T; want StartErrors", err)
+	}
+	if len(errs) != 2 || errs["bad1"] == nil || errs["bad2"] == nil {
+		t.Errorf("wrong per-path errors %#v; want errors for bad1 and bad2", errs)
+	}
+	want := "failed to start 2 provider(s):\n- bad1: exec format error\n- bad2: exec format error"
+	if got := err.Error(); got != want {
+		t.Errorf("wrong error message\ngot:  %s\nwant: %s", got, want)
+	}
+}
+
+func TestOpenManyCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Whichever path starts first cancels the context while it holds the
+	// only slot, so the other path is abandoned without being started.
+	var mu sync.Mutex
+	var started []string
+	start := func(ctx context.Context, path string) (Provider, error) {
+		mu.Lock()
+		started = append(started, path)
+		mu.Unlock()
+		cancel()
+		time.Sleep(10 * time.Millisecond)
+		return Offline(&Schema{}), nil
+	}
+	providers, err := openMany(ctx, []string{"a", "b"}, 1, start)
+	for _, p := range providers {
+		p.Close()
+	}
+
+	if len(started) != 1 {
+		t.Fatalf("started %#v; want exactly one path", started)
+	}
+	if providers[started[0]] == nil {
+		t.Errorf("no provider for %s, which started", started[0])
+	}
+	var errs StartErrors
+	if !errors.As(err, &errs) {
+		t.Fatalf("wrong error type %T; want StartErrors", err)
+	}
+	if len(errs) != 1 {
+		t.Fatalf("wrong errors %#v; want one for the abandoned path", errs)
+	}
+	for path, err := range errs {
+		if path == started[0] || err != context.Canceled {
+			t.Errorf("wrong error for %s: %v", path, err)
+		}
+	}
+}
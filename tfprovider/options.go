@@ -0,0 +1,493 @@
+package tfprovider
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"time"
+
+	"github.com/apparentlymart/terraform-schema-go/tfschema"
+	"github.com/zclconf/go-cty/cty"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
+
+	"github.com/apparentlymart/terraform-provider/tfprovider/internal/common"
+)
+
+// Option is the type of the functional options accepted by StartWithOptions,
+// Replay, and Offline, each of which customizes one aspect of the behavior
+// of the resulting provider.
+type Option func(*common.Options)
+
+// newOptions applies the given options to produce the settings for a new
+// provider, including the interceptors that implement the options that
+// apply to every RPC, in the order they wrap each call.
+//
+// Every function that creates a provider must use this, so that all of
+// the options are honored regardless of how the provider was created.
+func newOptions(opts []Option) *common.Options {
+	o := &common.Options{}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	if len(o.TypeConcurrencyLimits) != 0 {
+		o.Interceptors = append(o.Interceptors, common.TypeConcurrencyInterceptor(o.TypeConcurrencyLimits))
+	}
+	if o.Metrics != nil {
+		o.Interceptors = append(o.Interceptors, common.MetricsInterceptor(o.Metrics))
+	}
+	if o.RPCTimeout > 0 || len(o.OperationTimeouts) != 0 {
+		o.Interceptors = append(o.Interceptors, common.TimeoutInterceptor(o.RPCTimeout, o.OperationTimeouts))
+	}
+	if o.Logger != nil {
+		o.Interceptors = append(o.Interceptors, common.LoggingInterceptor(o.Logger))
+	}
+	return o
+}
+
+// WithRecorder causes every RPC request and response exchanged with the
+// provider plugin to be recorded into the file at the given path, which
+// will be created or overwritten.
+//
+// The resulting recording can be passed to Replay to create a provider that
+// answers requests using the recorded responses, without a live plugin.
+func WithRecorder(path string) Option {
+	return func(o *common.Options) {
+		o.RecordPath = path
+	}
+}
+
+// WithDecodeFormat causes the provider to accept responses from the plugin
+// only if they use the given serialization format, returning error
+// diagnostics for any response that uses the other format.
+//
+// The default is FormatAny, which accepts either format.
+func WithDecodeFormat(format Format) Option {
+	return func(o *common.Options) {
+		o.DecodeFormat = format
+	}
+}
+
+// WithPrivateSchemaVersion enables an advisory check that produces warnings
+// when the private data passed to Read, Plan, or Apply is accompanied by a
+// PrivateSchemaVersion that doesn't match the resource type's current schema
+// version, which suggests that the private data was produced by a different
+// version of the provider and may not be interpreted correctly.
+//
+// Responses always report the schema version their private data was
+// produced under, regardless of this option, so that callers can store it.
+func WithPrivateSchemaVersion() Option {
+	return func(o *common.Options) {
+		o.CheckPrivateSchemaVersion = true
+	}
+}
+
+// WithSchemaInterning causes the provider's schema to be decoded using the
+// given intern table, so that attribute types and description strings that
+// are identical to ones already in the table share memory with them.
+//
+// Passing the same table to many providers reduces the memory used to hold
+// their schemas when they have many attributes in common. Schemas are not
+// interned unless this option is used.
+func WithSchemaInterning(table *InternTable) Option {
+	return func(o *common.Options) {
+		o.SchemaIntern = table
+	}
+}
+
+// WithSchemaLoadRetry causes the request for the provider's schema, made
+// while starting the provider, to be retried according to the given policy
+// if it fails with a transient error.
+//
+// Retrieving the schema has no side-effects, so it is safe to retry
+// generously. This option does not affect any other operations, which are
+// never retried.
+func WithSchemaLoadRetry(policy RetryPolicy) Option {
+	return func(o *common.Options) {
+		o.SchemaRetry = &policy
+	}
+}
+
+// WithImportRetry causes imports that fail with an error diagnostic for which
+// the given function returns true to be retried according to the given
+// policy, such as when importing a newly-created remote object that the
+// provider cannot find until the object's creation has propagated. The
+// result of the final attempt is returned.
+//
+// The policy's delay and attempt limits apply as for WithSchemaLoadRetry,
+// but only the diagnostics the given function selects are retried: the
+// transient RPC errors that WithSchemaLoadRetry retries are not. This
+// option does not affect any other operations.
+func WithImportRetry(policy RetryPolicy, retryable func(Diagnostic) bool) Option {
+	return func(o *common.Options) {
+		o.ImportRetry = &policy
+		o.ImportRetryable = retryable
+	}
+}
+
+// WithSourceTag causes every diagnostic returned by the provider plugin to
+// have its Source field set to the given name, so that callers combining
+// diagnostics from many providers can tell which provider emitted each one.
+//
+// The name can be anything meaningful to the caller, such as the provider's
+// source address and version. By default diagnostics have no source.
+func WithSourceTag(name string) Option {
+	return func(o *common.Options) {
+		o.SourceTag = name
+	}
+}
+
+// WithHooks causes the given hooks to be notified before and after each
+// plan and apply operation on the provider's managed resource types.
+func WithHooks(hooks Hooks) Option {
+	return func(o *common.Options) {
+		o.Hooks = hooks
+	}
+}
+
+// WithConnectionStateCallback causes the given function to be called each
+// time the state of the gRPC connection to the provider plugin changes,
+// such as when it becomes ready, fails transiently, or shuts down.
+//
+// The function is called from a separate goroutine, which exits when the
+// provider is closed.
+func WithConnectionStateCallback(cb func(connectivity.State)) Option {
+	return func(o *common.Options) {
+		o.ConnectionStateCallback = cb
+	}
+}
+
+// WithPlanRequestValidation enables local validation of each plan request
+// before it is sent to the provider, checking that the prior state,
+// proposed new state, and configuration all conform to the resource type's
+// schema and are consistent with one another.
+//
+// This produces clearer errors for mistakes in the caller's own logic, at
+// the expense of some extra work for each request.
+func WithPlanRequestValidation() Option {
+	return func(o *common.Options) {
+		o.ValidatePlanRequests = true
+	}
+}
+
+// WithStderr causes everything the provider plugin writes to its stderr
+// stream to be written to the given writer.
+//
+// With this option, if the plugin panics then the panic message and stack
+// trace are also included in the error diagnostics for the request that
+// was in progress, in place of a bare report that the connection was lost.
+func WithStderr(w io.Writer) Option {
+	return func(o *common.Options) {
+		o.Stderr = w
+	}
+}
+
+// WithSlogLogger causes the provider to emit structured debug logs to the
+// given logger, including a record for each RPC call to the provider plugin
+// and records describing the loading of the provider's schema and any
+// retries of that request.
+//
+// RPC call records have the attributes "method" and "duration", along with
+// "type_name" for requests relating to a particular resource type and
+// "error" if the call failed. By default nothing is logged.
+func WithSlogLogger(logger *slog.Logger) Option {
+	return func(o *common.Options) {
+		o.Logger = logger
+	}
+}
+
+// WithResourceDiagnosticContext causes each diagnostic returned by the
+// provider plugin from an operation on a managed resource type to have its
+// Resource field set to the resource type name and the InstanceKey given in
+// the request, so that diagnostics aggregated from many resources describe
+// which resource they belong to.
+//
+// The summary and detail of each diagnostic are left unchanged.
+func WithResourceDiagnosticContext() Option {
+	return func(o *common.Options) {
+		o.ResourceDiagnosticContext = true
+	}
+}
+
+// WithPlannedPrivateCheck enables a debugging check that the private data
+// given to each apply operation is identical to the private data returned
+// by the plan for the same resource instance, as the protocol requires.
+// An apply with different private data fails with an error diagnostic
+// without calling the provider.
+//
+// Plans are matched with applies using the resource type name and the
+// InstanceKey field of the requests. An apply whose plan was not made
+// through the same provider instance is not checked.
+func WithPlannedPrivateCheck() Option {
+	return func(o *common.Options) {
+		o.PlannedPrivate = common.NewPlannedPrivateTracker()
+	}
+}
+
+// WithConfigRules registers rules constraining the combinations of
+// attributes that may be set in configurations for the resource type of the
+// given mode and name, such as ExactlyOneOf. ValidateConfig checks each
+// configuration against the rules before sending it to the provider, and
+// returns the rules' diagnostics without calling the provider if any rule
+// is violated.
+//
+// The option may be used more than once to register rules for several
+// resource types.
+func WithConfigRules(mode ResourceMode, typeName string, rules ...ConfigRule) Option {
+	return func(o *common.Options) {
+		o.AddConfigRules(mode, typeName, rules...)
+	}
+}
+
+// WithValueSanitizer registers a function that is called with every value
+// sent to the provider, along with the schema the value conforms to, just
+// before the value is encoded. The value the function returns is sent in
+// its place, which allows normalizing values for providers that are strict
+// about details such as the difference between an empty string and null.
+//
+// The function must return a value that still conforms to the schema, or
+// else encoding fails with an error diagnostic. It is also called for
+// values that are only encoded to check their validity locally, such as by
+// ConfigurePlanOnly.
+func WithValueSanitizer(sanitize func(cty.Value, *tfschema.Block) cty.Value) Option {
+	return func(o *common.Options) {
+		o.ValueSanitizer = sanitize
+	}
+}
+
+// WithContextPropagator registers a function that is called with the
+// context of each RPC call to the provider plugin, returning values to send
+// to the plugin as gRPC request metadata. This allows propagating
+// information carried in the context, such as distributed tracing headers,
+// into providers that understand it.
+//
+// The option may be used more than once, in which case the metadata from
+// all of the functions is sent.
+func WithContextPropagator(propagate func(ctx context.Context) map[string]string) Option {
+	return func(o *common.Options) {
+		o.Interceptors = append(o.Interceptors, common.ContextPropagationInterceptor(propagate))
+	}
+}
+
+// WithDiagnosticDedup causes exact duplicates to be removed from the
+// diagnostics returned by each call to the provider plugin, as described
+// by Diagnostics.Dedup. This is useful with providers that report the same
+// problem more than once, such as those combining several providers into
+// one.
+func WithDiagnosticDedup() Option {
+	return func(o *common.Options) {
+		o.DedupDiagnostics = true
+	}
+}
+
+// WithTypeEncodingOverride causes values sent to the provider for the
+// resource types named in the given map to be serialized in the format
+// given for each, rather than the default msgpack format. This can work
+// around bugs in providers that mishandle one of the formats for particular
+// resource types. The overrides apply to both managed and data resource
+// types of the given names.
+//
+// JSON cannot represent unknown values, so a request for a type that is
+// overridden to use FormatJSON fails with an error diagnostic if any of its
+// values contain unknown values, as planned states often do.
+func WithTypeEncodingOverride(formats map[string]Format) Option {
+	return func(o *common.Options) {
+		if o.TypeEncodingOverrides == nil {
+			o.TypeEncodingOverrides = make(map[string]Format, len(formats))
+		}
+		for typeName, format := range formats {
+			o.TypeEncodingOverrides[typeName] = format
+		}
+	}
+}
+
+// WithProviderMetaCache causes the provider to remember the encoding of the
+// most recent provider_meta value it was given, and to reuse that encoding
+// for later requests with an identical provider_meta value rather than
+// encoding it again. This reduces the work done by callers that perform
+// many operations with the same provider_meta value, such as applying
+// changes to many resources in the same module.
+func WithProviderMetaCache() Option {
+	return func(o *common.Options) {
+		o.ProviderMetaCache = common.NewEncodeCache()
+	}
+}
+
+// WithSecretResolver registers a function that resolves references to
+// secrets held in an external secret store. When the provider is
+// configured, each known string value in the configuration that starts with
+// the given scheme followed by a colon, such as "vault:secret/db#password"
+// for the scheme "vault", is passed to the function with the scheme and
+// colon removed, and the secret value it returns is sent to the provider in
+// its place. This keeps secrets out of the configuration value held by the
+// caller.
+//
+// TryConfigure also resolves the references, to report any that cannot be
+// resolved, but PrepareConfig leaves them in place.
+//
+// The option may be used more than once to register resolvers for several
+// schemes. The resolved secrets are sent to the provider, and so will be
+// included in any recording made with WithRecorder.
+func WithSecretResolver(scheme string, resolve func(ref string) (string, error)) Option {
+	return func(o *common.Options) {
+		o.SecretResolvers = append(o.SecretResolvers, common.SecretResolver{
+			Scheme:  scheme,
+			Resolve: resolve,
+		})
+	}
+}
+
+// WithPanicRecovery controls whether a panic while handling the values for
+// a read, plan, apply, or import operation is recovered and returned as an
+// error diagnostic, rather than crashing the calling program. Recovery is
+// enabled by default; disabling it can be useful when debugging, to see the
+// full stack trace of the panic.
+func WithPanicRecovery(enabled bool) Option {
+	return func(o *common.Options) {
+		o.DisablePanicRecovery = !enabled
+	}
+}
+
+// WithStrictCreatePlans makes it an error for a provider to return a null
+// planned state when asked to plan the creation of a new object, which would
+// otherwise produce only a warning diagnostic alongside the plan.
+func WithStrictCreatePlans() Option {
+	return func(o *common.Options) {
+		o.StrictCreatePlans = true
+	}
+}
+
+// WithRPCTimeout limits the time that each RPC call to the provider plugin
+// may take, after which the call fails with an error diagnostic. Requests
+// asking the provider to stop are not limited. Use WithOperationTimeouts to
+// set different limits for particular operations.
+func WithRPCTimeout(timeout time.Duration) Option {
+	return func(o *common.Options) {
+		o.RPCTimeout = timeout
+	}
+}
+
+// WithOperationTimeouts limits the time that RPC calls to the provider
+// plugin may take for each of the operations in the given map, which is
+// keyed by the Operation constants such as OperationApply. Calls for other
+// operations are limited by the timeout from WithRPCTimeout, if any. A
+// timeout of zero means no limit, even if WithRPCTimeout is also used.
+//
+// Timeouts are applied to individual RPC calls, so retried calls, such as
+// those made by WithImportRetry, each get the full time.
+func WithOperationTimeouts(timeouts map[string]time.Duration) Option {
+	return func(o *common.Options) {
+		if o.OperationTimeouts == nil {
+			o.OperationTimeouts = make(map[string]time.Duration, len(timeouts))
+		}
+		for op, timeout := range timeouts {
+			o.OperationTimeouts[op] = timeout
+		}
+	}
+}
+
+// WithMetrics reports the count, duration, and errors of the RPC calls made
+// to the provider plugin to the given collector, labelled with the RPC
+// method name and the resource type name, if any.
+func WithMetrics(collector MetricsCollector) Option {
+	return func(o *common.Options) {
+		o.Metrics = collector
+	}
+}
+
+// WithStrictImports makes Import return error diagnostics for any imported
+// state that lacks a value for one of its computed attributes, which would
+// otherwise cause problems only later, when the object is read.
+func WithStrictImports() Option {
+	return func(o *common.Options) {
+		o.StrictImports = true
+	}
+}
+
+// WithUnknownAsNull replaces any unknown values within the values decoded
+// from the provider's responses with null values of the same type, adding
+// a warning diagnostic that lists their paths. This suits callers that
+// expect wholly-known values and don't otherwise handle unknowns.
+//
+// This also applies to planned states, whose unknown values mark the
+// attributes that will be decided during apply, so callers that apply
+// plans should not use this option.
+func WithUnknownAsNull() Option {
+	return func(o *common.Options) {
+		o.UnknownAsNull = true
+	}
+}
+
+// WithDiagnosticLog accumulates all of the diagnostics returned by the
+// provider plugin over its lifetime, for retrieval with
+// Provider.AllDiagnostics. The log grows without limit, so this is best
+// suited to providers with a bounded lifetime, such as one per run.
+func WithDiagnosticLog() Option {
+	return func(o *common.Options) {
+		o.DiagnosticLog = common.NewDiagnosticLog()
+	}
+}
+
+// WithTypeConcurrencyLimit limits the number of RPC calls that may be in
+// progress at once for each of the resource types in the given map, keyed
+// by resource type name, so that concurrent use of the provider, including
+// the reads made by ReadDataSources, doesn't overwhelm backends that are
+// sensitive to load. Further calls for a type wait until earlier ones have
+// completed, while calls for other types proceed as normal.
+//
+// Types that are not in the map, or whose limit is zero or less, are not
+// limited.
+func WithTypeConcurrencyLimit(limits map[string]int) Option {
+	return func(o *common.Options) {
+		if o.TypeConcurrencyLimits == nil {
+			o.TypeConcurrencyLimits = make(map[string]int, len(limits))
+		}
+		for typeName, limit := range limits {
+			o.TypeConcurrencyLimits[typeName] = limit
+		}
+	}
+}
+
+// WithProviderAddress sets the provider's source address, such as
+// "registry.terraform.io/hashicorp/aws", which identifies the provider in
+// the output of Provider.ProvidersSchemaJSON.
+func WithProviderAddress(address string) Option {
+	return func(o *common.Options) {
+		o.ProviderAddress = address
+	}
+}
+
+// WithClientFactory causes the provider to use the given function to create
+// the client for its connection to the provider plugin, instead of the
+// client generated from the plugin protocol definitions, such as to
+// substitute a fake or wrapped client in tests.
+//
+// The function is called with the negotiated protocol version and must
+// return an implementation of the generated ProviderClient interface for
+// that version, or starting the provider fails.
+func WithClientFactory(factory func(protoVersion int, conn *grpc.ClientConn) interface{}) Option {
+	return func(o *common.Options) {
+		o.ClientFactory = factory
+	}
+}
+
+// WithConfigureProbe makes Configure call the given function after the
+// provider reports that it was configured successfully, to check that it
+// is really ready for use. If the function returns error diagnostics then
+// Configure returns them, and the provider remains unconfigured as if
+// Configure itself had failed. This catches providers that report success
+// before they are ready.
+//
+// The provider is treated as configured while the probe runs, so the probe
+// can use methods that require configuration, such as reading a data
+// resource that depends on the provider's credentials. Which request is a
+// cheap but meaningful check varies between providers, so the caller
+// chooses it.
+func WithConfigureProbe(probe func(ctx context.Context, provider Provider) Diagnostics) Option {
+	return func(o *common.Options) {
+		o.ConfigureProbe = func(ctx context.Context, provider interface{}) common.Diagnostics {
+			return probe(ctx, provider.(Provider))
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package tfprovider
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/zclconf/go-cty/cty"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/apparentlymart/terraform-provider/internal/tfplugin6"
+)
+
+// metadataServer6 is a fakeServer6 that records the request metadata of
+// each call to ConfigureProvider.
+type metadataServer6 struct {
+	fakeServer6
+
+	mu       sync.Mutex
+	received []metadata.MD
+}
+
+func (s *metadataServer6) ConfigureProvider(ctx context.Context, req *tfplugin6.ConfigureProvider_Request) (*tfplugin6.ConfigureProvider_Response, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	s.mu.Lock()
+	s.received = append(s.received, md)
+	s.mu.Unlock()
+	return s.fakeServer6.ConfigureProvider(ctx, req)
+}
+
+type traceIDKey struct{}
+
+func TestWithContextPropagator(t *testing.T) {
+	srv := &metadataServer6{}
+	provider := newTestProvider6(t, srv,
+		WithContextPropagator(func(ctx context.Context) map[string]string {
+			traceID, ok := ctx.Value(traceIDKey{}).(string)
+			if !ok {
+				return nil
+			}
+			return map[string]string{"Traceparent": traceID}
+		}),
+		WithContextPropagator(func(ctx context.Context) map[string]string {
+			return map[string]string{"x-caller": "test"}
+		}),
+	)
+
+	const traceID = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	ctx := context.WithValue(context.Background(), traceIDKey{}, traceID)
+	if diags := provider.Configure(ctx, Config{Value: cty.EmptyObjectVal}); diags.HasErrors() {
+		t.Fatalf("unexpected errors: %s", diags.Err())
+	}
+
+	if len(srv.received) != 1 {
+		t.Fatalf("server received %d ConfigureProvider calls; want 1", len(srv.received))
+	}
+	md := srv.received[0]
+	if got := md.Get("traceparent"); len(got) != 1 || got[0] != traceID {
+		t.Errorf("wrong traceparent %q; want %q", got, traceID)
+	}
+	if got := md.Get("x-caller"); len(got) != 1 || got[0] != "test" {
+		t.Errorf("wrong x-caller %q; want \"test\"", got)
+	}
+}
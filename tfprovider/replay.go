@@ -0,0 +1,37 @@
+package tfprovider
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/apparentlymart/terraform-provider/tfprovider/internal/common"
+	"github.com/apparentlymart/terraform-provider/tfprovider/internal/protocol5"
+	"github.com/apparentlymart/terraform-provider/tfprovider/internal/protocol6"
+)
+
+// Replay returns a provider that answers requests using the responses from
+// a recording previously created using the WithRecorder option, without
+// starting any plugin process.
+//
+// Requests are matched against the recording by RPC method and by the exact
+// content of the request, so the caller must make the same requests as were
+// made while recording in order to get the same results. Any request that
+// was not recorded fails with an RPC error.
+func Replay(path string, opts ...Option) (Provider, error) {
+	replayer, err := common.LoadReplayer(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load provider recording: %s", err)
+	}
+
+	o := newOptions(opts)
+	ctx := context.Background()
+
+	switch replayer.ProtocolVersion {
+	case 5:
+		return protocol5.NewProvider(ctx, nil, protocol5.NewInterceptedClient(nil, replayer.Intercept), o)
+	case 6:
+		return protocol6.NewProvider(ctx, nil, protocol6.NewInterceptedClient(nil, replayer.Intercept), o)
+	default:
+		return nil, fmt.Errorf("provider recording uses unsupported protocol version %d", replayer.ProtocolVersion)
+	}
+}
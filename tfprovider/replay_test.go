@@ -0,0 +1,128 @@
+package tfprovider
+
+import (
+	"context"
+	"log/slog"
+	"path/filepath"
+	"testing"
+
+	"github.com/zclconf/go-cty/cty"
+
+	"github.com/apparentlymart/terraform-provider/internal/tfplugin6"
+	"github.com/apparentlymart/terraform-provider/tfprovider/internal/common"
+	"github.com/apparentlymart/terraform-provider/tfprovider/internal/protocol6"
+)
+
+func TestRecordAndReplay(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "recording.jsonl")
+
+	// We record using the same interceptor StartWithOptions installs for
+	// WithRecorder, but with a provider served over an in-memory connection.
+	rec, err := common.NewRecorder(path, 6)
+	if err != nil {
+		t.Fatal(err)
+	}
+	o := newOptions(nil)
+	o.Interceptors = append(o.Interceptors, rec.Intercept)
+	o.OnClose(rec)
+	conn := dialFakeServer6(t, &fakeServer6{})
+	live, err := protocol6.NewProvider(ctx, nil, tfplugin6.NewProviderClient(conn), o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, diags := readFakeData(t, live, "world")
+	if diags.HasErrors() {
+		t.Fatalf("unexpected errors from live provider: %s", diags.Err())
+	}
+	if err := live.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	replayed, err := Replay(path)
+	if err != nil {
+		t.Fatalf("failed to load recording: %s", err)
+	}
+	defer replayed.Close()
+
+	if got, err := replayed.ManagedResourceSchemaVersion("fake_thing"); err != nil || got != 2 {
+		t.Errorf("wrong schema version %d (error %v) from replayed schema; want 2", got, err)
+	}
+	got, diags := readFakeData(t, replayed, "world")
+	if diags.HasErrors() {
+		t.Fatalf("unexpected errors from replayed provider: %s", diags.Err())
+	}
+	if !got.RawEquals(want) {
+		t.Errorf("wrong replayed result\ngot:  %#v\nwant: %#v", got, want)
+	}
+	if want := cty.StringVal("Hello, world!"); !got.GetAttr("greeting").RawEquals(want) {
+		t.Errorf("wrong greeting %#v; want %#v", got.GetAttr("greeting"), want)
+	}
+
+	// A request that differs from the recorded one has no response, even
+	// though the method is the same.
+	rt, err := replayed.DataResourceType("fake_data")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, diags = rt.Read(ctx, DataResourceReadRequest{
+		Config: cty.ObjectVal(map[string]cty.Value{
+			"name":     cty.StringVal("elsewhere"),
+			"greeting": cty.NullVal(cty.String),
+		}),
+	})
+	if !diags.HasErrors() {
+		t.Error("unrecorded request succeeded; want an error")
+	}
+}
+
+func TestReplayInvalidRecording(t *testing.T) {
+	_, err := Replay(filepath.Join(t.TempDir(), "nonexistent.jsonl"))
+	if err == nil {
+		t.Fatal("Replay succeeded for a nonexistent file; want an error")
+	}
+}
+
+func TestReplayOptions(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "recording.jsonl")
+
+	rec, err := common.NewRecorder(path, 6)
+	if err != nil {
+		t.Fatal(err)
+	}
+	o := newOptions(nil)
+	o.Interceptors = append(o.Interceptors, rec.Intercept)
+	o.OnClose(rec)
+	conn := dialFakeServer6(t, &fakeServer6{})
+	live, err := protocol6.NewProvider(ctx, nil, tfplugin6.NewProviderClient(conn), o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, diags := readFakeData(t, live, "world"); diags.HasErrors() {
+		t.Fatalf("unexpected errors from live provider: %s", diags.Err())
+	}
+	if err := live.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The options that apply to every RPC are honored for a replayed
+	// provider just as for a started one.
+	handler := &recordingHandler{}
+	replayed, err := Replay(path, WithSlogLogger(slog.New(handler)))
+	if err != nil {
+		t.Fatalf("failed to load recording: %s", err)
+	}
+	defer replayed.Close()
+	if _, diags := readFakeData(t, replayed, "world"); diags.HasErrors() {
+		t.Fatalf("unexpected errors from replayed provider: %s", diags.Err())
+	}
+
+	attrs := handler.find("provider RPC call", map[string]string{
+		"method":    "ReadDataSource",
+		"type_name": "fake_data",
+	})
+	if attrs == nil {
+		t.Errorf("no log record for the replayed ReadDataSource call in %d records", len(handler.records))
+	}
+}
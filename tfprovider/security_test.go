@@ -0,0 +1,98 @@
+package tfprovider
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"math/big"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+func TestConnectionSecurity(t *testing.T) {
+	cert, pool := testCertificate(t)
+	serverCreds := credentials.NewTLS(&tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+		MaxVersion:   tls.VersionTLS12,
+	})
+	clientCreds := credentials.NewTLS(&tls.Config{
+		RootCAs:    pool,
+		ServerName: "localhost",
+	})
+
+	tests := map[string]struct {
+		conn *grpc.ClientConn
+		want ConnectionSecurity
+	}{
+		"insecure": {
+			dialFakeServer6(t, &fakeServer6{}),
+			ConnectionSecurity{},
+		},
+		"tls": {
+			dialFakeServer6Creds(t, &fakeServer6{}, serverCreds, grpc.WithTransportCredentials(clientCreds)),
+			ConnectionSecurity{
+				TLS:     true,
+				Version: tls.VersionTLS12,
+			},
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			provider := newTestProvider6Conn(t, test.conn)
+
+			got, ok := provider.ConnectionSecurity()
+			if !ok {
+				t.Fatal("connection security not known")
+			}
+			if got.TLS != test.want.TLS || got.Version != test.want.Version {
+				t.Errorf("wrong result %#v; want %#v", got, test.want)
+			}
+			// The negotiated cipher suite depends on the hardware, so we
+			// only check that there is one exactly when TLS is in use.
+			if got.TLS != (got.CipherSuite != 0) {
+				t.Errorf("wrong cipher suite %#x for TLS %t", got.CipherSuite, got.TLS)
+			}
+		})
+	}
+}
+
+// testCertificate returns a self-signed certificate for "localhost", along
+// with a pool containing it for verifying connections that use it.
+func testCertificate(t *testing.T) (tls.Certificate, *x509.CertPool) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		DNSNames:              []string{"localhost"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(leaf)
+	return tls.Certificate{
+		Certificate: [][]byte{der},
+		PrivateKey:  key,
+		Leaf:        leaf,
+	}, pool
+}
@@ -10,10 +10,14 @@ package tfprovider
 import (
 	"context"
 	"fmt"
+	"io"
 	"os/exec"
 
+	"github.com/apparentlymart/terraform-schema-go/tfschema"
 	"github.com/zclconf/go-cty/cty"
 	"go.rpcplugin.org/rpcplugin"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
 
 	"github.com/apparentlymart/terraform-provider/tfprovider/internal/common"
 	"github.com/apparentlymart/terraform-provider/tfprovider/internal/protocol5"
@@ -23,17 +27,85 @@ import (
 // Provider represents a running provider plugin.
 type Provider interface {
 	// Schema retrieves the full schema for the provider.
+	//
+	// The schema is loaded when the provider starts, so the returned
+	// diagnostics can contain only warnings the provider returned along
+	// with its schema, such as deprecation notices.
 	Schema(ctx context.Context) (*Schema, Diagnostics)
 
+	// ProviderConfigSchema returns the schema for the provider's own
+	// configuration block, as would be passed to PrepareConfig.
+	//
+	// The returned block is shared with the provider's full schema and
+	// must not be modified.
+	ProviderConfigSchema() *tfschema.Block
+
+	// ProviderConfigType returns the type that a provider configuration
+	// object must conform to, as implied by ProviderConfigSchema.
+	ProviderConfigType() cty.Type
+
+	// ConnectionSecurity describes the transport security of the connection
+	// to the provider plugin, as negotiated when the provider started, such
+	// as for confirming that the connection uses TLS.
+	//
+	// The second return value is false if the information is not available,
+	// such as for a provider that is replaying a recording.
+	ConnectionSecurity() (ConnectionSecurity, bool)
+
+	// ConnectionState returns the current state of the gRPC connection to
+	// the provider plugin, or connectivity.Idle if there is no connection,
+	// such as for a provider that is replaying a recording.
+	ConnectionState() connectivity.State
+
+	// GRPCConn returns the gRPC connection to the provider plugin, as an
+	// escape hatch for callers that need to make protocol RPCs that this
+	// package doesn't otherwise support, using a client generated from the
+	// plugin protocol definitions for the protocol version the provider
+	// uses. Calls made this way bypass all of this package's encoding,
+	// validation, and options, including interceptors such as recording.
+	//
+	// The result is nil if there is no connection, such as for a provider
+	// that is replaying a recording, or after the provider has been closed.
+	GRPCConn() *grpc.ClientConn
+
+	// ConfigPrompts returns a description of each attribute of the provider
+	// configuration schema that a user may set, including those in nested
+	// blocks, in a consistent order suitable for prompting the user for
+	// each one interactively.
+	ConfigPrompts() []AttrPrompt
+
 	// PrepareConfig validates and normalizes an object representing a provider
 	// configuration, returning either the normalized object or error
-	// diagnostics describing any problems with it.
+	// diagnostics describing any problems with it. The Modified field of
+	// the result reports whether normalization changed the object.
+	//
+	// PrepareConfig doesn't resolve secrets given with WithSecretResolver:
+	// the provider sees the references themselves, and the result still
+	// contains them, so that secrets are resolved only by Configure.
 	PrepareConfig(ctx context.Context, config cty.Value) (Config, Diagnostics)
 
+	// TryConfigure checks whether the given configuration would be accepted
+	// by Configure, without configuring the provider. It asks the provider
+	// to validate the configuration and checks that the configuration is
+	// wholly known and can be encoded as Configure would send it, but
+	// doesn't ask the provider to configure itself, and so doesn't cause it
+	// to connect to any remote system. Unlike ValidateAll, it rejects
+	// configurations containing unknown values.
+	//
+	// Secrets given with WithSecretResolver are resolved as Configure would
+	// resolve them, so that problems resolving them are reported, but the
+	// provider is asked to validate the configuration with the references
+	// still in place, as for PrepareConfig, and the resolved secrets are
+	// never sent to it. TryConfigure can be called any number of times, and
+	// doesn't change whether the provider is configured.
+	TryConfigure(ctx context.Context, config cty.Value) Diagnostics
+
 	// Configure configures the provider using the given configuration.
 	//
 	// Each provider instance can be configured only once. If this method
-	// is called more than once, subsequent calls will return errors.
+	// is called more than once, subsequent calls will return errors. The
+	// only exception is a provider configured using ConfigurePlanOnly,
+	// which may then be fully configured using Configure.
 	//
 	// Unless Configure returns error diagnostics, after it returns the caller
 	// may use other methods which are documented as requiring configuration
@@ -42,30 +114,158 @@ type Provider interface {
 	// The given Config must have been prepared using PrepareConfig.
 	Configure(ctx context.Context, config Config) Diagnostics
 
+	// ConfigurePlanOnly is like Configure but tolerates a configuration
+	// containing unknown values, as can arise when planning changes where
+	// the provider configuration depends on other resources.
+	//
+	// If the configuration is wholly known then ConfigurePlanOnly behaves
+	// exactly like Configure. Otherwise, it only checks that the configuration
+	// conforms to the provider's schema, without calling the provider's own
+	// configuration logic. Resource type objects can then be obtained and
+	// their configurations validated, but any other resource operations
+	// return error diagnostics until the provider is fully configured by a
+	// later call to Configure with a wholly-known configuration.
+	ConfigurePlanOnly(ctx context.Context, config Config) Diagnostics
+
+	// ResourceExists reads the managed resource of the given type with the
+	// given prior state and private data, and reports whether the remote
+	// object still exists, which the provider indicates by returning a null
+	// new state.
+	//
+	// The provider must be configured before calling this method. If the
+	// returned diagnostics contain errors then the boolean result is
+	// meaningless.
+	ResourceExists(ctx context.Context, typeName string, state cty.Value, private []byte) (bool, Diagnostics)
+
+	// DetectDrift reads the managed resource of the given type with the given
+	// desired state and private data, and returns the differences between
+	// the desired state and the current state of the remote object, as
+	// reported by Diff. If the remote object no longer exists then the
+	// result is a single change from the desired state to null.
+	//
+	// The provider must be configured before calling this method.
+	DetectDrift(ctx context.Context, typeName string, desired cty.Value, private []byte) ([]AttrChange, Diagnostics)
+
+	// Refresh decodes the given stored state of a managed resource of the
+	// given type, in the JSON format Terraform uses in state snapshots, and
+	// then reads the current state of the remote object it represents.
+	//
+	// If storedVersion is older than the resource type's current schema
+	// version then the provider is first asked to upgrade the stored state.
+	// It is an error for storedVersion to be newer than the current version.
+	//
+	// The provider must be configured before calling this method.
+	Refresh(ctx context.Context, typeName string, rawStateJSON []byte, storedVersion int64, private []byte) (ManagedResourceReadResponse, Diagnostics)
+
+	// PlanAndDiff plans a change to a managed resource of the given type and
+	// then returns the planned changes, as described by PlanDiff, along with
+	// the provider's response. Changes whose new values are unknown until
+	// apply are reported as usual, and can be recognized using
+	// AttrChange.KnownAfterApply.
+	//
+	// The provider must be configured before calling this method. If the
+	// returned diagnostics contain errors then no changes are returned.
+	PlanAndDiff(ctx context.Context, typeName string, req ManagedResourcePlanRequest) (ManagedResourcePlanResponse, []AttrChange, Diagnostics)
+
+	// ReadDataSources reads each of the given data resources, which may be
+	// of different types, returning the result of each in the same order
+	// as the inputs.
+	//
+	// The reads are made concurrently, with a limited number of requests in
+	// progress at once. If the context is cancelled then reads not yet
+	// started are abandoned, and their results have error diagnostics.
+	// The provider must be configured before calling this method.
+	ReadDataSources(ctx context.Context, inputs []DataReadInput) []DataReadResult
+
+	// ValidateAll validates the given provider configuration and then each
+	// of the given managed and data resource configurations, keyed by
+	// resource type name, returning the diagnostics for each keyed by the
+	// configuration they relate to. Every given configuration has an entry
+	// in the result, which is nil if it is valid.
+	//
+	// Unlike the methods of resource type objects, ValidateAll can be called
+	// on an unconfigured provider. The resource configurations are validated
+	// concurrently, with a limited number of requests in progress at once.
+	ValidateAll(ctx context.Context, config cty.Value, resourceConfigs, dataConfigs map[string]cty.Value) map[ValidationTarget]Diagnostics
+
+	// ManagedResourceSchemaVersion returns the current schema version of the
+	// managed resource type with the given name, or an
+	// UnknownResourceTypeError if the provider has no such resource type.
+	//
+	// Stored state produced under an older version must be upgraded before
+	// it can be used with the resource type. Unlike ManagedResourceType,
+	// this method can be called on an unconfigured provider.
+	ManagedResourceSchemaVersion(typeName string) (int64, error)
+
+	// ResourceSchemaVersion returns the given version of the schema for the
+	// managed resource type with the given name.
+	//
+	// The provider plugin protocol allows retrieving only the current
+	// version of each schema, so this returns a SchemaVersionUnavailableError
+	// for any other version. State produced under an older version must be
+	// upgraded by the provider before it can be decoded.
+	ResourceSchemaVersion(typeName string, version int64) (*tfschema.Block, error)
+
+	// ProvidersSchemaJSON returns the provider's schema in the JSON format
+	// produced by "terraform providers schema -json", identifying the
+	// provider by the source address given with WithProviderAddress. It
+	// returns an error if the address is not known.
+	ProvidersSchemaJSON() ([]byte, error)
+
+	// AllTypeNames returns the names of all of the provider's managed and
+	// data resource types, each sorted in lexical order.
+	AllTypeNames() TypeInventory
+
+	// SchemaVersions returns the current schema version of each of the
+	// provider's managed resource types, keyed by type name. The caller may
+	// modify the returned map.
+	SchemaVersions() map[string]int64
+
 	// ManagedResourceType returns an object representing the managed resource
-	// type with the given name, or an error if the provider has no such managed
-	// resource type.
+	// type with the given name, or an UnknownResourceTypeError if the provider
+	// has no such managed resource type.
 	//
 	// The provider must be configured using [Configure] before calling this
 	// method. An unconfigured provider always returns an error.
 	ManagedResourceType(name string) (ManagedResourceType, error)
 
 	// DataResourceType returns an object representing the data resource
-	// type with the given name, or an error if the provider has no such data
-	// resource type.
+	// type with the given name, or an UnknownResourceTypeError if the provider
+	// has no such data resource type.
 	//
 	// The provider must be configured using [Configure] before calling this
 	// method. An unconfigured provider always returns an error.
 	DataResourceType(name string) (DataResourceType, error)
 
+	// AllDiagnostics returns all of the diagnostics that the provider plugin
+	// has returned so far, in the order they were returned, if the provider
+	// was started with WithDiagnosticLog. Otherwise it returns nil.
+	//
+	// Unlike other methods, AllDiagnostics may be called after Close, for a
+	// final review of everything the provider reported.
+	AllDiagnostics() Diagnostics
+
 	// Close kills the child process for this provider plugin, rendering the
 	// reciever unusable. Any further calls on the object after Close returns
 	// cause undefined behavior.
 	//
+	// If the child process turns out to have exited with a non-zero exit
+	// code, such as because it crashed, the returned error is a *CloseError
+	// describing the exit. The error is nil after a normal shutdown.
+	//
 	// Calling Close also invalidates any associated objects such as
 	// resource type objects.
 	Close() error
 
+	// Kill forcefully terminates the child process for this provider plugin
+	// without waiting for it to exit cleanly, for use when Close does not
+	// return in a timely manner. It has no effect for a provider that has
+	// no child process, such as one returned from Offline or Replay.
+	//
+	// Kill does not release the other resources associated with the
+	// provider, so Close must still be called if it has not been already.
+	Kill() error
+
 	// Sealed is a do-nothing method that exists only to represent that this
 	// interface may not be implemented by any type outside of this module,
 	// to allow the interface to expand in future to support new provider
@@ -91,20 +291,36 @@ type Provider interface {
 // "terraform-provider-", because that is the prefix Terraform itself looks
 // for in order to discover them automatically.
 func Start(ctx context.Context, exe string, args ...string) (Provider, error) {
+	return StartWithOptions(ctx, exe, args)
+}
+
+// StartWithOptions is like Start but additionally accepts options that
+// customize the behavior of the returned provider.
+func StartWithOptions(ctx context.Context, exe string, args []string, opts ...Option) (Provider, error) {
+	o := newOptions(opts)
+
+	cmd := exec.Command(exe, args...)
+	if o.Stderr != nil {
+		// We also retain the most recent output so that we can report
+		// the details if the plugin crashes.
+		crash := common.NewCrashCapture()
+		cmd.Stderr = io.MultiWriter(o.Stderr, crash)
+		o.StderrCapture = crash
+		o.Interceptors = append([]common.RPCInterceptor{crash.Intercept}, o.Interceptors...)
+	}
+
 	plugin, err := rpcplugin.New(ctx, &rpcplugin.ClientConfig{
 		Handshake: rpcplugin.HandshakeConfig{
 			CookieKey:   "TF_PLUGIN_MAGIC_COOKIE",
 			CookieValue: "d602bf8f470bc67ca7faa0386276bbdd4330efaf76d1a219cb4d6991ca9872b2",
 		},
-		Cmd: exec.Command(exe, args...),
-		ProtoVersions: map[int]rpcplugin.ClientVersion{
-			5: protocol5.PluginClient{},
-			6: protocol6.PluginClient{},
-		},
+		Cmd:           cmd,
+		ProtoVersions: pluginClients(o),
 	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to launch provider plugin: %s", err)
 	}
+	o.Cmd = cmd
 
 	protoVersion, clientProxy, err := plugin.Client(ctx)
 	if err != nil {
@@ -112,14 +328,46 @@ func Start(ctx context.Context, exe string, args ...string) (Provider, error) {
 		return nil, fmt.Errorf("failed to create plugin client: %s", err)
 	}
 
+	if o.RecordPath != "" {
+		rec, err := common.NewRecorder(o.RecordPath, protoVersion)
+		if err != nil {
+			plugin.Close()
+			return nil, fmt.Errorf("failed to create provider recording: %s", err)
+		}
+		// The recorder is the innermost interceptor so that it sees
+		// exactly what was exchanged with the plugin.
+		o.Interceptors = append(o.Interceptors, rec.Intercept)
+		o.OnClose(rec)
+	}
+
 	switch protoVersion {
 	case 5:
-		return protocol5.NewProvider(ctx, plugin, clientProxy)
+		return protocol5.NewProvider(ctx, plugin, clientProxy, o)
 	case 6:
-		return protocol6.NewProvider(ctx, plugin, clientProxy)
+		return protocol6.NewProvider(ctx, plugin, clientProxy, o)
 	default:
 		// Should not be possible to get here because the above cases cover
 		// all of the versions we listed in ProtoVersions; rpcplugin bug?
 		panic(fmt.Sprintf("unsupported protocol version %d", protoVersion))
 	}
 }
+
+// pluginClients returns the client implementations for each of the protocol
+// versions we support, using the client factory from the given options, if
+// any.
+func pluginClients(o *common.Options) map[int]rpcplugin.ClientVersion {
+	var v5 protocol5.PluginClient
+	var v6 protocol6.PluginClient
+	if factory := o.ClientFactory; factory != nil {
+		v5.NewClient = func(conn *grpc.ClientConn) interface{} {
+			return factory(5, conn)
+		}
+		v6.NewClient = func(conn *grpc.ClientConn) interface{} {
+			return factory(6, conn)
+		}
+	}
+	return map[int]rpcplugin.ClientVersion{
+		5: v5,
+		6: v6,
+	}
+}